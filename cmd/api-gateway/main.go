@@ -0,0 +1,56 @@
+package main
+
+import (
+	"context"
+	"log"
+
+	"github.com/be-bcv/ecommerce-backend/internal/gateway"
+	"github.com/be-bcv/ecommerce-backend/pkg/config"
+	"github.com/be-bcv/ecommerce-backend/pkg/middleware"
+	"github.com/be-bcv/ecommerce-backend/pkg/redis"
+	"github.com/gin-gonic/gin"
+)
+
+func main() {
+	// Load configuration
+	cfg := config.LoadConfig()
+
+	// Connect to Redis for token revocations
+	redisClient, err := redis.NewRedisClient(cfg.RedisHost, cfg.RedisPort, cfg.RedisPassword)
+	if err != nil {
+		log.Fatalf("Failed to connect to Redis: %v", err)
+	}
+	defer redisClient.Close()
+
+	// Setup gateway
+	gw, err := gateway.NewGateway(cfg, middleware.NewTokenRevocations(redisClient))
+	if err != nil {
+		log.Fatalf("Failed to configure gateway: %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	gw.RunHealthChecks(ctx)
+
+	// Setup router
+	router := gin.Default()
+	if err := middleware.SetTrustedProxies(router, cfg.TrustedProxies); err != nil {
+		log.Fatalf("Invalid trusted proxies: %v", err)
+	}
+	router.Use(middleware.CORSMiddleware())
+	router.Use(middleware.LoggerMiddleware())
+
+	router.GET("/health", gw.Health)
+
+	// Aggregated routes
+	router.GET("/api/v1/account/overview", gw.AccountOverview)
+
+	// Everything else under /api/v1 is proxied to the owning service
+	router.NoRoute(gw.Proxy)
+
+	// Start server
+	log.Printf("API gateway starting on port %s", cfg.Port)
+	if err := router.Run(":" + cfg.Port); err != nil {
+		log.Fatalf("Failed to start server: %v", err)
+	}
+}
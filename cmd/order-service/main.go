@@ -1,7 +1,14 @@
 package main
 
 import (
+	"context"
 	"log"
+	"net/http"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+	"time"
 
 	"github.com/be-bcv/ecommerce-backend/internal/handler"
 	"github.com/be-bcv/ecommerce-backend/internal/repository"
@@ -9,12 +16,15 @@ import (
 	"github.com/be-bcv/ecommerce-backend/internal/models"
 	"github.com/be-bcv/ecommerce-backend/pkg/config"
 	"github.com/be-bcv/ecommerce-backend/pkg/database"
+	"github.com/be-bcv/ecommerce-backend/pkg/messages"
 	"github.com/be-bcv/ecommerce-backend/pkg/middleware"
 	"github.com/be-bcv/ecommerce-backend/pkg/rabbitmq"
 	"github.com/be-bcv/ecommerce-backend/pkg/redis"
 	"github.com/gin-gonic/gin"
 )
 
+const shutdownTimeout = 10 * time.Second
+
 func main() {
 	// Load configuration
 	cfg := config.LoadConfig()
@@ -27,7 +37,7 @@ func main() {
 	defer db.Close()
 
 	// Auto migrate
-	if err := db.Migrate(&models.Cart{}, &models.Order{}, &models.OrderItem{}, &models.OrderStatusHistory{}, &models.Payment{}); err != nil {
+	if err := db.Migrate(&models.Cart{}, &models.Order{}, &models.OrderItem{}, &models.OrderStatusHistory{}, &models.Fulfillment{}, &models.ReturnRequest{}, &models.ReturnItem{}, &models.InventoryHold{}, &models.CheckoutSaga{}, &models.OutboxEvent{}); err != nil {
 		log.Fatalf("Failed to migrate database: %v", err)
 	}
 
@@ -45,32 +55,98 @@ func main() {
 	}
 	defer rabbitmqConn.Close()
 
+	// Declare the exchanges this service publishes to and consumes from
+	if err := rabbitmqConn.DeclareTopicExchanges(messages.ExchangeOrder, messages.ExchangeCheckout, messages.ExchangePayment, messages.ExchangeProduct); err != nil {
+		log.Fatalf("Failed to declare exchanges: %v", err)
+	}
+
+	publisher, err := rabbitmq.NewPublisher(rabbitmqConn)
+	if err != nil {
+		log.Fatalf("Failed to create RabbitMQ publisher: %v", err)
+	}
+	defer publisher.Close()
+
 	// Setup repositories
-	cartRepo := repository.NewCartRepository(db)
-	orderRepo := repository.NewOrderRepository(db)
-	paymentRepo := repository.NewPaymentRepository(db)
+	cartRepo := repository.NewCartRepository(db.DB)
+	orderRepo := repository.NewOrderRepository(db.DB)
+	returnRepo := repository.NewReturnRepository(db.DB)
+	sagaRepo := repository.NewCheckoutSagaRepository(db.DB)
+	outboxRepo := repository.NewOutboxRepository(db.DB)
 
 	// Setup services
-	cartService := service.NewCartService(cartRepo, redisClient)
-	orderService := service.NewOrderService(orderRepo, cartRepo, redisClient, rabbitmqConn, cfg)
-	paymentService := service.NewPaymentService(paymentRepo, orderRepo, redisClient, rabbitmqConn, cfg)
+	cartService := service.NewCartService(cartRepo, redisClient, cfg)
+	orderService := service.NewOrderService(orderRepo, returnRepo, cartRepo, sagaRepo, outboxRepo, redisClient, cfg)
+
+	// Background workers run until the service is asked to stop
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	// Relay outbox events to RabbitMQ
+	go service.NewOutboxRelay(outboxRepo, publisher).Run(ctx)
+
+	// Compensate checkouts that stopped making progress
+	go orderService.RunCheckoutTimeouts(ctx)
+
+	// Cancel orders whose payment expired so their stock goes back on sale
+	go orderService.RunInventoryHoldExpiry(ctx)
+
+	// Payment events keep each order's payment status in sync
+	paymentConsumer := rabbitmq.NewConsumer(rabbitmqConn, rabbitmq.ConsumerConfig{
+		Queue:    "order_service.payment_events",
+		Exchange: messages.ExchangePayment,
+	}, orderService.PaymentEventHandlers())
+
+	// Stock replies drive the checkout saga
+	stockConsumer := rabbitmq.NewConsumer(rabbitmqConn, rabbitmq.ConsumerConfig{
+		Queue:    "order_service.stock_events",
+		Exchange: messages.ExchangeProduct,
+	}, orderService.StockEventHandlers())
+
+	var consumers sync.WaitGroup
+	for name, consumer := range map[string]*rabbitmq.Consumer{"payment": paymentConsumer, "stock": stockConsumer} {
+		consumers.Add(1)
+		go func(name string, consumer *rabbitmq.Consumer) {
+			defer consumers.Done()
+			if err := consumer.Run(ctx); err != nil {
+				log.Fatalf("Failed to consume %s events: %v", name, err)
+			}
+		}(name, consumer)
+	}
 
 	// Setup handlers
 	cartHandler := handler.NewCartHandler(cartService)
 	orderHandler := handler.NewOrderHandler(orderService)
-	paymentHandler := handler.NewPaymentHandler(paymentService)
+
+	// Access tokens of revoked sessions are rejected before they expire
+	jwtAuth := middleware.JWTAuthMiddleware(cfg.JWTSecret, middleware.NewTokenRevocations(redisClient))
+
+	// Rate limits are counted in Redis across instances
+	limiter := middleware.NewRateLimiter(redisClient)
+	defaultLimit, err := middleware.ParseRateLimit("default", cfg.RateLimitDefault)
+	if err != nil {
+		log.Fatalf("Invalid rate limit: %v", err)
+	}
+	rateLimit := middleware.RateLimiterMiddleware(limiter, defaultLimit)
 
 	// Setup router
 	router := gin.Default()
+	if err := middleware.SetTrustedProxies(router, cfg.TrustedProxies); err != nil {
+		log.Fatalf("Invalid trusted proxies: %v", err)
+	}
 	router.Use(middleware.CORSMiddleware())
 	router.Use(middleware.LoggerMiddleware())
 
+	// Health check used by the API gateway
+	router.GET("/health", func(c *gin.Context) {
+		c.JSON(http.StatusOK, gin.H{"status": "ok"})
+	})
+
 	// Routes
 	api := router.Group("/api/v1")
 	{
 		// Protected routes (require authentication)
 		protected := api.Group("/")
-		protected.Use(middleware.JWTAuthMiddleware(cfg.JWTSecret))
+		protected.Use(jwtAuth, rateLimit)
 		{
 			// Cart routes
 			cart := protected.Group("/cart")
@@ -90,24 +166,61 @@ func main() {
 				orders.POST("", orderHandler.CreateOrder)
 				orders.PUT("/:id/cancel", orderHandler.CancelOrder)
 				orders.GET("/:id/status", orderHandler.GetOrderStatus)
+				orders.POST("/:id/returns", orderHandler.CreateReturn)
 			}
 
-			// Payment routes
-			payments := protected.Group("/payments")
+			// Return routes: the buyer's returns
+			returns := protected.Group("/returns")
 			{
-				payments.POST("", paymentHandler.CreatePayment)
-				payments.GET("/:id", paymentHandler.GetPaymentByID)
-				payments.POST("/:id/callback", paymentHandler.PaymentCallback)
+				returns.GET("", orderHandler.GetMyReturns)
+				returns.GET("/:id", orderHandler.GetMyReturn)
 			}
 
 			// Checkout
 			protected.POST("/checkout", orderHandler.Checkout)
+
+			// Seller routes: each seller sees and ships only their part of an order
+			seller := protected.Group("/seller")
+			seller.Use(middleware.RequireRoles(middleware.RoleSeller))
+			{
+				seller.GET("/orders", orderHandler.GetSellerOrders)
+				seller.GET("/orders/:id", orderHandler.GetSellerOrder)
+				seller.PUT("/orders/:id/status", orderHandler.UpdateSellerOrderStatus)
+				seller.GET("/returns", orderHandler.GetSellerReturns)
+				seller.POST("/returns/:id/approve", orderHandler.ApproveReturn)
+				seller.POST("/returns/:id/reject", orderHandler.RejectReturn)
+			}
+
+			// Admin routes
+			admin := protected.Group("/admin")
+			admin.Use(middleware.RequireRoles(middleware.RoleAdmin))
+			{
+				admin.GET("/orders", orderHandler.GetAllOrders)
+				admin.PUT("/orders/:id/status", orderHandler.UpdateOrderStatus)
+				admin.GET("/returns", orderHandler.GetAllReturns)
+				admin.POST("/returns/:id/approve", orderHandler.ApproveReturn)
+				admin.POST("/returns/:id/reject", orderHandler.RejectReturn)
+				admin.POST("/returns/:id/retry-refund", orderHandler.RetryRefund)
+			}
 		}
 	}
 
 	// Start server
-	log.Printf("Order service starting on port %s", cfg.Port)
-	if err := router.Run(":" + cfg.Port); err != nil {
-		log.Fatalf("Failed to start server: %v", err)
+	srv := &http.Server{Addr: ":" + cfg.Port, Handler: router}
+	go func() {
+		log.Printf("Order service starting on port %s", cfg.Port)
+		if err := srv.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+			log.Fatalf("Failed to start server: %v", err)
+		}
+	}()
+
+	// Finish in-flight requests and messages before exiting
+	<-ctx.Done()
+	log.Printf("Order service shutting down")
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
+	if err := srv.Shutdown(shutdownCtx); err != nil {
+		log.Printf("Failed to shut down server: %v", err)
 	}
+	consumers.Wait()
 }
\ No newline at end of file
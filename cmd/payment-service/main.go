@@ -0,0 +1,214 @@
+package main
+
+import (
+	"context"
+	"log"
+	"net/http"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/be-bcv/ecommerce-backend/internal/handler"
+	"github.com/be-bcv/ecommerce-backend/internal/models"
+	"github.com/be-bcv/ecommerce-backend/internal/repository"
+	"github.com/be-bcv/ecommerce-backend/internal/service"
+	"github.com/be-bcv/ecommerce-backend/pkg/config"
+	"github.com/be-bcv/ecommerce-backend/pkg/database"
+	"github.com/be-bcv/ecommerce-backend/pkg/messages"
+	"github.com/be-bcv/ecommerce-backend/pkg/middleware"
+	"github.com/be-bcv/ecommerce-backend/pkg/rabbitmq"
+	"github.com/be-bcv/ecommerce-backend/pkg/redis"
+	"github.com/gin-gonic/gin"
+)
+
+const shutdownTimeout = 10 * time.Second
+
+func main() {
+	// Load configuration
+	cfg := config.LoadConfig()
+
+	// Initialize database
+	db, err := database.NewDatabase(cfg.DBHost, cfg.DBPort, cfg.DBUser, cfg.DBPassword, cfg.DBName+"_payment")
+	if err != nil {
+		log.Fatalf("Failed to connect to database: %v", err)
+	}
+	defer db.Close()
+
+	// Auto migrate
+	if err := repository.NewPaymentRepository(db.DB).MigrateRefundKeys(); err != nil {
+		log.Fatalf("Failed to migrate refund keys: %v", err)
+	}
+	if err := db.Migrate(&models.Payment{}, &models.Refund{}, &models.LedgerTransaction{}, &models.LedgerEntry{}, &models.SellerEarning{}, &models.PayoutBatch{}, &models.Payout{}, &models.SellerBankAccount{}, &models.OutboxEvent{}); err != nil {
+		log.Fatalf("Failed to migrate database: %v", err)
+	}
+
+	// Initialize Redis
+	redisClient, err := redis.NewRedisClient(cfg.RedisHost, cfg.RedisPort, cfg.RedisPassword)
+	if err != nil {
+		log.Fatalf("Failed to connect to Redis: %v", err)
+	}
+	defer redisClient.Close()
+
+	// Initialize RabbitMQ
+	rabbitmqConn, err := rabbitmq.NewRabbitMQ(cfg.RabbitMQURL)
+	if err != nil {
+		log.Fatalf("Failed to connect to RabbitMQ: %v", err)
+	}
+	defer rabbitmqConn.Close()
+
+	// Declare the exchanges this service publishes to and consumes from
+	if err := rabbitmqConn.DeclareTopicExchanges(messages.ExchangePayment, messages.ExchangeCheckout, messages.ExchangeOrder, messages.ExchangeUser); err != nil {
+		log.Fatalf("Failed to declare exchanges: %v", err)
+	}
+
+	publisher, err := rabbitmq.NewPublisher(rabbitmqConn)
+	if err != nil {
+		log.Fatalf("Failed to create RabbitMQ publisher: %v", err)
+	}
+	defer publisher.Close()
+
+	// Setup repositories
+	paymentRepo := repository.NewPaymentRepository(db.DB)
+	ledgerRepo := repository.NewLedgerRepository(db.DB)
+	outboxRepo := repository.NewOutboxRepository(db.DB)
+
+	// Setup services
+	paymentService := service.NewPaymentService(paymentRepo, ledgerRepo, outboxRepo, redisClient, cfg)
+	settlementService, err := service.NewSettlementService(ledgerRepo, cfg)
+	if err != nil {
+		log.Fatalf("Failed to configure seller settlement: %v", err)
+	}
+
+	// Background workers run until the service is asked to stop
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	// Relay outbox events to RabbitMQ
+	go service.NewOutboxRelay(outboxRepo, publisher).Run(ctx)
+
+	// Checkout commands open and void payments
+	commandConsumer := rabbitmq.NewConsumer(rabbitmqConn, rabbitmq.ConsumerConfig{
+		Queue:    "payment_service.checkout_commands",
+		Exchange: messages.ExchangeCheckout,
+	}, paymentService.CommandHandlers())
+
+	// Delivered fulfillments credit the sellers' earnings
+	orderConsumer := rabbitmq.NewConsumer(rabbitmqConn, rabbitmq.ConsumerConfig{
+		Queue:    "payment_service.order_events",
+		Exchange: messages.ExchangeOrder,
+	}, settlementService.OrderEventHandlers())
+
+	// Approved returns are refunded
+	returnConsumer := rabbitmq.NewConsumer(rabbitmqConn, rabbitmq.ConsumerConfig{
+		Queue:    "payment_service.return_events",
+		Exchange: messages.ExchangeOrder,
+	}, paymentService.ReturnEventHandlers())
+
+	// Approved store bank accounts are where payouts go
+	storeConsumer := rabbitmq.NewConsumer(rabbitmqConn, rabbitmq.ConsumerConfig{
+		Queue:    "payment_service.store_events",
+		Exchange: messages.ExchangeUser,
+	}, settlementService.StoreEventHandlers())
+
+	var consumers sync.WaitGroup
+	for name, consumer := range map[string]*rabbitmq.Consumer{"checkout commands": commandConsumer, "order events": orderConsumer, "return events": returnConsumer, "store events": storeConsumer} {
+		consumers.Add(1)
+		go func(name string, consumer *rabbitmq.Consumer) {
+			defer consumers.Done()
+			if err := consumer.Run(ctx); err != nil {
+				log.Fatalf("Failed to consume %s: %v", name, err)
+			}
+		}(name, consumer)
+	}
+
+	// Make seller earnings available once their return window ends
+	go settlementService.RunEarningsRelease(ctx)
+
+	// Setup handlers
+	paymentHandler := handler.NewPaymentHandler(paymentService)
+	settlementHandler := handler.NewSettlementHandler(settlementService)
+
+	// Access tokens of revoked sessions are rejected before they expire
+	jwtAuth := middleware.JWTAuthMiddleware(cfg.JWTSecret, middleware.NewTokenRevocations(redisClient))
+
+	// Rate limits are counted in Redis across instances
+	limiter := middleware.NewRateLimiter(redisClient)
+	defaultLimit, err := middleware.ParseRateLimit("default", cfg.RateLimitDefault)
+	if err != nil {
+		log.Fatalf("Invalid rate limit: %v", err)
+	}
+	rateLimit := middleware.RateLimiterMiddleware(limiter, defaultLimit)
+
+	// Setup router
+	router := gin.Default()
+	if err := middleware.SetTrustedProxies(router, cfg.TrustedProxies); err != nil {
+		log.Fatalf("Invalid trusted proxies: %v", err)
+	}
+	router.Use(middleware.CORSMiddleware())
+	router.Use(middleware.LoggerMiddleware())
+
+	// Health check used by the API gateway
+	router.GET("/health", func(c *gin.Context) {
+		c.JSON(http.StatusOK, gin.H{"status": "ok"})
+	})
+
+	// Routes
+	api := router.Group("/api/v1")
+	{
+		// Midtrans payment notifications (verified by signature, not JWT)
+		api.POST("/payments/notification", paymentHandler.HandleNotification)
+
+		// Protected routes (require authentication)
+		protected := api.Group("/")
+		protected.Use(jwtAuth, rateLimit)
+		{
+			// Payment routes
+			payments := protected.Group("/payments")
+			{
+				payments.POST("", paymentHandler.CreatePayment)
+				payments.GET("/:id", paymentHandler.GetPaymentByID)
+			}
+
+			// Seller settlement routes
+			seller := protected.Group("/seller")
+			seller.Use(middleware.RequireRoles(middleware.RoleSeller))
+			{
+				seller.GET("/balance", settlementHandler.GetMyBalance)
+				seller.GET("/ledger", settlementHandler.GetMyLedger)
+			}
+
+			// Admin payout routes
+			payouts := protected.Group("/admin/payouts")
+			payouts.Use(middleware.RequireRoles(middleware.RoleAdmin))
+			{
+				payouts.POST("/batches", settlementHandler.CreatePayoutBatch)
+				payouts.GET("/batches", settlementHandler.GetPayoutBatches)
+				payouts.GET("/batches/:id", settlementHandler.GetPayoutBatch)
+				payouts.POST("/batches/:id/process", settlementHandler.ProcessPayoutBatch)
+				payouts.GET("/batches/:id/export", settlementHandler.ExportPayoutBatch)
+				payouts.PUT("/:id/status", settlementHandler.UpdatePayoutStatus)
+			}
+		}
+	}
+
+	// Start server
+	srv := &http.Server{Addr: ":" + cfg.Port, Handler: router}
+	go func() {
+		log.Printf("Payment service starting on port %s", cfg.Port)
+		if err := srv.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+			log.Fatalf("Failed to start server: %v", err)
+		}
+	}()
+
+	// Finish in-flight requests and messages before exiting
+	<-ctx.Done()
+	log.Printf("Payment service shutting down")
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
+	if err := srv.Shutdown(shutdownCtx); err != nil {
+		log.Printf("Failed to shut down server: %v", err)
+	}
+	consumers.Wait()
+}
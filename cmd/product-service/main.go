@@ -1,7 +1,14 @@
 package main
 
 import (
+	"context"
 	"log"
+	"net/http"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+	"time"
 
 	"github.com/be-bcv/ecommerce-backend/internal/handler"
 	"github.com/be-bcv/ecommerce-backend/internal/repository"
@@ -9,12 +16,15 @@ import (
 	"github.com/be-bcv/ecommerce-backend/internal/models"
 	"github.com/be-bcv/ecommerce-backend/pkg/config"
 	"github.com/be-bcv/ecommerce-backend/pkg/database"
+	"github.com/be-bcv/ecommerce-backend/pkg/messages"
 	"github.com/be-bcv/ecommerce-backend/pkg/middleware"
 	"github.com/be-bcv/ecommerce-backend/pkg/rabbitmq"
 	"github.com/be-bcv/ecommerce-backend/pkg/redis"
 	"github.com/gin-gonic/gin"
 )
 
+const shutdownTimeout = 10 * time.Second
+
 func main() {
 	// Load configuration
 	cfg := config.LoadConfig()
@@ -27,7 +37,7 @@ func main() {
 	defer db.Close()
 
 	// Auto migrate
-	if err := db.Migrate(&models.Category{}, &models.Product{}, &models.ProductReview{}); err != nil {
+	if err := db.Migrate(&models.Category{}, &models.Product{}, &models.ProductReview{}, &models.StockReservation{}, &models.StockRestock{}, &models.Storefront{}, &models.OutboxEvent{}); err != nil {
 		log.Fatalf("Failed to migrate database: %v", err)
 	}
 
@@ -45,37 +55,115 @@ func main() {
 	}
 	defer rabbitmqConn.Close()
 
+	// Declare the exchanges this service publishes to and consumes from
+	if err := rabbitmqConn.DeclareTopicExchanges(messages.ExchangeProduct, messages.ExchangeCheckout, messages.ExchangeUser, messages.ExchangeOrder); err != nil {
+		log.Fatalf("Failed to declare exchanges: %v", err)
+	}
+
+	publisher, err := rabbitmq.NewPublisher(rabbitmqConn)
+	if err != nil {
+		log.Fatalf("Failed to create RabbitMQ publisher: %v", err)
+	}
+	defer publisher.Close()
+
 	// Setup repositories
-	categoryRepo := repository.NewCategoryRepository(db)
-	productRepo := repository.NewProductRepository(db)
-	reviewRepo := repository.NewProductReviewRepository(db)
+	categoryRepo := repository.NewCategoryRepository(db.DB)
+	productRepo := repository.NewProductRepository(db.DB)
+	reviewRepo := repository.NewProductReviewRepository(db.DB)
+	storefrontRepo := repository.NewStorefrontRepository(db.DB)
+	outboxRepo := repository.NewOutboxRepository(db.DB)
 
 	// Setup services
-	categoryService := service.NewCategoryService(categoryRepo)
-	productService := service.NewProductService(productRepo, categoryRepo, redisClient, rabbitmqConn)
+	categoryService := service.NewCategoryService(categoryRepo, redisClient)
+	productService := service.NewProductService(productRepo, categoryRepo, redisClient)
 	reviewService := service.NewProductReviewService(reviewRepo, productRepo)
+	storefrontService := service.NewStorefrontService(storefrontRepo, productRepo, reviewRepo)
+
+	// Background workers run until the service is asked to stop
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	// Relay outbox events to RabbitMQ
+	go service.NewOutboxRelay(outboxRepo, publisher).Run(ctx)
+
+	// Checkout commands reserve and release stock
+	commandConsumer := rabbitmq.NewConsumer(rabbitmqConn, rabbitmq.ConsumerConfig{
+		Queue:    "product_service.checkout_commands",
+		Exchange: messages.ExchangeCheckout,
+	}, productService.CommandHandlers())
+
+	// Store events keep the public storefronts in sync with user-service
+	storeConsumer := rabbitmq.NewConsumer(rabbitmqConn, rabbitmq.ConsumerConfig{
+		Queue:    "product_service.store_events",
+		Exchange: messages.ExchangeUser,
+	}, storefrontService.StoreEventHandlers())
+
+	// Approved returns put their items back in stock
+	orderConsumer := rabbitmq.NewConsumer(rabbitmqConn, rabbitmq.ConsumerConfig{
+		Queue:    "product_service.order_events",
+		Exchange: messages.ExchangeOrder,
+	}, productService.OrderEventHandlers())
+
+	// Product events invalidate the catalog cache
+	productConsumer := rabbitmq.NewConsumer(rabbitmqConn, rabbitmq.ConsumerConfig{
+		Queue:    "product_service.product_events",
+		Exchange: messages.ExchangeProduct,
+	}, productService.ProductEventHandlers())
+
+	var consumers sync.WaitGroup
+	for name, consumer := range map[string]*rabbitmq.Consumer{"checkout commands": commandConsumer, "store events": storeConsumer, "order events": orderConsumer, "product events": productConsumer} {
+		consumers.Add(1)
+		go func(name string, consumer *rabbitmq.Consumer) {
+			defer consumers.Done()
+			if err := consumer.Run(ctx); err != nil {
+				log.Fatalf("Failed to consume %s: %v", name, err)
+			}
+		}(name, consumer)
+	}
 
 	// Setup handlers
 	categoryHandler := handler.NewCategoryHandler(categoryService)
 	productHandler := handler.NewProductHandler(productService)
 	reviewHandler := handler.NewProductReviewHandler(reviewService)
+	storefrontHandler := handler.NewStorefrontHandler(storefrontService)
+
+	// Access tokens of revoked sessions are rejected before they expire
+	jwtAuth := middleware.JWTAuthMiddleware(cfg.JWTSecret, middleware.NewTokenRevocations(redisClient))
+
+	// Rate limits are counted in Redis across instances
+	limiter := middleware.NewRateLimiter(redisClient)
+	defaultLimit, err := middleware.ParseRateLimit("default", cfg.RateLimitDefault)
+	if err != nil {
+		log.Fatalf("Invalid rate limit: %v", err)
+	}
+	rateLimit := middleware.RateLimiterMiddleware(limiter, defaultLimit)
 
 	// Setup router
 	router := gin.Default()
+	if err := middleware.SetTrustedProxies(router, cfg.TrustedProxies); err != nil {
+		log.Fatalf("Invalid trusted proxies: %v", err)
+	}
 	router.Use(middleware.CORSMiddleware())
 	router.Use(middleware.LoggerMiddleware())
 
+	// Health check used by the API gateway
+	router.GET("/health", func(c *gin.Context) {
+		c.JSON(http.StatusOK, gin.H{"status": "ok"})
+	})
+
 	// Routes
 	api := router.Group("/api/v1")
 	{
 		// Public routes
 		categories := api.Group("/categories")
+		categories.Use(rateLimit)
 		{
 			categories.GET("", categoryHandler.GetAllCategories)
 			categories.GET("/:id", categoryHandler.GetCategoryByID)
 		}
 
 		products := api.Group("/products")
+		products.Use(rateLimit)
 		{
 			products.GET("", productHandler.GetAllProducts)
 			products.GET("/:id", productHandler.GetProductByID)
@@ -84,17 +172,21 @@ func main() {
 			products.GET("/:id/reviews", reviewHandler.GetProductReviews)
 		}
 
+		api.GET("/stores/:slug", rateLimit, storefrontHandler.GetStorefront)
+
 		// Protected routes (require authentication)
 		protected := api.Group("/")
-		protected.Use(middleware.JWTAuthMiddleware(cfg.JWTSecret))
+		protected.Use(jwtAuth, rateLimit)
 		{
-			// Product management for sellers
+			sellerOnly := middleware.RequireRoles(middleware.RoleSeller, middleware.RoleAdmin)
+
 			products := protected.Group("/products")
 			{
-				products.POST("", productHandler.CreateProduct)
-				products.PUT("/:id", productHandler.UpdateProduct)
-				products.DELETE("/:id", productHandler.DeleteProduct)
-				products.PUT("/:id/stock", productHandler.UpdateStock)
+				// Product management for sellers; sellers may only change their own products
+				products.POST("", sellerOnly, productHandler.CreateProduct)
+				products.PUT("/:id", sellerOnly, productHandler.UpdateProduct)
+				products.DELETE("/:id", sellerOnly, productHandler.DeleteProduct)
+				products.PUT("/:id/stock", sellerOnly, productHandler.UpdateStock)
 
 				// Product reviews
 				products.POST("/:id/reviews", reviewHandler.CreateReview)
@@ -102,20 +194,50 @@ func main() {
 				products.DELETE("/reviews/:reviewId", reviewHandler.DeleteReview)
 			}
 
+			// Seller's own catalogue
+			protected.GET("/sellers/me/products", sellerOnly, productHandler.GetMyProducts)
+
 			// Category management (admin only)
 			categories := protected.Group("/categories")
-			// TODO: Add admin middleware
+			categories.Use(middleware.RequireRoles(middleware.RoleAdmin))
 			{
 				categories.POST("", categoryHandler.CreateCategory)
 				categories.PUT("/:id", categoryHandler.UpdateCategory)
 				categories.DELETE("/:id", categoryHandler.DeleteCategory)
 			}
+
+			// Admin routes
+			admin := protected.Group("/admin")
+			admin.Use(middleware.RequireRoles(middleware.RoleAdmin))
+			{
+				// Cache hit, miss and stale counters for monitoring
+				admin.GET("/cache/stats", func(c *gin.Context) {
+					stats := productService.CacheStats()
+					for name, cacheStats := range categoryService.CacheStats() {
+						stats[name] = cacheStats
+					}
+					c.JSON(http.StatusOK, stats)
+				})
+			}
 		}
 	}
 
 	// Start server
-	log.Printf("Product service starting on port %s", cfg.Port)
-	if err := router.Run(":" + cfg.Port); err != nil {
-		log.Fatalf("Failed to start server: %v", err)
+	srv := &http.Server{Addr: ":" + cfg.Port, Handler: router}
+	go func() {
+		log.Printf("Product service starting on port %s", cfg.Port)
+		if err := srv.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+			log.Fatalf("Failed to start server: %v", err)
+		}
+	}()
+
+	// Finish in-flight requests and messages before exiting
+	<-ctx.Done()
+	log.Printf("Product service shutting down")
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
+	if err := srv.Shutdown(shutdownCtx); err != nil {
+		log.Printf("Failed to shut down server: %v", err)
 	}
+	consumers.Wait()
 }
\ No newline at end of file
@@ -1,13 +1,17 @@
 package main
 
 import (
+	"context"
 	"log"
+	"net/http"
 
 	"github.com/be-bcv/ecommerce-backend/internal/handler"
+	"github.com/be-bcv/ecommerce-backend/internal/models"
 	"github.com/be-bcv/ecommerce-backend/internal/repository"
 	"github.com/be-bcv/ecommerce-backend/internal/service"
 	"github.com/be-bcv/ecommerce-backend/pkg/config"
 	"github.com/be-bcv/ecommerce-backend/pkg/database"
+	"github.com/be-bcv/ecommerce-backend/pkg/messages"
 	"github.com/be-bcv/ecommerce-backend/pkg/middleware"
 	"github.com/be-bcv/ecommerce-backend/pkg/rabbitmq"
 	"github.com/be-bcv/ecommerce-backend/pkg/redis"
@@ -26,7 +30,7 @@ func main() {
 	defer db.Close()
 
 	// Auto migrate
-	if err := db.Migrate(&models.User{}, &models.UserSession{}); err != nil {
+	if err := db.Migrate(&models.User{}, &models.UserSession{}, &models.Store{}, &models.StoreBankChange{}, &models.OutboxEvent{}); err != nil {
 		log.Fatalf("Failed to migrate database: %v", err)
 	}
 
@@ -44,49 +48,118 @@ func main() {
 	}
 	defer rabbitmqConn.Close()
 
+	// Declare the exchanges this service publishes to
+	if err := rabbitmqConn.DeclareTopicExchanges(messages.ExchangeUser); err != nil {
+		log.Fatalf("Failed to declare exchanges: %v", err)
+	}
+
+	publisher, err := rabbitmq.NewPublisher(rabbitmqConn)
+	if err != nil {
+		log.Fatalf("Failed to create RabbitMQ publisher: %v", err)
+	}
+	defer publisher.Close()
+
 	// Setup repositories
-	userRepo := repository.NewUserRepository(db)
+	userRepo := repository.NewUserRepository(db.DB)
+	storeRepo := repository.NewStoreRepository(db.DB)
+	outboxRepo := repository.NewOutboxRepository(db.DB)
 
 	// Setup services
-	userService := service.NewUserService(userRepo, redisClient, rabbitmqConn, cfg)
+	userService := service.NewUserService(userRepo, redisClient, cfg)
+	storeService := service.NewStoreService(storeRepo, userRepo)
+
+	// Relay outbox events to RabbitMQ
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go service.NewOutboxRelay(outboxRepo, publisher).Run(ctx)
 
 	// Setup handlers
 	userHandler := handler.NewUserHandler(userService)
+	storeHandler := handler.NewStoreHandler(storeService)
+
+	// Access tokens of revoked sessions are rejected before they expire
+	jwtAuth := middleware.JWTAuthMiddleware(cfg.JWTSecret, middleware.NewTokenRevocations(redisClient))
+
+	// Rate limits are counted in Redis across instances
+	limiter := middleware.NewRateLimiter(redisClient)
+	defaultLimit, err := middleware.ParseRateLimit("default", cfg.RateLimitDefault)
+	if err != nil {
+		log.Fatalf("Invalid rate limit: %v", err)
+	}
+	authLimit, err := middleware.ParseRateLimit("auth", cfg.RateLimitAuth)
+	if err != nil {
+		log.Fatalf("Invalid rate limit: %v", err)
+	}
+	rateLimit := middleware.RateLimiterMiddleware(limiter, defaultLimit)
+	authRateLimit := middleware.RateLimiterMiddleware(limiter, authLimit)
 
 	// Setup router
 	router := gin.Default()
+	if err := middleware.SetTrustedProxies(router, cfg.TrustedProxies); err != nil {
+		log.Fatalf("Invalid trusted proxies: %v", err)
+	}
 	router.Use(middleware.CORSMiddleware())
 	router.Use(middleware.LoggerMiddleware())
 
+	// Health check used by the API gateway
+	router.GET("/health", func(c *gin.Context) {
+		c.JSON(http.StatusOK, gin.H{"status": "ok"})
+	})
+
 	// Routes
 	api := router.Group("/api/v1")
 	{
 		// Auth routes
 		auth := api.Group("/auth")
+		auth.Use(rateLimit)
 		{
-			auth.POST("/register", userHandler.Register)
-			auth.POST("/login", userHandler.Login)
+			// Stricter limits against credential stuffing and sign-up spam
+			auth.POST("/register", authRateLimit, userHandler.Register)
+			auth.POST("/login", authRateLimit, userHandler.Login)
 			auth.POST("/logout", userHandler.Logout)
 			auth.POST("/refresh", userHandler.RefreshToken)
 		}
 
 		// User routes (protected)
 		users := api.Group("/users")
-		users.Use(middleware.JWTAuthMiddleware(cfg.JWTSecret))
+		users.Use(jwtAuth, rateLimit)
 		{
 			users.GET("/profile", userHandler.GetProfile)
 			users.PUT("/profile", userHandler.UpdateProfile)
 			users.DELETE("/account", userHandler.DeleteAccount)
+
+			// Signed-in devices: list them, sign one out or all of them
+			users.GET("/sessions", userHandler.GetSessions)
+			users.DELETE("/sessions/:id", userHandler.RevokeSession)
+			users.DELETE("/sessions", userHandler.LogoutEverywhere)
+
+			// Seller onboarding: apply with a store profile, then manage it
+			users.POST("/store", storeHandler.Apply)
+			users.GET("/store", storeHandler.GetMyStore)
+			users.PUT("/store", storeHandler.UpdateMyStore)
 		}
 
 		// Admin routes
 		admin := api.Group("/admin")
-		admin.Use(middleware.JWTAuthMiddleware(cfg.JWTSecret))
-		// TODO: Add admin role middleware
+		admin.Use(jwtAuth, rateLimit, middleware.RequireRoles(middleware.RoleAdmin))
 		{
 			admin.GET("/users", userHandler.GetAllUsers)
 			admin.GET("/users/:id", userHandler.GetUserByID)
 			admin.PUT("/users/:id/status", userHandler.UpdateUserStatus)
+
+			// Failed login lockouts, by account email or IP
+			admin.GET("/lockouts", userHandler.GetLoginLockouts)
+			admin.DELETE("/lockouts/:scope/:key", userHandler.ClearLoginLockout)
+
+			// Seller applications
+			admin.GET("/stores", storeHandler.GetAllStores)
+			admin.POST("/stores/:id/approve", storeHandler.ApproveStore)
+			admin.POST("/stores/:id/reject", storeHandler.RejectStore)
+
+			// Bank account changes of approved stores
+			admin.GET("/stores/bank-changes", storeHandler.GetBankChanges)
+			admin.POST("/stores/bank-changes/:id/approve", storeHandler.ApproveBankChange)
+			admin.POST("/stores/bank-changes/:id/reject", storeHandler.RejectBankChange)
 		}
 	}
 
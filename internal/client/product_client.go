@@ -0,0 +1,59 @@
+package client
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/be-bcv/ecommerce-backend/internal/models"
+	"github.com/google/uuid"
+)
+
+// ProductClient talks to the product service REST API from other services.
+type ProductClient struct {
+	baseURL    string
+	httpClient *http.Client
+}
+
+func NewProductClient(baseURL string) *ProductClient {
+	return &ProductClient{
+		baseURL: strings.TrimRight(baseURL, "/"),
+		httpClient: &http.Client{
+			Timeout: 10 * time.Second,
+		},
+	}
+}
+
+type productEnvelope struct {
+	Status  string          `json:"status"`
+	Message string          `json:"message"`
+	Data    *models.Product `json:"data"`
+}
+
+// GetProduct returns the active product with the given ID, or nil if the
+// product service does not know about it.
+func (c *ProductClient) GetProduct(id uuid.UUID) (*models.Product, error) {
+	url := fmt.Sprintf("%s/api/v1/products/%s", c.baseURL, id.String())
+
+	resp, err := c.httpClient.Get(url)
+	if err != nil {
+		return nil, fmt.Errorf("failed to reach product service: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotFound {
+		return nil, nil
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("product service returned status %d", resp.StatusCode)
+	}
+
+	var envelope productEnvelope
+	if err := json.NewDecoder(resp.Body).Decode(&envelope); err != nil {
+		return nil, fmt.Errorf("failed to decode product response: %w", err)
+	}
+
+	return envelope.Data, nil
+}
@@ -0,0 +1,95 @@
+package gateway
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sync"
+
+	"github.com/be-bcv/ecommerce-backend/pkg/utils"
+	"github.com/gin-gonic/gin"
+)
+
+const recentOrdersLimit = 5
+
+// AccountOverview is the gateway-composed view of the caller's account.
+type AccountOverview struct {
+	Profile      json.RawMessage `json:"profile"`
+	RecentOrders json.RawMessage `json:"recent_orders"`
+}
+
+// AccountOverview returns the caller's profile and most recent orders in one
+// response, fetched from user-service and order-service in parallel.
+func (g *Gateway) AccountOverview(c *gin.Context) {
+	if _, err := g.authenticate(c.Request); err != nil {
+		utils.ErrorResponse(c, http.StatusUnauthorized, "Authentication required", err.Error())
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(c.Request.Context(), defaultTimeout)
+	defer cancel()
+
+	var (
+		overview              AccountOverview
+		profileErr, ordersErr error
+		wg                    sync.WaitGroup
+	)
+	wg.Add(2)
+	go func() {
+		defer wg.Done()
+		overview.Profile, profileErr = g.fetch(ctx, c.Request, "user-service", "/api/v1/users/profile")
+	}()
+	go func() {
+		defer wg.Done()
+		overview.RecentOrders, ordersErr = g.fetch(ctx, c.Request, "order-service", fmt.Sprintf("/api/v1/orders?page=1&limit=%d", recentOrdersLimit))
+	}()
+	wg.Wait()
+
+	for _, err := range []error{profileErr, ordersErr} {
+		if err != nil {
+			utils.ErrorResponse(c, http.StatusBadGateway, "Failed to load account overview", err.Error())
+			return
+		}
+	}
+
+	utils.SuccessResponse(c, "Account overview retrieved successfully", overview)
+}
+
+// fetch performs an authenticated GET against an upstream on behalf of the
+// original request and returns the data field of the response envelope.
+func (g *Gateway) fetch(ctx context.Context, orig *http.Request, upstreamName, path string) (json.RawMessage, error) {
+	upstream := g.upstreams[upstreamName]
+	inst := upstream.pick()
+	if inst == nil {
+		return nil, fmt.Errorf("%s unavailable", upstreamName)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, inst.url.String()+path, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Authorization", orig.Header.Get("Authorization"))
+	req.Header.Set("Accept", "application/json")
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		if ctx.Err() == nil {
+			upstream.markDown(inst, err)
+		}
+		return nil, fmt.Errorf("%s request failed: %w", upstreamName, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("%s returned status %d", upstreamName, resp.StatusCode)
+	}
+
+	var envelope struct {
+		Data json.RawMessage `json:"data"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&envelope); err != nil {
+		return nil, fmt.Errorf("failed to decode %s response: %w", upstreamName, err)
+	}
+	return envelope.Data, nil
+}
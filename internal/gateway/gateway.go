@@ -0,0 +1,236 @@
+package gateway
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httputil"
+	"strings"
+	"time"
+
+	"github.com/be-bcv/ecommerce-backend/pkg/config"
+	"github.com/be-bcv/ecommerce-backend/pkg/middleware"
+	"github.com/be-bcv/ecommerce-backend/pkg/utils"
+	"github.com/gin-gonic/gin"
+)
+
+const defaultTimeout = 10 * time.Second
+
+// Route maps a path prefix under /api/v1 to the upstream that serves it.
+type Route struct {
+	Prefix   string
+	Upstream *Upstream
+	Timeout  time.Duration
+	// Public lists the methods that may be called without a token. Use "*"
+	// to make every method public.
+	Public []string
+}
+
+func (r *Route) isPublic(method string) bool {
+	for _, m := range r.Public {
+		if m == "*" || m == method {
+			return true
+		}
+	}
+	return false
+}
+
+// matches reports whether path is the route prefix or below it.
+func (r *Route) matches(path string) bool {
+	return path == r.Prefix || strings.HasPrefix(path, r.Prefix+"/")
+}
+
+type Gateway struct {
+	routes      []Route
+	upstreams   map[string]*Upstream
+	jwtSecret   string
+	revocations *middleware.TokenRevocations
+}
+
+// NewGateway builds the routing table from the service URLs in the config.
+// Each URL may list several instances separated by commas.
+func NewGateway(cfg *config.Config, revocations *middleware.TokenRevocations) (*Gateway, error) {
+	upstreams := make(map[string]*Upstream)
+	for name, urls := range map[string]string{
+		"product-service": cfg.ProductServiceURL,
+		"user-service":    cfg.UserServiceURL,
+		"order-service":   cfg.OrderServiceURL,
+		"payment-service": cfg.PaymentServiceURL,
+	} {
+		upstream, err := NewUpstream(name, urls)
+		if err != nil {
+			return nil, err
+		}
+		upstreams[name] = upstream
+	}
+
+	product := upstreams["product-service"]
+	user := upstreams["user-service"]
+	order := upstreams["order-service"]
+	payment := upstreams["payment-service"]
+
+	// Routes are matched in order, so more specific prefixes must come
+	// before the prefixes they extend
+	routes := []Route{
+		{Prefix: "/api/v1/auth", Upstream: user, Public: []string{"*"}},
+		{Prefix: "/api/v1/users", Upstream: user},
+		{Prefix: "/api/v1/admin/users", Upstream: user},
+		{Prefix: "/api/v1/admin/stores", Upstream: user},
+		{Prefix: "/api/v1/admin/lockouts", Upstream: user},
+		{Prefix: "/api/v1/products", Upstream: product, Public: []string{http.MethodGet}},
+		{Prefix: "/api/v1/categories", Upstream: product, Public: []string{http.MethodGet}},
+		{Prefix: "/api/v1/sellers", Upstream: product},
+		{Prefix: "/api/v1/stores", Upstream: product, Public: []string{http.MethodGet}},
+		{Prefix: "/api/v1/admin/cache", Upstream: product},
+		{Prefix: "/api/v1/cart", Upstream: order},
+		{Prefix: "/api/v1/orders", Upstream: order},
+		{Prefix: "/api/v1/admin/orders", Upstream: order},
+		{Prefix: "/api/v1/seller/orders", Upstream: order},
+		{Prefix: "/api/v1/seller/returns", Upstream: order},
+		{Prefix: "/api/v1/returns", Upstream: order},
+		{Prefix: "/api/v1/admin/returns", Upstream: order},
+		// Checkout prices every item against product-service before writing
+		{Prefix: "/api/v1/checkout", Upstream: order, Timeout: 30 * time.Second},
+		// Midtrans authenticates notifications with a signature, not a JWT
+		{Prefix: "/api/v1/payments/notification", Upstream: payment, Public: []string{http.MethodPost}},
+		// Creating a payment waits on Midtrans, whose client times out at 30s
+		{Prefix: "/api/v1/payments", Upstream: payment, Timeout: 35 * time.Second},
+		{Prefix: "/api/v1/seller/balance", Upstream: payment},
+		{Prefix: "/api/v1/seller/ledger", Upstream: payment},
+		{Prefix: "/api/v1/admin/payouts", Upstream: payment},
+	}
+
+	return &Gateway{
+		routes:      routes,
+		upstreams:   upstreams,
+		jwtSecret:   cfg.JWTSecret,
+		revocations: revocations,
+	}, nil
+}
+
+// RunHealthChecks starts the health checks of every upstream and returns
+// immediately; they stop when ctx is cancelled.
+func (g *Gateway) RunHealthChecks(ctx context.Context) {
+	for _, upstream := range g.upstreams {
+		go upstream.RunHealthChecks(ctx)
+	}
+}
+
+// Health reports the gateway as healthy while every upstream has at least
+// one instance in rotation.
+func (g *Gateway) Health(c *gin.Context) {
+	services := make(map[string]bool, len(g.upstreams))
+	healthy := true
+	for name, upstream := range g.upstreams {
+		services[name] = upstream.Healthy()
+		healthy = healthy && services[name]
+	}
+
+	if !healthy {
+		utils.ErrorResponse(c, http.StatusServiceUnavailable, "Some services are unavailable", services)
+		return
+	}
+	utils.SuccessResponse(c, "OK", services)
+}
+
+func (g *Gateway) match(path string) *Route {
+	for i := range g.routes {
+		if g.routes[i].matches(path) {
+			return &g.routes[i]
+		}
+	}
+	return nil
+}
+
+// Proxy forwards the request to the upstream serving its path. Routes that
+// are not public reject requests without a valid, unrevoked bearer token.
+// The token is forwarded as is, and services authenticate it again with
+// JWTAuthMiddleware, since they can also be reached without the gateway.
+func (g *Gateway) Proxy(c *gin.Context) {
+	route := g.match(c.Request.URL.Path)
+	if route == nil {
+		utils.ErrorResponse(c, http.StatusNotFound, "Route not found", nil)
+		return
+	}
+
+	if _, err := g.authenticate(c.Request); err != nil {
+		if !route.isPublic(c.Request.Method) {
+			utils.ErrorResponse(c, http.StatusUnauthorized, "Authentication required", err.Error())
+			return
+		}
+		// Public routes ignore bad tokens rather than forwarding them
+		c.Request.Header.Del("Authorization")
+	}
+
+	inst := route.Upstream.pick()
+	if inst == nil {
+		utils.ErrorResponse(c, http.StatusServiceUnavailable, "Service unavailable", route.Upstream.name)
+		return
+	}
+
+	timeout := route.Timeout
+	if timeout == 0 {
+		timeout = defaultTimeout
+	}
+	ctx, cancel := context.WithTimeout(c.Request.Context(), timeout)
+	defer cancel()
+
+	inst.proxy.ServeHTTP(c.Writer, c.Request.WithContext(ctx))
+}
+
+var errMissingToken = errors.New("bearer token required")
+
+func (g *Gateway) authenticate(r *http.Request) (*middleware.Claims, error) {
+	authHeader := r.Header.Get("Authorization")
+	tokenString := strings.TrimPrefix(authHeader, "Bearer ")
+	if authHeader == "" || tokenString == authHeader {
+		return nil, errMissingToken
+	}
+
+	claims, err := middleware.ParseToken(tokenString, g.jwtSecret)
+	if err != nil {
+		return nil, errors.New("invalid token")
+	}
+	if g.revocations.IsRevoked(r.Context(), claims) {
+		return nil, errors.New("token has been revoked")
+	}
+	return claims, nil
+}
+
+func newReverseProxy(u *Upstream, inst *instance) *httputil.ReverseProxy {
+	proxy := httputil.NewSingleHostReverseProxy(inst.url)
+
+	// The gateway answers CORS itself, so drop the copies services add
+	proxy.ModifyResponse = func(resp *http.Response) error {
+		for header := range resp.Header {
+			if strings.HasPrefix(header, "Access-Control-") {
+				resp.Header.Del(header)
+			}
+		}
+		return nil
+	}
+
+	proxy.ErrorHandler = func(w http.ResponseWriter, r *http.Request, err error) {
+		status, message := http.StatusBadGateway, "Service unavailable"
+		switch {
+		case errors.Is(err, context.DeadlineExceeded):
+			status, message = http.StatusGatewayTimeout, "Service timed out"
+		case errors.Is(err, context.Canceled):
+			// The client went away; nobody is left to read a response
+			return
+		default:
+			u.markDown(inst, err)
+		}
+
+		w.Header().Set("Content-Type", "application/json; charset=utf-8")
+		w.WriteHeader(status)
+		json.NewEncoder(w).Encode(utils.Response{
+			Status:  "error",
+			Message: message,
+			Error:   u.name,
+		})
+	}
+
+	return proxy
+}
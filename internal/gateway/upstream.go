@@ -0,0 +1,150 @@
+package gateway
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net/http"
+	"net/http/httputil"
+	"net/url"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	healthCheckInterval = 10 * time.Second
+	healthCheckTimeout  = 2 * time.Second
+)
+
+// instance is one running copy of a downstream service.
+type instance struct {
+	url     *url.URL
+	proxy   *httputil.ReverseProxy
+	healthy atomic.Bool
+}
+
+// Upstream is a downstream service with one or more instances. Requests are
+// spread round-robin over the instances that passed their last health check.
+type Upstream struct {
+	name      string
+	instances []*instance
+	next      atomic.Uint64
+	client    *http.Client
+}
+
+// NewUpstream builds an upstream from a comma-separated list of base URLs,
+// e.g. "http://product-service-1:8001,http://product-service-2:8001".
+func NewUpstream(name, urls string) (*Upstream, error) {
+	u := &Upstream{
+		name:   name,
+		client: &http.Client{Timeout: healthCheckTimeout},
+	}
+
+	for _, raw := range strings.Split(urls, ",") {
+		raw = strings.TrimSpace(raw)
+		if raw == "" {
+			continue
+		}
+		target, err := url.Parse(strings.TrimRight(raw, "/"))
+		if err != nil || target.Scheme == "" || target.Host == "" {
+			return nil, fmt.Errorf("invalid %s URL %q", name, raw)
+		}
+
+		inst := &instance{url: target}
+		inst.proxy = newReverseProxy(u, inst)
+		// Assume healthy until the first check says otherwise
+		inst.healthy.Store(true)
+		u.instances = append(u.instances, inst)
+	}
+
+	if len(u.instances) == 0 {
+		return nil, fmt.Errorf("no URL configured for %s", name)
+	}
+	return u, nil
+}
+
+// pick returns the next healthy instance, or nil when none is available.
+func (u *Upstream) pick() *instance {
+	n := uint64(len(u.instances))
+	start := u.next.Add(1)
+	for i := uint64(0); i < n; i++ {
+		inst := u.instances[(start+i)%n]
+		if inst.healthy.Load() {
+			return inst
+		}
+	}
+	return nil
+}
+
+// markDown takes an instance out of rotation until its next successful
+// health check.
+func (u *Upstream) markDown(inst *instance, reason error) {
+	if inst.healthy.Swap(false) {
+		log.Printf("Upstream %s instance %s marked unhealthy: %v", u.name, inst.url, reason)
+	}
+}
+
+// RunHealthChecks polls GET /health on every instance until ctx is cancelled.
+func (u *Upstream) RunHealthChecks(ctx context.Context) {
+	ticker := time.NewTicker(healthCheckInterval)
+	defer ticker.Stop()
+
+	for {
+		u.checkAll(ctx)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (u *Upstream) checkAll(ctx context.Context) {
+	var wg sync.WaitGroup
+	for _, inst := range u.instances {
+		wg.Add(1)
+		go func(inst *instance) {
+			defer wg.Done()
+
+			if err := u.check(ctx, inst); err != nil {
+				u.markDown(inst, err)
+				return
+			}
+			if !inst.healthy.Swap(true) {
+				log.Printf("Upstream %s instance %s is healthy again", u.name, inst.url)
+			}
+		}(inst)
+	}
+	wg.Wait()
+}
+
+func (u *Upstream) check(ctx context.Context, inst *instance) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, inst.url.String()+"/health", nil)
+	if err != nil {
+		return err
+	}
+
+	resp, err := u.client.Do(req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("health check returned %d", resp.StatusCode)
+	}
+	return nil
+}
+
+// Healthy reports whether at least one instance is in rotation.
+func (u *Upstream) Healthy() bool {
+	for _, inst := range u.instances {
+		if inst.healthy.Load() {
+			return true
+		}
+	}
+	return false
+}
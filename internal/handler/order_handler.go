@@ -0,0 +1,638 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/be-bcv/ecommerce-backend/internal/models"
+	"github.com/be-bcv/ecommerce-backend/internal/service"
+	"github.com/be-bcv/ecommerce-backend/pkg/utils"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// Cart Handlers
+type CartHandler struct {
+	cartService *service.CartService
+}
+
+func NewCartHandler(cartService *service.CartService) *CartHandler {
+	return &CartHandler{cartService: cartService}
+}
+
+func (h *CartHandler) GetCart(c *gin.Context) {
+	userID, ok := getUserID(c)
+	if !ok {
+		return
+	}
+
+	cart, err := h.cartService.GetCart(userID)
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to fetch cart", err.Error())
+		return
+	}
+
+	utils.SuccessResponse(c, "Cart retrieved successfully", cart)
+}
+
+func (h *CartHandler) AddToCart(c *gin.Context) {
+	userID, ok := getUserID(c)
+	if !ok {
+		return
+	}
+
+	var req service.AddToCartRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, "Invalid request data", err.Error())
+		return
+	}
+
+	if err := h.cartService.AddToCart(userID, &req); err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, "Failed to add item to cart", err.Error())
+		return
+	}
+
+	utils.SuccessResponse(c, "Item added to cart successfully", nil)
+}
+
+func (h *CartHandler) UpdateCartItem(c *gin.Context) {
+	userID, ok := getUserID(c)
+	if !ok {
+		return
+	}
+
+	cartID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, "Invalid cart item ID", err.Error())
+		return
+	}
+
+	var req service.UpdateCartItemRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, "Invalid request data", err.Error())
+		return
+	}
+
+	if err := h.cartService.UpdateCartItem(cartID, userID, &req); err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, "Failed to update cart item", err.Error())
+		return
+	}
+
+	utils.SuccessResponse(c, "Cart item updated successfully", nil)
+}
+
+func (h *CartHandler) RemoveFromCart(c *gin.Context) {
+	userID, ok := getUserID(c)
+	if !ok {
+		return
+	}
+
+	cartID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, "Invalid cart item ID", err.Error())
+		return
+	}
+
+	if err := h.cartService.RemoveFromCart(cartID, userID); err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, "Failed to remove cart item", err.Error())
+		return
+	}
+
+	utils.SuccessResponse(c, "Cart item removed successfully", nil)
+}
+
+func (h *CartHandler) ClearCart(c *gin.Context) {
+	userID, ok := getUserID(c)
+	if !ok {
+		return
+	}
+
+	if err := h.cartService.ClearCart(userID); err != nil {
+		utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to clear cart", err.Error())
+		return
+	}
+
+	utils.SuccessResponse(c, "Cart cleared successfully", nil)
+}
+
+// Order Handlers
+type OrderHandler struct {
+	orderService *service.OrderService
+}
+
+func NewOrderHandler(orderService *service.OrderService) *OrderHandler {
+	return &OrderHandler{orderService: orderService}
+}
+
+func (h *OrderHandler) GetUserOrders(c *gin.Context) {
+	userID, ok := getUserID(c)
+	if !ok {
+		return
+	}
+
+	pageStr := c.DefaultQuery("page", "1")
+	limitStr := c.DefaultQuery("limit", "10")
+
+	page, err := strconv.Atoi(pageStr)
+	if err != nil || page < 1 {
+		page = 1
+	}
+
+	limit, err := strconv.Atoi(limitStr)
+	if err != nil || limit < 1 || limit > 100 {
+		limit = 10
+	}
+
+	orders, total, err := h.orderService.GetUserOrders(userID, page, limit)
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to fetch orders", err.Error())
+		return
+	}
+
+	pagination := utils.NewPagination(page, limit, int(total))
+	utils.PagedResponse(c, "Orders retrieved successfully", orders, pagination)
+}
+
+func (h *OrderHandler) GetOrderByID(c *gin.Context) {
+	userID, ok := getUserID(c)
+	if !ok {
+		return
+	}
+
+	orderID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, "Invalid order ID", err.Error())
+		return
+	}
+
+	order, err := h.orderService.GetOrderByID(orderID, userID)
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusNotFound, "Order not found", err.Error())
+		return
+	}
+
+	utils.SuccessResponse(c, "Order retrieved successfully", order)
+}
+
+func (h *OrderHandler) CreateOrder(c *gin.Context) {
+	userID, ok := getUserID(c)
+	if !ok {
+		return
+	}
+
+	var req service.CreateOrderRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, "Invalid request data", err.Error())
+		return
+	}
+
+	order, err := h.orderService.CreateOrder(userID, &req)
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, "Failed to create order", err.Error())
+		return
+	}
+
+	utils.SuccessResponse(c, "Order created successfully", order)
+}
+
+func (h *OrderHandler) Checkout(c *gin.Context) {
+	userID, ok := getUserID(c)
+	if !ok {
+		return
+	}
+
+	var req service.CheckoutRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, "Invalid request data", err.Error())
+		return
+	}
+
+	order, err := h.orderService.Checkout(userID, &req)
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, "Checkout failed", err.Error())
+		return
+	}
+
+	utils.SuccessResponse(c, "Checkout successful", order)
+}
+
+func (h *OrderHandler) CancelOrder(c *gin.Context) {
+	userID, ok := getUserID(c)
+	if !ok {
+		return
+	}
+
+	orderID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, "Invalid order ID", err.Error())
+		return
+	}
+
+	// The cancellation reason is optional, so an empty body is fine
+	var req service.CancelOrderRequest
+	if c.Request.ContentLength > 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			utils.ErrorResponse(c, http.StatusBadRequest, "Invalid request data", err.Error())
+			return
+		}
+	}
+
+	if err := h.orderService.CancelOrder(orderID, userID, &req); err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, "Failed to cancel order", err.Error())
+		return
+	}
+
+	utils.SuccessResponse(c, "Order cancelled successfully", nil)
+}
+
+func (h *OrderHandler) GetOrderStatus(c *gin.Context) {
+	userID, ok := getUserID(c)
+	if !ok {
+		return
+	}
+
+	orderID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, "Invalid order ID", err.Error())
+		return
+	}
+
+	status, err := h.orderService.GetOrderStatus(orderID, userID)
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusNotFound, "Order not found", err.Error())
+		return
+	}
+
+	utils.SuccessResponse(c, "Order status retrieved successfully", status)
+}
+
+func (h *OrderHandler) GetAllOrders(c *gin.Context) {
+	pageStr := c.DefaultQuery("page", "1")
+	limitStr := c.DefaultQuery("limit", "10")
+	status := c.Query("status")
+
+	page, err := strconv.Atoi(pageStr)
+	if err != nil || page < 1 {
+		page = 1
+	}
+
+	limit, err := strconv.Atoi(limitStr)
+	if err != nil || limit < 1 || limit > 100 {
+		limit = 10
+	}
+
+	orders, total, err := h.orderService.GetAllOrders(page, limit, status)
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to fetch orders", err.Error())
+		return
+	}
+
+	pagination := utils.NewPagination(page, limit, int(total))
+	utils.PagedResponse(c, "Orders retrieved successfully", orders, pagination)
+}
+
+func (h *OrderHandler) UpdateOrderStatus(c *gin.Context) {
+	userID, ok := getUserID(c)
+	if !ok {
+		return
+	}
+
+	orderID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, "Invalid order ID", err.Error())
+		return
+	}
+
+	var req service.UpdateOrderStatusRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, "Invalid request data", err.Error())
+		return
+	}
+
+	if err := h.orderService.UpdateOrderStatus(orderID, userID, c.GetString("role"), &req); err != nil {
+		if errors.Is(err, models.ErrInvalidOrderStatusTransition) {
+			utils.ErrorResponse(c, http.StatusConflict, "Failed to update order status", err.Error())
+			return
+		}
+		utils.ErrorResponse(c, http.StatusBadRequest, "Failed to update order status", err.Error())
+		return
+	}
+
+	utils.SuccessResponse(c, "Order status updated successfully", nil)
+}
+
+// getUserID reads the authenticated user set by JWTAuthMiddleware. When it
+// returns false an error response has already been written.
+func getUserID(c *gin.Context) (uuid.UUID, bool) {
+	userIDStr, exists := c.Get("user_id")
+	if !exists {
+		utils.ErrorResponse(c, http.StatusUnauthorized, "User not authenticated", nil)
+		return uuid.Nil, false
+	}
+
+	userID, err := uuid.Parse(userIDStr.(string))
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, "Invalid user ID", err.Error())
+		return uuid.Nil, false
+	}
+
+	return userID, true
+}
+
+// GetSellerOrders lists the calling seller's part of every order they sell in.
+func (h *OrderHandler) GetSellerOrders(c *gin.Context) {
+	sellerID, ok := getUserID(c)
+	if !ok {
+		return
+	}
+
+	pageStr := c.DefaultQuery("page", "1")
+	limitStr := c.DefaultQuery("limit", "10")
+	status := c.Query("status")
+
+	page, err := strconv.Atoi(pageStr)
+	if err != nil || page < 1 {
+		page = 1
+	}
+
+	limit, err := strconv.Atoi(limitStr)
+	if err != nil || limit < 1 || limit > 100 {
+		limit = 10
+	}
+
+	orders, total, err := h.orderService.GetSellerOrders(sellerID, page, limit, status)
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to fetch seller orders", err.Error())
+		return
+	}
+
+	pagination := utils.NewPagination(page, limit, int(total))
+	utils.PagedResponse(c, "Seller orders retrieved successfully", orders, pagination)
+}
+
+func (h *OrderHandler) GetSellerOrder(c *gin.Context) {
+	sellerID, ok := getUserID(c)
+	if !ok {
+		return
+	}
+
+	fulfillmentID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, "Invalid order ID", err.Error())
+		return
+	}
+
+	order, err := h.orderService.GetSellerOrder(fulfillmentID, sellerID)
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusNotFound, "Order not found", err.Error())
+		return
+	}
+
+	utils.SuccessResponse(c, "Seller order retrieved successfully", order)
+}
+
+func (h *OrderHandler) UpdateSellerOrderStatus(c *gin.Context) {
+	sellerID, ok := getUserID(c)
+	if !ok {
+		return
+	}
+
+	fulfillmentID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, "Invalid order ID", err.Error())
+		return
+	}
+
+	var req service.UpdateFulfillmentRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, "Invalid request data", err.Error())
+		return
+	}
+
+	if err := h.orderService.UpdateSellerOrderStatus(fulfillmentID, sellerID, &req); err != nil {
+		if errors.Is(err, models.ErrInvalidOrderStatusTransition) {
+			utils.ErrorResponse(c, http.StatusConflict, "Failed to update order status", err.Error())
+			return
+		}
+		utils.ErrorResponse(c, http.StatusBadRequest, "Failed to update order status", err.Error())
+		return
+	}
+
+	utils.SuccessResponse(c, "Order status updated successfully", nil)
+}
+
+func (h *OrderHandler) CreateReturn(c *gin.Context) {
+	userID, ok := getUserID(c)
+	if !ok {
+		return
+	}
+
+	orderID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, "Invalid order ID", err.Error())
+		return
+	}
+
+	var req service.CreateReturnRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, "Invalid request data", err.Error())
+		return
+	}
+
+	ret, err := h.orderService.CreateReturn(orderID, userID, &req)
+	if err != nil {
+		if errors.Is(err, models.ErrReturnInProgress) {
+			utils.ErrorResponse(c, http.StatusConflict, "Failed to request return", err.Error())
+			return
+		}
+		utils.ErrorResponse(c, http.StatusBadRequest, "Failed to request return", err.Error())
+		return
+	}
+
+	utils.SuccessResponse(c, "Return requested successfully", ret)
+}
+
+func (h *OrderHandler) GetMyReturns(c *gin.Context) {
+	userID, ok := getUserID(c)
+	if !ok {
+		return
+	}
+
+	pageStr := c.DefaultQuery("page", "1")
+	limitStr := c.DefaultQuery("limit", "10")
+
+	page, err := strconv.Atoi(pageStr)
+	if err != nil || page < 1 {
+		page = 1
+	}
+
+	limit, err := strconv.Atoi(limitStr)
+	if err != nil || limit < 1 || limit > 100 {
+		limit = 10
+	}
+
+	returns, total, err := h.orderService.GetUserReturns(userID, page, limit)
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to fetch returns", err.Error())
+		return
+	}
+
+	pagination := utils.NewPagination(page, limit, int(total))
+	utils.PagedResponse(c, "Returns retrieved successfully", returns, pagination)
+}
+
+func (h *OrderHandler) GetMyReturn(c *gin.Context) {
+	userID, ok := getUserID(c)
+	if !ok {
+		return
+	}
+
+	returnID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, "Invalid return ID", err.Error())
+		return
+	}
+
+	ret, err := h.orderService.GetUserReturn(returnID, userID)
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusNotFound, "Return not found", err.Error())
+		return
+	}
+
+	utils.SuccessResponse(c, "Return retrieved successfully", ret)
+}
+
+// GetSellerReturns lists returns of the calling seller's items.
+func (h *OrderHandler) GetSellerReturns(c *gin.Context) {
+	sellerID, ok := getUserID(c)
+	if !ok {
+		return
+	}
+
+	pageStr := c.DefaultQuery("page", "1")
+	limitStr := c.DefaultQuery("limit", "10")
+	status := c.Query("status")
+
+	page, err := strconv.Atoi(pageStr)
+	if err != nil || page < 1 {
+		page = 1
+	}
+
+	limit, err := strconv.Atoi(limitStr)
+	if err != nil || limit < 1 || limit > 100 {
+		limit = 10
+	}
+
+	returns, total, err := h.orderService.GetSellerReturns(sellerID, status, page, limit)
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to fetch returns", err.Error())
+		return
+	}
+
+	pagination := utils.NewPagination(page, limit, int(total))
+	utils.PagedResponse(c, "Returns retrieved successfully", returns, pagination)
+}
+
+func (h *OrderHandler) GetAllReturns(c *gin.Context) {
+	pageStr := c.DefaultQuery("page", "1")
+	limitStr := c.DefaultQuery("limit", "10")
+	status := c.Query("status")
+
+	page, err := strconv.Atoi(pageStr)
+	if err != nil || page < 1 {
+		page = 1
+	}
+
+	limit, err := strconv.Atoi(limitStr)
+	if err != nil || limit < 1 || limit > 100 {
+		limit = 10
+	}
+
+	returns, total, err := h.orderService.GetAllReturns(status, page, limit)
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to fetch returns", err.Error())
+		return
+	}
+
+	pagination := utils.NewPagination(page, limit, int(total))
+	utils.PagedResponse(c, "Returns retrieved successfully", returns, pagination)
+}
+
+// ApproveReturn approves a return as its seller or as an admin.
+func (h *OrderHandler) ApproveReturn(c *gin.Context) {
+	reviewerID, ok := getUserID(c)
+	if !ok {
+		return
+	}
+
+	returnID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, "Invalid return ID", err.Error())
+		return
+	}
+
+	if err := h.orderService.ApproveReturn(returnID, reviewerID, c.GetString("role")); err != nil {
+		returnReviewError(c, "Failed to approve return", err)
+		return
+	}
+
+	utils.SuccessResponse(c, "Return approved successfully", nil)
+}
+
+// RejectReturn rejects a return as its seller or as an admin.
+func (h *OrderHandler) RejectReturn(c *gin.Context) {
+	reviewerID, ok := getUserID(c)
+	if !ok {
+		return
+	}
+
+	returnID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, "Invalid return ID", err.Error())
+		return
+	}
+
+	var req service.RejectReturnRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, "Invalid request data", err.Error())
+		return
+	}
+
+	if err := h.orderService.RejectReturn(returnID, reviewerID, c.GetString("role"), &req); err != nil {
+		returnReviewError(c, "Failed to reject return", err)
+		return
+	}
+
+	utils.SuccessResponse(c, "Return rejected successfully", nil)
+}
+
+func (h *OrderHandler) RetryRefund(c *gin.Context) {
+	adminID, ok := getUserID(c)
+	if !ok {
+		return
+	}
+
+	returnID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, "Invalid return ID", err.Error())
+		return
+	}
+
+	if err := h.orderService.RetryRefund(returnID, adminID); err != nil {
+		returnReviewError(c, "Failed to retry refund", err)
+		return
+	}
+
+	utils.SuccessResponse(c, "Refund requested again", nil)
+}
+
+// returnReviewError answers 409 when the return or its order is not in a
+// status the change can be made from, and 400 for any other failure.
+func returnReviewError(c *gin.Context, message string, err error) {
+	if errors.Is(err, models.ErrReturnNotPending) || errors.Is(err, models.ErrInvalidOrderStatusTransition) {
+		utils.ErrorResponse(c, http.StatusConflict, message, err.Error())
+		return
+	}
+	utils.ErrorResponse(c, http.StatusBadRequest, message, err.Error())
+}
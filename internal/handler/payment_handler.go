@@ -0,0 +1,261 @@
+package handler
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/be-bcv/ecommerce-backend/internal/models"
+	"github.com/be-bcv/ecommerce-backend/internal/service"
+	"github.com/be-bcv/ecommerce-backend/pkg/midtrans"
+	"github.com/be-bcv/ecommerce-backend/pkg/utils"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// Payment Handlers
+type PaymentHandler struct {
+	paymentService *service.PaymentService
+}
+
+func NewPaymentHandler(paymentService *service.PaymentService) *PaymentHandler {
+	return &PaymentHandler{paymentService: paymentService}
+}
+
+func (h *PaymentHandler) CreatePayment(c *gin.Context) {
+	userID, ok := getUserID(c)
+	if !ok {
+		return
+	}
+
+	var req service.CreatePaymentRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, "Invalid request data", err.Error())
+		return
+	}
+
+	payment, err := h.paymentService.CreatePayment(userID, &req)
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, "Failed to create payment", err.Error())
+		return
+	}
+
+	utils.SuccessResponse(c, "Payment created successfully", payment)
+}
+
+func (h *PaymentHandler) GetPaymentByID(c *gin.Context) {
+	userID, ok := getUserID(c)
+	if !ok {
+		return
+	}
+
+	paymentID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, "Invalid payment ID", err.Error())
+		return
+	}
+
+	payment, err := h.paymentService.GetPaymentByID(paymentID, userID)
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusNotFound, "Payment not found", err.Error())
+		return
+	}
+
+	utils.SuccessResponse(c, "Payment retrieved successfully", payment)
+}
+
+// HandleNotification receives Midtrans payment notifications. It is public,
+// so authenticity is established by the notification signature instead of a JWT.
+func (h *PaymentHandler) HandleNotification(c *gin.Context) {
+	var notification midtrans.Notification
+	if err := c.ShouldBindJSON(&notification); err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, "Invalid notification payload", err.Error())
+		return
+	}
+
+	if err := h.paymentService.HandleNotification(&notification); err != nil {
+		switch {
+		case errors.Is(err, service.ErrInvalidSignature):
+			utils.ErrorResponse(c, http.StatusForbidden, "Invalid notification signature", nil)
+		case errors.Is(err, service.ErrPaymentNotFound):
+			utils.ErrorResponse(c, http.StatusNotFound, "Payment not found", err.Error())
+		default:
+			utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to process payment notification", err.Error())
+		}
+		return
+	}
+
+	utils.SuccessResponse(c, "Notification processed successfully", nil)
+}
+
+// Settlement Handlers
+type SettlementHandler struct {
+	settlementService *service.SettlementService
+}
+
+func NewSettlementHandler(settlementService *service.SettlementService) *SettlementHandler {
+	return &SettlementHandler{settlementService: settlementService}
+}
+
+func (h *SettlementHandler) GetMyBalance(c *gin.Context) {
+	sellerID, ok := getUserID(c)
+	if !ok {
+		return
+	}
+
+	balance, err := h.settlementService.GetSellerBalance(sellerID)
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to fetch balance", err.Error())
+		return
+	}
+
+	utils.SuccessResponse(c, "Balance retrieved successfully", balance)
+}
+
+func (h *SettlementHandler) GetMyLedger(c *gin.Context) {
+	sellerID, ok := getUserID(c)
+	if !ok {
+		return
+	}
+
+	pageStr := c.DefaultQuery("page", "1")
+	limitStr := c.DefaultQuery("limit", "10")
+
+	page, err := strconv.Atoi(pageStr)
+	if err != nil || page < 1 {
+		page = 1
+	}
+
+	limit, err := strconv.Atoi(limitStr)
+	if err != nil || limit < 1 || limit > 100 {
+		limit = 10
+	}
+
+	entries, total, err := h.settlementService.GetSellerLedger(sellerID, page, limit)
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to fetch ledger", err.Error())
+		return
+	}
+
+	pagination := utils.NewPagination(page, limit, int(total))
+	utils.PagedResponse(c, "Ledger retrieved successfully", entries, pagination)
+}
+
+func (h *SettlementHandler) CreatePayoutBatch(c *gin.Context) {
+	adminID, ok := getUserID(c)
+	if !ok {
+		return
+	}
+
+	batch, err := h.settlementService.CreatePayoutBatch(adminID)
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, "Failed to create payout batch", err.Error())
+		return
+	}
+
+	utils.SuccessResponse(c, "Payout batch created successfully", batch)
+}
+
+func (h *SettlementHandler) GetPayoutBatches(c *gin.Context) {
+	status := c.Query("status")
+	pageStr := c.DefaultQuery("page", "1")
+	limitStr := c.DefaultQuery("limit", "10")
+
+	page, err := strconv.Atoi(pageStr)
+	if err != nil || page < 1 {
+		page = 1
+	}
+
+	limit, err := strconv.Atoi(limitStr)
+	if err != nil || limit < 1 || limit > 100 {
+		limit = 10
+	}
+
+	batches, total, err := h.settlementService.GetPayoutBatches(page, limit, status)
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to fetch payout batches", err.Error())
+		return
+	}
+
+	pagination := utils.NewPagination(page, limit, int(total))
+	utils.PagedResponse(c, "Payout batches retrieved successfully", batches, pagination)
+}
+
+func (h *SettlementHandler) GetPayoutBatch(c *gin.Context) {
+	batchID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, "Invalid payout batch ID", err.Error())
+		return
+	}
+
+	batch, err := h.settlementService.GetPayoutBatch(batchID)
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusNotFound, "Payout batch not found", err.Error())
+		return
+	}
+
+	utils.SuccessResponse(c, "Payout batch retrieved successfully", batch)
+}
+
+func (h *SettlementHandler) ProcessPayoutBatch(c *gin.Context) {
+	batchID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, "Invalid payout batch ID", err.Error())
+		return
+	}
+
+	batch, err := h.settlementService.ProcessPayoutBatch(batchID)
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, "Failed to process payout batch", err.Error())
+		return
+	}
+
+	utils.SuccessResponse(c, "Payout batch is being processed", batch)
+}
+
+// ExportPayoutBatch downloads the batch's payouts as CSV.
+func (h *SettlementHandler) ExportPayoutBatch(c *gin.Context) {
+	batchID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, "Invalid payout batch ID", err.Error())
+		return
+	}
+
+	// Render before writing anything, so a failure can still answer JSON
+	var buf bytes.Buffer
+	if err := h.settlementService.ExportPayoutBatchCSV(batchID, &buf); err != nil {
+		utils.ErrorResponse(c, http.StatusNotFound, "Failed to export payout batch", err.Error())
+		return
+	}
+
+	c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=payout-batch-%s.csv", batchID))
+	c.Data(http.StatusOK, "text/csv; charset=utf-8", buf.Bytes())
+}
+
+func (h *SettlementHandler) UpdatePayoutStatus(c *gin.Context) {
+	payoutID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, "Invalid payout ID", err.Error())
+		return
+	}
+
+	var req service.UpdatePayoutStatusRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, "Invalid request data", err.Error())
+		return
+	}
+
+	payout, err := h.settlementService.UpdatePayoutStatus(payoutID, &req)
+	if err != nil {
+		if errors.Is(err, models.ErrPayoutNotPending) {
+			utils.ErrorResponse(c, http.StatusConflict, "Failed to update payout status", err.Error())
+			return
+		}
+		utils.ErrorResponse(c, http.StatusBadRequest, "Failed to update payout status", err.Error())
+		return
+	}
+
+	utils.SuccessResponse(c, "Payout status updated successfully", payout)
+}
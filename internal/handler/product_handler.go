@@ -1,6 +1,7 @@
 package handler
 
 import (
+	"errors"
 	"net/http"
 	"strconv"
 
@@ -19,13 +20,18 @@ func NewProductHandler(productService *service.ProductService) *ProductHandler {
 }
 
 func (h *ProductHandler) CreateProduct(c *gin.Context) {
+	sellerID, ok := getUserID(c)
+	if !ok {
+		return
+	}
+
 	var req service.CreateProductRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
 		utils.ErrorResponse(c, http.StatusBadRequest, "Invalid request data", err.Error())
 		return
 	}
 
-	product, err := h.productService.CreateProduct(&req)
+	product, err := h.productService.CreateProduct(sellerID, &req)
 	if err != nil {
 		utils.ErrorResponse(c, http.StatusBadRequest, "Failed to create product", err.Error())
 		return
@@ -149,6 +155,11 @@ func (h *ProductHandler) GetProductsByCategory(c *gin.Context) {
 }
 
 func (h *ProductHandler) UpdateProduct(c *gin.Context) {
+	userID, ok := getUserID(c)
+	if !ok {
+		return
+	}
+
 	idStr := c.Param("id")
 	id, err := uuid.Parse(idStr)
 	if err != nil {
@@ -162,9 +173,9 @@ func (h *ProductHandler) UpdateProduct(c *gin.Context) {
 		return
 	}
 
-	product, err := h.productService.UpdateProduct(id, &req)
+	product, err := h.productService.UpdateProduct(id, userID, c.GetString("role"), &req)
 	if err != nil {
-		utils.ErrorResponse(c, http.StatusBadRequest, "Failed to update product", err.Error())
+		productMutationError(c, "Failed to update product", err)
 		return
 	}
 
@@ -172,6 +183,11 @@ func (h *ProductHandler) UpdateProduct(c *gin.Context) {
 }
 
 func (h *ProductHandler) DeleteProduct(c *gin.Context) {
+	userID, ok := getUserID(c)
+	if !ok {
+		return
+	}
+
 	idStr := c.Param("id")
 	id, err := uuid.Parse(idStr)
 	if err != nil {
@@ -179,8 +195,8 @@ func (h *ProductHandler) DeleteProduct(c *gin.Context) {
 		return
 	}
 
-	if err := h.productService.DeleteProduct(id); err != nil {
-		utils.ErrorResponse(c, http.StatusBadRequest, "Failed to delete product", err.Error())
+	if err := h.productService.DeleteProduct(id, userID, c.GetString("role")); err != nil {
+		productMutationError(c, "Failed to delete product", err)
 		return
 	}
 
@@ -188,6 +204,11 @@ func (h *ProductHandler) DeleteProduct(c *gin.Context) {
 }
 
 func (h *ProductHandler) UpdateStock(c *gin.Context) {
+	userID, ok := getUserID(c)
+	if !ok {
+		return
+	}
+
 	idStr := c.Param("id")
 	id, err := uuid.Parse(idStr)
 	if err != nil {
@@ -201,14 +222,54 @@ func (h *ProductHandler) UpdateStock(c *gin.Context) {
 		return
 	}
 
-	if err := h.productService.UpdateStock(id, &req); err != nil {
-		utils.ErrorResponse(c, http.StatusBadRequest, "Failed to update stock", err.Error())
+	if err := h.productService.UpdateStock(id, userID, c.GetString("role"), &req); err != nil {
+		productMutationError(c, "Failed to update stock", err)
 		return
 	}
 
 	utils.SuccessResponse(c, "Stock updated successfully", nil)
 }
 
+// GetMyProducts lists the calling seller's own products, including inactive ones.
+func (h *ProductHandler) GetMyProducts(c *gin.Context) {
+	sellerID, ok := getUserID(c)
+	if !ok {
+		return
+	}
+
+	pageStr := c.DefaultQuery("page", "1")
+	limitStr := c.DefaultQuery("limit", "10")
+
+	page, err := strconv.Atoi(pageStr)
+	if err != nil || page < 1 {
+		page = 1
+	}
+
+	limit, err := strconv.Atoi(limitStr)
+	if err != nil || limit < 1 || limit > 100 {
+		limit = 10
+	}
+
+	products, total, err := h.productService.GetProductsBySeller(sellerID, page, limit)
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to fetch seller products", err.Error())
+		return
+	}
+
+	pagination := utils.NewPagination(page, limit, int(total))
+	utils.PagedResponse(c, "Seller products retrieved successfully", products, pagination)
+}
+
+// productMutationError answers 403 when the caller does not own the product
+// and 400 for any other failure.
+func productMutationError(c *gin.Context, message string, err error) {
+	if errors.Is(err, service.ErrNotProductOwner) {
+		utils.ErrorResponse(c, http.StatusForbidden, message, err.Error())
+		return
+	}
+	utils.ErrorResponse(c, http.StatusBadRequest, message, err.Error())
+}
+
 // Category Handlers
 type CategoryHandler struct {
 	categoryService *service.CategoryService
@@ -429,4 +490,38 @@ func (h *ProductReviewHandler) DeleteReview(c *gin.Context) {
 	}
 
 	utils.SuccessResponse(c, "Review deleted successfully", nil)
-}
\ No newline at end of file
+}
+// Storefront Handlers
+type StorefrontHandler struct {
+	storefrontService *service.StorefrontService
+}
+
+func NewStorefrontHandler(storefrontService *service.StorefrontService) *StorefrontHandler {
+	return &StorefrontHandler{storefrontService: storefrontService}
+}
+
+// GetStorefront returns a store's public profile with a page of its active
+// products; the pagination describes the products.
+func (h *StorefrontHandler) GetStorefront(c *gin.Context) {
+	pageStr := c.DefaultQuery("page", "1")
+	limitStr := c.DefaultQuery("limit", "10")
+
+	page, err := strconv.Atoi(pageStr)
+	if err != nil || page < 1 {
+		page = 1
+	}
+
+	limit, err := strconv.Atoi(limitStr)
+	if err != nil || limit < 1 || limit > 100 {
+		limit = 10
+	}
+
+	storefront, total, err := h.storefrontService.GetStorefront(c.Param("slug"), page, limit)
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusNotFound, "Store not found", err.Error())
+		return
+	}
+
+	pagination := utils.NewPagination(page, limit, int(total))
+	utils.PagedResponse(c, "Store retrieved successfully", storefront, pagination)
+}
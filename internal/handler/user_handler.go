@@ -1,11 +1,12 @@
 package handler
 
 import (
+	"errors"
+	"math"
 	"net/http"
 	"strconv"
 
 	"github.com/be-bcv/ecommerce-backend/internal/service"
-	"github.com/be-bcv/ecommerce-backend/pkg/middleware"
 	"github.com/be-bcv/ecommerce-backend/pkg/utils"
 	"github.com/gin-gonic/gin"
 	"github.com/google/uuid"
@@ -26,7 +27,7 @@ func (h *UserHandler) Register(c *gin.Context) {
 		return
 	}
 
-	response, err := h.userService.Register(&req)
+	response, err := h.userService.Register(&req, clientInfo(c))
 	if err != nil {
 		utils.ErrorResponse(c, http.StatusBadRequest, "Registration failed", err.Error())
 		return
@@ -42,8 +43,14 @@ func (h *UserHandler) Login(c *gin.Context) {
 		return
 	}
 
-	response, err := h.userService.Login(&req)
+	response, err := h.userService.Login(&req, clientInfo(c))
 	if err != nil {
+		var locked *service.LoginLockedError
+		if errors.As(err, &locked) {
+			c.Header("Retry-After", strconv.Itoa(int(math.Ceil(locked.RetryAfter.Seconds()))))
+			utils.ErrorResponse(c, http.StatusTooManyRequests, "Login failed", err.Error())
+			return
+		}
 		utils.ErrorResponse(c, http.StatusUnauthorized, "Login failed", err.Error())
 		return
 	}
@@ -76,7 +83,7 @@ func (h *UserHandler) RefreshToken(c *gin.Context) {
 		return
 	}
 
-	response, err := h.userService.RefreshToken(req.RefreshToken)
+	response, err := h.userService.RefreshToken(req.RefreshToken, clientInfo(c))
 	if err != nil {
 		utils.ErrorResponse(c, http.StatusUnauthorized, "Token refresh failed", err.Error())
 		return
@@ -85,6 +92,68 @@ func (h *UserHandler) RefreshToken(c *gin.Context) {
 	utils.SuccessResponse(c, "Token refreshed successfully", response)
 }
 
+func (h *UserHandler) GetSessions(c *gin.Context) {
+	userID, ok := getUserID(c)
+	if !ok {
+		return
+	}
+
+	// Tokens issued before sessions were tracked have no session ID
+	currentSessionID, _ := uuid.Parse(c.GetString("session_id"))
+
+	sessions, err := h.userService.GetSessions(userID, currentSessionID)
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to fetch sessions", err.Error())
+		return
+	}
+
+	utils.SuccessResponse(c, "Sessions retrieved successfully", sessions)
+}
+
+func (h *UserHandler) RevokeSession(c *gin.Context) {
+	userID, ok := getUserID(c)
+	if !ok {
+		return
+	}
+
+	sessionID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, "Invalid session ID", err.Error())
+		return
+	}
+
+	if err := h.userService.RevokeSession(userID, sessionID); err != nil {
+		utils.ErrorResponse(c, http.StatusNotFound, "Failed to revoke session", err.Error())
+		return
+	}
+
+	utils.SuccessResponse(c, "Session revoked successfully", nil)
+}
+
+func (h *UserHandler) LogoutEverywhere(c *gin.Context) {
+	userID, ok := getUserID(c)
+	if !ok {
+		return
+	}
+
+	if err := h.userService.LogoutEverywhere(userID); err != nil {
+		utils.ErrorResponse(c, http.StatusInternalServerError, "Logout failed", err.Error())
+		return
+	}
+
+	utils.SuccessResponse(c, "Logged out of all sessions successfully", nil)
+}
+
+// clientInfo describes the client of the request. Its IP is only taken from
+// X-Forwarded-For on requests from TRUSTED_PROXIES, since login lockouts are
+// counted per IP.
+func clientInfo(c *gin.Context) service.ClientInfo {
+	return service.ClientInfo{
+		IPAddress: c.ClientIP(),
+		UserAgent: c.Request.UserAgent(),
+	}
+}
+
 func (h *UserHandler) GetProfile(c *gin.Context) {
 	userIDStr, exists := c.Get("user_id")
 	if !exists {
@@ -220,4 +289,234 @@ func (h *UserHandler) UpdateUserStatus(c *gin.Context) {
 	}
 
 	utils.SuccessResponse(c, "User status updated successfully", nil)
-}
\ No newline at end of file
+}
+
+func (h *UserHandler) GetLoginLockouts(c *gin.Context) {
+	lockouts, err := h.userService.GetLoginLockouts()
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to fetch lockouts", err.Error())
+		return
+	}
+
+	utils.SuccessResponse(c, "Lockouts retrieved successfully", lockouts)
+}
+
+func (h *UserHandler) ClearLoginLockout(c *gin.Context) {
+	if err := h.userService.ClearLoginLockout(c.Param("scope"), c.Param("key")); err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, "Failed to clear lockout", err.Error())
+		return
+	}
+
+	utils.SuccessResponse(c, "Lockout cleared successfully", nil)
+}
+// Store Handlers
+type StoreHandler struct {
+	storeService *service.StoreService
+}
+
+func NewStoreHandler(storeService *service.StoreService) *StoreHandler {
+	return &StoreHandler{storeService: storeService}
+}
+
+func (h *StoreHandler) Apply(c *gin.Context) {
+	userID, ok := getUserID(c)
+	if !ok {
+		return
+	}
+
+	var req service.ApplyStoreRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, "Invalid request data", err.Error())
+		return
+	}
+
+	store, err := h.storeService.Apply(userID, &req)
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, "Failed to submit store application", err.Error())
+		return
+	}
+
+	utils.SuccessResponse(c, "Store application submitted successfully", store)
+}
+
+func (h *StoreHandler) GetMyStore(c *gin.Context) {
+	userID, ok := getUserID(c)
+	if !ok {
+		return
+	}
+
+	store, err := h.storeService.GetMyStore(userID)
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusNotFound, "Store not found", err.Error())
+		return
+	}
+
+	utils.SuccessResponse(c, "Store retrieved successfully", store)
+}
+
+func (h *StoreHandler) UpdateMyStore(c *gin.Context) {
+	userID, ok := getUserID(c)
+	if !ok {
+		return
+	}
+
+	var req service.UpdateStoreRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, "Invalid request data", err.Error())
+		return
+	}
+
+	store, err := h.storeService.UpdateMyStore(userID, &req)
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, "Failed to update store", err.Error())
+		return
+	}
+
+	utils.SuccessResponse(c, "Store updated successfully", store)
+}
+
+func (h *StoreHandler) GetAllStores(c *gin.Context) {
+	status := c.Query("status")
+	pageStr := c.DefaultQuery("page", "1")
+	limitStr := c.DefaultQuery("limit", "10")
+
+	page, err := strconv.Atoi(pageStr)
+	if err != nil || page < 1 {
+		page = 1
+	}
+
+	limit, err := strconv.Atoi(limitStr)
+	if err != nil || limit < 1 || limit > 100 {
+		limit = 10
+	}
+
+	stores, total, err := h.storeService.GetAllStores(status, page, limit)
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to fetch stores", err.Error())
+		return
+	}
+
+	pagination := utils.NewPagination(page, limit, int(total))
+	utils.PagedResponse(c, "Stores retrieved successfully", stores, pagination)
+}
+
+func (h *StoreHandler) ApproveStore(c *gin.Context) {
+	adminID, ok := getUserID(c)
+	if !ok {
+		return
+	}
+
+	storeID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, "Invalid store ID", err.Error())
+		return
+	}
+
+	store, err := h.storeService.ApproveStore(storeID, adminID)
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, "Failed to approve store", err.Error())
+		return
+	}
+
+	utils.SuccessResponse(c, "Store approved successfully", store)
+}
+
+func (h *StoreHandler) RejectStore(c *gin.Context) {
+	adminID, ok := getUserID(c)
+	if !ok {
+		return
+	}
+
+	storeID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, "Invalid store ID", err.Error())
+		return
+	}
+
+	var req service.RejectStoreRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, "Invalid request data", err.Error())
+		return
+	}
+
+	store, err := h.storeService.RejectStore(storeID, adminID, &req)
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, "Failed to reject store", err.Error())
+		return
+	}
+
+	utils.SuccessResponse(c, "Store rejected successfully", store)
+}
+
+func (h *StoreHandler) GetBankChanges(c *gin.Context) {
+	status := c.Query("status")
+	pageStr := c.DefaultQuery("page", "1")
+	limitStr := c.DefaultQuery("limit", "10")
+
+	page, err := strconv.Atoi(pageStr)
+	if err != nil || page < 1 {
+		page = 1
+	}
+
+	limit, err := strconv.Atoi(limitStr)
+	if err != nil || limit < 1 || limit > 100 {
+		limit = 10
+	}
+
+	changes, total, err := h.storeService.GetBankChanges(status, page, limit)
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to fetch bank account changes", err.Error())
+		return
+	}
+
+	pagination := utils.NewPagination(page, limit, int(total))
+	utils.PagedResponse(c, "Bank account changes retrieved successfully", changes, pagination)
+}
+
+func (h *StoreHandler) ApproveBankChange(c *gin.Context) {
+	adminID, ok := getUserID(c)
+	if !ok {
+		return
+	}
+
+	changeID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, "Invalid bank account change ID", err.Error())
+		return
+	}
+
+	change, err := h.storeService.ApproveBankChange(changeID, adminID)
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, "Failed to approve bank account change", err.Error())
+		return
+	}
+
+	utils.SuccessResponse(c, "Bank account change approved successfully", change)
+}
+
+func (h *StoreHandler) RejectBankChange(c *gin.Context) {
+	adminID, ok := getUserID(c)
+	if !ok {
+		return
+	}
+
+	changeID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, "Invalid bank account change ID", err.Error())
+		return
+	}
+
+	var req service.RejectStoreRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, "Invalid request data", err.Error())
+		return
+	}
+
+	change, err := h.storeService.RejectBankChange(changeID, adminID, &req)
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, "Failed to reject bank account change", err.Error())
+		return
+	}
+
+	utils.SuccessResponse(c, "Bank account change rejected successfully", change)
+}
@@ -0,0 +1,51 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Checkout saga states. A saga starts in SagaStateReservingStock when the
+// order is placed and ends completed once paid, or compensated once the
+// order was cancelled and the other services were told to undo their steps.
+const (
+	SagaStateReservingStock  = "reserving_stock"
+	SagaStateCreatingPayment = "creating_payment"
+	SagaStateAwaitingPayment = "awaiting_payment"
+	SagaStateCompleted       = "completed"
+	SagaStateCompensated     = "compensated"
+)
+
+// ActiveSagaStates are the states in which a saga is still waiting on
+// another service and may time out.
+var ActiveSagaStates = []string{SagaStateReservingStock, SagaStateCreatingPayment, SagaStateAwaitingPayment}
+
+// CheckoutSaga tracks an order through checkout: stock is reserved in
+// product-service, a payment is opened in payment-service and the order is
+// confirmed once it is paid. It is stored next to the order so the
+// orchestrator can resume after a restart.
+type CheckoutSaga struct {
+	ID            uuid.UUID  `gorm:"type:uuid;primary_key;default:gen_random_uuid()" json:"id"`
+	OrderID       uuid.UUID  `gorm:"type:uuid;not null;uniqueIndex" json:"order_id"`
+	State         string     `gorm:"not null;index" json:"state"`
+	PaymentID     uuid.UUID  `gorm:"type:uuid" json:"payment_id"`
+	FailureReason string     `json:"failure_reason"`
+	Deadline      *time.Time `gorm:"index" json:"deadline"` // when the current step times out; nil once finished
+	CreatedAt     time.Time  `json:"created_at"`
+	UpdatedAt     time.Time  `json:"updated_at"`
+}
+
+// IsActive reports whether the saga has not finished yet.
+func (s *CheckoutSaga) IsActive() bool {
+	for _, state := range ActiveSagaStates {
+		if s.State == state {
+			return true
+		}
+	}
+	return false
+}
+
+func (CheckoutSaga) TableName() string {
+	return "checkout_sagas"
+}
@@ -0,0 +1,37 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Fulfillment is the part of an order one seller ships: the seller's items,
+// their shipping cost and the parcel's tracking details. An order gets one
+// fulfillment per seller in it and is paid for once as a whole.
+//
+// Fulfillments use the order statuses and transitions. They follow the
+// order while it is pending, confirmed or cancelled; sellers then ship and
+// deliver their fulfillments, and the order becomes shipped or delivered
+// once all of its fulfillments are.
+type Fulfillment struct {
+	ID             uuid.UUID  `gorm:"type:uuid;primary_key;default:gen_random_uuid()" json:"id"`
+	OrderID        uuid.UUID  `gorm:"type:uuid;not null;index" json:"order_id"`
+	SellerID       uuid.UUID  `gorm:"type:uuid;not null;index" json:"seller_id"`
+	Status         string     `gorm:"not null;default:pending;index" json:"status"`
+	Subtotal       float64    `gorm:"not null" json:"subtotal"`
+	ShippingCost   float64    `gorm:"not null;default:0" json:"shipping_cost"`
+	Courier        string     `json:"courier"`
+	TrackingNumber string     `json:"tracking_number"`
+	ShippingDate   *time.Time `json:"shipping_date"`
+	DeliveryDate   *time.Time `json:"delivery_date"`
+	CreatedAt      time.Time  `json:"created_at"`
+	UpdatedAt      time.Time  `json:"updated_at"`
+
+	Items []OrderItem `gorm:"foreignKey:FulfillmentID" json:"items,omitempty"`
+	Order *Order      `gorm:"foreignKey:OrderID" json:"-"`
+}
+
+func (Fulfillment) TableName() string {
+	return "order_fulfillments"
+}
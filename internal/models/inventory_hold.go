@@ -0,0 +1,33 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const (
+	InventoryHoldHeld      = "held"
+	InventoryHoldReleased  = "released"
+	InventoryHoldConverted = "converted"
+)
+
+// InventoryHold is the stock an order item keeps out of sale while the order
+// waits to be paid. It expires together with the order's payment; an expired
+// hold gets the order cancelled, which gives the stock back. Holds are
+// released when the order is cancelled and converted when it is confirmed.
+type InventoryHold struct {
+	ID          uuid.UUID  `gorm:"type:uuid;primary_key;default:gen_random_uuid()" json:"id"`
+	OrderID     uuid.UUID  `gorm:"type:uuid;not null;index" json:"order_id"`
+	OrderItemID uuid.UUID  `gorm:"type:uuid;not null;uniqueIndex" json:"order_item_id"`
+	ProductID   uuid.UUID  `gorm:"type:uuid;not null" json:"product_id"`
+	Quantity    int        `gorm:"not null" json:"quantity"`
+	Status      string     `gorm:"not null;index" json:"status"` // held, released or converted
+	ExpiresAt   *time.Time `gorm:"index" json:"expires_at"`      // the payment's expiry; nil until a payment is opened
+	CreatedAt   time.Time  `json:"created_at"`
+	UpdatedAt   time.Time  `json:"updated_at"`
+}
+
+func (InventoryHold) TableName() string {
+	return "inventory_holds"
+}
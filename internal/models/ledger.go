@@ -0,0 +1,179 @@
+package models
+
+import (
+	"errors"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Ledger accounts. Seller accounts are kept per seller through
+// LedgerEntry.SellerID; platform accounts have no seller.
+const (
+	// LedgerAccountCustomerFunds is the money customers paid that the
+	// platform still holds (an asset: debits increase it).
+	LedgerAccountCustomerFunds = "customer_funds"
+	// LedgerAccountCommission is the platform's commission revenue.
+	LedgerAccountCommission = "commission"
+	// LedgerAccountPayoutsInTransit is money batched for payout to sellers
+	// but not yet confirmed as transferred.
+	LedgerAccountPayoutsInTransit = "payouts_in_transit"
+	// LedgerAccountSellerHeld is what a seller earned on delivered items that
+	// are still within the return window.
+	LedgerAccountSellerHeld = "seller_held"
+	// LedgerAccountSellerAvailable is what a seller may be paid out.
+	LedgerAccountSellerAvailable = "seller_available"
+)
+
+const (
+	LedgerTransactionSale         = "sale"
+	LedgerTransactionRelease      = "release"
+	LedgerTransactionPayout       = "payout"
+	LedgerTransactionPayoutPaid   = "payout_paid"
+	LedgerTransactionPayoutFailed = "payout_failed"
+	LedgerTransactionRefund       = "refund"
+)
+
+// LedgerTransaction is one balanced posting to the seller settlement ledger:
+// its entries' debits equal their credits. Reference identifies the business
+// event it records, e.g. "sale:<order item id>", so it is posted only once.
+type LedgerTransaction struct {
+	ID          uuid.UUID `gorm:"type:uuid;primary_key;default:gen_random_uuid()" json:"id"`
+	Type        string    `gorm:"not null;index" json:"type"`
+	Reference   string    `gorm:"not null;uniqueIndex" json:"reference"`
+	Description string    `json:"description"`
+	CreatedAt   time.Time `json:"created_at"`
+
+	Entries []LedgerEntry `gorm:"foreignKey:TransactionID" json:"entries,omitempty"`
+}
+
+type LedgerEntry struct {
+	ID            uuid.UUID  `gorm:"type:uuid;primary_key;default:gen_random_uuid()" json:"id"`
+	TransactionID uuid.UUID  `gorm:"type:uuid;not null;index" json:"transaction_id"`
+	Account       string     `gorm:"not null;index:idx_ledger_entries_account" json:"account"`
+	SellerID      *uuid.UUID `gorm:"type:uuid;index:idx_ledger_entries_account" json:"seller_id,omitempty"`
+	Debit         float64    `gorm:"not null;default:0" json:"debit"`
+	Credit        float64    `gorm:"not null;default:0" json:"credit"`
+	CreatedAt     time.Time  `json:"created_at"`
+
+	Transaction *LedgerTransaction `gorm:"foreignKey:TransactionID" json:"transaction,omitempty"`
+}
+
+const (
+	SellerEarningHeld      = "held"
+	SellerEarningAvailable = "available"
+)
+
+// SellerEarning is what a seller is owed for one delivered order item: the
+// item's subtotal less the platform commission. It is held until
+// AvailableAt, the end of the return window, and then released for payout.
+// Refunded returns take back their share of it: RefundedGross of the
+// subtotal and RefundedNet of the seller's part, the rest being commission.
+type SellerEarning struct {
+	ID               uuid.UUID  `gorm:"type:uuid;primary_key;default:gen_random_uuid()" json:"id"`
+	OrderItemID      uuid.UUID  `gorm:"type:uuid;not null;uniqueIndex" json:"order_item_id"`
+	OrderID          uuid.UUID  `gorm:"type:uuid;not null;index" json:"order_id"`
+	OrderNumber      string     `json:"order_number"`
+	FulfillmentID    uuid.UUID  `gorm:"type:uuid;not null" json:"fulfillment_id"`
+	SellerID         uuid.UUID  `gorm:"type:uuid;not null;index" json:"seller_id"`
+	ProductID        uuid.UUID  `gorm:"type:uuid;not null" json:"product_id"`
+	Quantity         int        `gorm:"not null" json:"quantity"`
+	Gross            float64    `gorm:"not null" json:"gross"`
+	CommissionRate   float64    `gorm:"not null" json:"commission_rate"`
+	Commission       float64    `gorm:"not null" json:"commission"`
+	Net              float64    `gorm:"not null" json:"net"`
+	ReturnedQuantity int        `gorm:"not null;default:0" json:"returned_quantity"`
+	RefundedGross    float64    `gorm:"not null;default:0" json:"refunded_gross"`
+	RefundedNet      float64    `gorm:"not null;default:0" json:"refunded_net"`
+	Status           string     `gorm:"not null;index" json:"status"` // held or available
+	DeliveredAt      time.Time  `gorm:"not null" json:"delivered_at"`
+	AvailableAt      time.Time  `gorm:"not null;index" json:"available_at"`
+	ReleasedAt       *time.Time `json:"released_at"`
+	CreatedAt        time.Time  `json:"created_at"`
+	UpdatedAt        time.Time  `json:"updated_at"`
+}
+
+// ErrPayoutNotPending is returned when settling a payout that was already
+// settled or whose batch is not being processed.
+var ErrPayoutNotPending = errors.New("payout is not awaiting settlement")
+
+const (
+	PayoutBatchPending    = "pending"
+	PayoutBatchProcessing = "processing"
+	PayoutBatchCompleted  = "completed"
+
+	PayoutPending = "pending"
+	PayoutPaid    = "paid"
+	PayoutFailed  = "failed"
+)
+
+// PayoutBatch pays every seller their available balance. A batch is created
+// pending, moved to processing once finance has taken it (e.g. as CSV) to the
+// bank, and completed when each of its payouts is marked paid or failed.
+type PayoutBatch struct {
+	ID          uuid.UUID  `gorm:"type:uuid;primary_key;default:gen_random_uuid()" json:"id"`
+	Status      string     `gorm:"not null;index" json:"status"`
+	TotalAmount float64    `gorm:"not null" json:"total_amount"`
+	PayoutCount int        `gorm:"not null" json:"payout_count"`
+	CreatedBy   uuid.UUID  `gorm:"type:uuid;not null" json:"created_by"`
+	ProcessedAt *time.Time `json:"processed_at"`
+	CompletedAt *time.Time `json:"completed_at"`
+	CreatedAt   time.Time  `json:"created_at"`
+	UpdatedAt   time.Time  `json:"updated_at"`
+
+	Payouts []Payout `gorm:"foreignKey:BatchID" json:"payouts,omitempty"`
+}
+
+// Payout is one seller's transfer within a batch, to the bank account the
+// seller had when the batch was created. A failed payout returns the amount
+// to the seller's available balance for the next batch.
+type Payout struct {
+	ID                uuid.UUID  `gorm:"type:uuid;primary_key;default:gen_random_uuid()" json:"id"`
+	BatchID           uuid.UUID  `gorm:"type:uuid;not null;index" json:"batch_id"`
+	SellerID          uuid.UUID  `gorm:"type:uuid;not null;index" json:"seller_id"`
+	Amount            float64    `gorm:"not null" json:"amount"`
+	BankName          string     `gorm:"not null;default:''" json:"bank_name"`
+	BankAccountNumber string     `gorm:"not null;default:''" json:"bank_account_number"`
+	BankAccountName   string     `gorm:"not null;default:''" json:"bank_account_name"`
+	Status            string     `gorm:"not null;index" json:"status"`
+	Reference         string     `json:"reference"` // the bank transfer reference
+	FailureReason     string     `json:"failure_reason,omitempty"`
+	PaidAt            *time.Time `json:"paid_at"`
+	CreatedAt         time.Time  `json:"created_at"`
+	UpdatedAt         time.Time  `json:"updated_at"`
+}
+
+// SellerBankAccount is payment-service's copy of the bank account a seller's
+// payouts go to, kept up to date from store events.
+type SellerBankAccount struct {
+	SellerID          uuid.UUID `gorm:"type:uuid;primary_key" json:"seller_id"`
+	StoreID           uuid.UUID `gorm:"type:uuid;not null" json:"store_id"`
+	BankName          string    `gorm:"not null" json:"bank_name"`
+	BankAccountNumber string    `gorm:"not null" json:"bank_account_number"`
+	BankAccountName   string    `gorm:"not null" json:"bank_account_name"`
+	UpdatedAt         time.Time `json:"updated_at"` // when the account was approved in user-service
+}
+
+func (LedgerTransaction) TableName() string {
+	return "ledger_transactions"
+}
+
+func (LedgerEntry) TableName() string {
+	return "ledger_entries"
+}
+
+func (SellerEarning) TableName() string {
+	return "seller_earnings"
+}
+
+func (PayoutBatch) TableName() string {
+	return "payout_batches"
+}
+
+func (Payout) TableName() string {
+	return "payouts"
+}
+
+func (SellerBankAccount) TableName() string {
+	return "seller_bank_accounts"
+}
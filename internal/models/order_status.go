@@ -0,0 +1,60 @@
+package models
+
+import "errors"
+
+const (
+	OrderStatusPending   = "pending"
+	OrderStatusConfirmed = "confirmed"
+	OrderStatusShipped   = "shipped"
+	OrderStatusDelivered = "delivered"
+	OrderStatusCancelled = "cancelled"
+	// OrderStatusReturned and OrderStatusRefunded are reached only through
+	// return requests: an approved return moves the order to returned, and
+	// its refund to refunded. A refunded order may be returned again.
+	OrderStatusReturned = "returned"
+	OrderStatusRefunded = "refunded"
+)
+
+// ActorSystem marks status changes made by the platform itself (payment
+// notifications, background jobs) rather than by a user.
+const ActorSystem = "system"
+
+var ErrInvalidOrderStatusTransition = errors.New("invalid order status transition")
+
+// orderStatusTransitions lists, for every order status, the statuses an order
+// may move to next. Statuses without an entry are final.
+var orderStatusTransitions = map[string][]string{
+	OrderStatusPending:   {OrderStatusConfirmed, OrderStatusCancelled},
+	OrderStatusConfirmed: {OrderStatusShipped, OrderStatusCancelled},
+	OrderStatusShipped:   {OrderStatusDelivered},
+	OrderStatusDelivered: {OrderStatusReturned},
+	OrderStatusReturned:  {OrderStatusRefunded},
+	OrderStatusRefunded:  {OrderStatusReturned},
+}
+
+// NextOrderStatuses returns the statuses an order in the given status may move to.
+func NextOrderStatuses(from string) []string {
+	next := orderStatusTransitions[from]
+	result := make([]string, len(next))
+	copy(result, next)
+	return result
+}
+
+// CanTransitionOrderStatus reports whether an order may move from one status to another.
+func CanTransitionOrderStatus(from, to string) bool {
+	for _, status := range orderStatusTransitions[from] {
+		if status == to {
+			return true
+		}
+	}
+	return false
+}
+
+// IsValidOrderStatus reports whether status is one of the known order statuses.
+func IsValidOrderStatus(status string) bool {
+	switch status {
+	case OrderStatusPending, OrderStatusConfirmed, OrderStatusShipped, OrderStatusDelivered, OrderStatusCancelled, OrderStatusReturned, OrderStatusRefunded:
+		return true
+	}
+	return false
+}
@@ -0,0 +1,101 @@
+package models
+
+import "testing"
+
+func TestCanTransitionOrderStatus(t *testing.T) {
+	statuses := []string{
+		OrderStatusPending,
+		OrderStatusConfirmed,
+		OrderStatusShipped,
+		OrderStatusDelivered,
+		OrderStatusCancelled,
+		OrderStatusReturned,
+		OrderStatusRefunded,
+	}
+	allowed := map[[2]string]bool{
+		{OrderStatusPending, OrderStatusConfirmed}:   true,
+		{OrderStatusPending, OrderStatusCancelled}:   true,
+		{OrderStatusConfirmed, OrderStatusShipped}:   true,
+		{OrderStatusConfirmed, OrderStatusCancelled}: true,
+		{OrderStatusShipped, OrderStatusDelivered}:   true,
+		{OrderStatusDelivered, OrderStatusReturned}:  true,
+		{OrderStatusReturned, OrderStatusRefunded}:   true,
+		{OrderStatusRefunded, OrderStatusReturned}:   true,
+	}
+
+	// Every pair of known statuses, so a new transition must be added here
+	for _, from := range statuses {
+		for _, to := range statuses {
+			want := allowed[[2]string{from, to}]
+			if got := CanTransitionOrderStatus(from, to); got != want {
+				t.Errorf("CanTransitionOrderStatus(%q, %q) = %v, want %v", from, to, got, want)
+			}
+		}
+	}
+
+	tests := []struct {
+		name     string
+		from, to string
+	}{
+		{"unknown from", "lost", OrderStatusConfirmed},
+		{"unknown to", OrderStatusPending, "lost"},
+		{"empty", "", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if CanTransitionOrderStatus(tt.from, tt.to) {
+				t.Errorf("CanTransitionOrderStatus(%q, %q) = true, want false", tt.from, tt.to)
+			}
+		})
+	}
+}
+
+func TestNextOrderStatuses(t *testing.T) {
+	tests := []struct {
+		from string
+		want []string
+	}{
+		{OrderStatusPending, []string{OrderStatusConfirmed, OrderStatusCancelled}},
+		{OrderStatusShipped, []string{OrderStatusDelivered}},
+		{OrderStatusCancelled, nil},
+		{"lost", nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.from, func(t *testing.T) {
+			got := NextOrderStatuses(tt.from)
+			if len(got) != len(tt.want) {
+				t.Fatalf("NextOrderStatuses(%q) = %v, want %v", tt.from, got, tt.want)
+			}
+			for i := range got {
+				if got[i] != tt.want[i] {
+					t.Errorf("NextOrderStatuses(%q) = %v, want %v", tt.from, got, tt.want)
+				}
+			}
+		})
+	}
+
+	// The result is a copy the caller may change
+	next := NextOrderStatuses(OrderStatusPending)
+	next[0] = OrderStatusRefunded
+	if CanTransitionOrderStatus(OrderStatusPending, OrderStatusRefunded) {
+		t.Error("changing the result of NextOrderStatuses changed the transition table")
+	}
+}
+
+func TestIsValidOrderStatus(t *testing.T) {
+	tests := []struct {
+		status string
+		want   bool
+	}{
+		{OrderStatusPending, true},
+		{OrderStatusRefunded, true},
+		{"", false},
+		{"Pending", false},
+		{"lost", false},
+	}
+	for _, tt := range tests {
+		if got := IsValidOrderStatus(tt.status); got != tt.want {
+			t.Errorf("IsValidOrderStatus(%q) = %v, want %v", tt.status, got, tt.want)
+		}
+	}
+}
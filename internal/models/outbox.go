@@ -0,0 +1,50 @@
+package models
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/be-bcv/ecommerce-backend/pkg/messages"
+	"github.com/google/uuid"
+)
+
+// OutboxEvent is an event waiting to be published to RabbitMQ. It is written
+// in the same transaction as the change it describes and drained by the
+// outbox relay, so events are never lost between commit and publish.
+type OutboxEvent struct {
+	ID            uuid.UUID  `gorm:"type:uuid;primary_key" json:"id"`
+	Exchange      string     `gorm:"not null" json:"exchange"`
+	EventName     string     `gorm:"not null" json:"event_name"`
+	Payload       string     `gorm:"type:jsonb;not null" json:"payload"` // the encoded messages.EventMessage
+	Attempts      int        `gorm:"not null;default:0" json:"attempts"`
+	LastError     string     `json:"last_error"`
+	NextAttemptAt time.Time  `gorm:"index" json:"next_attempt_at"`
+	SentAt        *time.Time `gorm:"index" json:"sent_at"`
+	CreatedAt     time.Time  `json:"created_at"`
+}
+
+// NewOutboxEvent encodes event for publishing to exchange. The outbox row
+// shares the event's ID, which consumers see as the AMQP message-id.
+func NewOutboxEvent(exchange string, event messages.EventMessage) (*OutboxEvent, error) {
+	payload, err := json.Marshal(event)
+	if err != nil {
+		return nil, err
+	}
+
+	id, err := uuid.Parse(event.EventID)
+	if err != nil {
+		return nil, err
+	}
+
+	return &OutboxEvent{
+		ID:            id,
+		Exchange:      exchange,
+		EventName:     event.EventName,
+		Payload:       string(payload),
+		NextAttemptAt: event.Timestamp,
+	}, nil
+}
+
+func (OutboxEvent) TableName() string {
+	return "outbox_events"
+}
@@ -0,0 +1,32 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type Payment struct {
+	ID            uuid.UUID  `gorm:"type:uuid;primary_key;default:gen_random_uuid()" json:"id"`
+	OrderID       uuid.UUID  `gorm:"type:uuid;not null;index" json:"order_id"`
+	OrderNumber   string     `json:"order_number"`
+	UserID        uuid.UUID  `gorm:"type:uuid;not null" json:"user_id"`
+	Amount        float64    `gorm:"not null" json:"amount"`
+	Method        string     `gorm:"not null" json:"method"`        // snap, credit_card, bank_transfer, e_wallet; empty until the customer pays
+	Status        string     `gorm:"default:pending" json:"status"` // see payment_status.go
+	MidtransID    string     `gorm:"index" json:"midtrans_id"`
+	MidtransVA    string     `json:"midtrans_va"`
+	SnapToken     string     `json:"snap_token"`
+	PaymentURL    string     `json:"payment_url"`
+	ExpiredAt     time.Time  `json:"expired_at"`
+	PaidAt        *time.Time `json:"paid_at"`
+	TransactionID string     `json:"transaction_id"`
+	CreatedAt     time.Time  `json:"created_at"`
+	UpdatedAt     time.Time  `json:"updated_at"`
+
+	Refunds []Refund `gorm:"foreignKey:PaymentID" json:"refunds,omitempty"`
+}
+
+func (Payment) TableName() string {
+	return "payments"
+}
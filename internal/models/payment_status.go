@@ -0,0 +1,10 @@
+package models
+
+const (
+	PaymentStatusPending   = "pending"
+	PaymentStatusPaid      = "paid"
+	PaymentStatusFailed    = "failed"
+	PaymentStatusCancelled = "cancelled"
+	PaymentStatusExpired   = "expired"
+	PaymentStatusRefunded  = "refunded"
+)
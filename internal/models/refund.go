@@ -0,0 +1,39 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const (
+	RefundStatusPending   = "pending"
+	RefundStatusSucceeded = "succeeded"
+	RefundStatusFailed    = "failed"
+)
+
+// Refund gives a buyer back what they paid for the items of an approved
+// return, or everything left of the payment of an order cancelled after it
+// was paid, through Midtrans on the order's payment. A payment may be
+// refunded several times, once per return, up to its amount.
+//
+// RefundKey makes a refund idempotent and is also the Midtrans refund key:
+// the return ID for returns, and the payment ID for cancelled orders.
+type Refund struct {
+	ID            uuid.UUID  `gorm:"type:uuid;primary_key;default:gen_random_uuid()" json:"id"`
+	PaymentID     uuid.UUID  `gorm:"type:uuid;not null;index" json:"payment_id"`
+	OrderID       uuid.UUID  `gorm:"type:uuid;not null;index" json:"order_id"`
+	ReturnID      *uuid.UUID `gorm:"type:uuid;index" json:"return_id"` // nil for cancelled orders
+	RefundKey     string     `gorm:"not null;uniqueIndex" json:"refund_key"`
+	Amount        float64    `gorm:"not null" json:"amount"`
+	Reason        string     `json:"reason"`
+	Status        string     `gorm:"not null;default:pending" json:"status"`
+	FailureReason string     `json:"failure_reason,omitempty"`
+	RefundedAt    *time.Time `json:"refunded_at"`
+	CreatedAt     time.Time  `json:"created_at"`
+	UpdatedAt     time.Time  `json:"updated_at"`
+}
+
+func (Refund) TableName() string {
+	return "refunds"
+}
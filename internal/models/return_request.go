@@ -0,0 +1,76 @@
+package models
+
+import (
+	"errors"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const (
+	ReturnStatusRequested    = "requested"
+	ReturnStatusApproved     = "approved"
+	ReturnStatusRejected     = "rejected"
+	ReturnStatusRefunded     = "refunded"
+	ReturnStatusRefundFailed = "refund_failed"
+)
+
+// OpenReturnStatuses are the statuses of a return that has not been settled
+// yet. An order has at most one open return at a time.
+var OpenReturnStatuses = []string{ReturnStatusRequested, ReturnStatusApproved, ReturnStatusRefundFailed}
+
+var (
+	// ErrReturnNotPending is returned when a return is not in a status the
+	// requested change can be made from, e.g. approving it twice.
+	ErrReturnNotPending = errors.New("return is not awaiting this change")
+	// ErrReturnInProgress is returned when opening a return on an order that
+	// already has an open one.
+	ErrReturnInProgress = errors.New("order already has a return in progress")
+	// ErrReturnQuantityExceeded is returned when a return asks for more of an
+	// item than was ordered and not returned yet.
+	ErrReturnQuantityExceeded = errors.New("return quantity exceeds the quantity left to return")
+)
+
+// ReturnRequest is a buyer's request to send back items of one seller's
+// delivered fulfillment. Approving it restocks the items, refunds the buyer
+// through payment-service and moves the order to returned, then refunded.
+type ReturnRequest struct {
+	ID                  uuid.UUID  `gorm:"type:uuid;primary_key;default:gen_random_uuid()" json:"id"`
+	OrderID             uuid.UUID  `gorm:"type:uuid;not null;index" json:"order_id"`
+	FulfillmentID       uuid.UUID  `gorm:"type:uuid;not null;index" json:"fulfillment_id"`
+	UserID              uuid.UUID  `gorm:"type:uuid;not null;index" json:"user_id"`
+	SellerID            uuid.UUID  `gorm:"type:uuid;not null;index" json:"seller_id"`
+	Status              string     `gorm:"not null;default:requested;index" json:"status"`
+	Reason              string     `gorm:"not null" json:"reason"`
+	Photos              []string   `gorm:"type:text[]" json:"photos"`
+	RefundAmount        float64    `gorm:"not null" json:"refund_amount"` // the returned items' subtotal; shipping is not refunded
+	ReviewedBy          *uuid.UUID `gorm:"type:uuid" json:"reviewed_by,omitempty"`
+	ReviewedByRole      string     `json:"reviewed_by_role,omitempty"` // seller or admin
+	ReviewedAt          *time.Time `json:"reviewed_at"`
+	RejectionReason     string     `json:"rejection_reason,omitempty"`
+	RefundedAt          *time.Time `json:"refunded_at"`
+	RefundFailureReason string     `json:"refund_failure_reason,omitempty"`
+	CreatedAt           time.Time  `json:"created_at"`
+	UpdatedAt           time.Time  `json:"updated_at"`
+
+	Items []ReturnItem `gorm:"foreignKey:ReturnID" json:"items,omitempty"`
+}
+
+type ReturnItem struct {
+	ID          uuid.UUID `gorm:"type:uuid;primary_key;default:gen_random_uuid()" json:"id"`
+	ReturnID    uuid.UUID `gorm:"type:uuid;not null;index" json:"return_id"`
+	OrderItemID uuid.UUID `gorm:"type:uuid;not null;index" json:"order_item_id"`
+	ProductID   uuid.UUID `gorm:"type:uuid;not null" json:"product_id"`
+	ProductName string    `json:"product_name"`
+	Quantity    int       `gorm:"not null" json:"quantity"`
+	Amount      float64   `gorm:"not null" json:"amount"` // unit price times quantity
+	CreatedAt   time.Time `json:"created_at"`
+}
+
+func (ReturnRequest) TableName() string {
+	return "return_requests"
+}
+
+func (ReturnItem) TableName() string {
+	return "return_items"
+}
@@ -0,0 +1,46 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const (
+	StockReservationReserved = "reserved"
+	StockReservationReleased = "released"
+	StockReservationRejected = "rejected"
+)
+
+// StockReservation records the stock an order took from a product, so the
+// same order is never reserved twice and a cancellation gives back exactly
+// what was taken. Rejected orders get a single row for the product that
+// could not be reserved.
+type StockReservation struct {
+	ID        uuid.UUID `gorm:"type:uuid;primary_key;default:gen_random_uuid()" json:"id"`
+	OrderID   uuid.UUID `gorm:"type:uuid;not null;uniqueIndex:idx_stock_reservation_order_product" json:"order_id"`
+	ProductID uuid.UUID `gorm:"type:uuid;not null;uniqueIndex:idx_stock_reservation_order_product" json:"product_id"`
+	Quantity  int       `gorm:"not null" json:"quantity"`
+	Status    string    `gorm:"not null" json:"status"` // reserved, released or rejected
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+func (StockReservation) TableName() string {
+	return "stock_reservations"
+}
+
+// StockRestock records the stock an approved return put back into a
+// product, so a redelivered return is restocked only once.
+type StockRestock struct {
+	ID        uuid.UUID `gorm:"type:uuid;primary_key;default:gen_random_uuid()" json:"id"`
+	ReturnID  uuid.UUID `gorm:"type:uuid;not null;uniqueIndex:idx_stock_restock_return_product" json:"return_id"`
+	OrderID   uuid.UUID `gorm:"type:uuid;not null;index" json:"order_id"`
+	ProductID uuid.UUID `gorm:"type:uuid;not null;uniqueIndex:idx_stock_restock_return_product" json:"product_id"`
+	Quantity  int       `gorm:"not null" json:"quantity"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+func (StockRestock) TableName() string {
+	return "stock_restocks"
+}
@@ -0,0 +1,92 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+const (
+	StoreStatusPending  = "pending"
+	StoreStatusApproved = "approved"
+	StoreStatusRejected = "rejected"
+)
+
+const (
+	BankChangeStatusPending  = "pending"
+	BankChangeStatusApproved = "approved"
+	BankChangeStatusRejected = "rejected"
+)
+
+// Store is a user's seller profile, owned by user-service. It is created by
+// applying to become a seller and stays pending until an admin approves it,
+// which makes the user a seller. A rejected application may be resubmitted.
+type Store struct {
+	ID                uuid.UUID      `gorm:"type:uuid;primary_key;default:gen_random_uuid()" json:"id"`
+	UserID            uuid.UUID      `gorm:"type:uuid;not null;uniqueIndex" json:"user_id"`
+	Name              string         `gorm:"not null" json:"name"`
+	Slug              string         `gorm:"not null;uniqueIndex" json:"slug"`
+	Description       string         `json:"description"`
+	LogoURL           string         `json:"logo_url"`
+	Address           string         `gorm:"not null" json:"address"`
+	BankName          string         `gorm:"not null" json:"bank_name"`
+	BankAccountNumber string         `gorm:"not null" json:"bank_account_number"`
+	BankAccountName   string         `gorm:"not null" json:"bank_account_name"`
+	Status            string         `gorm:"not null;index" json:"status"` // pending, approved or rejected
+	RejectionReason   string         `json:"rejection_reason,omitempty"`
+	ReviewedBy        *uuid.UUID     `gorm:"type:uuid" json:"reviewed_by,omitempty"`
+	ReviewedAt        *time.Time     `json:"reviewed_at,omitempty"`
+	CreatedAt         time.Time      `json:"created_at"`
+	UpdatedAt         time.Time      `json:"updated_at"`
+	DeletedAt         gorm.DeletedAt `gorm:"index" json:"-"`
+
+	User User `gorm:"foreignKey:UserID" json:"user,omitempty"`
+	// PendingBankChange is the bank account change awaiting review, if any
+	PendingBankChange *StoreBankChange `gorm:"-" json:"pending_bank_change,omitempty"`
+}
+
+// StoreBankChange is a seller's request to change the payout bank account of
+// an approved store. Payouts keep going to the current account until an
+// admin approves the change. The rows are kept as the record of who asked
+// for each change and who reviewed it.
+type StoreBankChange struct {
+	ID                uuid.UUID  `gorm:"type:uuid;primary_key;default:gen_random_uuid()" json:"id"`
+	StoreID           uuid.UUID  `gorm:"type:uuid;not null;index" json:"store_id"`
+	BankName          string     `gorm:"not null" json:"bank_name"`
+	BankAccountNumber string     `gorm:"not null" json:"bank_account_number"`
+	BankAccountName   string     `gorm:"not null" json:"bank_account_name"`
+	Status            string     `gorm:"not null;index" json:"status"` // pending, approved or rejected
+	RejectionReason   string     `json:"rejection_reason,omitempty"`
+	RequestedBy       uuid.UUID  `gorm:"type:uuid;not null" json:"requested_by"`
+	ReviewedBy        *uuid.UUID `gorm:"type:uuid" json:"reviewed_by,omitempty"`
+	ReviewedAt        *time.Time `json:"reviewed_at,omitempty"`
+	CreatedAt         time.Time  `json:"created_at"` // when the change was requested
+	UpdatedAt         time.Time  `json:"updated_at"`
+}
+
+// Storefront is product-service's public copy of an approved store, kept up
+// to date from store events. It leaves out the store's bank details.
+type Storefront struct {
+	ID          uuid.UUID `gorm:"type:uuid;primary_key" json:"id"`
+	SellerID    uuid.UUID `gorm:"type:uuid;not null;uniqueIndex" json:"seller_id"`
+	Name        string    `gorm:"not null" json:"name"`
+	Slug        string    `gorm:"not null;uniqueIndex" json:"slug"`
+	Description string    `json:"description"`
+	LogoURL     string    `json:"logo_url"`
+	Address     string    `json:"address"`
+	CreatedAt   time.Time `json:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at"` // when the store last changed in user-service
+}
+
+func (Store) TableName() string {
+	return "stores"
+}
+
+func (StoreBankChange) TableName() string {
+	return "store_bank_changes"
+}
+
+func (Storefront) TableName() string {
+	return "storefronts"
+}
@@ -21,13 +21,20 @@ type User struct {
 	DeletedAt gorm.DeletedAt `gorm:"index" json:"-"`
 }
 
+// UserSession is a device the user is signed in on. It holds the hash of
+// the session's current refresh token, and the access tokens issued for it
+// carry its ID, so deleting the session signs the device out.
 type UserSession struct {
-	ID        uuid.UUID `gorm:"type:uuid;primary_key;default:gen_random_uuid()" json:"id"`
-	UserID    uuid.UUID `gorm:"type:uuid;not null" json:"user_id"`
-	Token     string    `gorm:"uniqueIndex;not null" json:"token"`
-	ExpiresAt time.Time `gorm:"not null" json:"expires_at"`
-	CreatedAt time.Time `json:"created_at"`
-	User      User      `gorm:"foreignKey:UserID" json:"user,omitempty"`
+	ID         uuid.UUID `gorm:"type:uuid;primary_key;default:gen_random_uuid()" json:"id"`
+	UserID     uuid.UUID `gorm:"type:uuid;not null;index" json:"user_id"`
+	Token      string    `gorm:"uniqueIndex;not null" json:"-"` // SHA-256 of the refresh token
+	Device     string    `json:"device"`
+	IPAddress  string    `json:"ip_address"`
+	UserAgent  string    `json:"user_agent"`
+	LastUsedAt time.Time `json:"last_used_at"`
+	ExpiresAt  time.Time `gorm:"not null" json:"expires_at"`
+	CreatedAt  time.Time `json:"created_at"`
+	User       User      `gorm:"foreignKey:UserID" json:"-"`
 }
 
 func (User) TableName() string {
@@ -0,0 +1,67 @@
+package repository
+
+import (
+	"errors"
+	"time"
+
+	"github.com/be-bcv/ecommerce-backend/internal/models"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+type CheckoutSagaRepository struct {
+	db *gorm.DB
+}
+
+func NewCheckoutSagaRepository(db *gorm.DB) *CheckoutSagaRepository {
+	return &CheckoutSagaRepository{db: db}
+}
+
+func (r *CheckoutSagaRepository) GetByOrderID(orderID uuid.UUID) (*models.CheckoutSaga, error) {
+	var saga models.CheckoutSaga
+	err := r.db.Where("order_id = ?", orderID).First(&saga).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &saga, nil
+}
+
+// Transition saves saga only if it is still in fromState, enqueueing events
+// in the same transaction, and reports whether it did. A concurrent handler
+// that moved the saga first makes it return false.
+func (r *CheckoutSagaRepository) Transition(saga *models.CheckoutSaga, fromState string, events ...*models.OutboxEvent) (bool, error) {
+	updated := false
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		result := tx.Model(&models.CheckoutSaga{}).
+			Where("id = ? AND state = ?", saga.ID, fromState).
+			Updates(map[string]interface{}{
+				"state":          saga.State,
+				"payment_id":     saga.PaymentID,
+				"failure_reason": saga.FailureReason,
+				"deadline":       saga.Deadline,
+			})
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return nil
+		}
+		updated = true
+		return enqueueOutbox(tx, events)
+	})
+	return updated && err == nil, err
+}
+
+// GetTimedOut returns up to limit active sagas whose current step is past its
+// deadline, oldest deadline first.
+func (r *CheckoutSagaRepository) GetTimedOut(now time.Time, limit int) ([]models.CheckoutSaga, error) {
+	var sagas []models.CheckoutSaga
+	err := r.db.Where("state IN ? AND deadline < ?", models.ActiveSagaStates, now).
+		Order("deadline").
+		Limit(limit).
+		Find(&sagas).Error
+	return sagas, err
+}
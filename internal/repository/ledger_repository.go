@@ -0,0 +1,540 @@
+package repository
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"time"
+
+	"github.com/be-bcv/ecommerce-backend/internal/models"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// ErrUnbalancedTransaction is returned when a ledger posting's debits and
+// credits differ.
+var ErrUnbalancedTransaction = errors.New("ledger transaction is not balanced")
+
+// payoutBatchLock serializes payout batch creation, so one available balance
+// is never put into two batches.
+const payoutBatchLock = 7301
+
+type LedgerRepository struct {
+	db *gorm.DB
+}
+
+func NewLedgerRepository(db *gorm.DB) *LedgerRepository {
+	return &LedgerRepository{db: db}
+}
+
+// SellerBalance is a seller's balance on one ledger account.
+type SellerBalance struct {
+	SellerID uuid.UUID
+	Amount   float64
+}
+
+// postTransaction writes a balanced ledger transaction with its entries.
+// It returns false without writing anything if a transaction with the same
+// reference was already posted.
+func postTransaction(tx *gorm.DB, txn *models.LedgerTransaction) (bool, error) {
+	var debits, credits float64
+	for _, entry := range txn.Entries {
+		debits += entry.Debit
+		credits += entry.Credit
+	}
+	if math.Abs(debits-credits) >= 0.005 {
+		return false, fmt.Errorf("%w: %s debits %.2f, credits %.2f", ErrUnbalancedTransaction, txn.Reference, debits, credits)
+	}
+
+	result := tx.Omit(clause.Associations).Clauses(clause.OnConflict{DoNothing: true}).Create(txn)
+	if result.Error != nil {
+		return false, result.Error
+	}
+	if result.RowsAffected == 0 {
+		return false, nil
+	}
+
+	for i := range txn.Entries {
+		txn.Entries[i].TransactionID = txn.ID
+	}
+	if err := tx.Create(&txn.Entries).Error; err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// RecordSales stores the earnings and posts a sale transaction for each:
+// the customer's money is split between the seller's held balance and the
+// platform's commission. Earnings already recorded for their order item are
+// skipped, so redelivered events are harmless.
+func (r *LedgerRepository) RecordSales(earnings []*models.SellerEarning) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		for _, earning := range earnings {
+			result := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(earning)
+			if result.Error != nil {
+				return result.Error
+			}
+			if result.RowsAffected == 0 {
+				continue
+			}
+
+			sellerID := earning.SellerID
+			entries := []models.LedgerEntry{
+				{ID: uuid.New(), Account: models.LedgerAccountCustomerFunds, Debit: earning.Gross},
+				{ID: uuid.New(), Account: models.LedgerAccountSellerHeld, SellerID: &sellerID, Credit: earning.Net},
+			}
+			if earning.Commission > 0 {
+				entries = append(entries, models.LedgerEntry{ID: uuid.New(), Account: models.LedgerAccountCommission, Credit: earning.Commission})
+			}
+
+			if _, err := postTransaction(tx, &models.LedgerTransaction{
+				ID:          uuid.New(),
+				Type:        models.LedgerTransactionSale,
+				Reference:   "sale:" + earning.OrderItemID.String(),
+				Description: fmt.Sprintf("Order %s, %d x product %s", earning.OrderNumber, earning.Quantity, earning.ProductID),
+				Entries:     entries,
+			}); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// ReleaseDue moves up to limit held earnings whose return window ended
+// before now to their sellers' available balances, and returns how many it
+// released. Earnings locked by another instance are skipped.
+func (r *LedgerRepository) ReleaseDue(now time.Time, limit int) (int, error) {
+	released := 0
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		var earnings []models.SellerEarning
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE", Options: "SKIP LOCKED"}).
+			Where("status = ? AND available_at <= ?", models.SellerEarningHeld, now).
+			Order("available_at").
+			Limit(limit).
+			Find(&earnings).Error; err != nil {
+			return err
+		}
+
+		for i := range earnings {
+			earning := &earnings[i]
+			if err := tx.Model(earning).Updates(map[string]interface{}{
+				"status":      models.SellerEarningAvailable,
+				"released_at": &now,
+			}).Error; err != nil {
+				return err
+			}
+
+			// Only what refunds left of the earning is released
+			amount := math.Round((earning.Net-earning.RefundedNet)*100) / 100
+			if amount <= 0 {
+				continue
+			}
+			sellerID := earning.SellerID
+			if _, err := postTransaction(tx, &models.LedgerTransaction{
+				ID:          uuid.New(),
+				Type:        models.LedgerTransactionRelease,
+				Reference:   "release:" + earning.ID.String(),
+				Description: fmt.Sprintf("Return window ended for order %s", earning.OrderNumber),
+				Entries: []models.LedgerEntry{
+					{ID: uuid.New(), Account: models.LedgerAccountSellerHeld, SellerID: &sellerID, Debit: amount},
+					{ID: uuid.New(), Account: models.LedgerAccountSellerAvailable, SellerID: &sellerID, Credit: amount},
+				},
+			}); err != nil {
+				return err
+			}
+		}
+
+		released = len(earnings)
+		return nil
+	})
+	return released, err
+}
+
+// RefundedItem is the quantity of an order item a refund gave back.
+type RefundedItem struct {
+	OrderItemID uuid.UUID
+	Quantity    int
+}
+
+// RecordRefund takes back from the sellers' earnings what a refunded return
+// paid for: the returned share of each item's subtotal leaves the platform's
+// funds, and is taken from the seller's held balance, or available balance
+// if already released, and from the commission. The last units of an item
+// take whatever is left, so rounding never leaves cents behind. Items
+// without an earning, i.e. not settled through the ledger, are skipped, and
+// items already recorded for the return are not taken twice.
+func (r *LedgerRepository) RecordRefund(returnID uuid.UUID, items []RefundedItem) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		for _, item := range items {
+			var earning models.SellerEarning
+			err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("order_item_id = ?", item.OrderItemID).First(&earning).Error
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				continue
+			}
+			if err != nil {
+				return err
+			}
+
+			quantity := item.Quantity
+			remaining := earning.Quantity - earning.ReturnedQuantity
+			if quantity > remaining {
+				quantity = remaining
+			}
+			if quantity <= 0 {
+				continue
+			}
+
+			var gross, net float64
+			if quantity == remaining {
+				gross = math.Round((earning.Gross-earning.RefundedGross)*100) / 100
+				net = math.Round((earning.Net-earning.RefundedNet)*100) / 100
+			} else {
+				share := float64(quantity) / float64(earning.Quantity)
+				gross = math.Round(earning.Gross*share*100) / 100
+				net = math.Round(earning.Net*share*100) / 100
+			}
+			commission := math.Round((gross-net)*100) / 100
+
+			sellerAccount := models.LedgerAccountSellerHeld
+			if earning.Status == models.SellerEarningAvailable {
+				sellerAccount = models.LedgerAccountSellerAvailable
+			}
+			sellerID := earning.SellerID
+			entries := []models.LedgerEntry{
+				{ID: uuid.New(), Account: models.LedgerAccountCustomerFunds, Credit: gross},
+				{ID: uuid.New(), Account: sellerAccount, SellerID: &sellerID, Debit: net},
+			}
+			if commission != 0 {
+				entries = append(entries, models.LedgerEntry{ID: uuid.New(), Account: models.LedgerAccountCommission, Debit: commission})
+			}
+
+			posted, err := postTransaction(tx, &models.LedgerTransaction{
+				ID:          uuid.New(),
+				Type:        models.LedgerTransactionRefund,
+				Reference:   "refund:" + returnID.String() + ":" + item.OrderItemID.String(),
+				Description: fmt.Sprintf("Refunded return of %d x product %s from order %s", quantity, earning.ProductID, earning.OrderNumber),
+				Entries:     entries,
+			})
+			if err != nil {
+				return err
+			}
+			if !posted {
+				continue
+			}
+
+			if err := tx.Model(&earning).Updates(map[string]interface{}{
+				"returned_quantity": gorm.Expr("returned_quantity + ?", quantity),
+				"refunded_gross":    gorm.Expr("refunded_gross + ?", gross),
+				"refunded_net":      gorm.Expr("refunded_net + ?", net),
+			}).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// GetSellerBalances returns the seller's balance on each seller account,
+// i.e. credits less debits, keyed by account.
+func (r *LedgerRepository) GetSellerBalances(sellerID uuid.UUID) (map[string]float64, error) {
+	var rows []struct {
+		Account string
+		Balance float64
+	}
+	err := r.db.Model(&models.LedgerEntry{}).
+		Select("account, COALESCE(SUM(credit - debit), 0) AS balance").
+		Where("seller_id = ?", sellerID).
+		Group("account").
+		Scan(&rows).Error
+	if err != nil {
+		return nil, err
+	}
+
+	balances := make(map[string]float64, len(rows))
+	for _, row := range rows {
+		balances[row.Account] = row.Balance
+	}
+	return balances, nil
+}
+
+// GetPaidOutTotal returns how much has been transferred to the seller.
+func (r *LedgerRepository) GetPaidOutTotal(sellerID uuid.UUID) (float64, error) {
+	var total float64
+	err := r.db.Model(&models.Payout{}).
+		Select("COALESCE(SUM(amount), 0)").
+		Where("seller_id = ? AND status = ?", sellerID, models.PayoutPaid).
+		Scan(&total).Error
+	return total, err
+}
+
+// GetSellerEntries returns the seller's ledger entries with their
+// transactions, newest first.
+func (r *LedgerRepository) GetSellerEntries(sellerID uuid.UUID, page, limit int) ([]models.LedgerEntry, int64, error) {
+	var entries []models.LedgerEntry
+	var total int64
+
+	query := r.db.Model(&models.LedgerEntry{}).
+		Where("seller_id = ?", sellerID)
+
+	// Count total
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+
+	// Pagination
+	offset := (page - 1) * limit
+	err := query.Preload("Transaction").
+		Offset(offset).Limit(limit).Order("created_at desc").Find(&entries).Error
+
+	return entries, total, err
+}
+
+// CreatePayoutBatch puts every positive available seller balance into a new
+// pending batch, one payout per seller, and moves the amounts from the
+// sellers' available balances to payouts in transit. It returns nil if no
+// seller has anything to be paid.
+func (r *LedgerRepository) CreatePayoutBatch(createdBy uuid.UUID) (*models.PayoutBatch, error) {
+	var batch *models.PayoutBatch
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Exec("SELECT pg_advisory_xact_lock(?)", payoutBatchLock).Error; err != nil {
+			return err
+		}
+
+		var balances []SellerBalance
+		if err := tx.Model(&models.LedgerEntry{}).
+			Select("seller_id, SUM(credit - debit) AS amount").
+			Where("account = ?", models.LedgerAccountSellerAvailable).
+			Group("seller_id").
+			Having("SUM(credit - debit) >= 0.01").
+			Order("seller_id").
+			Scan(&balances).Error; err != nil {
+			return err
+		}
+		if len(balances) == 0 {
+			return nil
+		}
+
+		// Payouts go to the bank account each seller has now
+		sellerIDs := make([]uuid.UUID, 0, len(balances))
+		for _, balance := range balances {
+			sellerIDs = append(sellerIDs, balance.SellerID)
+		}
+		var accounts []models.SellerBankAccount
+		if err := tx.Where("seller_id IN ?", sellerIDs).Find(&accounts).Error; err != nil {
+			return err
+		}
+		bankAccounts := make(map[uuid.UUID]models.SellerBankAccount, len(accounts))
+		for _, account := range accounts {
+			bankAccounts[account.SellerID] = account
+		}
+
+		payouts := make([]models.Payout, 0, len(balances))
+		batchID := uuid.New()
+		var totalAmount float64
+		for _, balance := range balances {
+			// Sellers without a known bank account keep their balance for
+			// a later batch
+			account, ok := bankAccounts[balance.SellerID]
+			if !ok {
+				continue
+			}
+			amount := math.Round(balance.Amount*100) / 100
+			totalAmount += amount
+			payouts = append(payouts, models.Payout{
+				ID:                uuid.New(),
+				BatchID:           batchID,
+				SellerID:          balance.SellerID,
+				Amount:            amount,
+				BankName:          account.BankName,
+				BankAccountNumber: account.BankAccountNumber,
+				BankAccountName:   account.BankAccountName,
+				Status:            models.PayoutPending,
+			})
+		}
+		if len(payouts) == 0 {
+			return nil
+		}
+
+		batch = &models.PayoutBatch{
+			ID:          batchID,
+			Status:      models.PayoutBatchPending,
+			TotalAmount: totalAmount,
+			PayoutCount: len(payouts),
+			CreatedBy:   createdBy,
+			Payouts:     payouts,
+		}
+
+		if err := tx.Omit(clause.Associations).Create(batch).Error; err != nil {
+			return err
+		}
+		for i := range batch.Payouts {
+			payout := &batch.Payouts[i]
+			if err := tx.Create(payout).Error; err != nil {
+				return err
+			}
+
+			sellerID := payout.SellerID
+			if _, err := postTransaction(tx, &models.LedgerTransaction{
+				ID:          uuid.New(),
+				Type:        models.LedgerTransactionPayout,
+				Reference:   "payout:" + payout.ID.String(),
+				Description: fmt.Sprintf("Payout batch %s", batch.ID),
+				Entries: []models.LedgerEntry{
+					{ID: uuid.New(), Account: models.LedgerAccountSellerAvailable, SellerID: &sellerID, Debit: payout.Amount},
+					{ID: uuid.New(), Account: models.LedgerAccountPayoutsInTransit, Credit: payout.Amount},
+				},
+			}); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return batch, nil
+}
+
+// UpsertSellerBankAccount inserts the seller's bank account or overwrites
+// the stored one, unless the stored one is newer, so redelivered or
+// reordered events are harmless.
+func (r *LedgerRepository) UpsertSellerBankAccount(account *models.SellerBankAccount) error {
+	return r.db.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "seller_id"}},
+		DoUpdates: clause.AssignmentColumns([]string{"store_id", "bank_name", "bank_account_number", "bank_account_name", "updated_at"}),
+		Where: clause.Where{Exprs: []clause.Expression{
+			clause.Expr{SQL: "seller_bank_accounts.updated_at <= excluded.updated_at"},
+		}},
+	}).Create(account).Error
+}
+
+func (r *LedgerRepository) GetPayoutBatchByID(id uuid.UUID) (*models.PayoutBatch, error) {
+	var batch models.PayoutBatch
+	err := r.db.Preload("Payouts", func(db *gorm.DB) *gorm.DB {
+		return db.Order("seller_id")
+	}).Where("id = ?", id).First(&batch).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &batch, nil
+}
+
+func (r *LedgerRepository) GetPayoutBatches(page, limit int, status string) ([]models.PayoutBatch, int64, error) {
+	var batches []models.PayoutBatch
+	var total int64
+
+	query := r.db.Model(&models.PayoutBatch{})
+	if status != "" {
+		query = query.Where("status = ?", status)
+	}
+
+	// Count total
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+
+	// Pagination
+	offset := (page - 1) * limit
+	err := query.Offset(offset).Limit(limit).Order("created_at desc").Find(&batches).Error
+
+	return batches, total, err
+}
+
+// StartPayoutBatch moves a pending batch to processing and reports whether
+// it did.
+func (r *LedgerRepository) StartPayoutBatch(id uuid.UUID) (bool, error) {
+	now := time.Now()
+	result := r.db.Model(&models.PayoutBatch{}).
+		Where("id = ? AND status = ?", id, models.PayoutBatchPending).
+		Updates(map[string]interface{}{
+			"status":       models.PayoutBatchProcessing,
+			"processed_at": &now,
+		})
+	return result.RowsAffected > 0, result.Error
+}
+
+// SettlePayout marks a pending payout of a processing batch paid or failed.
+// A paid payout leaves the platform's funds; a failed one goes back to the
+// seller's available balance. The batch completes with its last payout.
+// It returns nil if there is no such payout.
+func (r *LedgerRepository) SettlePayout(payoutID uuid.UUID, status, reference, failureReason string) (*models.Payout, error) {
+	var payout models.Payout
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("id = ?", payoutID).First(&payout).Error; err != nil {
+			return err
+		}
+
+		var batch models.PayoutBatch
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("id = ?", payout.BatchID).First(&batch).Error; err != nil {
+			return err
+		}
+		if payout.Status != models.PayoutPending || batch.Status != models.PayoutBatchProcessing {
+			return models.ErrPayoutNotPending
+		}
+
+		now := time.Now()
+		updates := map[string]interface{}{
+			"status":    status,
+			"reference": reference,
+		}
+		sellerID := payout.SellerID
+		txn := &models.LedgerTransaction{
+			ID:        uuid.New(),
+			Reference: status + ":" + payout.ID.String(),
+		}
+		switch status {
+		case models.PayoutPaid:
+			updates["paid_at"] = &now
+			txn.Type = models.LedgerTransactionPayoutPaid
+			txn.Description = fmt.Sprintf("Paid out in batch %s", batch.ID)
+			txn.Entries = []models.LedgerEntry{
+				{ID: uuid.New(), Account: models.LedgerAccountPayoutsInTransit, Debit: payout.Amount},
+				{ID: uuid.New(), Account: models.LedgerAccountCustomerFunds, Credit: payout.Amount},
+			}
+		case models.PayoutFailed:
+			updates["failure_reason"] = failureReason
+			txn.Type = models.LedgerTransactionPayoutFailed
+			txn.Description = fmt.Sprintf("Payout in batch %s failed: %s", batch.ID, failureReason)
+			txn.Entries = []models.LedgerEntry{
+				{ID: uuid.New(), Account: models.LedgerAccountPayoutsInTransit, Debit: payout.Amount},
+				{ID: uuid.New(), Account: models.LedgerAccountSellerAvailable, SellerID: &sellerID, Credit: payout.Amount},
+			}
+		default:
+			return fmt.Errorf("unknown payout status %s", status)
+		}
+
+		if err := tx.Model(&payout).Updates(updates).Error; err != nil {
+			return err
+		}
+		if _, err := postTransaction(tx, txn); err != nil {
+			return err
+		}
+
+		// Complete the batch once none of its payouts is pending
+		var pending int64
+		if err := tx.Model(&models.Payout{}).
+			Where("batch_id = ? AND status = ?", batch.ID, models.PayoutPending).
+			Count(&pending).Error; err != nil {
+			return err
+		}
+		if pending == 0 {
+			return tx.Model(&batch).Updates(map[string]interface{}{
+				"status":       models.PayoutBatchCompleted,
+				"completed_at": &now,
+			}).Error
+		}
+		return nil
+	})
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &payout, nil
+}
@@ -2,10 +2,13 @@ package repository
 
 import (
 	"errors"
+	"fmt"
+	"time"
 
 	"github.com/be-bcv/ecommerce-backend/internal/models"
 	"github.com/google/uuid"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
 type CartRepository struct {
@@ -35,7 +38,7 @@ func (r *CartRepository) AddToCart(cart *models.Cart) error {
 
 func (r *CartRepository) GetCart(userID uuid.UUID) ([]models.Cart, error) {
 	var cartItems []models.Cart
-	err := r.db.Preload("Product").Where("user_id = ?", userID).Find(&cartItems).Error
+	err := r.db.Where("user_id = ?", userID).Find(&cartItems).Error
 	return cartItems, err
 }
 
@@ -55,7 +58,7 @@ func (r *CartRepository) ClearCart(userID uuid.UUID) error {
 
 func (r *CartRepository) GetCartItemByID(cartID uuid.UUID) (*models.Cart, error) {
 	var cart models.Cart
-	err := r.db.Preload("Product").Where("id = ?", cartID).First(&cart).Error
+	err := r.db.Where("id = ?", cartID).First(&cart).Error
 	if err != nil {
 		if errors.Is(err, gorm.ErrRecordNotFound) {
 			return nil, nil
@@ -73,33 +76,132 @@ func NewOrderRepository(db *gorm.DB) *OrderRepository {
 	return &OrderRepository{db: db}
 }
 
-func (r *OrderRepository) CreateOrder(order *models.Order) error {
+// ErrCartChanged is returned by Checkout when the cart was modified after it
+// was priced, so the caller can re-read it instead of ordering stale items.
+var ErrCartChanged = errors.New("cart was modified during checkout")
+
+// CreateOrder writes the order, its items, the initial status history, the
+// checkout saga and events in one transaction.
+func (r *OrderRepository) CreateOrder(order *models.Order, saga *models.CheckoutSaga, events ...*models.OutboxEvent) error {
 	return r.db.Transaction(func(tx *gorm.DB) error {
-		// Create order
-		if err := tx.Create(order).Error; err != nil {
+		if err := createOrder(tx, order); err != nil {
+			return err
+		}
+		if err := tx.Create(saga).Error; err != nil {
 			return err
 		}
+		return enqueueOutbox(tx, events)
+	})
+}
 
-		// Create order status history
-		history := &models.OrderStatusHistory{
-			ID:        uuid.New(),
-			OrderID:   order.ID,
-			ToStatus:  order.Status,
-			Notes:     "Order created",
-			CreatedBy: order.UserID,
+// Checkout converts the given cart rows into the order, starts its checkout
+// saga and removes the rows from the cart in the same transaction. The user's
+// cart is locked and compared against cartItems first, so a concurrent cart
+// change aborts the checkout.
+func (r *OrderRepository) Checkout(order *models.Order, cartItems []models.Cart, saga *models.CheckoutSaga, events ...*models.OutboxEvent) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		var current []models.Cart
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("user_id = ?", order.UserID).
+			Find(&current).Error; err != nil {
+			return err
+		}
+
+		if len(current) != len(cartItems) {
+			return ErrCartChanged
+		}
+		quantities := make(map[uuid.UUID]int, len(current))
+		for _, item := range current {
+			quantities[item.ID] = item.Quantity
 		}
-		return tx.Create(history).Error
+		cartIDs := make([]uuid.UUID, 0, len(cartItems))
+		for _, item := range cartItems {
+			if quantity, ok := quantities[item.ID]; !ok || quantity != item.Quantity {
+				return ErrCartChanged
+			}
+			cartIDs = append(cartIDs, item.ID)
+		}
+
+		if err := createOrder(tx, order); err != nil {
+			return err
+		}
+		if err := tx.Create(saga).Error; err != nil {
+			return err
+		}
+
+		if err := tx.Where("id IN ?", cartIDs).Delete(&models.Cart{}).Error; err != nil {
+			return err
+		}
+
+		return enqueueOutbox(tx, events)
 	})
 }
 
+func createOrder(tx *gorm.DB, order *models.Order) error {
+	// Create order
+	if err := tx.Omit(clause.Associations).Create(order).Error; err != nil {
+		return err
+	}
+
+	// Create one fulfillment per seller, each with its own status history
+	for i := range order.Fulfillments {
+		fulfillment := &order.Fulfillments[i]
+		fulfillment.OrderID = order.ID
+		if err := tx.Omit(clause.Associations).Create(fulfillment).Error; err != nil {
+			return err
+		}
+		if err := tx.Create(&models.OrderStatusHistory{
+			ID:            uuid.New(),
+			OrderID:       order.ID,
+			FulfillmentID: &fulfillment.ID,
+			ToStatus:      fulfillment.Status,
+			Notes:         "Order created",
+			CreatedBy:     order.UserID,
+			CreatedByRole: "user",
+		}).Error; err != nil {
+			return err
+		}
+	}
+
+	// Create order items, each holding its stock until the order is paid
+	for i := range order.Items {
+		order.Items[i].OrderID = order.ID
+		if err := tx.Omit(clause.Associations).Create(&order.Items[i]).Error; err != nil {
+			return err
+		}
+
+		hold := &models.InventoryHold{
+			ID:          uuid.New(),
+			OrderID:     order.ID,
+			OrderItemID: order.Items[i].ID,
+			ProductID:   order.Items[i].ProductID,
+			Quantity:    order.Items[i].Quantity,
+			Status:      models.InventoryHoldHeld,
+		}
+		if err := tx.Create(hold).Error; err != nil {
+			return err
+		}
+	}
+
+	// Create order status history
+	history := &models.OrderStatusHistory{
+		ID:            uuid.New(),
+		OrderID:       order.ID,
+		ToStatus:      order.Status,
+		Notes:         "Order created",
+		CreatedBy:     order.UserID,
+		CreatedByRole: "user",
+	}
+	return tx.Create(history).Error
+}
+
 func (r *OrderRepository) CreateOrderItem(item *models.OrderItem) error {
 	return r.db.Create(item).Error
 }
 
 func (r *OrderRepository) GetOrderByID(orderID uuid.UUID, userID uuid.UUID) (*models.Order, error) {
 	var order models.Order
-	err := r.db.Preload("Items.Product").
-		Preload("Payment").
+	err := r.db.Preload("Items").Preload("Fulfillments").
 		Where("id = ? AND user_id = ?", orderID, userID).
 		First(&order).Error
 	if err != nil {
@@ -113,8 +215,7 @@ func (r *OrderRepository) GetOrderByID(orderID uuid.UUID, userID uuid.UUID) (*mo
 
 func (r *OrderRepository) GetOrderByIDForAdmin(orderID uuid.UUID) (*models.Order, error) {
 	var order models.Order
-	err := r.db.Preload("Items.Product").
-		Preload("Payment").
+	err := r.db.Preload("Items").Preload("Fulfillments").
 		Where("id = ?", orderID).
 		First(&order).Error
 	if err != nil {
@@ -131,8 +232,7 @@ func (r *OrderRepository) GetUserOrders(userID uuid.UUID, page, limit int) ([]mo
 	var total int64
 
 	query := r.db.Model(&models.Order{}).
-		Preload("Items.Product").
-		Preload("Payment").
+		Preload("Items").
 		Where("user_id = ?", userID)
 
 	// Count total
@@ -152,8 +252,7 @@ func (r *OrderRepository) GetAllOrders(page, limit int, status string) ([]models
 	var total int64
 
 	query := r.db.Model(&models.Order{}).
-		Preload("Items.Product").
-		Preload("Payment")
+		Preload("Items")
 
 	if status != "" {
 		query = query.Where("status = ?", status)
@@ -171,115 +270,281 @@ func (r *OrderRepository) GetAllOrders(page, limit int, status string) ([]models
 	return orders, total, err
 }
 
-func (r *OrderRepository) UpdateOrderStatus(orderID uuid.UUID, status string, notes string, updatedBy uuid.UUID) error {
+// UpdateOrderStatus moves the order to a new status if the order status state
+// machine allows it, stamps the shipping/delivery dates, settles the order's
+// inventory holds and records who made the change in the status history.
+// The order's fulfillments move along with it; the transition is refused if
+// one of them cannot, e.g. cancelling an order part of which has shipped.
+// events are enqueued only if the transition happens.
+func (r *OrderRepository) UpdateOrderStatus(orderID uuid.UUID, status string, notes string, updatedBy uuid.UUID, updatedByRole string, events ...*models.OutboxEvent) error {
 	return r.db.Transaction(func(tx *gorm.DB) error {
-		// Get current order
+		// Get current order, locked so concurrent transitions are serialized
 		var order models.Order
-		if err := tx.Where("id = ?", orderID).First(&order).Error; err != nil {
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("id = ?", orderID).First(&order).Error; err != nil {
 			return err
 		}
 
+		if !models.CanTransitionOrderStatus(order.Status, status) {
+			return fmt.Errorf("%w: %s -> %s", models.ErrInvalidOrderStatusTransition, order.Status, status)
+		}
+
+		updates := map[string]interface{}{
+			"status": status,
+		}
+		now := time.Now()
+		switch status {
+		case models.OrderStatusShipped:
+			updates["shipping_date"] = &now
+		case models.OrderStatusDelivered:
+			updates["delivery_date"] = &now
+		}
+
 		// Update order status
-		if err := tx.Model(&order).Update("status", status).Error; err != nil {
+		if err := tx.Model(&order).Updates(updates).Error; err != nil {
 			return err
 		}
 
 		// Create status history
 		history := &models.OrderStatusHistory{
-			ID:        uuid.New(),
-			OrderID:   orderID,
-			FromStatus: order.Status,
-			ToStatus:  status,
-			Notes:     notes,
-			CreatedBy: updatedBy,
-		}
-		return tx.Create(history).Error
+			ID:            uuid.New(),
+			OrderID:       orderID,
+			FromStatus:    order.Status,
+			ToStatus:      status,
+			Notes:         notes,
+			CreatedBy:     updatedBy,
+			CreatedByRole: updatedByRole,
+		}
+		if err := tx.Create(history).Error; err != nil {
+			return err
+		}
+
+		if err := moveFulfillments(tx, orderID, status, notes, updatedBy, updatedByRole); err != nil {
+			return err
+		}
+
+		// Cancelled orders give their held stock back; confirmed ones keep it
+		holdStatus := ""
+		switch status {
+		case models.OrderStatusCancelled:
+			holdStatus = models.InventoryHoldReleased
+		case models.OrderStatusConfirmed:
+			holdStatus = models.InventoryHoldConverted
+		}
+		if holdStatus != "" {
+			if err := tx.Model(&models.InventoryHold{}).
+				Where("order_id = ? AND status = ?", orderID, models.InventoryHoldHeld).
+				Update("status", holdStatus).Error; err != nil {
+				return err
+			}
+		}
+
+		return enqueueOutbox(tx, events)
 	})
 }
 
-func (r *OrderRepository) UpdatePaymentStatus(orderID uuid.UUID, paymentID uuid.UUID, paymentStatus string) error {
-	return r.db.Model(&models.Order{}).
-		Where("id = ?", orderID).
-		Updates(map[string]interface{}{
-			"payment_id":     paymentID,
-			"payment_status": paymentStatus,
-		}).Error
-}
+// moveFulfillments moves every fulfillment of the order that is not already
+// in status, or cancelled, to status along with the order.
+func moveFulfillments(tx *gorm.DB, orderID uuid.UUID, status, notes string, updatedBy uuid.UUID, updatedByRole string) error {
+	var fulfillments []models.Fulfillment
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+		Where("order_id = ? AND status NOT IN ?", orderID, []string{status, models.OrderStatusCancelled}).
+		Find(&fulfillments).Error; err != nil {
+		return err
+	}
 
-func (r *OrderRepository) GetOrderStatusHistories(orderID uuid.UUID) ([]models.OrderStatusHistory, error) {
-	var histories []models.OrderStatusHistory
-	err := r.db.Where("order_id = ?", orderID).Order("created_at desc").Find(&histories).Error
-	return histories, err
+	for i := range fulfillments {
+		if !models.CanTransitionOrderStatus(fulfillments[i].Status, status) {
+			return fmt.Errorf("%w: fulfillment %s is %s", models.ErrInvalidOrderStatusTransition, fulfillments[i].ID, fulfillments[i].Status)
+		}
+		if err := updateFulfillmentStatus(tx, &fulfillments[i], status, notes, updatedBy, updatedByRole); err != nil {
+			return err
+		}
+	}
+	return nil
 }
 
-func (r *OrderRepository) UpdateOrder(order *models.Order) error {
-	return r.db.Save(order).Error
-}
+// updateFulfillmentStatus saves the fulfillment in its new status, stamps the
+// shipping/delivery dates and records the change in its status history.
+func updateFulfillmentStatus(tx *gorm.DB, fulfillment *models.Fulfillment, status, notes string, updatedBy uuid.UUID, updatedByRole string) error {
+	fromStatus := fulfillment.Status
+	updates := map[string]interface{}{
+		"status":          status,
+		"courier":         fulfillment.Courier,
+		"tracking_number": fulfillment.TrackingNumber,
+	}
+	now := time.Now()
+	switch status {
+	case models.OrderStatusShipped:
+		updates["shipping_date"] = &now
+	case models.OrderStatusDelivered:
+		updates["delivery_date"] = &now
+	}
+	if err := tx.Model(fulfillment).Updates(updates).Error; err != nil {
+		return err
+	}
 
-type PaymentRepository struct {
-	db *gorm.DB
+	return tx.Create(&models.OrderStatusHistory{
+		ID:            uuid.New(),
+		OrderID:       fulfillment.OrderID,
+		FulfillmentID: &fulfillment.ID,
+		FromStatus:    fromStatus,
+		ToStatus:      status,
+		Notes:         notes,
+		CreatedBy:     updatedBy,
+		CreatedByRole: updatedByRole,
+	}).Error
 }
 
-func NewPaymentRepository(db *gorm.DB) *PaymentRepository {
-	return &PaymentRepository{db: db}
+// FulfillmentUpdate is a seller's change to one fulfillment.
+type FulfillmentUpdate struct {
+	FulfillmentID  uuid.UUID
+	Status         string
+	Courier        string
+	TrackingNumber string
+	Notes          string
+	UpdatedBy      uuid.UUID
+	UpdatedByRole  string
 }
 
-func (r *PaymentRepository) CreatePayment(payment *models.Payment) error {
-	return r.db.Create(payment).Error
-}
+// UpdateFulfillmentStatus moves a fulfillment to a new status if the order
+// status state machine allows it. Once every fulfillment that is not
+// cancelled has shipped, or been delivered, the order follows. events is
+// called with the updated fulfillment and the order's new status, or "" if
+// the order stays as it is, and its events are enqueued in the same
+// transaction. The fulfillment is passed with its items and order.
+func (r *OrderRepository) UpdateFulfillmentStatus(update *FulfillmentUpdate, events func(fulfillment *models.Fulfillment, orderStatus string) ([]*models.OutboxEvent, error)) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		var fulfillment models.Fulfillment
+		if err := tx.Preload("Items").Where("id = ?", update.FulfillmentID).First(&fulfillment).Error; err != nil {
+			return err
+		}
 
-func (r *PaymentRepository) GetPaymentByID(paymentID uuid.UUID) (*models.Payment, error) {
-	var payment models.Payment
-	err := r.db.Where("id = ?", paymentID).First(&payment).Error
-	if err != nil {
-		if errors.Is(err, gorm.ErrRecordNotFound) {
-			return nil, nil
+		// Lock the order first, in the same order as UpdateOrderStatus
+		var order models.Order
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("id = ?", fulfillment.OrderID).First(&order).Error; err != nil {
+			return err
 		}
-		return nil, err
-	}
-	return &payment, nil
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("id = ?", fulfillment.ID).First(&fulfillment).Error; err != nil {
+			return err
+		}
+
+		if !models.CanTransitionOrderStatus(fulfillment.Status, update.Status) {
+			return fmt.Errorf("%w: %s -> %s", models.ErrInvalidOrderStatusTransition, fulfillment.Status, update.Status)
+		}
+
+		if update.Courier != "" {
+			fulfillment.Courier = update.Courier
+		}
+		if update.TrackingNumber != "" {
+			fulfillment.TrackingNumber = update.TrackingNumber
+		}
+		if err := updateFulfillmentStatus(tx, &fulfillment, update.Status, update.Notes, update.UpdatedBy, update.UpdatedByRole); err != nil {
+			return err
+		}
+		fulfillment.Status = update.Status
+		fulfillment.Order = &order
+
+		orderStatus, err := rollUpFulfillments(tx, &order, update.UpdatedBy, update.UpdatedByRole)
+		if err != nil {
+			return err
+		}
+
+		outboxEvents, err := events(&fulfillment, orderStatus)
+		if err != nil {
+			return err
+		}
+		return enqueueOutbox(tx, outboxEvents)
+	})
 }
 
-func (r *PaymentRepository) GetPaymentByOrderID(orderID uuid.UUID) (*models.Payment, error) {
-	var payment models.Payment
-	err := r.db.Where("order_id = ?", orderID).First(&payment).Error
-	if err != nil {
-		if errors.Is(err, gorm.ErrRecordNotFound) {
-			return nil, nil
+// rollUpFulfillments moves a confirmed order to shipped once all of its live
+// fulfillments have shipped, and a shipped order to delivered once all have
+// been delivered. It returns the order's new status, or "" if it stays.
+func rollUpFulfillments(tx *gorm.DB, order *models.Order, updatedBy uuid.UUID, updatedByRole string) (string, error) {
+	var statuses []string
+	if err := tx.Model(&models.Fulfillment{}).
+		Where("order_id = ? AND status <> ?", order.ID, models.OrderStatusCancelled).
+		Pluck("status", &statuses).Error; err != nil {
+		return "", err
+	}
+
+	shipped, delivered := len(statuses) > 0, len(statuses) > 0
+	for _, status := range statuses {
+		if status != models.OrderStatusShipped && status != models.OrderStatusDelivered {
+			shipped = false
+		}
+		if status != models.OrderStatusDelivered {
+			delivered = false
 		}
-		return nil, err
 	}
-	return &payment, nil
-}
 
-func (r *PaymentRepository) UpdatePaymentStatus(paymentID uuid.UUID, status string, transactionID string) error {
+	var status, notes string
+	switch {
+	case order.Status == models.OrderStatusConfirmed && shipped:
+		status, notes = models.OrderStatusShipped, "All items shipped"
+	case order.Status == models.OrderStatusShipped && delivered:
+		status, notes = models.OrderStatusDelivered, "All items delivered"
+	default:
+		return "", nil
+	}
+
 	updates := map[string]interface{}{
 		"status": status,
 	}
-
-	if transactionID != "" {
-		updates["transaction_id"] = transactionID
+	now := time.Now()
+	switch status {
+	case models.OrderStatusShipped:
+		updates["shipping_date"] = &now
+	case models.OrderStatusDelivered:
+		updates["delivery_date"] = &now
 	}
-
-	if status == "paid" {
-		now := time.Now()
-		updates["paid_at"] = &now
+	if err := tx.Model(order).Updates(updates).Error; err != nil {
+		return "", err
 	}
 
-	return r.db.Model(&models.Payment{}).
-		Where("id = ?", paymentID).
-		Updates(updates).Error
+	history := &models.OrderStatusHistory{
+		ID:            uuid.New(),
+		OrderID:       order.ID,
+		FromStatus:    order.Status,
+		ToStatus:      status,
+		Notes:         notes,
+		CreatedBy:     updatedBy,
+		CreatedByRole: updatedByRole,
+	}
+	if err := tx.Create(history).Error; err != nil {
+		return "", err
+	}
+	return status, nil
 }
 
-func (r *PaymentRepository) UpdatePayment(payment *models.Payment) error {
-	return r.db.Save(payment).Error
+// GetSellerFulfillment returns the seller's fulfillment with its items and
+// order, or nil if the seller has no such fulfillment.
+func (r *OrderRepository) GetSellerFulfillment(fulfillmentID, sellerID uuid.UUID) (*models.Fulfillment, error) {
+	var fulfillment models.Fulfillment
+	err := r.db.Preload("Items").Preload("Order").
+		Where("id = ? AND seller_id = ?", fulfillmentID, sellerID).
+		First(&fulfillment).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &fulfillment, nil
 }
 
-func (r *PaymentRepository) GetPaymentsByUserID(userID uuid.UUID, page, limit int) ([]models.Payment, int64, error) {
-	var payments []models.Payment
+// GetSellerFulfillments lists the seller's fulfillments, optionally filtered
+// by status, newest first.
+func (r *OrderRepository) GetSellerFulfillments(sellerID uuid.UUID, page, limit int, status string) ([]models.Fulfillment, int64, error) {
+	var fulfillments []models.Fulfillment
 	var total int64
 
-	query := r.db.Model(&models.Payment{}).Where("user_id = ?", userID)
+	query := r.db.Model(&models.Fulfillment{}).
+		Where("seller_id = ?", sellerID)
+
+	if status != "" {
+		query = query.Where("status = ?", status)
+	}
 
 	// Count total
 	if err := query.Count(&total).Error; err != nil {
@@ -288,7 +553,55 @@ func (r *PaymentRepository) GetPaymentsByUserID(userID uuid.UUID, page, limit in
 
 	// Pagination
 	offset := (page - 1) * limit
-	err := query.Offset(offset).Limit(limit).Order("created_at desc").Find(&payments).Error
+	err := query.Preload("Items").Preload("Order").
+		Offset(offset).Limit(limit).Order("created_at desc").Find(&fulfillments).Error
+
+	return fulfillments, total, err
+}
+
+// GetFulfillmentStatusHistories returns the fulfillment's status history,
+// newest first.
+func (r *OrderRepository) GetFulfillmentStatusHistories(fulfillmentID uuid.UUID) ([]models.OrderStatusHistory, error) {
+	var histories []models.OrderStatusHistory
+	err := r.db.Where("fulfillment_id = ?", fulfillmentID).Order("created_at desc").Find(&histories).Error
+	return histories, err
+}
+
+// SetInventoryHoldExpiry makes the order's held stock expire at expiresAt,
+// the expiry of its current payment.
+func (r *OrderRepository) SetInventoryHoldExpiry(orderID uuid.UUID, expiresAt time.Time) error {
+	return r.db.Model(&models.InventoryHold{}).
+		Where("order_id = ? AND status = ?", orderID, models.InventoryHoldHeld).
+		Update("expires_at", expiresAt).Error
+}
+
+// GetOrdersWithExpiredHolds returns the IDs of up to limit orders that still
+// hold stock past its expiry.
+func (r *OrderRepository) GetOrdersWithExpiredHolds(now time.Time, limit int) ([]uuid.UUID, error) {
+	var orderIDs []uuid.UUID
+	err := r.db.Model(&models.InventoryHold{}).
+		Distinct("order_id").
+		Where("status = ? AND expires_at < ?", models.InventoryHoldHeld, now).
+		Limit(limit).
+		Pluck("order_id", &orderIDs).Error
+	return orderIDs, err
+}
+
+func (r *OrderRepository) UpdatePaymentStatus(orderID uuid.UUID, paymentID uuid.UUID, paymentStatus string) error {
+	return r.db.Model(&models.Order{}).
+		Where("id = ?", orderID).
+		Updates(map[string]interface{}{
+			"payment_id":     paymentID,
+			"payment_status": paymentStatus,
+		}).Error
+}
+
+func (r *OrderRepository) GetOrderStatusHistories(orderID uuid.UUID) ([]models.OrderStatusHistory, error) {
+	var histories []models.OrderStatusHistory
+	err := r.db.Where("order_id = ? AND fulfillment_id IS NULL", orderID).Order("created_at desc").Find(&histories).Error
+	return histories, err
+}
 
-	return payments, total, err
-}
\ No newline at end of file
+func (r *OrderRepository) UpdateOrder(order *models.Order) error {
+	return r.db.Save(order).Error
+}
@@ -0,0 +1,93 @@
+package repository
+
+import (
+	"time"
+
+	"github.com/be-bcv/ecommerce-backend/internal/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type OutboxRepository struct {
+	db *gorm.DB
+}
+
+func NewOutboxRepository(db *gorm.DB) *OutboxRepository {
+	return &OutboxRepository{db: db}
+}
+
+// enqueueOutbox writes events to the outbox as part of tx.
+func enqueueOutbox(tx *gorm.DB, events []*models.OutboxEvent) error {
+	if len(events) == 0 {
+		return nil
+	}
+	return tx.Create(events).Error
+}
+
+// withOutbox runs write and enqueues events in one transaction, so the events
+// are stored if and only if the change they describe is committed.
+func withOutbox(db *gorm.DB, events []*models.OutboxEvent, write func(tx *gorm.DB) error) error {
+	if len(events) == 0 {
+		return write(db)
+	}
+	return db.Transaction(func(tx *gorm.DB) error {
+		if err := write(tx); err != nil {
+			return err
+		}
+		return enqueueOutbox(tx, events)
+	})
+}
+
+// ProcessDue locks up to limit unsent events that are due, oldest first, and
+// hands them to publish. Rows locked by another relay are skipped. Published
+// events are marked sent; on the first failure the event is rescheduled after
+// retryDelay and the rest of the batch is left for the next run. It returns
+// the number of events published.
+func (r *OutboxRepository) ProcessDue(limit int, publish func(event *models.OutboxEvent) error, retryDelay func(attempts int) time.Duration) (int, error) {
+	sent := 0
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		var events []models.OutboxEvent
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE", Options: "SKIP LOCKED"}).
+			Where("sent_at IS NULL AND next_attempt_at <= ?", time.Now()).
+			Order("created_at").
+			Limit(limit).
+			Find(&events).Error; err != nil {
+			return err
+		}
+
+		for i := range events {
+			event := &events[i]
+			if err := publish(event); err != nil {
+				attempts := event.Attempts + 1
+				return tx.Model(event).Updates(map[string]interface{}{
+					"attempts":        attempts,
+					"last_error":      err.Error(),
+					"next_attempt_at": time.Now().Add(retryDelay(attempts)),
+				}).Error
+			}
+
+			now := time.Now()
+			if err := tx.Model(event).Updates(map[string]interface{}{
+				"attempts": event.Attempts + 1,
+				"sent_at":  &now,
+			}).Error; err != nil {
+				return err
+			}
+			sent++
+		}
+		return nil
+	})
+	return sent, err
+}
+
+// DeleteSentBefore removes events that were published before t.
+func (r *OutboxRepository) DeleteSentBefore(t time.Time) (int64, error) {
+	result := r.db.Where("sent_at IS NOT NULL AND sent_at < ?", t).Delete(&models.OutboxEvent{})
+	return result.RowsAffected, result.Error
+}
+
+// Enqueue writes events to the outbox on their own, for events that do not
+// accompany another change.
+func (r *OutboxRepository) Enqueue(events ...*models.OutboxEvent) error {
+	return enqueueOutbox(r.db, events)
+}
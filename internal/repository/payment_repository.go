@@ -0,0 +1,233 @@
+package repository
+
+import (
+	"errors"
+	"time"
+
+	"github.com/be-bcv/ecommerce-backend/internal/models"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+type PaymentRepository struct {
+	db *gorm.DB
+}
+
+func NewPaymentRepository(db *gorm.DB) *PaymentRepository {
+	return &PaymentRepository{db: db}
+}
+
+func (r *PaymentRepository) CreatePayment(payment *models.Payment, events ...*models.OutboxEvent) error {
+	return withOutbox(r.db, events, func(tx *gorm.DB) error {
+		return tx.Create(payment).Error
+	})
+}
+
+// MigrateRefundKeys moves refunds created before refund_key existed, when
+// return_id held the key, to the refund_key column and clears return_id of
+// cancelled-order refunds. It runs before AutoMigrate, which neither fills
+// the new NOT NULL column nor drops NOT NULL from return_id.
+func (r *PaymentRepository) MigrateRefundKeys() error {
+	migrator := r.db.Migrator()
+	if !migrator.HasTable(&models.Refund{}) || migrator.HasColumn(&models.Refund{}, "refund_key") {
+		return nil
+	}
+
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		for _, stmt := range []string{
+			"ALTER TABLE refunds ADD COLUMN refund_key text",
+			"UPDATE refunds SET refund_key = return_id::text",
+			"ALTER TABLE refunds ALTER COLUMN return_id DROP NOT NULL",
+			"UPDATE refunds SET return_id = NULL WHERE return_id = payment_id",
+		} {
+			if err := tx.Exec(stmt).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+func (r *PaymentRepository) GetPaymentByID(paymentID uuid.UUID) (*models.Payment, error) {
+	var payment models.Payment
+	err := r.db.Where("id = ?", paymentID).First(&payment).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &payment, nil
+}
+
+func (r *PaymentRepository) GetPaymentByOrderID(orderID uuid.UUID) (*models.Payment, error) {
+	var payment models.Payment
+	err := r.db.Where("order_id = ?", orderID).Order("created_at desc").First(&payment).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &payment, nil
+}
+
+func (r *PaymentRepository) GetPaymentByMidtransID(midtransID string) (*models.Payment, error) {
+	var payment models.Payment
+	err := r.db.Where("midtrans_id = ?", midtransID).First(&payment).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &payment, nil
+}
+
+// TransitionPaymentStatus moves a payment from one status to another only if
+// it is still in fromStatus, and reports whether it did. events are enqueued
+// only if the payment moved.
+func (r *PaymentRepository) TransitionPaymentStatus(paymentID uuid.UUID, fromStatus, toStatus string, transactionID string, events ...*models.OutboxEvent) (bool, error) {
+	updates := map[string]interface{}{
+		"status": toStatus,
+	}
+
+	if transactionID != "" {
+		updates["transaction_id"] = transactionID
+	}
+
+	if toStatus == models.PaymentStatusPaid {
+		now := time.Now()
+		updates["paid_at"] = &now
+	}
+
+	updated := false
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		result := tx.Model(&models.Payment{}).
+			Where("id = ? AND status = ?", paymentID, fromStatus).
+			Updates(updates)
+		if result.Error != nil {
+			return result.Error
+		}
+		updated = result.RowsAffected > 0
+		if !updated {
+			return nil
+		}
+		return enqueueOutbox(tx, events)
+	})
+	return updated, err
+}
+
+func (r *PaymentRepository) UpdatePaymentStatus(paymentID uuid.UUID, status string, transactionID string) error {
+	updates := map[string]interface{}{
+		"status": status,
+	}
+
+	if transactionID != "" {
+		updates["transaction_id"] = transactionID
+	}
+
+	if status == "paid" {
+		now := time.Now()
+		updates["paid_at"] = &now
+	}
+
+	return r.db.Model(&models.Payment{}).
+		Where("id = ?", paymentID).
+		Updates(updates).Error
+}
+
+func (r *PaymentRepository) UpdatePayment(payment *models.Payment, events ...*models.OutboxEvent) error {
+	return withOutbox(r.db, events, func(tx *gorm.DB) error {
+		return tx.Save(payment).Error
+	})
+}
+
+func (r *PaymentRepository) GetPaymentsByUserID(userID uuid.UUID, page, limit int) ([]models.Payment, int64, error) {
+	var payments []models.Payment
+	var total int64
+
+	query := r.db.Model(&models.Payment{}).Where("user_id = ?", userID)
+
+	// Count total
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+
+	// Pagination
+	offset := (page - 1) * limit
+	err := query.Offset(offset).Limit(limit).Order("created_at desc").Find(&payments).Error
+
+	return payments, total, err
+}
+
+// GetSettledPaymentByOrderID returns the order's payment that was paid, and
+// possibly refunded since.
+func (r *PaymentRepository) GetSettledPaymentByOrderID(orderID uuid.UUID) (*models.Payment, error) {
+	var payment models.Payment
+	err := r.db.Where("order_id = ? AND status IN ?", orderID, []string{models.PaymentStatusPaid, models.PaymentStatusRefunded}).
+		Order("created_at desc").
+		First(&payment).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &payment, nil
+}
+
+func (r *PaymentRepository) CreateRefund(refund *models.Refund) error {
+	return r.db.Create(refund).Error
+}
+
+func (r *PaymentRepository) GetRefundByKey(refundKey string) (*models.Refund, error) {
+	var refund models.Refund
+	err := r.db.Where("refund_key = ?", refundKey).First(&refund).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &refund, nil
+}
+
+// GetRefundedTotal returns how much of the payment has been refunded.
+func (r *PaymentRepository) GetRefundedTotal(paymentID uuid.UUID) (float64, error) {
+	var total float64
+	err := r.db.Model(&models.Refund{}).
+		Select("COALESCE(SUM(amount), 0)").
+		Where("payment_id = ? AND status = ?", paymentID, models.RefundStatusSucceeded).
+		Scan(&total).Error
+	return total, err
+}
+
+// CompleteRefund marks the refund succeeded and its payment refunded.
+func (r *PaymentRepository) CompleteRefund(refund *models.Refund) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		now := time.Now()
+		if err := tx.Model(refund).Updates(map[string]interface{}{
+			"status":         models.RefundStatusSucceeded,
+			"failure_reason": "",
+			"refunded_at":    &now,
+		}).Error; err != nil {
+			return err
+		}
+
+		return tx.Model(&models.Payment{}).
+			Where("id = ?", refund.PaymentID).
+			Update("status", models.PaymentStatusRefunded).Error
+	})
+}
+
+func (r *PaymentRepository) UpdateRefundStatus(refundID uuid.UUID, status, failureReason string, events ...*models.OutboxEvent) error {
+	return withOutbox(r.db, events, func(tx *gorm.DB) error {
+		return tx.Model(&models.Refund{}).
+			Where("id = ?", refundID).
+			Updates(map[string]interface{}{
+				"status":         status,
+				"failure_reason": failureReason,
+			}).Error
+	})
+}
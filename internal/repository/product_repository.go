@@ -2,10 +2,13 @@ package repository
 
 import (
 	"errors"
+	"fmt"
+	"sort"
 
 	"github.com/be-bcv/ecommerce-backend/internal/models"
 	"github.com/google/uuid"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
 type CategoryRepository struct {
@@ -54,8 +57,11 @@ func NewProductRepository(db *gorm.DB) *ProductRepository {
 	return &ProductRepository{db: db}
 }
 
-func (r *ProductRepository) Create(product *models.Product) error {
-	return r.db.Create(product).Error
+// Create inserts the product and enqueues events in the same transaction.
+func (r *ProductRepository) Create(product *models.Product, events ...*models.OutboxEvent) error {
+	return withOutbox(r.db, events, func(tx *gorm.DB) error {
+		return tx.Create(product).Error
+	})
 }
 
 func (r *ProductRepository) GetByID(id uuid.UUID) (*models.Product, error) {
@@ -179,21 +185,322 @@ func (r *ProductRepository) GetBySeller(sellerID uuid.UUID, page, limit int) ([]
 	return products, total, err
 }
 
-func (r *ProductRepository) Update(product *models.Product) error {
-	return r.db.Save(product).Error
+// GetActiveBySeller returns the seller's products that are on sale.
+func (r *ProductRepository) GetActiveBySeller(sellerID uuid.UUID, page, limit int) ([]models.Product, int64, error) {
+	var products []models.Product
+	var total int64
+
+	query := r.db.Model(&models.Product{}).
+		Preload("Category").
+		Where("seller_id = ? AND is_active = ?", sellerID, true)
+
+	// Count total
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+
+	// Pagination
+	offset := (page - 1) * limit
+	err := query.Order("created_at DESC").Offset(offset).Limit(limit).Find(&products).Error
+
+	return products, total, err
+}
+
+// Update sets the given columns on the product and moves its stock by
+// stockDelta in one transaction. Only the named columns are written, and the
+// stock change is applied relative to the current row (never below zero), so
+// a reservation committed since the product was read is kept. The events
+// built from the updated product and its stock change are enqueued in the
+// same transaction. Returns nil if the product does not exist.
+func (r *ProductRepository) Update(productID uuid.UUID, updates map[string]interface{}, stockDelta int, events func(product *models.Product, change StockChange) ([]*models.OutboxEvent, error)) (*models.Product, error) {
+	var product models.Product
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("id = ?", productID).
+			First(&product).Error; err != nil {
+			return err
+		}
+		oldStock := product.Stock
+
+		columns := make(map[string]interface{}, len(updates)+1)
+		for column, value := range updates {
+			columns[column] = value
+		}
+		if stockDelta != 0 {
+			columns["stock"] = gorm.Expr("GREATEST(stock + ?, 0)", stockDelta)
+		}
+		if len(columns) > 0 {
+			if err := tx.Model(&models.Product{}).Where("id = ?", productID).Updates(columns).Error; err != nil {
+				return err
+			}
+			product = models.Product{}
+			if err := tx.Where("id = ?", productID).First(&product).Error; err != nil {
+				return err
+			}
+		}
+
+		outboxEvents, err := events(&product, StockChange{
+			ProductID: productID,
+			OldStock:  oldStock,
+			NewStock:  product.Stock,
+		})
+		if err != nil {
+			return err
+		}
+		return enqueueOutbox(tx, outboxEvents)
+	})
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &product, nil
+}
+
+func (r *ProductRepository) Delete(id uuid.UUID, events ...*models.OutboxEvent) error {
+	return withOutbox(r.db, events, func(tx *gorm.DB) error {
+		// Soft delete
+		return tx.Model(&models.Product{}).
+			Where("id = ?", id).
+			Update("is_active", false).Error
+	})
+}
+
+// StockChange is the effect of a reservation or release on one product.
+type StockChange struct {
+	ProductID uuid.UUID
+	OldStock  int
+	NewStock  int
+}
+
+// InsufficientStockError is returned by ReserveStock when a product is
+// unavailable or has less stock than the order asks for.
+type InsufficientStockError struct {
+	ProductID uuid.UUID
+	Requested int
+	Available int
+}
+
+func (e *InsufficientStockError) Error() string {
+	return fmt.Sprintf("insufficient stock for product %s: requested %d, available %d", e.ProductID, e.Requested, e.Available)
+}
+
+// ReserveStock takes quantities (by product) out of stock for the order and
+// records the reservations in one transaction. Each decrement is conditional
+// on enough stock being left, so stock never goes negative; if any product
+// falls short nothing is changed and an *InsufficientStockError is returned.
+// The events built from the resulting changes are enqueued in the same
+// transaction. Orders that already have reservations are left untouched and
+// yield no changes.
+func (r *ProductRepository) ReserveStock(orderID uuid.UUID, quantities map[uuid.UUID]int, events func(changes []StockChange) ([]*models.OutboxEvent, error)) ([]StockChange, error) {
+	// Lock products in a fixed order so concurrent reservations cannot deadlock
+	productIDs := make([]uuid.UUID, 0, len(quantities))
+	for productID := range quantities {
+		productIDs = append(productIDs, productID)
+	}
+	sort.Slice(productIDs, func(i, j int) bool {
+		return productIDs[i].String() < productIDs[j].String()
+	})
+
+	var changes []StockChange
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		var existing int64
+		if err := tx.Model(&models.StockReservation{}).Where("order_id = ?", orderID).Count(&existing).Error; err != nil {
+			return err
+		}
+		if existing > 0 {
+			return nil
+		}
+
+		for _, productID := range productIDs {
+			quantity := quantities[productID]
+
+			var product models.Product
+			result := tx.Model(&product).
+				Clauses(clause.Returning{Columns: []clause.Column{{Name: "stock"}}}).
+				Where("id = ? AND is_active = ? AND stock >= ?", productID, true, quantity).
+				Update("stock", gorm.Expr("stock - ?", quantity))
+			if result.Error != nil {
+				return result.Error
+			}
+			if result.RowsAffected == 0 {
+				return r.insufficientStock(tx, productID, quantity)
+			}
+
+			changes = append(changes, StockChange{
+				ProductID: productID,
+				OldStock:  product.Stock + quantity,
+				NewStock:  product.Stock,
+			})
+			if err := tx.Create(&models.StockReservation{
+				ID:        uuid.New(),
+				OrderID:   orderID,
+				ProductID: productID,
+				Quantity:  quantity,
+				Status:    models.StockReservationReserved,
+			}).Error; err != nil {
+				return err
+			}
+		}
+
+		outboxEvents, err := events(changes)
+		if err != nil {
+			return err
+		}
+		return enqueueOutbox(tx, outboxEvents)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return changes, nil
 }
 
-func (r *ProductRepository) UpdateStock(productID uuid.UUID, newStock int) error {
-	return r.db.Model(&models.Product{}).
-		Where("id = ?", productID).
-		Update("stock", newStock).Error
+// insufficientStock builds the error for a product whose conditional
+// decrement matched no row.
+func (r *ProductRepository) insufficientStock(tx *gorm.DB, productID uuid.UUID, requested int) error {
+	var product models.Product
+	err := tx.Where("id = ? AND is_active = ?", productID, true).First(&product).Error
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		return err
+	}
+	return &InsufficientStockError{
+		ProductID: productID,
+		Requested: requested,
+		Available: product.Stock,
+	}
 }
 
-func (r *ProductRepository) Delete(id uuid.UUID) error {
-	// Soft delete
-	return r.db.Model(&models.Product{}).
-		Where("id = ?", id).
-		Update("is_active", false).Error
+// RejectStockReservation records that the order could not be reserved and
+// enqueues events in the same transaction.
+func (r *ProductRepository) RejectStockReservation(orderID uuid.UUID, productID uuid.UUID, quantity int, events ...*models.OutboxEvent) error {
+	return withOutbox(r.db, events, func(tx *gorm.DB) error {
+		return tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&models.StockReservation{
+			ID:        uuid.New(),
+			OrderID:   orderID,
+			ProductID: productID,
+			Quantity:  quantity,
+			Status:    models.StockReservationRejected,
+		}).Error
+	})
+}
+
+// ReleaseStock puts the stock held by the order's reservations back and marks
+// them released in one transaction, together with the events built from the
+// resulting changes. Releasing an order twice changes nothing.
+func (r *ProductRepository) ReleaseStock(orderID uuid.UUID, events func(changes []StockChange) ([]*models.OutboxEvent, error)) ([]StockChange, error) {
+	var changes []StockChange
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		var reservations []models.StockReservation
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("order_id = ? AND status = ?", orderID, models.StockReservationReserved).
+			Order("product_id").
+			Find(&reservations).Error; err != nil {
+			return err
+		}
+		if len(reservations) == 0 {
+			return nil
+		}
+
+		for _, reservation := range reservations {
+			var product models.Product
+			result := tx.Model(&product).
+				Clauses(clause.Returning{Columns: []clause.Column{{Name: "stock"}}}).
+				Where("id = ?", reservation.ProductID).
+				Update("stock", gorm.Expr("stock + ?", reservation.Quantity))
+			if result.Error != nil {
+				return result.Error
+			}
+			if result.RowsAffected > 0 {
+				changes = append(changes, StockChange{
+					ProductID: reservation.ProductID,
+					OldStock:  product.Stock - reservation.Quantity,
+					NewStock:  product.Stock,
+				})
+			}
+
+			if err := tx.Model(&reservation).Update("status", models.StockReservationReleased).Error; err != nil {
+				return err
+			}
+		}
+
+		outboxEvents, err := events(changes)
+		if err != nil {
+			return err
+		}
+		return enqueueOutbox(tx, outboxEvents)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return changes, nil
+}
+
+// RestockReturn puts quantities (by product) of a returned order back into
+// stock and records the restocks, enqueueing the events built from the
+// changes in the same transaction. Returns that were restocked before are
+// left untouched and yield no changes.
+func (r *ProductRepository) RestockReturn(returnID, orderID uuid.UUID, quantities map[uuid.UUID]int, events func(changes []StockChange) ([]*models.OutboxEvent, error)) ([]StockChange, error) {
+	// Lock products in a fixed order so concurrent restocks cannot deadlock
+	productIDs := make([]uuid.UUID, 0, len(quantities))
+	for productID := range quantities {
+		productIDs = append(productIDs, productID)
+	}
+	sort.Slice(productIDs, func(i, j int) bool {
+		return productIDs[i].String() < productIDs[j].String()
+	})
+
+	var changes []StockChange
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		var existing int64
+		if err := tx.Model(&models.StockRestock{}).Where("return_id = ?", returnID).Count(&existing).Error; err != nil {
+			return err
+		}
+		if existing > 0 {
+			return nil
+		}
+
+		for _, productID := range productIDs {
+			quantity := quantities[productID]
+
+			// Deleted products are restocked too, in case they come back
+			var product models.Product
+			result := tx.Model(&product).
+				Clauses(clause.Returning{Columns: []clause.Column{{Name: "stock"}}}).
+				Where("id = ?", productID).
+				Update("stock", gorm.Expr("stock + ?", quantity))
+			if result.Error != nil {
+				return result.Error
+			}
+			if result.RowsAffected > 0 {
+				changes = append(changes, StockChange{
+					ProductID: productID,
+					OldStock:  product.Stock - quantity,
+					NewStock:  product.Stock,
+				})
+			}
+
+			if err := tx.Create(&models.StockRestock{
+				ID:        uuid.New(),
+				ReturnID:  returnID,
+				OrderID:   orderID,
+				ProductID: productID,
+				Quantity:  quantity,
+			}).Error; err != nil {
+				return err
+			}
+		}
+
+		outboxEvents, err := events(changes)
+		if err != nil {
+			return err
+		}
+		return enqueueOutbox(tx, outboxEvents)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return changes, nil
 }
 
 func (r *ProductRepository) GetBySKU(sku string) (*models.Product, error) {
@@ -289,6 +596,21 @@ func (r *ProductReviewRepository) GetAverageRating(productID uuid.UUID) (float64
 	return avgRating, err
 }
 
+// GetSellerRating returns the average rating and number of reviews across
+// all of the seller's products.
+func (r *ProductReviewRepository) GetSellerRating(sellerID uuid.UUID) (float64, int64, error) {
+	var result struct {
+		Average float64
+		Count   int64
+	}
+	err := r.db.Model(&models.ProductReview{}).
+		Joins("JOIN products ON products.id = product_reviews.product_id AND products.deleted_at IS NULL").
+		Where("products.seller_id = ?", sellerID).
+		Select("COALESCE(AVG(product_reviews.rating), 0) AS average, COUNT(*) AS count").
+		Scan(&result).Error
+	return result.Average, result.Count, err
+}
+
 func (r *ProductReviewRepository) HasUserReviewed(userID, productID uuid.UUID) (bool, error) {
 	var count int64
 	err := r.db.Model(&models.ProductReview{}).
@@ -0,0 +1,170 @@
+package repository
+
+import (
+	"errors"
+	"os"
+	"testing"
+
+	"github.com/be-bcv/ecommerce-backend/internal/models"
+	"github.com/google/uuid"
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+// testDB returns a transaction on the Postgres database in TEST_DATABASE_URL
+// with tables migrated, rolled back when the test ends, or skips the test if
+// none is configured.
+func testDB(t *testing.T, tables ...interface{}) *gorm.DB {
+	t.Helper()
+	dsn := os.Getenv("TEST_DATABASE_URL")
+	if dsn == "" {
+		t.Skip("TEST_DATABASE_URL is not set")
+	}
+
+	db, err := gorm.Open(postgres.Open(dsn), &gorm.Config{
+		DisableForeignKeyConstraintWhenMigrating: true,
+		Logger:                                   logger.Default.LogMode(logger.Silent),
+	})
+	if err != nil {
+		t.Fatalf("failed to connect to test database: %v", err)
+	}
+	if err := db.AutoMigrate(tables...); err != nil {
+		t.Fatalf("failed to migrate test database: %v", err)
+	}
+
+	tx := db.Begin()
+	if tx.Error != nil {
+		t.Fatalf("failed to begin transaction: %v", tx.Error)
+	}
+	t.Cleanup(func() {
+		tx.Rollback()
+		if sqlDB, err := db.DB(); err == nil {
+			sqlDB.Close()
+		}
+	})
+	return tx
+}
+
+func TestReserveStock(t *testing.T) {
+	tests := []struct {
+		name string
+		// setup runs before the reservation under test, with the quantities
+		// it will ask for
+		setup       func(t *testing.T, r *ProductRepository, orderID uuid.UUID, quantities map[uuid.UUID]int)
+		quantityA   int
+		wantChanges int
+		wantStockA  int
+		wantStockB  int
+		wantEvents  bool
+		wantErr     bool
+	}{
+		{
+			name:        "first reservation",
+			quantityA:   3,
+			wantChanges: 2,
+			wantStockA:  7,
+			wantStockB:  9,
+			wantEvents:  true,
+		},
+		{
+			name: "redelivered command",
+			setup: func(t *testing.T, r *ProductRepository, orderID uuid.UUID, quantities map[uuid.UUID]int) {
+				if _, err := r.ReserveStock(orderID, quantities, noStockEvents); err != nil {
+					t.Fatalf("first ReserveStock() error = %v", err)
+				}
+			},
+			quantityA:   3,
+			wantChanges: 0,
+			wantStockA:  7,
+			wantStockB:  9,
+		},
+		{
+			name: "order rejected before",
+			setup: func(t *testing.T, r *ProductRepository, orderID uuid.UUID, quantities map[uuid.UUID]int) {
+				for productID, quantity := range quantities {
+					if err := r.RejectStockReservation(orderID, productID, quantity); err != nil {
+						t.Fatalf("RejectStockReservation() error = %v", err)
+					}
+					break
+				}
+			},
+			quantityA:   3,
+			wantChanges: 0,
+			wantStockA:  10,
+			wantStockB:  10,
+		},
+		{
+			name:       "insufficient stock takes nothing",
+			quantityA:  11,
+			wantStockA: 10,
+			wantStockB: 10,
+			wantErr:    true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db := testDB(t, &models.Product{}, &models.StockReservation{}, &models.OutboxEvent{})
+			r := NewProductRepository(db)
+
+			products := make([]*models.Product, 2)
+			for i := range products {
+				products[i] = &models.Product{
+					ID:         uuid.New(),
+					Name:       "Test Product",
+					Price:      10000,
+					Stock:      10,
+					SKU:        "TEST-" + uuid.NewString(),
+					CategoryID: uuid.New(),
+					SellerID:   uuid.New(),
+					IsActive:   true,
+				}
+				if err := db.Create(products[i]).Error; err != nil {
+					t.Fatalf("failed to create product: %v", err)
+				}
+			}
+
+			orderID := uuid.New()
+			quantities := map[uuid.UUID]int{products[0].ID: tt.quantityA, products[1].ID: 1}
+			if tt.setup != nil {
+				tt.setup(t, r, orderID, quantities)
+			}
+
+			eventsCalled := false
+			changes, err := r.ReserveStock(orderID, quantities, func(changes []StockChange) ([]*models.OutboxEvent, error) {
+				eventsCalled = true
+				return nil, nil
+			})
+
+			var insufficient *InsufficientStockError
+			if tt.wantErr {
+				if !errors.As(err, &insufficient) || insufficient.ProductID != products[0].ID || insufficient.Available != 10 {
+					t.Errorf("ReserveStock() error = %v, want insufficient stock of product A with 10 available", err)
+				}
+			} else if err != nil {
+				t.Fatalf("ReserveStock() error = %v", err)
+			}
+			if len(changes) != tt.wantChanges {
+				t.Errorf("ReserveStock() changes = %d, want %d", len(changes), tt.wantChanges)
+			}
+			if eventsCalled != tt.wantEvents {
+				t.Errorf("events built = %v, want %v", eventsCalled, tt.wantEvents)
+			}
+
+			for i, want := range []int{tt.wantStockA, tt.wantStockB} {
+				var product models.Product
+				if err := db.First(&product, "id = ?", products[i].ID).Error; err != nil {
+					t.Fatalf("failed to load product: %v", err)
+				}
+				if product.Stock != want {
+					t.Errorf("product %d stock = %d, want %d", i, product.Stock, want)
+				}
+			}
+		})
+	}
+}
+
+func noStockEvents([]StockChange) ([]*models.OutboxEvent, error) {
+	return nil, nil
+}
@@ -0,0 +1,205 @@
+package repository
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/be-bcv/ecommerce-backend/internal/models"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type ReturnRepository struct {
+	db *gorm.DB
+}
+
+func NewReturnRepository(db *gorm.DB) *ReturnRepository {
+	return &ReturnRepository{db: db}
+}
+
+// Create stores a new return with its items. The order is locked so that
+// each order has at most one open return and no item is returned more
+// times than it was ordered, counting every return that was not rejected.
+func (r *ReturnRepository) Create(ret *models.ReturnRequest) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		var order models.Order
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("id = ?", ret.OrderID).First(&order).Error; err != nil {
+			return err
+		}
+
+		var open int64
+		if err := tx.Model(&models.ReturnRequest{}).
+			Where("order_id = ? AND status IN ?", ret.OrderID, models.OpenReturnStatuses).
+			Count(&open).Error; err != nil {
+			return err
+		}
+		if open > 0 {
+			return models.ErrReturnInProgress
+		}
+
+		var items []models.OrderItem
+		if err := tx.Where("order_id = ?", ret.OrderID).Find(&items).Error; err != nil {
+			return err
+		}
+		remaining := make(map[uuid.UUID]int, len(items))
+		for _, item := range items {
+			remaining[item.ID] = item.Quantity
+		}
+
+		var returned []struct {
+			OrderItemID uuid.UUID
+			Quantity    int
+		}
+		if err := tx.Model(&models.ReturnItem{}).
+			Select("return_items.order_item_id, SUM(return_items.quantity) AS quantity").
+			Joins("JOIN return_requests ON return_requests.id = return_items.return_id").
+			Where("return_requests.order_id = ? AND return_requests.status <> ?", ret.OrderID, models.ReturnStatusRejected).
+			Group("return_items.order_item_id").
+			Scan(&returned).Error; err != nil {
+			return err
+		}
+		for _, item := range returned {
+			remaining[item.OrderItemID] -= item.Quantity
+		}
+
+		for _, item := range ret.Items {
+			if item.Quantity > remaining[item.OrderItemID] {
+				return fmt.Errorf("%w: %s has %d left", models.ErrReturnQuantityExceeded, item.ProductName, remaining[item.OrderItemID])
+			}
+		}
+
+		return tx.Create(ret).Error
+	})
+}
+
+func (r *ReturnRepository) GetByID(id uuid.UUID) (*models.ReturnRequest, error) {
+	var ret models.ReturnRequest
+	err := r.db.Preload("Items").Where("id = ?", id).First(&ret).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &ret, nil
+}
+
+// GetAll lists returns, newest first, optionally filtered by status. Zero
+// user or seller IDs match every user or seller.
+func (r *ReturnRepository) GetAll(userID, sellerID uuid.UUID, status string, page, limit int) ([]models.ReturnRequest, int64, error) {
+	var returns []models.ReturnRequest
+	var total int64
+
+	query := r.db.Model(&models.ReturnRequest{})
+	if userID != uuid.Nil {
+		query = query.Where("user_id = ?", userID)
+	}
+	if sellerID != uuid.Nil {
+		query = query.Where("seller_id = ?", sellerID)
+	}
+	if status != "" {
+		query = query.Where("status = ?", status)
+	}
+
+	// Count total
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+
+	// Pagination
+	offset := (page - 1) * limit
+	err := query.Preload("Items").Offset(offset).Limit(limit).Order("created_at desc").Find(&returns).Error
+
+	return returns, total, err
+}
+
+// ReturnUpdate moves a return from one of the From statuses to Status.
+// Updates are further columns to set. If OrderStatus is set, the order moves
+// to it as well and the change is recorded in its status history; if
+// PaymentStatus is set, it becomes the order's payment status.
+type ReturnUpdate struct {
+	ReturnID      uuid.UUID
+	From          []string
+	Status        string
+	Updates       map[string]interface{}
+	OrderStatus   string
+	PaymentStatus string
+	Notes         string
+	UpdatedBy     uuid.UUID
+	UpdatedByRole string
+}
+
+// UpdateStatus applies update, failing with models.ErrReturnNotPending if
+// the return is not in one of the From statuses. events is called with the
+// updated return and its events are enqueued in the same transaction.
+func (r *ReturnRepository) UpdateStatus(update *ReturnUpdate, events func(ret *models.ReturnRequest) ([]*models.OutboxEvent, error)) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		var ret models.ReturnRequest
+		if err := tx.Preload("Items").Where("id = ?", update.ReturnID).First(&ret).Error; err != nil {
+			return err
+		}
+
+		// Lock the order before the return, like every other order change
+		var order models.Order
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("id = ?", ret.OrderID).First(&order).Error; err != nil {
+			return err
+		}
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Select("status").Where("id = ?", ret.ID).First(&ret).Error; err != nil {
+			return err
+		}
+
+		allowed := false
+		for _, status := range update.From {
+			if ret.Status == status {
+				allowed = true
+				break
+			}
+		}
+		if !allowed {
+			return fmt.Errorf("%w: return is %s", models.ErrReturnNotPending, ret.Status)
+		}
+
+		updates := map[string]interface{}{
+			"status": update.Status,
+		}
+		for column, value := range update.Updates {
+			updates[column] = value
+		}
+		if err := tx.Model(&ret).Updates(updates).Error; err != nil {
+			return err
+		}
+
+		if update.OrderStatus != "" {
+			if !models.CanTransitionOrderStatus(order.Status, update.OrderStatus) {
+				return fmt.Errorf("%w: %s -> %s", models.ErrInvalidOrderStatusTransition, order.Status, update.OrderStatus)
+			}
+			fromStatus := order.Status
+			if err := tx.Model(&order).Update("status", update.OrderStatus).Error; err != nil {
+				return err
+			}
+			if err := tx.Create(&models.OrderStatusHistory{
+				ID:            uuid.New(),
+				OrderID:       order.ID,
+				FromStatus:    fromStatus,
+				ToStatus:      update.OrderStatus,
+				Notes:         update.Notes,
+				CreatedBy:     update.UpdatedBy,
+				CreatedByRole: update.UpdatedByRole,
+			}).Error; err != nil {
+				return err
+			}
+		}
+		if update.PaymentStatus != "" {
+			if err := tx.Model(&order).Update("payment_status", update.PaymentStatus).Error; err != nil {
+				return err
+			}
+		}
+
+		outboxEvents, err := events(&ret)
+		if err != nil {
+			return err
+		}
+		return enqueueOutbox(tx, outboxEvents)
+	})
+}
@@ -0,0 +1,192 @@
+package repository
+
+import (
+	"errors"
+
+	"github.com/be-bcv/ecommerce-backend/internal/models"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+type StoreRepository struct {
+	db *gorm.DB
+}
+
+func NewStoreRepository(db *gorm.DB) *StoreRepository {
+	return &StoreRepository{db: db}
+}
+
+func (r *StoreRepository) Create(store *models.Store) error {
+	return r.db.Omit("User").Create(store).Error
+}
+
+func (r *StoreRepository) GetByID(id uuid.UUID) (*models.Store, error) {
+	var store models.Store
+	err := r.db.Where("id = ?", id).First(&store).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &store, nil
+}
+
+func (r *StoreRepository) GetByUserID(userID uuid.UUID) (*models.Store, error) {
+	var store models.Store
+	err := r.db.Where("user_id = ?", userID).First(&store).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &store, nil
+}
+
+// SlugTaken reports whether another store than excludeID uses slug.
+// Soft-deleted stores keep their slug, as the unique index still covers them.
+func (r *StoreRepository) SlugTaken(slug string, excludeID uuid.UUID) (bool, error) {
+	var count int64
+	err := r.db.Unscoped().Model(&models.Store{}).
+		Where("slug = ? AND id <> ?", slug, excludeID).
+		Count(&count).Error
+	return count > 0, err
+}
+
+// GetAll returns stores with the given status, or all stores if status is
+// empty, oldest application first.
+func (r *StoreRepository) GetAll(status string, page, limit int) ([]models.Store, int64, error) {
+	var stores []models.Store
+	var total int64
+
+	query := r.db.Model(&models.Store{})
+	if status != "" {
+		query = query.Where("status = ?", status)
+	}
+
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+
+	offset := (page - 1) * limit
+	err := query.Preload("User").Order("created_at").Offset(offset).Limit(limit).Find(&stores).Error
+	return stores, total, err
+}
+
+// Update saves the store and enqueues events in the same transaction.
+func (r *StoreRepository) Update(store *models.Store, events ...*models.OutboxEvent) error {
+	return withOutbox(r.db, events, func(tx *gorm.DB) error {
+		return tx.Omit("User").Save(store).Error
+	})
+}
+
+// RequestBankChange saves the store and records a bank account change for
+// review, replacing (as rejected) any change still pending, and enqueues
+// events in the same transaction.
+func (r *StoreRepository) RequestBankChange(store *models.Store, change *models.StoreBankChange, events ...*models.OutboxEvent) error {
+	return withOutbox(r.db, events, func(tx *gorm.DB) error {
+		if err := tx.Omit("User").Save(store).Error; err != nil {
+			return err
+		}
+		if err := tx.Model(&models.StoreBankChange{}).
+			Where("store_id = ? AND status = ?", store.ID, models.BankChangeStatusPending).
+			Updates(map[string]interface{}{
+				"status":           models.BankChangeStatusRejected,
+				"rejection_reason": "Replaced by a newer request",
+			}).Error; err != nil {
+			return err
+		}
+		return tx.Create(change).Error
+	})
+}
+
+func (r *StoreRepository) GetBankChangeByID(id uuid.UUID) (*models.StoreBankChange, error) {
+	var change models.StoreBankChange
+	err := r.db.Where("id = ?", id).First(&change).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &change, nil
+}
+
+// GetPendingBankChange returns the store's bank account change awaiting
+// review, or nil.
+func (r *StoreRepository) GetPendingBankChange(storeID uuid.UUID) (*models.StoreBankChange, error) {
+	var change models.StoreBankChange
+	err := r.db.Where("store_id = ? AND status = ?", storeID, models.BankChangeStatusPending).First(&change).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &change, nil
+}
+
+// GetBankChanges returns bank account changes with the given status, or all
+// of them if status is empty, oldest request first.
+func (r *StoreRepository) GetBankChanges(status string, page, limit int) ([]models.StoreBankChange, int64, error) {
+	var changes []models.StoreBankChange
+	var total int64
+
+	query := r.db.Model(&models.StoreBankChange{})
+	if status != "" {
+		query = query.Where("status = ?", status)
+	}
+
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+
+	offset := (page - 1) * limit
+	err := query.Order("created_at").Offset(offset).Limit(limit).Find(&changes).Error
+	return changes, total, err
+}
+
+// ReviewBankChange saves the reviewed change, and the store if the change
+// was approved, and enqueues events in the same transaction. It fails if
+// the change is no longer pending.
+func (r *StoreRepository) ReviewBankChange(change *models.StoreBankChange, store *models.Store, events ...*models.OutboxEvent) error {
+	return withOutbox(r.db, events, func(tx *gorm.DB) error {
+		result := tx.Model(&models.StoreBankChange{}).
+			Where("id = ? AND status = ?", change.ID, models.BankChangeStatusPending).
+			Updates(map[string]interface{}{
+				"status":           change.Status,
+				"rejection_reason": change.RejectionReason,
+				"reviewed_by":      change.ReviewedBy,
+				"reviewed_at":      change.ReviewedAt,
+			})
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return errors.New("bank account change is not pending")
+		}
+		if store == nil {
+			return nil
+		}
+		return tx.Model(store).Updates(map[string]interface{}{
+			"bank_name":           store.BankName,
+			"bank_account_number": store.BankAccountNumber,
+			"bank_account_name":   store.BankAccountName,
+		}).Error
+	})
+}
+
+// Approve saves the approved store together with its owner, whose role the
+// caller has changed, and enqueues events in the same transaction.
+func (r *StoreRepository) Approve(store *models.Store, owner *models.User, events ...*models.OutboxEvent) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Omit("User").Save(store).Error; err != nil {
+			return err
+		}
+		if err := tx.Model(owner).Update("role", owner.Role).Error; err != nil {
+			return err
+		}
+		return enqueueOutbox(tx, events)
+	})
+}
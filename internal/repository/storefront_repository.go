@@ -0,0 +1,41 @@
+package repository
+
+import (
+	"errors"
+
+	"github.com/be-bcv/ecommerce-backend/internal/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type StorefrontRepository struct {
+	db *gorm.DB
+}
+
+func NewStorefrontRepository(db *gorm.DB) *StorefrontRepository {
+	return &StorefrontRepository{db: db}
+}
+
+// Upsert inserts the storefront or overwrites the stored copy, unless the
+// stored copy is newer, so redelivered or reordered events are harmless.
+func (r *StorefrontRepository) Upsert(storefront *models.Storefront) error {
+	return r.db.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "id"}},
+		DoUpdates: clause.AssignmentColumns([]string{"seller_id", "name", "slug", "description", "logo_url", "address", "updated_at"}),
+		Where: clause.Where{Exprs: []clause.Expression{
+			clause.Expr{SQL: "storefronts.updated_at <= excluded.updated_at"},
+		}},
+	}).Create(storefront).Error
+}
+
+func (r *StorefrontRepository) GetBySlug(slug string) (*models.Storefront, error) {
+	var storefront models.Storefront
+	err := r.db.Where("slug = ?", slug).First(&storefront).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &storefront, nil
+}
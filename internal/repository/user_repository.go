@@ -2,6 +2,7 @@ package repository
 
 import (
 	"errors"
+	"time"
 
 	"github.com/be-bcv/ecommerce-backend/internal/models"
 	"github.com/google/uuid"
@@ -16,8 +17,11 @@ func NewUserRepository(db *gorm.DB) *UserRepository {
 	return &UserRepository{db: db}
 }
 
-func (r *UserRepository) Create(user *models.User) error {
-	return r.db.Create(user).Error
+// Create inserts the user and enqueues events in the same transaction.
+func (r *UserRepository) Create(user *models.User, events ...*models.OutboxEvent) error {
+	return withOutbox(r.db, events, func(tx *gorm.DB) error {
+		return tx.Create(user).Error
+	})
 }
 
 func (r *UserRepository) GetByEmail(email string) (*models.User, error) {
@@ -88,4 +92,34 @@ func (r *UserRepository) DeleteSessionByToken(token string) error {
 
 func (r *UserRepository) DeleteAllUserSessions(userID uuid.UUID) error {
 	return r.db.Where("user_id = ?", userID).Delete(&models.UserSession{}).Error
+}
+
+// GetUserSessions lists the user's unexpired sessions, most recently used
+// first.
+func (r *UserRepository) GetUserSessions(userID uuid.UUID) ([]models.UserSession, error) {
+	var sessions []models.UserSession
+	err := r.db.Where("user_id = ? AND expires_at > NOW()", userID).Order("last_used_at desc").Find(&sessions).Error
+	return sessions, err
+}
+
+// DeleteUserSession deletes one of the user's sessions, reporting whether
+// it existed.
+func (r *UserRepository) DeleteUserSession(userID, sessionID uuid.UUID) (bool, error) {
+	result := r.db.Where("id = ? AND user_id = ?", sessionID, userID).Delete(&models.UserSession{})
+	return result.RowsAffected > 0, result.Error
+}
+
+// RotateSessionToken replaces the session's refresh token if it is still
+// oldToken, so that a refresh token can only be used once. It reports
+// whether the token was replaced.
+func (r *UserRepository) RotateSessionToken(sessionID uuid.UUID, oldToken, newToken, ipAddress string, expiresAt time.Time) (bool, error) {
+	result := r.db.Model(&models.UserSession{}).
+		Where("id = ? AND token = ?", sessionID, oldToken).
+		Updates(map[string]interface{}{
+			"token":        newToken,
+			"ip_address":   ipAddress,
+			"last_used_at": time.Now(),
+			"expires_at":   expiresAt,
+		})
+	return result.RowsAffected > 0, result.Error
 }
\ No newline at end of file
@@ -0,0 +1,78 @@
+package service
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/be-bcv/ecommerce-backend/internal/models"
+	"github.com/be-bcv/ecommerce-backend/pkg/redis"
+	"github.com/google/uuid"
+)
+
+// Catalog cache lifetimes. Products are invalidated when they change, so
+// they can be cached for long; list pages are flushed on every product
+// change but not on stock changes, so they are kept briefly. Past the fresh
+// TTL, values are served stale until the hard TTL while they are refreshed.
+const (
+	productCacheTTL         = 30 * time.Minute
+	productCacheHardTTL     = time.Hour
+	productPageCacheTTL     = time.Minute
+	productPageCacheHardTTL = 2 * time.Minute
+	categoryCacheTTL        = time.Hour
+	categoryCacheHardTTL    = 2 * time.Hour
+	catalogNegativeTTL      = time.Minute
+)
+
+// productPage is a cached page of the product listing.
+type productPage struct {
+	Products []models.Product `json:"products"`
+	Total    int64            `json:"total"`
+}
+
+func newProductCache(client *redis.RedisClient) *redis.Cache[models.Product] {
+	return redis.NewCache[models.Product](client, redis.CacheConfig{
+		Prefix:      "product",
+		TTL:         productCacheTTL,
+		HardTTL:     productCacheHardTTL,
+		NegativeTTL: catalogNegativeTTL,
+	})
+}
+
+func newProductPageCache(client *redis.RedisClient) *redis.Cache[productPage] {
+	return redis.NewCache[productPage](client, redis.CacheConfig{
+		Prefix:  "products:page",
+		TTL:     productPageCacheTTL,
+		HardTTL: productPageCacheHardTTL,
+	})
+}
+
+func newCategoryCache(client *redis.RedisClient) *redis.Cache[models.Category] {
+	return redis.NewCache[models.Category](client, redis.CacheConfig{
+		Prefix:      "category",
+		TTL:         categoryCacheTTL,
+		HardTTL:     categoryCacheHardTTL,
+		NegativeTTL: catalogNegativeTTL,
+	})
+}
+
+func newCategoryListCache(client *redis.RedisClient) *redis.Cache[[]models.Category] {
+	return redis.NewCache[[]models.Category](client, redis.CacheConfig{
+		Prefix:  "categories",
+		TTL:     categoryCacheTTL,
+		HardTTL: categoryCacheHardTTL,
+	})
+}
+
+// productPageKey identifies a listing page. Sorting is normalized the way
+// the repository applies it, so unknown sort options share one entry.
+func productPageKey(page, limit int, categoryID uuid.UUID, sortBy, sortOrder string) string {
+	switch sortBy {
+	case "price", "created_at", "name":
+		if sortOrder != "desc" {
+			sortOrder = "asc"
+		}
+	default:
+		sortBy, sortOrder = "created_at", "desc"
+	}
+	return fmt.Sprintf("%s:%s:%s:%d:%d", categoryID, sortBy, sortOrder, page, limit)
+}
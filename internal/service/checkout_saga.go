@@ -0,0 +1,386 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/be-bcv/ecommerce-backend/internal/models"
+	"github.com/be-bcv/ecommerce-backend/pkg/messages"
+	"github.com/be-bcv/ecommerce-backend/pkg/rabbitmq"
+	"github.com/google/uuid"
+)
+
+// The checkout saga is orchestrated by order-service:
+//
+//	order placed (pending)    -> checkout.reserve_stock  -> product-service
+//	product.stock_reserved    -> checkout.create_payment -> payment-service
+//	payment.opened            -> wait for the customer to pay
+//	payment.success           -> order confirmed, saga completed
+//
+// A rejection, a cancellation or a step that times out cancels the order and
+// sends checkout.release_stock and checkout.void_payment; both are no-ops for
+// steps that never happened. Commands are written to the outbox together with
+// the saga state, so a crash never loses a step.
+const (
+	// sagaStepTimeout bounds how long product- and payment-service may take
+	// to answer a command.
+	sagaStepTimeout = 5 * time.Minute
+	// sagaPaymentGrace is added to the payment expiry so a late Midtrans
+	// notification can still complete the saga.
+	sagaPaymentGrace = 15 * time.Minute
+
+	sagaSweepInterval = 30 * time.Second
+	sagaSweepBatch    = 50
+	sagaMaxRetries    = 3
+)
+
+// Failure reasons recorded on compensated sagas and sent with compensations.
+const (
+	SagaReasonInsufficientStock = "insufficient_stock"
+	SagaReasonCancelled         = "cancelled"
+	SagaReasonTimeout           = "checkout_timeout"
+	SagaReasonPaymentExpired    = "payment_expired"
+)
+
+// startCheckout returns the saga for a newly built order and the events to
+// store with it: order.created and the command reserving its stock.
+func (s *OrderService) startCheckout(order *models.Order) (*models.CheckoutSaga, []*models.OutboxEvent, error) {
+	deadline := time.Now().Add(sagaStepTimeout)
+	saga := &models.CheckoutSaga{
+		ID:       uuid.New(),
+		OrderID:  order.ID,
+		State:    models.SagaStateReservingStock,
+		Deadline: &deadline,
+	}
+
+	created, err := s.orderCreatedEvent(order)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	items := make([]messages.OrderItemEvent, 0, len(order.Items))
+	for _, item := range order.Items {
+		items = append(items, messages.OrderItemEvent{
+			ProductID: item.ProductID.String(),
+			Quantity:  item.Quantity,
+		})
+	}
+	reserve, err := checkoutCommand(messages.CommandReserveStock, messages.ReserveStockCommand{
+		OrderID: order.ID.String(),
+		Items:   items,
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return saga, []*models.OutboxEvent{created, reserve}, nil
+}
+
+// StockEventHandlers returns the handlers for the product events
+// order-service consumes to learn whether an order's stock was reserved.
+func (s *OrderService) StockEventHandlers() map[string]rabbitmq.EventHandler {
+	return map[string]rabbitmq.EventHandler{
+		messages.EventStockReserved: func(event *messages.RawEventMessage) error {
+			var data messages.StockReservedEvent
+			if err := event.Decode(&data); err != nil {
+				return err
+			}
+			return s.HandleStockReserved(&data)
+		},
+		messages.EventStockRejected: func(event *messages.RawEventMessage) error {
+			var data messages.StockRejectedEvent
+			if err := event.Decode(&data); err != nil {
+				return err
+			}
+			return s.HandleStockRejected(&data)
+		},
+	}
+}
+
+// HandleStockReserved moves the saga on to opening a payment for the order.
+func (s *OrderService) HandleStockReserved(event *messages.StockReservedEvent) error {
+	orderID, err := uuid.Parse(event.OrderID)
+	if err != nil {
+		return fmt.Errorf("invalid order ID %q: %w", event.OrderID, err)
+	}
+
+	order, err := s.orderRepo.GetOrderByIDForAdmin(orderID)
+	if err != nil {
+		return err
+	}
+	if order == nil {
+		log.Printf("Ignoring stock reservation for unknown order %s", orderID)
+		return nil
+	}
+
+	saga, moved, err := s.advanceSaga(orderID, []string{models.SagaStateReservingStock}, func(saga *models.CheckoutSaga) ([]*models.OutboxEvent, error) {
+		deadline := time.Now().Add(sagaStepTimeout)
+		saga.State = models.SagaStateCreatingPayment
+		saga.Deadline = &deadline
+
+		command, err := checkoutCommand(messages.CommandCreatePayment, messages.CreatePaymentCommand{
+			OrderID:     order.ID.String(),
+			OrderNumber: order.OrderNumber,
+			UserID:      order.UserID.String(),
+			Amount:      order.TotalAmount,
+		})
+		if err != nil {
+			return nil, err
+		}
+		return []*models.OutboxEvent{command}, nil
+	})
+	if err != nil || moved || saga == nil {
+		return err
+	}
+
+	// The reservation arrived after the saga was compensated, e.g. because
+	// it was retried; give the stock back again
+	if saga.State == models.SagaStateCompensated {
+		command, err := checkoutCommand(messages.CommandReleaseStock, messages.ReleaseStockCommand{
+			OrderID: orderID.String(),
+			Reason:  saga.FailureReason,
+		})
+		if err != nil {
+			return err
+		}
+		return s.outboxRepo.Enqueue(command)
+	}
+	return nil
+}
+
+// HandleStockRejected cancels an order whose items could not be reserved.
+func (s *OrderService) HandleStockRejected(event *messages.StockRejectedEvent) error {
+	orderID, err := uuid.Parse(event.OrderID)
+	if err != nil {
+		return fmt.Errorf("invalid order ID %q: %w", event.OrderID, err)
+	}
+
+	notes := fmt.Sprintf("Insufficient stock for product %s (requested %d, available %d)", event.ProductID, event.Requested, event.Available)
+	return s.failCheckout(orderID, SagaReasonInsufficientStock, notes)
+}
+
+// HandlePaymentOpened records the order's pending payment and waits for the
+// customer to pay it until shortly after it expires.
+func (s *OrderService) HandlePaymentOpened(event *messages.PaymentOpenedEvent) error {
+	order, paymentID, err := s.getPaymentOrder(event.OrderID, event.PaymentID)
+	if err != nil || order == nil {
+		return err
+	}
+
+	saga, moved, err := s.advanceSaga(order.ID, []string{models.SagaStateCreatingPayment}, func(saga *models.CheckoutSaga) ([]*models.OutboxEvent, error) {
+		deadline := event.ExpiredAt.Add(sagaPaymentGrace)
+		saga.State = models.SagaStateAwaitingPayment
+		saga.PaymentID = paymentID
+		saga.Deadline = &deadline
+		return nil, nil
+	})
+	if err != nil {
+		return err
+	}
+
+	// The payment was opened after the saga was compensated; void it again
+	if !moved && saga != nil && saga.State == models.SagaStateCompensated {
+		return s.voidPayment(order.ID, saga.FailureReason)
+	}
+
+	if order.PaymentStatus == models.PaymentStatusPaid {
+		return nil
+	}
+	if err := s.orderRepo.SetInventoryHoldExpiry(order.ID, event.ExpiredAt); err != nil {
+		return err
+	}
+	return s.orderRepo.UpdatePaymentStatus(order.ID, paymentID, models.PaymentStatusPending)
+}
+
+// extendPaymentDeadline gives the customer until a new payment attempt
+// expires to pay, holding the order's stock as long.
+func (s *OrderService) extendPaymentDeadline(orderID, paymentID uuid.UUID, expiredAt time.Time) error {
+	if err := s.orderRepo.SetInventoryHoldExpiry(orderID, expiredAt); err != nil {
+		return err
+	}
+
+	_, _, err := s.advanceSaga(orderID, []string{models.SagaStateCreatingPayment, models.SagaStateAwaitingPayment}, func(saga *models.CheckoutSaga) ([]*models.OutboxEvent, error) {
+		deadline := expiredAt.Add(sagaPaymentGrace)
+		saga.State = models.SagaStateAwaitingPayment
+		saga.PaymentID = paymentID
+		saga.Deadline = &deadline
+		return nil, nil
+	})
+	return err
+}
+
+// completeCheckout marks the saga completed once the order is paid. A
+// payment for an order whose checkout was already compensated is voided
+// again, which refunds it.
+func (s *OrderService) completeCheckout(orderID, paymentID uuid.UUID) error {
+	saga, _, err := s.advanceSaga(orderID, models.ActiveSagaStates, func(saga *models.CheckoutSaga) ([]*models.OutboxEvent, error) {
+		saga.State = models.SagaStateCompleted
+		saga.PaymentID = paymentID
+		saga.Deadline = nil
+		return nil, nil
+	})
+	if err != nil {
+		return err
+	}
+	if saga != nil && saga.State == models.SagaStateCompensated {
+		log.Printf("Order %s was paid by payment %s after checkout was compensated (%s); refunding it", orderID, paymentID, saga.FailureReason)
+		return s.voidPayment(orderID, saga.FailureReason)
+	}
+	return nil
+}
+
+// voidPayment sends checkout.void_payment again for an order whose checkout
+// was compensated, for a payment that was opened or paid afterwards. Voiding
+// a settled payment refunds it.
+func (s *OrderService) voidPayment(orderID uuid.UUID, reason string) error {
+	command, err := checkoutCommand(messages.CommandVoidPayment, messages.VoidPaymentCommand{
+		OrderID: orderID.String(),
+		Reason:  reason,
+	})
+	if err != nil {
+		return err
+	}
+	return s.outboxRepo.Enqueue(command)
+}
+
+// cancelOrder cancels the order and, in the same transaction, tells
+// product-service and payment-service to release its stock and void its
+// payment. An active checkout saga is marked compensated with reason.
+func (s *OrderService) cancelOrder(orderID uuid.UUID, reason, notes string, actorID uuid.UUID, actorRole string) error {
+	cancelled, err := s.orderCancelledEvent(orderID, reason, notes)
+	if err != nil {
+		return err
+	}
+	release, err := checkoutCommand(messages.CommandReleaseStock, messages.ReleaseStockCommand{
+		OrderID: orderID.String(),
+		Reason:  reason,
+	})
+	if err != nil {
+		return err
+	}
+	void, err := checkoutCommand(messages.CommandVoidPayment, messages.VoidPaymentCommand{
+		OrderID: orderID.String(),
+		Reason:  reason,
+	})
+	if err != nil {
+		return err
+	}
+
+	if err := s.orderRepo.UpdateOrderStatus(orderID, models.OrderStatusCancelled, notes, actorID, actorRole, cancelled, release, void); err != nil {
+		return err
+	}
+	return s.compensateSaga(orderID, reason)
+}
+
+func (s *OrderService) compensateSaga(orderID uuid.UUID, reason string) error {
+	_, _, err := s.advanceSaga(orderID, models.ActiveSagaStates, func(saga *models.CheckoutSaga) ([]*models.OutboxEvent, error) {
+		saga.State = models.SagaStateCompensated
+		saga.FailureReason = reason
+		saga.Deadline = nil
+		return nil, nil
+	})
+	return err
+}
+
+// failCheckout compensates the order's checkout on behalf of the system. An
+// order that was paid meanwhile completes its saga instead, and one that is
+// already cancelled only has its saga closed.
+func (s *OrderService) failCheckout(orderID uuid.UUID, reason, notes string) error {
+	order, err := s.orderRepo.GetOrderByIDForAdmin(orderID)
+	if err != nil {
+		return err
+	}
+	if order == nil {
+		log.Printf("Ignoring checkout failure for unknown order %s", orderID)
+		return nil
+	}
+
+	switch {
+	case order.PaymentStatus == models.PaymentStatusPaid:
+		return s.completeCheckout(orderID, order.PaymentID)
+	case order.Status == models.OrderStatusCancelled:
+		return s.compensateSaga(orderID, reason)
+	}
+
+	err = s.cancelOrder(orderID, reason, notes, uuid.Nil, models.ActorSystem)
+	if errors.Is(err, models.ErrInvalidOrderStatusTransition) {
+		// Moved on concurrently; the next sweep looks at it again
+		return nil
+	}
+	return err
+}
+
+// advanceSaga applies change to the order's saga if it is in one of the from
+// states, and saves it along with the events change returns. If another
+// handler moves the saga first, change is applied again to the fresh saga.
+// It returns the saga as last read, or nil for orders without one, and
+// whether change was saved.
+func (s *OrderService) advanceSaga(orderID uuid.UUID, from []string, change func(saga *models.CheckoutSaga) ([]*models.OutboxEvent, error)) (*models.CheckoutSaga, bool, error) {
+	for attempt := 0; attempt < sagaMaxRetries; attempt++ {
+		saga, err := s.sagaRepo.GetByOrderID(orderID)
+		if err != nil || saga == nil {
+			return nil, false, err
+		}
+		if !containsState(from, saga.State) {
+			return saga, false, nil
+		}
+
+		fromState := saga.State
+		events, err := change(saga)
+		if err != nil {
+			return nil, false, err
+		}
+		moved, err := s.sagaRepo.Transition(saga, fromState, events...)
+		if err != nil {
+			return nil, false, err
+		}
+		if moved {
+			return saga, true, nil
+		}
+	}
+	return nil, false, fmt.Errorf("checkout saga for order %s changed concurrently", orderID)
+}
+
+func containsState(states []string, state string) bool {
+	for _, s := range states {
+		if s == state {
+			return true
+		}
+	}
+	return false
+}
+
+// RunCheckoutTimeouts compensates checkouts whose current step timed out,
+// polling until ctx is cancelled.
+func (s *OrderService) RunCheckoutTimeouts(ctx context.Context) {
+	ticker := time.NewTicker(sagaSweepInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		sagas, err := s.sagaRepo.GetTimedOut(time.Now(), sagaSweepBatch)
+		if err != nil {
+			log.Printf("Failed to load timed out checkouts: %v", err)
+			continue
+		}
+		for _, saga := range sagas {
+			notes := fmt.Sprintf("Checkout timed out while %s", strings.ReplaceAll(saga.State, "_", " "))
+			if err := s.failCheckout(saga.OrderID, SagaReasonTimeout, notes); err != nil {
+				log.Printf("Failed to compensate checkout of order %s: %v", saga.OrderID, err)
+			}
+		}
+	}
+}
+
+func checkoutCommand(name string, data interface{}) (*models.OutboxEvent, error) {
+	return models.NewOutboxEvent(messages.ExchangeCheckout, messages.NewEvent(name, "order-service", data))
+}
@@ -0,0 +1,204 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/be-bcv/ecommerce-backend/internal/models"
+	"github.com/be-bcv/ecommerce-backend/internal/repository"
+	"github.com/be-bcv/ecommerce-backend/pkg/messages"
+	"github.com/be-bcv/ecommerce-backend/pkg/middleware"
+	"github.com/google/uuid"
+)
+
+type UpdateFulfillmentRequest struct {
+	Status         string `json:"status" binding:"required"`
+	Courier        string `json:"courier"`
+	TrackingNumber string `json:"tracking_number"`
+	Notes          string `json:"notes"`
+}
+
+// SellerOrderResponse is a seller's view of an order: their fulfillment with
+// its items and where to ship it, without the other sellers' parts.
+type SellerOrderResponse struct {
+	*models.Fulfillment
+	OrderNumber         string                      `json:"order_number"`
+	PaymentStatus       string                      `json:"payment_status"`
+	Address             string                      `json:"address"`
+	City                string                      `json:"city"`
+	Province            string                      `json:"province"`
+	PostalCode          string                      `json:"postal_code"`
+	Notes               string                      `json:"notes"`
+	AllowedNextStatuses []string                    `json:"allowed_next_statuses"`
+	History             []models.OrderStatusHistory `json:"history,omitempty"`
+}
+
+// sellerStatuses are the statuses sellers may move their fulfillments to;
+// the rest follow the order as a whole.
+var sellerStatuses = []string{models.OrderStatusShipped, models.OrderStatusDelivered}
+
+// GetSellerOrders lists the seller's fulfillments, optionally filtered by status.
+func (s *OrderService) GetSellerOrders(sellerID uuid.UUID, page, limit int, status string) ([]SellerOrderResponse, int64, error) {
+	fulfillments, total, err := s.orderRepo.GetSellerFulfillments(sellerID, page, limit, status)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	responses := make([]SellerOrderResponse, 0, len(fulfillments))
+	for i := range fulfillments {
+		responses = append(responses, *newSellerOrderResponse(&fulfillments[i]))
+	}
+
+	return responses, total, nil
+}
+
+// GetSellerOrder returns one of the seller's fulfillments with its status history.
+func (s *OrderService) GetSellerOrder(fulfillmentID, sellerID uuid.UUID) (*SellerOrderResponse, error) {
+	fulfillment, err := s.orderRepo.GetSellerFulfillment(fulfillmentID, sellerID)
+	if err != nil {
+		return nil, err
+	}
+	if fulfillment == nil {
+		return nil, errors.New("order not found")
+	}
+
+	response := newSellerOrderResponse(fulfillment)
+	response.History, err = s.orderRepo.GetFulfillmentStatusHistories(fulfillmentID)
+	if err != nil {
+		return nil, err
+	}
+
+	return response, nil
+}
+
+// UpdateSellerOrderStatus lets a seller ship or deliver their fulfillment.
+// Shipping requires the courier and tracking number. When the last part of
+// an order ships or is delivered, the order follows and order.updated is
+// published.
+func (s *OrderService) UpdateSellerOrderStatus(fulfillmentID, sellerID uuid.UUID, req *UpdateFulfillmentRequest) error {
+	if !isSellerStatus(req.Status) {
+		return fmt.Errorf("sellers cannot move orders to %s status", req.Status)
+	}
+
+	fulfillment, err := s.orderRepo.GetSellerFulfillment(fulfillmentID, sellerID)
+	if err != nil {
+		return err
+	}
+	if fulfillment == nil {
+		return errors.New("order not found")
+	}
+
+	if req.Status == models.OrderStatusShipped && (req.Courier == "" || req.TrackingNumber == "") {
+		return errors.New("courier and tracking number are required to ship an order")
+	}
+
+	update := &repository.FulfillmentUpdate{
+		FulfillmentID:  fulfillmentID,
+		Status:         req.Status,
+		Courier:        req.Courier,
+		TrackingNumber: req.TrackingNumber,
+		Notes:          req.Notes,
+		UpdatedBy:      sellerID,
+		UpdatedByRole:  middleware.RoleSeller,
+	}
+	return s.orderRepo.UpdateFulfillmentStatus(update, func(fulfillment *models.Fulfillment, orderStatus string) ([]*models.OutboxEvent, error) {
+		var events []*models.OutboxEvent
+		if fulfillment.Status == models.OrderStatusDelivered {
+			event, err := s.fulfillmentDeliveredEvent(fulfillment, fulfillment.Order.OrderNumber, fulfillment.Items)
+			if err != nil {
+				return nil, err
+			}
+			events = append(events, event)
+		}
+		if orderStatus != "" {
+			event, err := s.orderStatusEvent(fulfillment.OrderID, orderStatus)
+			if err != nil {
+				return nil, err
+			}
+			events = append(events, event)
+		}
+		return events, nil
+	})
+}
+
+// fulfillmentDeliveredEvents returns an order.fulfillment_delivered event for
+// every fulfillment of the order that delivering the whole order delivers.
+func (s *OrderService) fulfillmentDeliveredEvents(order *models.Order) ([]*models.OutboxEvent, error) {
+	var events []*models.OutboxEvent
+	for i := range order.Fulfillments {
+		fulfillment := &order.Fulfillments[i]
+		if fulfillment.Status == models.OrderStatusDelivered || fulfillment.Status == models.OrderStatusCancelled {
+			continue
+		}
+
+		var items []models.OrderItem
+		for _, item := range order.Items {
+			if item.FulfillmentID == fulfillment.ID {
+				items = append(items, item)
+			}
+		}
+
+		event, err := s.fulfillmentDeliveredEvent(fulfillment, order.OrderNumber, items)
+		if err != nil {
+			return nil, err
+		}
+		events = append(events, event)
+	}
+	return events, nil
+}
+
+func (s *OrderService) fulfillmentDeliveredEvent(fulfillment *models.Fulfillment, orderNumber string, items []models.OrderItem) (*models.OutboxEvent, error) {
+	deliveredItems := make([]messages.DeliveredOrderItemEvent, 0, len(items))
+	for _, item := range items {
+		deliveredItems = append(deliveredItems, messages.DeliveredOrderItemEvent{
+			OrderItemID: item.ID.String(),
+			ProductID:   item.ProductID.String(),
+			Quantity:    item.Quantity,
+			Subtotal:    item.Subtotal,
+		})
+	}
+
+	event := messages.NewEvent(messages.EventFulfillmentDelivered, "order-service", messages.FulfillmentDeliveredEvent{
+		FulfillmentID: fulfillment.ID.String(),
+		OrderID:       fulfillment.OrderID.String(),
+		OrderNumber:   orderNumber,
+		SellerID:      fulfillment.SellerID.String(),
+		DeliveredAt:   time.Now(),
+		Items:         deliveredItems,
+	})
+
+	return models.NewOutboxEvent(messages.ExchangeOrder, event)
+}
+
+func newSellerOrderResponse(fulfillment *models.Fulfillment) *SellerOrderResponse {
+	response := &SellerOrderResponse{
+		Fulfillment:         fulfillment,
+		AllowedNextStatuses: []string{},
+	}
+	for _, status := range models.NextOrderStatuses(fulfillment.Status) {
+		if isSellerStatus(status) {
+			response.AllowedNextStatuses = append(response.AllowedNextStatuses, status)
+		}
+	}
+
+	if order := fulfillment.Order; order != nil {
+		response.OrderNumber = order.OrderNumber
+		response.PaymentStatus = order.PaymentStatus
+		response.Address = order.Address
+		response.City = order.City
+		response.Province = order.Province
+		response.PostalCode = order.PostalCode
+		response.Notes = order.Notes
+	}
+	return response
+}
+
+func isSellerStatus(status string) bool {
+	for _, s := range sellerStatuses {
+		if s == status {
+			return true
+		}
+	}
+	return false
+}
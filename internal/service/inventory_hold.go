@@ -0,0 +1,61 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"log"
+	"time"
+
+	"github.com/be-bcv/ecommerce-backend/internal/models"
+	"github.com/google/uuid"
+)
+
+const (
+	holdSweepInterval = 30 * time.Second
+	holdSweepBatch    = 50
+)
+
+// RunInventoryHoldExpiry cancels orders whose inventory holds expired with
+// their payment, polling until ctx is cancelled. Cancelling releases the
+// holds and tells product-service to put the stock back.
+func (s *OrderService) RunInventoryHoldExpiry(ctx context.Context) {
+	ticker := time.NewTicker(holdSweepInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		orderIDs, err := s.orderRepo.GetOrdersWithExpiredHolds(time.Now(), holdSweepBatch)
+		if err != nil {
+			log.Printf("Failed to load expired inventory holds: %v", err)
+			continue
+		}
+		for _, orderID := range orderIDs {
+			if err := s.expireOrder(orderID); err != nil {
+				log.Printf("Failed to expire order %s: %v", orderID, err)
+			}
+		}
+	}
+}
+
+// expireOrder cancels an unpaid order whose payment expired.
+func (s *OrderService) expireOrder(orderID uuid.UUID) error {
+	order, err := s.orderRepo.GetOrderByIDForAdmin(orderID)
+	if err != nil || order == nil {
+		return err
+	}
+	// Paid just now; confirming the order converts the holds
+	if order.PaymentStatus == models.PaymentStatusPaid {
+		return nil
+	}
+
+	err = s.cancelOrder(orderID, SagaReasonPaymentExpired, "Payment expired", uuid.Nil, models.ActorSystem)
+	if errors.Is(err, models.ErrInvalidOrderStatusTransition) {
+		return nil
+	}
+	return err
+}
@@ -0,0 +1,222 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/be-bcv/ecommerce-backend/pkg/redis"
+	goredis "github.com/redis/go-redis/v9"
+)
+
+// Lockout scopes: failed logins are counted per account (email) and per
+// client IP.
+const (
+	LockoutScopeAccount = "account"
+	LockoutScopeIP      = "ip"
+)
+
+// Failed login policy. Failures are forgotten after loginFailureWindow
+// without one. Past the delay threshold, every further failure makes the
+// client wait before the next attempt, doubling from one second; at the
+// lockout threshold the account or IP is locked for loginLockoutDuration.
+// IPs get higher thresholds since one address may serve many users.
+const (
+	loginFailureWindow     = 15 * time.Minute
+	loginLockoutDuration   = 15 * time.Minute
+	accountLoginDelayAfter = 3
+	accountLockoutAfter    = 10
+	ipLoginDelayAfter      = 10
+	ipLockoutAfter         = 50
+)
+
+// ErrInvalidCredentials is returned for every failed login, whether the
+// account exists or not, so that logins cannot be used to find accounts.
+var ErrInvalidCredentials = errors.New("invalid email or password")
+
+// LoginLockedError is returned when an account or IP must wait before
+// trying to log in again.
+type LoginLockedError struct {
+	RetryAfter time.Duration
+}
+
+func (e *LoginLockedError) Error() string {
+	return fmt.Sprintf("too many failed login attempts, try again in %s", e.RetryAfter.Round(time.Second))
+}
+
+// LoginLockout is an account or IP that currently may not log in.
+type LoginLockout struct {
+	Scope       string    `json:"scope"`
+	Key         string    `json:"key"`
+	Failures    int64     `json:"failures"`
+	LockedUntil time.Time `json:"locked_until"`
+}
+
+// LoginGuard counts failed logins in Redis and delays or locks out the
+// accounts and IPs they come from.
+type LoginGuard struct {
+	redis *redis.RedisClient
+}
+
+func NewLoginGuard(redis *redis.RedisClient) *LoginGuard {
+	return &LoginGuard{redis: redis}
+}
+
+func loginFailuresKey(scope, key string) string {
+	return fmt.Sprintf("login_failures:%s:%s", scope, key)
+}
+
+func loginLockKey(scope, key string) string {
+	return fmt.Sprintf("login_lock:%s:%s", scope, key)
+}
+
+func normalizeEmail(email string) string {
+	return strings.ToLower(strings.TrimSpace(email))
+}
+
+// loginAttemptScript checks the account and IP locks and, if neither is
+// set, counts the attempt as a failure of both, all in one step, so that
+// parallel guesses can't get past the check before any of them is counted.
+// ARGV holds the failure window, the length of the account's delay schedule
+// and then the account's and the IP's schedules: the lock in milliseconds
+// after each number of failures, the last entry applying to any higher
+// number. It returns how long the client has to wait if it was locked, or 0.
+var loginAttemptScript = goredis.NewScript(`
+local retry = math.max(redis.call("PTTL", KEYS[1]), redis.call("PTTL", KEYS[3]))
+if retry > 0 then
+	return retry
+end
+local function count(lock, failures, first, last)
+	local n = redis.call("INCR", failures)
+	redis.call("PEXPIRE", failures, ARGV[1])
+	local delay = tonumber(ARGV[math.min(first + n - 1, last)])
+	if delay > 0 then
+		redis.call("SET", lock, n, "PX", delay)
+	end
+end
+local split = 2 + tonumber(ARGV[2])
+count(KEYS[1], KEYS[2], 3, split)
+count(KEYS[3], KEYS[4], split + 1, #ARGV)
+return 0
+`)
+
+// Attempt returns a *LoginLockedError if the account or the IP is locked.
+// Otherwise it counts the attempt as a failed login, locking the account or
+// IP when their failures call for it, before the password is checked;
+// RecordSuccess takes it back if the login succeeds.
+func (g *LoginGuard) Attempt(ctx context.Context, email, ip string) error {
+	email = normalizeEmail(email)
+	keys := []string{
+		loginLockKey(LockoutScopeAccount, email), loginFailuresKey(LockoutScopeAccount, email),
+		loginLockKey(LockoutScopeIP, ip), loginFailuresKey(LockoutScopeIP, ip),
+	}
+	args := []interface{}{loginFailureWindow.Milliseconds(), accountLockoutAfter}
+	args = append(args, loginDelaySchedule(accountLoginDelayAfter, accountLockoutAfter)...)
+	args = append(args, loginDelaySchedule(ipLoginDelayAfter, ipLockoutAfter)...)
+
+	retryAfter, err := loginAttemptScript.Run(ctx, g.redis.GetClient(), keys, args...).Int64()
+	if err != nil {
+		return err
+	}
+	if retryAfter > 0 {
+		return &LoginLockedError{RetryAfter: time.Duration(retryAfter) * time.Millisecond}
+	}
+	return nil
+}
+
+// loginDelaySchedule lists loginDelay in milliseconds for 1 up to
+// lockoutAfter failures.
+func loginDelaySchedule(delayAfter, lockoutAfter int64) []interface{} {
+	schedule := make([]interface{}, 0, lockoutAfter)
+	for failures := int64(1); failures <= lockoutAfter; failures++ {
+		schedule = append(schedule, loginDelay(failures, delayAfter, lockoutAfter).Milliseconds())
+	}
+	return schedule
+}
+
+// loginDelay is how long a client with the given number of failures has to
+// wait before trying again.
+func loginDelay(failures, delayAfter, lockoutAfter int64) time.Duration {
+	if failures >= lockoutAfter {
+		return loginLockoutDuration
+	}
+	if failures <= delayAfter {
+		return 0
+	}
+
+	// Capped before converting, as large powers overflow a Duration
+	seconds := math.Pow(2, float64(failures-delayAfter-1))
+	if seconds >= loginLockoutDuration.Seconds() {
+		return loginLockoutDuration
+	}
+	return time.Duration(seconds) * time.Second
+}
+
+// RecordSuccess forgets the account's failures, and takes back the failure
+// Attempt counted for the IP. The IP's earlier failures are kept, so that
+// logging into one account does not reset guessing at others.
+func (g *LoginGuard) RecordSuccess(ctx context.Context, email, ip string) error {
+	email = normalizeEmail(email)
+	if err := g.redis.Del(ctx, loginFailuresKey(LockoutScopeAccount, email), loginLockKey(LockoutScopeAccount, email)); err != nil {
+		return err
+	}
+	return g.redis.GetClient().Decr(ctx, loginFailuresKey(LockoutScopeIP, ip)).Err()
+}
+
+// GetLockouts lists the accounts and IPs that are currently locked.
+func (g *LoginGuard) GetLockouts(ctx context.Context) ([]LoginLockout, error) {
+	client := g.redis.GetClient()
+	lockouts := []LoginLockout{}
+
+	iter := client.Scan(ctx, 0, "login_lock:*", 100).Iterator()
+	for iter.Next(ctx) {
+		scope, key, ok := strings.Cut(strings.TrimPrefix(iter.Val(), "login_lock:"), ":")
+		if !ok {
+			continue
+		}
+
+		value, err := client.Get(ctx, iter.Val()).Result()
+		if errors.Is(err, goredis.Nil) {
+			// Expired since the scan
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		ttl, err := client.PTTL(ctx, iter.Val()).Result()
+		if err != nil {
+			return nil, err
+		}
+		if ttl <= 0 {
+			continue
+		}
+
+		failures, _ := strconv.ParseInt(value, 10, 64)
+		lockouts = append(lockouts, LoginLockout{
+			Scope:       scope,
+			Key:         key,
+			Failures:    failures,
+			LockedUntil: time.Now().Add(ttl),
+		})
+	}
+	if err := iter.Err(); err != nil {
+		return nil, err
+	}
+	return lockouts, nil
+}
+
+// ClearLockout unlocks an account or IP and forgets its failures.
+func (g *LoginGuard) ClearLockout(ctx context.Context, scope, key string) error {
+	switch scope {
+	case LockoutScopeAccount:
+		key = normalizeEmail(key)
+	case LockoutScopeIP:
+	default:
+		return fmt.Errorf("invalid lockout scope %q, expected %s or %s", scope, LockoutScopeAccount, LockoutScopeIP)
+	}
+	return g.redis.Del(ctx, loginFailuresKey(scope, key), loginLockKey(scope, key))
+}
@@ -0,0 +1,59 @@
+package service
+
+import (
+	"testing"
+	"time"
+)
+
+func TestLoginDelay(t *testing.T) {
+	tests := []struct {
+		name                     string
+		failures                 int64
+		delayAfter, lockoutAfter int64
+		want                     time.Duration
+	}{
+		{"account first failure", 1, accountLoginDelayAfter, accountLockoutAfter, 0},
+		{"account at the delay threshold", 3, accountLoginDelayAfter, accountLockoutAfter, 0},
+		{"account first delay", 4, accountLoginDelayAfter, accountLockoutAfter, time.Second},
+		{"account delay doubles", 5, accountLoginDelayAfter, accountLockoutAfter, 2 * time.Second},
+		{"account last delay", 9, accountLoginDelayAfter, accountLockoutAfter, 32 * time.Second},
+		{"account locked out", 10, accountLoginDelayAfter, accountLockoutAfter, loginLockoutDuration},
+		{"account past lockout", 25, accountLoginDelayAfter, accountLockoutAfter, loginLockoutDuration},
+		{"ip below the delay threshold", 10, ipLoginDelayAfter, ipLockoutAfter, 0},
+		{"ip first delay", 11, ipLoginDelayAfter, ipLockoutAfter, time.Second},
+		{"ip delay below the cap", 20, ipLoginDelayAfter, ipLockoutAfter, 512 * time.Second},
+		{"ip delay capped at the lockout", 21, ipLoginDelayAfter, ipLockoutAfter, loginLockoutDuration},
+		{"ip delay capped long before lockout", 49, ipLoginDelayAfter, ipLockoutAfter, loginLockoutDuration},
+		{"ip locked out", 50, ipLoginDelayAfter, ipLockoutAfter, loginLockoutDuration},
+		{"no failures", 0, accountLoginDelayAfter, accountLockoutAfter, 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := loginDelay(tt.failures, tt.delayAfter, tt.lockoutAfter); got != tt.want {
+				t.Errorf("loginDelay(%d, %d, %d) = %s, want %s", tt.failures, tt.delayAfter, tt.lockoutAfter, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestLoginDelaySchedule(t *testing.T) {
+	got := loginDelaySchedule(accountLoginDelayAfter, accountLockoutAfter)
+	want := []int64{0, 0, 0, 1000, 2000, 4000, 8000, 16000, 32000, loginLockoutDuration.Milliseconds()}
+
+	if len(got) != len(want) {
+		t.Fatalf("loginDelaySchedule() has %d entries, want %d", len(got), len(want))
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("loginDelaySchedule()[%d] = %v, want %d ms", i, got[i], want[i])
+		}
+	}
+
+	// Every delay is a valid lock time for the login script
+	for i, delay := range loginDelaySchedule(ipLoginDelayAfter, ipLockoutAfter) {
+		if ms := delay.(int64); ms < 0 || ms > loginLockoutDuration.Milliseconds() {
+			t.Errorf("IP loginDelaySchedule()[%d] = %d ms, want 0 to %d", i, ms, loginLockoutDuration.Milliseconds())
+		}
+	}
+}
@@ -0,0 +1,674 @@
+package service
+
+import (
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"log"
+	"math"
+	"time"
+
+	"github.com/be-bcv/ecommerce-backend/internal/client"
+	"github.com/be-bcv/ecommerce-backend/internal/models"
+	"github.com/be-bcv/ecommerce-backend/internal/repository"
+	"github.com/be-bcv/ecommerce-backend/pkg/config"
+	"github.com/be-bcv/ecommerce-backend/pkg/messages"
+	"github.com/be-bcv/ecommerce-backend/pkg/middleware"
+	"github.com/be-bcv/ecommerce-backend/pkg/rabbitmq"
+	"github.com/be-bcv/ecommerce-backend/pkg/redis"
+	"github.com/google/uuid"
+)
+
+// Cart Service
+type CartService struct {
+	cartRepo      *repository.CartRepository
+	productClient *client.ProductClient
+	redis         *redis.RedisClient
+}
+
+func NewCartService(cartRepo *repository.CartRepository, redis *redis.RedisClient, config *config.Config) *CartService {
+	return &CartService{
+		cartRepo:      cartRepo,
+		productClient: client.NewProductClient(config.ProductServiceURL),
+		redis:         redis,
+	}
+}
+
+type AddToCartRequest struct {
+	ProductID uuid.UUID `json:"product_id" binding:"required"`
+	Quantity  int       `json:"quantity" binding:"required,min=1"`
+}
+
+type UpdateCartItemRequest struct {
+	Quantity int `json:"quantity" binding:"required,min=1"`
+}
+
+// CartItemResponse is a cart item with its product as product-service has
+// it now. Product is nil if the product is no longer available.
+type CartItemResponse struct {
+	models.Cart
+	Product *models.Product `json:"product"`
+}
+
+type CartResponse struct {
+	Items      []CartItemResponse `json:"items"`
+	TotalItems int                `json:"total_items"`
+}
+
+func (s *CartService) GetCart(userID uuid.UUID) (*CartResponse, error) {
+	cartItems, err := s.cartRepo.GetCart(userID)
+	if err != nil {
+		return nil, err
+	}
+
+	// Products live in product-service, not order_db
+	items := make([]CartItemResponse, 0, len(cartItems))
+	totalItems := 0
+	for _, cartItem := range cartItems {
+		product, err := s.productClient.GetProduct(cartItem.ProductID)
+		if err != nil {
+			return nil, err
+		}
+		items = append(items, CartItemResponse{Cart: cartItem, Product: product})
+		totalItems += cartItem.Quantity
+	}
+
+	return &CartResponse{
+		Items:      items,
+		TotalItems: totalItems,
+	}, nil
+}
+
+func (s *CartService) AddToCart(userID uuid.UUID, req *AddToCartRequest) error {
+	product, err := s.productClient.GetProduct(req.ProductID)
+	if err != nil {
+		return err
+	}
+	if product == nil || !product.IsActive {
+		return fmt.Errorf("product %s not found", req.ProductID)
+	}
+
+	cart := &models.Cart{
+		ID:        uuid.New(),
+		UserID:    userID,
+		ProductID: req.ProductID,
+		Quantity:  req.Quantity,
+	}
+
+	return s.cartRepo.AddToCart(cart)
+}
+
+func (s *CartService) UpdateCartItem(cartID uuid.UUID, userID uuid.UUID, req *UpdateCartItemRequest) error {
+	if _, err := s.getOwnedCartItem(cartID, userID); err != nil {
+		return err
+	}
+
+	return s.cartRepo.UpdateCartItem(cartID, userID, req.Quantity)
+}
+
+func (s *CartService) RemoveFromCart(cartID uuid.UUID, userID uuid.UUID) error {
+	if _, err := s.getOwnedCartItem(cartID, userID); err != nil {
+		return err
+	}
+
+	return s.cartRepo.RemoveFromCart(cartID, userID)
+}
+
+func (s *CartService) ClearCart(userID uuid.UUID) error {
+	return s.cartRepo.ClearCart(userID)
+}
+
+func (s *CartService) getOwnedCartItem(cartID uuid.UUID, userID uuid.UUID) (*models.Cart, error) {
+	item, err := s.cartRepo.GetCartItemByID(cartID)
+	if err != nil {
+		return nil, err
+	}
+	if item == nil || item.UserID != userID {
+		return nil, errors.New("cart item not found")
+	}
+	return item, nil
+}
+
+// Order Service
+type OrderService struct {
+	orderRepo     *repository.OrderRepository
+	returnRepo    *repository.ReturnRepository
+	cartRepo      *repository.CartRepository
+	sagaRepo      *repository.CheckoutSagaRepository
+	outboxRepo    *repository.OutboxRepository
+	productClient *client.ProductClient
+	redis         *redis.RedisClient
+	config        *config.Config
+}
+
+func NewOrderService(orderRepo *repository.OrderRepository, returnRepo *repository.ReturnRepository, cartRepo *repository.CartRepository, sagaRepo *repository.CheckoutSagaRepository, outboxRepo *repository.OutboxRepository, redis *redis.RedisClient, config *config.Config) *OrderService {
+	return &OrderService{
+		orderRepo:     orderRepo,
+		returnRepo:    returnRepo,
+		cartRepo:      cartRepo,
+		sagaRepo:      sagaRepo,
+		outboxRepo:    outboxRepo,
+		productClient: client.NewProductClient(config.ProductServiceURL),
+		redis:         redis,
+		config:        config,
+	}
+}
+
+type OrderItemRequest struct {
+	ProductID uuid.UUID `json:"product_id" binding:"required"`
+	Quantity  int       `json:"quantity" binding:"required,min=1"`
+}
+
+type ShippingAddressRequest struct {
+	Address    string `json:"address" binding:"required"`
+	City       string `json:"city"`
+	Province   string `json:"province"`
+	PostalCode string `json:"postal_code"`
+	Notes      string `json:"notes"`
+}
+
+type CreateOrderRequest struct {
+	ShippingAddressRequest
+	Items []OrderItemRequest `json:"items" binding:"required,min=1,dive"`
+}
+
+type CheckoutRequest struct {
+	ShippingAddressRequest
+}
+
+type CancelOrderRequest struct {
+	Reason string `json:"reason"`
+}
+
+type UpdateOrderStatusRequest struct {
+	Status string `json:"status" binding:"required"`
+	Notes  string `json:"notes"`
+}
+
+type OrderStatusResponse struct {
+	OrderID             uuid.UUID                   `json:"order_id"`
+	OrderNumber         string                      `json:"order_number"`
+	Status              string                      `json:"status"`
+	PaymentStatus       string                      `json:"payment_status"`
+	AllowedNextStatuses []string                    `json:"allowed_next_statuses"`
+	ShippingDate        *time.Time                  `json:"shipping_date"`
+	DeliveryDate        *time.Time                  `json:"delivery_date"`
+	History             []models.OrderStatusHistory `json:"history"`
+}
+
+// CreateOrder places an order for an explicit list of products, bypassing the cart.
+func (s *OrderService) CreateOrder(userID uuid.UUID, req *CreateOrderRequest) (*models.Order, error) {
+	order, err := s.buildOrder(userID, req.Items, &req.ShippingAddressRequest)
+	if err != nil {
+		return nil, err
+	}
+
+	// Start the checkout saga with the order
+	saga, events, err := s.startCheckout(order)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.orderRepo.CreateOrder(order, saga, events...); err != nil {
+		return nil, err
+	}
+
+	return order, nil
+}
+
+// Checkout converts the user's cart into an order. Prices are snapshotted
+// into the order items, and the order is written and the cart cleared in a
+// single transaction.
+func (s *OrderService) Checkout(userID uuid.UUID, req *CheckoutRequest) (*models.Order, error) {
+	cartItems, err := s.cartRepo.GetCart(userID)
+	if err != nil {
+		return nil, err
+	}
+	if len(cartItems) == 0 {
+		return nil, errors.New("cart is empty")
+	}
+
+	items := make([]OrderItemRequest, 0, len(cartItems))
+	for _, cartItem := range cartItems {
+		items = append(items, OrderItemRequest{
+			ProductID: cartItem.ProductID,
+			Quantity:  cartItem.Quantity,
+		})
+	}
+
+	order, err := s.buildOrder(userID, items, &req.ShippingAddressRequest)
+	if err != nil {
+		return nil, err
+	}
+
+	// Start the checkout saga with the order
+	saga, events, err := s.startCheckout(order)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.orderRepo.Checkout(order, cartItems, saga, events...); err != nil {
+		if errors.Is(err, repository.ErrCartChanged) {
+			return nil, errors.New("cart changed during checkout, please review your cart and try again")
+		}
+		return nil, err
+	}
+
+	return order, nil
+}
+
+func (s *OrderService) GetUserOrders(userID uuid.UUID, page, limit int) ([]models.Order, int64, error) {
+	return s.orderRepo.GetUserOrders(userID, page, limit)
+}
+
+func (s *OrderService) GetOrderByID(orderID uuid.UUID, userID uuid.UUID) (*models.Order, error) {
+	order, err := s.orderRepo.GetOrderByID(orderID, userID)
+	if err != nil {
+		return nil, err
+	}
+	if order == nil {
+		return nil, errors.New("order not found")
+	}
+	return order, nil
+}
+
+func (s *OrderService) CancelOrder(orderID uuid.UUID, userID uuid.UUID, req *CancelOrderRequest) error {
+	order, err := s.GetOrderByID(orderID, userID)
+	if err != nil {
+		return err
+	}
+
+	if !models.CanTransitionOrderStatus(order.Status, models.OrderStatusCancelled) {
+		return fmt.Errorf("order cannot be cancelled in %s status", order.Status)
+	}
+
+	notes := "Cancelled by customer"
+	if req.Reason != "" {
+		notes = req.Reason
+	}
+
+	return s.cancelOrder(orderID, SagaReasonCancelled, notes, userID, middleware.RoleUser)
+}
+
+// GetAllOrders lists every order, optionally filtered by status, for back-office use.
+func (s *OrderService) GetAllOrders(page, limit int, status string) ([]models.Order, int64, error) {
+	return s.orderRepo.GetAllOrders(page, limit, status)
+}
+
+// UpdateOrderStatus moves an order along the status state machine on behalf
+// of a back-office user.
+func (s *OrderService) UpdateOrderStatus(orderID uuid.UUID, actorID uuid.UUID, actorRole string, req *UpdateOrderStatusRequest) error {
+	if !models.IsValidOrderStatus(req.Status) {
+		return fmt.Errorf("unknown order status %s", req.Status)
+	}
+	if req.Status == models.OrderStatusReturned || req.Status == models.OrderStatusRefunded {
+		return errors.New("orders are returned and refunded through return requests")
+	}
+
+	order, err := s.orderRepo.GetOrderByIDForAdmin(orderID)
+	if err != nil {
+		return err
+	}
+	if order == nil {
+		return errors.New("order not found")
+	}
+
+	// Cancellations also undo the order's checkout
+	if req.Status == models.OrderStatusCancelled {
+		return s.cancelOrder(orderID, SagaReasonCancelled, req.Notes, actorID, actorRole)
+	}
+
+	event, err := s.orderStatusEvent(orderID, req.Status)
+	if err != nil {
+		return err
+	}
+	events := []*models.OutboxEvent{event}
+
+	// Delivering the order delivers every seller's part still on its way
+	if req.Status == models.OrderStatusDelivered {
+		deliveredEvents, err := s.fulfillmentDeliveredEvents(order)
+		if err != nil {
+			return err
+		}
+		events = append(events, deliveredEvents...)
+	}
+
+	return s.orderRepo.UpdateOrderStatus(orderID, req.Status, req.Notes, actorID, actorRole, events...)
+}
+
+// PaymentEventHandlers returns the handlers for the payment events
+// order-service consumes to keep each order's payment state in sync.
+func (s *OrderService) PaymentEventHandlers() map[string]rabbitmq.EventHandler {
+	return map[string]rabbitmq.EventHandler{
+		messages.EventPaymentOpened: func(event *messages.RawEventMessage) error {
+			var data messages.PaymentOpenedEvent
+			if err := event.Decode(&data); err != nil {
+				return err
+			}
+			return s.HandlePaymentOpened(&data)
+		},
+		messages.EventPaymentCreated: func(event *messages.RawEventMessage) error {
+			var data messages.PaymentCreatedEvent
+			if err := event.Decode(&data); err != nil {
+				return err
+			}
+			return s.HandlePaymentCreated(&data)
+		},
+		messages.EventPaymentSuccess: func(event *messages.RawEventMessage) error {
+			var data messages.PaymentSuccessEvent
+			if err := event.Decode(&data); err != nil {
+				return err
+			}
+			return s.HandlePaymentSuccess(&data)
+		},
+		messages.EventPaymentFailed: func(event *messages.RawEventMessage) error {
+			var data messages.PaymentFailedEvent
+			if err := event.Decode(&data); err != nil {
+				return err
+			}
+			return s.HandlePaymentFailed(&data)
+		},
+		messages.EventPaymentRefunded: func(event *messages.RawEventMessage) error {
+			var data messages.PaymentRefundedEvent
+			if err := event.Decode(&data); err != nil {
+				return err
+			}
+			return s.HandlePaymentRefunded(&data)
+		},
+		messages.EventRefundFailed: func(event *messages.RawEventMessage) error {
+			var data messages.RefundFailedEvent
+			if err := event.Decode(&data); err != nil {
+				return err
+			}
+			return s.HandleRefundFailed(&data)
+		},
+	}
+}
+
+// HandlePaymentCreated links the order to the payment the customer started
+// and waits for it until it expires.
+func (s *OrderService) HandlePaymentCreated(event *messages.PaymentCreatedEvent) error {
+	order, paymentID, err := s.getPaymentOrder(event.OrderID, event.PaymentID)
+	if err != nil || order == nil {
+		return err
+	}
+	if order.PaymentStatus == models.PaymentStatusPaid {
+		return nil
+	}
+
+	if err := s.extendPaymentDeadline(order.ID, paymentID, event.ExpiredAt); err != nil {
+		return err
+	}
+
+	return s.orderRepo.UpdatePaymentStatus(order.ID, paymentID, models.PaymentStatusPending)
+}
+
+// HandlePaymentSuccess marks the order paid, confirms it and completes its
+// checkout. Orders that already moved on keep their status, and a payment
+// for an order cancelled meanwhile is refunded without marking it paid.
+func (s *OrderService) HandlePaymentSuccess(event *messages.PaymentSuccessEvent) error {
+	order, paymentID, err := s.getPaymentOrder(event.OrderID, event.PaymentID)
+	if err != nil || order == nil {
+		return err
+	}
+
+	if order.Status == models.OrderStatusCancelled {
+		log.Printf("Order %s was paid by payment %s after it was cancelled; refunding it", order.ID, paymentID)
+		if err := s.compensateSaga(order.ID, SagaReasonCancelled); err != nil {
+			return err
+		}
+		return s.voidPayment(order.ID, SagaReasonCancelled)
+	}
+
+	if err := s.orderRepo.UpdatePaymentStatus(order.ID, paymentID, models.PaymentStatusPaid); err != nil {
+		return err
+	}
+
+	if !models.CanTransitionOrderStatus(order.Status, models.OrderStatusConfirmed) {
+		return s.completeCheckout(order.ID, paymentID)
+	}
+
+	outboxEvent, err := s.orderStatusEvent(order.ID, models.OrderStatusConfirmed)
+	if err != nil {
+		return err
+	}
+	if err := s.orderRepo.UpdateOrderStatus(order.ID, models.OrderStatusConfirmed, "Payment received", uuid.Nil, models.ActorSystem, outboxEvent); err != nil {
+		if !errors.Is(err, models.ErrInvalidOrderStatusTransition) {
+			return err
+		}
+	}
+
+	return s.completeCheckout(order.ID, paymentID)
+}
+
+// HandlePaymentFailed records that the order's payment attempt failed, so the
+// customer can start a new one.
+func (s *OrderService) HandlePaymentFailed(event *messages.PaymentFailedEvent) error {
+	order, paymentID, err := s.getPaymentOrder(event.OrderID, event.PaymentID)
+	if err != nil || order == nil {
+		return err
+	}
+	// A stale failure for an attempt the customer already replaced is ignored
+	if order.PaymentStatus == models.PaymentStatusPaid || (order.PaymentID != uuid.Nil && order.PaymentID != paymentID) {
+		return nil
+	}
+
+	return s.orderRepo.UpdatePaymentStatus(order.ID, paymentID, models.PaymentStatusFailed)
+}
+
+// getPaymentOrder parses the IDs carried by a payment event and loads the
+// order. Events for unknown orders are logged and yield a nil order.
+func (s *OrderService) getPaymentOrder(orderIDStr, paymentIDStr string) (*models.Order, uuid.UUID, error) {
+	orderID, err := uuid.Parse(orderIDStr)
+	if err != nil {
+		return nil, uuid.Nil, fmt.Errorf("invalid order ID %q: %w", orderIDStr, err)
+	}
+	paymentID, err := uuid.Parse(paymentIDStr)
+	if err != nil {
+		return nil, uuid.Nil, fmt.Errorf("invalid payment ID %q: %w", paymentIDStr, err)
+	}
+
+	order, err := s.orderRepo.GetOrderByIDForAdmin(orderID)
+	if err != nil {
+		return nil, uuid.Nil, err
+	}
+	if order == nil {
+		log.Printf("Ignoring payment event for unknown order %s", orderID)
+	}
+	return order, paymentID, nil
+}
+
+func (s *OrderService) GetOrderStatus(orderID uuid.UUID, userID uuid.UUID) (*OrderStatusResponse, error) {
+	order, err := s.GetOrderByID(orderID, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	histories, err := s.orderRepo.GetOrderStatusHistories(orderID)
+	if err != nil {
+		return nil, err
+	}
+
+	return &OrderStatusResponse{
+		OrderID:             order.ID,
+		OrderNumber:         order.OrderNumber,
+		Status:              order.Status,
+		PaymentStatus:       order.PaymentStatus,
+		AllowedNextStatuses: models.NextOrderStatuses(order.Status),
+		ShippingDate:        order.ShippingDate,
+		DeliveryDate:        order.DeliveryDate,
+		History:             histories,
+	}, nil
+}
+
+// buildOrder prices the requested items against the product service and
+// returns an unsaved pending order with its items and totals filled in. The
+// items are split into one fulfillment per seller, each shipped and charged
+// for shipping separately.
+func (s *OrderService) buildOrder(userID uuid.UUID, items []OrderItemRequest, shipping *ShippingAddressRequest) (*models.Order, error) {
+	var subtotal, shippingCost float64
+	orderItems := make([]models.OrderItem, 0, len(items))
+	fulfillments := make([]models.Fulfillment, 0, 1)
+	sellerFulfillment := make(map[uuid.UUID]int)
+	sellerWeight := make(map[uuid.UUID]float64)
+
+	// A product may be listed more than once; its stock must cover all lines
+	quantities := make(map[uuid.UUID]int, len(items))
+	for _, item := range items {
+		quantities[item.ProductID] += item.Quantity
+	}
+	products := make(map[uuid.UUID]*models.Product, len(quantities))
+
+	for _, item := range items {
+		product, ok := products[item.ProductID]
+		if !ok {
+			var err error
+			product, err = s.productClient.GetProduct(item.ProductID)
+			if err != nil {
+				return nil, err
+			}
+			if product == nil || !product.IsActive {
+				return nil, fmt.Errorf("product %s not found", item.ProductID)
+			}
+			if product.Stock < quantities[item.ProductID] {
+				return nil, fmt.Errorf("insufficient stock for product %s", product.Name)
+			}
+			products[item.ProductID] = product
+		}
+
+		itemSubtotal := product.Price * float64(item.Quantity)
+		subtotal += itemSubtotal
+
+		index, ok := sellerFulfillment[product.SellerID]
+		if !ok {
+			index = len(fulfillments)
+			sellerFulfillment[product.SellerID] = index
+			fulfillments = append(fulfillments, models.Fulfillment{
+				ID:       uuid.New(),
+				SellerID: product.SellerID,
+				Status:   models.OrderStatusPending,
+			})
+		}
+		fulfillments[index].Subtotal += itemSubtotal
+		sellerWeight[product.SellerID] += product.Weight * float64(item.Quantity)
+
+		orderItems = append(orderItems, models.OrderItem{
+			ID:            uuid.New(),
+			FulfillmentID: fulfillments[index].ID,
+			SellerID:      product.SellerID,
+			ProductID:     product.ID,
+			ProductName:   product.Name,
+			Quantity:      item.Quantity,
+			Price:         product.Price,
+			Subtotal:      itemSubtotal,
+		})
+	}
+
+	for i := range fulfillments {
+		fulfillments[i].ShippingCost = calculateShippingCost(sellerWeight[fulfillments[i].SellerID])
+		shippingCost += fulfillments[i].ShippingCost
+	}
+
+	orderNumber, err := generateOrderNumber()
+	if err != nil {
+		return nil, err
+	}
+
+	return &models.Order{
+		ID:            uuid.New(),
+		UserID:        userID,
+		OrderNumber:   orderNumber,
+		CreatedAt:     time.Now(),
+		Status:        models.OrderStatusPending,
+		Subtotal:      subtotal,
+		ShippingCost:  shippingCost,
+		TotalAmount:   subtotal + shippingCost,
+		Address:       shipping.Address,
+		City:          shipping.City,
+		Province:      shipping.Province,
+		PostalCode:    shipping.PostalCode,
+		PaymentStatus: "pending",
+		Notes:         shipping.Notes,
+		Items:         orderItems,
+		Fulfillments:  fulfillments,
+	}, nil
+}
+
+const (
+	shippingBaseCost  = 10000 // IDR, covers the first kilogram
+	shippingCostPerKg = 5000  // IDR, per additional started kilogram
+)
+
+// calculateShippingCost charges a flat rate for the first kilogram and a
+// per-kilogram rate for every additional started kilogram.
+func calculateShippingCost(weightKg float64) float64 {
+	extraKg := math.Ceil(weightKg) - 1
+	if extraKg < 0 {
+		extraKg = 0
+	}
+	return shippingBaseCost + extraKg*shippingCostPerKg
+}
+
+// orderNumberAlphabet avoids characters that are easily confused when an
+// order number is read out loud or typed in (0/O, 1/I/L).
+const orderNumberAlphabet = "23456789ABCDEFGHJKMNPQRSTUVWXYZ"
+
+// generateOrderNumber returns a human-readable order number such as
+// ORD-20240131-K7M2QX.
+func generateOrderNumber() (string, error) {
+	suffix := make([]byte, 6)
+	if _, err := rand.Read(suffix); err != nil {
+		return "", fmt.Errorf("failed to generate order number: %w", err)
+	}
+	for i, b := range suffix {
+		suffix[i] = orderNumberAlphabet[int(b)%len(orderNumberAlphabet)]
+	}
+
+	return fmt.Sprintf("ORD-%s-%s", time.Now().Format("20060102"), suffix), nil
+}
+
+func (s *OrderService) orderCreatedEvent(order *models.Order) (*models.OutboxEvent, error) {
+	items := make([]messages.OrderItemEvent, 0, len(order.Items))
+	for _, item := range order.Items {
+		items = append(items, messages.OrderItemEvent{
+			ProductID: item.ProductID.String(),
+			Quantity:  item.Quantity,
+		})
+	}
+
+	event := messages.NewEvent(messages.EventOrderCreated, "order-service", messages.OrderCreatedEvent{
+		OrderID:     order.ID.String(),
+		OrderNumber: order.OrderNumber,
+		UserID:      order.UserID.String(),
+		Total:       order.TotalAmount,
+		Status:      order.Status,
+		CreatedAt:   order.CreatedAt,
+		Items:       items,
+	})
+
+	return models.NewOutboxEvent(messages.ExchangeOrder, event)
+}
+
+// orderStatusEvent returns the order.updated event announcing a move to
+// status. Cancellations are announced by orderCancelledEvent instead.
+func (s *OrderService) orderStatusEvent(orderID uuid.UUID, status string) (*models.OutboxEvent, error) {
+	event := messages.NewEvent(messages.EventOrderUpdated, "order-service", messages.OrderUpdatedEvent{
+		OrderID: orderID.String(),
+		Status:  status,
+	})
+
+	return models.NewOutboxEvent(messages.ExchangeOrder, event)
+}
+
+// orderCancelledEvent returns the order.cancelled event. reason is a
+// machine-readable code such as payment_expired; notes is the text recorded
+// in the status history.
+func (s *OrderService) orderCancelledEvent(orderID uuid.UUID, reason, notes string) (*models.OutboxEvent, error) {
+	event := messages.NewEvent(messages.EventOrderCancelled, "order-service", messages.OrderCancelledEvent{
+		OrderID: orderID.String(),
+		Reason:  reason,
+		Notes:   notes,
+	})
+
+	return models.NewOutboxEvent(messages.ExchangeOrder, event)
+}
@@ -0,0 +1,119 @@
+package service
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/be-bcv/ecommerce-backend/internal/client"
+	"github.com/be-bcv/ecommerce-backend/internal/models"
+	"github.com/google/uuid"
+)
+
+func TestBuildOrderStock(t *testing.T) {
+	product := &models.Product{
+		ID:       uuid.New(),
+		Name:     "Test Product",
+		Price:    10000,
+		Stock:    3,
+		SellerID: uuid.New(),
+		IsActive: true,
+		Weight:   0.5,
+	}
+	other := &models.Product{
+		ID:       uuid.New(),
+		Name:     "Other Product",
+		Price:    5000,
+		Stock:    1,
+		SellerID: product.SellerID,
+		IsActive: true,
+	}
+
+	tests := []struct {
+		name         string
+		items        []OrderItemRequest
+		wantErr      string
+		wantItems    int
+		wantSubtotal float64
+	}{
+		{
+			name:         "within stock",
+			items:        []OrderItemRequest{{product.ID, 3}},
+			wantItems:    1,
+			wantSubtotal: 30000,
+		},
+		{
+			name:    "over stock",
+			items:   []OrderItemRequest{{product.ID, 4}},
+			wantErr: "insufficient stock",
+		},
+		{
+			name:         "duplicate lines within stock",
+			items:        []OrderItemRequest{{product.ID, 1}, {other.ID, 1}, {product.ID, 2}},
+			wantItems:    3,
+			wantSubtotal: 35000,
+		},
+		{
+			name:    "duplicate lines each within stock but not together",
+			items:   []OrderItemRequest{{product.ID, 2}, {product.ID, 2}},
+			wantErr: "insufficient stock",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var requests atomic.Int32
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				requests.Add(1)
+				for _, p := range []*models.Product{product, other} {
+					if strings.HasSuffix(r.URL.Path, "/products/"+p.ID.String()) {
+						json.NewEncoder(w).Encode(productEnvelope{Status: "success", Data: p})
+						return
+					}
+				}
+				w.WriteHeader(http.StatusNotFound)
+			}))
+			defer server.Close()
+
+			s := &OrderService{productClient: client.NewProductClient(server.URL)}
+			order, err := s.buildOrder(uuid.New(), tt.items, &ShippingAddressRequest{Address: "Jl. Test 1"})
+
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("buildOrder() error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("buildOrder() error = %v", err)
+			}
+			if len(order.Items) != tt.wantItems || order.Subtotal != tt.wantSubtotal {
+				t.Errorf("buildOrder() = %d items for %v, want %d for %v", len(order.Items), order.Subtotal, tt.wantItems, tt.wantSubtotal)
+			}
+			if len(order.Fulfillments) != 1 {
+				t.Errorf("fulfillments = %d, want 1 for the single seller", len(order.Fulfillments))
+			}
+			// Each product is fetched once
+			if got, want := int(requests.Load()), countProducts(tt.items); got != want {
+				t.Errorf("product requests = %d, want %d", got, want)
+			}
+		})
+	}
+}
+
+// productEnvelope is the product-service response the client decodes.
+type productEnvelope struct {
+	Status string          `json:"status"`
+	Data   *models.Product `json:"data"`
+}
+
+func countProducts(items []OrderItemRequest) int {
+	products := make(map[uuid.UUID]bool)
+	for _, item := range items {
+		products[item.ProductID] = true
+	}
+	return len(products)
+}
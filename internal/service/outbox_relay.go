@@ -0,0 +1,94 @@
+package service
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"github.com/be-bcv/ecommerce-backend/internal/models"
+	"github.com/be-bcv/ecommerce-backend/internal/repository"
+	"github.com/be-bcv/ecommerce-backend/pkg/rabbitmq"
+)
+
+const (
+	outboxPollInterval = time.Second
+	outboxBatchSize    = 100
+	outboxMaxBackoff   = 5 * time.Minute
+	// Published events are kept for a while to help with debugging
+	outboxRetention  = 7 * 24 * time.Hour
+	outboxPurgeEvery = time.Hour
+)
+
+// OutboxRelay drains the outbox table to RabbitMQ. Events are marked sent
+// only after the broker confirms them, so delivery is at-least-once and
+// consumers must tolerate duplicates (the AMQP message-id is the event ID).
+type OutboxRelay struct {
+	outboxRepo *repository.OutboxRepository
+	publisher  *rabbitmq.Publisher
+}
+
+func NewOutboxRelay(outboxRepo *repository.OutboxRepository, publisher *rabbitmq.Publisher) *OutboxRelay {
+	return &OutboxRelay{
+		outboxRepo: outboxRepo,
+		publisher:  publisher,
+	}
+}
+
+// Run polls the outbox until ctx is cancelled. Several relays may run against
+// the same database; each event is claimed by only one of them.
+func (r *OutboxRelay) Run(ctx context.Context) {
+	ticker := time.NewTicker(outboxPollInterval)
+	defer ticker.Stop()
+	lastPurge := time.Now()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		r.drain(ctx)
+
+		if time.Since(lastPurge) >= outboxPurgeEvery {
+			if _, err := r.outboxRepo.DeleteSentBefore(time.Now().Add(-outboxRetention)); err != nil {
+				log.Printf("Failed to purge outbox: %v", err)
+			}
+			lastPurge = time.Now()
+		}
+	}
+}
+
+// drain publishes due events batch by batch until the outbox has no more
+// due events or a publish fails.
+func (r *OutboxRelay) drain(ctx context.Context) {
+	for ctx.Err() == nil {
+		var publishErr error
+		sent, err := r.outboxRepo.ProcessDue(outboxBatchSize, func(event *models.OutboxEvent) error {
+			publishErr = r.publisher.PublishEncoded(event.Exchange, []byte(event.Payload))
+			if publishErr != nil {
+				log.Printf("Failed to relay %s event %s (attempt %d): %v", event.EventName, event.ID, event.Attempts+1, publishErr)
+			}
+			return publishErr
+		}, outboxRetryDelay)
+		if err != nil {
+			log.Printf("Failed to process outbox: %v", err)
+			return
+		}
+		if publishErr != nil || sent < outboxBatchSize {
+			return
+		}
+	}
+}
+
+// outboxRetryDelay backs off exponentially from one second up to outboxMaxBackoff.
+func outboxRetryDelay(attempts int) time.Duration {
+	delay := time.Second
+	for i := 1; i < attempts && delay < outboxMaxBackoff; i++ {
+		delay *= 2
+	}
+	if delay > outboxMaxBackoff {
+		delay = outboxMaxBackoff
+	}
+	return delay
+}
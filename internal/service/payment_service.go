@@ -0,0 +1,679 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/be-bcv/ecommerce-backend/internal/models"
+	"github.com/be-bcv/ecommerce-backend/internal/repository"
+	"github.com/be-bcv/ecommerce-backend/pkg/config"
+	"github.com/be-bcv/ecommerce-backend/pkg/messages"
+	"github.com/be-bcv/ecommerce-backend/pkg/midtrans"
+	"github.com/be-bcv/ecommerce-backend/pkg/rabbitmq"
+	"github.com/be-bcv/ecommerce-backend/pkg/redis"
+	"github.com/google/uuid"
+)
+
+// Payment Service
+type PaymentService struct {
+	paymentRepo *repository.PaymentRepository
+	ledgerRepo  *repository.LedgerRepository
+	outboxRepo  *repository.OutboxRepository
+	midtrans    *midtrans.Client
+	redis       *redis.RedisClient
+	config      *config.Config
+}
+
+func NewPaymentService(paymentRepo *repository.PaymentRepository, ledgerRepo *repository.LedgerRepository, outboxRepo *repository.OutboxRepository, redis *redis.RedisClient, config *config.Config) *PaymentService {
+	return &PaymentService{
+		paymentRepo: paymentRepo,
+		ledgerRepo:  ledgerRepo,
+		outboxRepo:  outboxRepo,
+		midtrans: midtrans.NewClient(midtrans.Config{
+			ServerKey:   config.MidtransServerKey,
+			Environment: config.MidtransEnvironment,
+			APIURL:      config.MidtransAPIURL,
+			SnapURL:     config.MidtransSnapURL,
+		}),
+		redis:  redis,
+		config: config,
+	}
+}
+
+// paymentExpiry is how long a customer has to complete a Midtrans payment.
+const paymentExpiry = 24 * time.Hour
+
+// Payment methods accepted by CreatePayment. bank_transfer is charged through
+// the Core API to get a virtual account; the rest go through Snap.
+const (
+	PaymentMethodSnap         = "snap"
+	PaymentMethodCreditCard   = "credit_card"
+	PaymentMethodEWallet      = "e_wallet"
+	PaymentMethodBankTransfer = "bank_transfer"
+)
+
+// snapEnabledPayments restricts the Snap page to the chosen method. A nil
+// entry lets the customer pick any method enabled on the merchant account.
+var snapEnabledPayments = map[string][]string{
+	PaymentMethodSnap:       nil,
+	PaymentMethodCreditCard: {"credit_card"},
+	PaymentMethodEWallet:    {"gopay", "shopeepay"},
+}
+
+type CreatePaymentRequest struct {
+	OrderID uuid.UUID `json:"order_id" binding:"required"`
+	Method  string    `json:"method" binding:"required,oneof=snap credit_card e_wallet bank_transfer"`
+	Bank    string    `json:"bank" binding:"required_if=Method bank_transfer,omitempty,oneof=bca bni bri permata cimb"`
+}
+
+// CreatePayment starts a Midtrans transaction for an order. Orders get a
+// pending payment once the checkout saga has reserved their stock; the customer's first
+// attempt charges that payment, and a new attempt is created only after the
+// previous one expired or failed.
+func (s *PaymentService) CreatePayment(userID uuid.UUID, req *CreatePaymentRequest) (*models.Payment, error) {
+	latest, err := s.paymentRepo.GetPaymentByOrderID(req.OrderID)
+	if err != nil {
+		return nil, err
+	}
+	if latest == nil || latest.UserID != userID {
+		return nil, errors.New("order not found")
+	}
+
+	payment := latest
+	switch latest.Status {
+	case models.PaymentStatusPaid, models.PaymentStatusRefunded:
+		return nil, errors.New("order already paid")
+	case models.PaymentStatusCancelled:
+		return nil, errors.New("order has been cancelled")
+	case models.PaymentStatusPending:
+		// Reuse the outstanding payment if the customer retries
+		if latest.Method != "" && latest.ExpiredAt.After(time.Now()) {
+			return latest, nil
+		}
+		if latest.Method != "" {
+			payment = newPaymentAttempt(latest)
+		}
+	default:
+		payment = newPaymentAttempt(latest)
+	}
+
+	payment.Method = req.Method
+	payment.ExpiredAt = time.Now().Add(paymentExpiry)
+	// Midtrans order IDs must be unique per transaction, and a new payment may
+	// be created for the same order after the previous one expired.
+	payment.MidtransID = fmt.Sprintf("%s-%s", payment.OrderNumber, payment.ID.String()[:8])
+
+	if req.Method == PaymentMethodBankTransfer {
+		err = s.chargeBankTransfer(payment, req.Bank)
+	} else {
+		err = s.createSnapTransaction(payment)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	outboxEvent, err := s.paymentCreatedEvent(payment)
+	if err != nil {
+		return nil, err
+	}
+	if payment == latest {
+		err = s.paymentRepo.UpdatePayment(payment, outboxEvent)
+	} else {
+		err = s.paymentRepo.CreatePayment(payment, outboxEvent)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return payment, nil
+}
+
+// newPaymentAttempt returns a fresh pending payment for the same order as prev.
+func newPaymentAttempt(prev *models.Payment) *models.Payment {
+	return &models.Payment{
+		ID:          uuid.New(),
+		OrderID:     prev.OrderID,
+		OrderNumber: prev.OrderNumber,
+		UserID:      prev.UserID,
+		Amount:      prev.Amount,
+		Status:      models.PaymentStatusPending,
+	}
+}
+
+func (s *PaymentService) createSnapTransaction(payment *models.Payment) error {
+	resp, err := s.midtrans.CreateSnapTransaction(&midtrans.SnapRequest{
+		TransactionDetails: midtrans.TransactionDetails{
+			OrderID:     payment.MidtransID,
+			GrossAmount: grossAmount(payment.Amount),
+		},
+		EnabledPayments: snapEnabledPayments[payment.Method],
+		Expiry: &midtrans.SnapExpiry{
+			Unit:     "minute",
+			Duration: int(paymentExpiry.Minutes()),
+		},
+	})
+	if err != nil {
+		return fmt.Errorf("failed to create Midtrans transaction: %w", err)
+	}
+
+	payment.SnapToken = resp.Token
+	payment.PaymentURL = resp.RedirectURL
+	return nil
+}
+
+func (s *PaymentService) chargeBankTransfer(payment *models.Payment, bank string) error {
+	resp, err := s.midtrans.Charge(&midtrans.ChargeRequest{
+		PaymentType: "bank_transfer",
+		TransactionDetails: midtrans.TransactionDetails{
+			OrderID:     payment.MidtransID,
+			GrossAmount: grossAmount(payment.Amount),
+		},
+		BankTransfer: &midtrans.BankTransferDetails{
+			Bank: bank,
+		},
+		CustomExpiry: &midtrans.CustomExpiry{
+			ExpiryDuration: int(paymentExpiry.Minutes()),
+			Unit:           "minute",
+		},
+	})
+	if err != nil {
+		return fmt.Errorf("failed to create Midtrans virtual account: %w", err)
+	}
+
+	payment.MidtransVA = resp.VirtualAccount()
+	payment.TransactionID = resp.TransactionID
+	if expiresAt, ok := resp.ExpiresAt(); ok {
+		payment.ExpiredAt = expiresAt
+	}
+	return nil
+}
+
+// grossAmount converts an amount to the whole-rupiah integer Midtrans expects.
+func grossAmount(amount float64) int64 {
+	return int64(math.Round(amount))
+}
+
+func (s *PaymentService) GetPaymentByID(paymentID uuid.UUID, userID uuid.UUID) (*models.Payment, error) {
+	payment, err := s.paymentRepo.GetPaymentByID(paymentID)
+	if err != nil {
+		return nil, err
+	}
+	if payment == nil || payment.UserID != userID {
+		return nil, ErrPaymentNotFound
+	}
+	return payment, nil
+}
+
+var (
+	ErrInvalidSignature = errors.New("invalid notification signature")
+	ErrPaymentNotFound  = errors.New("payment not found")
+)
+
+// HandleNotification applies a Midtrans payment notification. Notifications
+// are verified against the server key, and replays of a status the payment
+// already has are acknowledged without side effects, since Midtrans retries
+// until it gets a 2xx response.
+func (s *PaymentService) HandleNotification(n *midtrans.Notification) error {
+	if !s.midtrans.VerifySignature(n) {
+		return ErrInvalidSignature
+	}
+
+	payment, err := s.paymentRepo.GetPaymentByMidtransID(n.OrderID)
+	if err != nil {
+		return err
+	}
+	if payment == nil {
+		return ErrPaymentNotFound
+	}
+
+	amount, err := strconv.ParseFloat(n.GrossAmount, 64)
+	if err != nil || grossAmount(amount) != grossAmount(payment.Amount) {
+		return fmt.Errorf("gross amount %s does not match payment amount", n.GrossAmount)
+	}
+
+	status := mapTransactionStatus(n.TransactionStatus, n.FraudStatus)
+	if status == "" {
+		log.Printf("Ignoring Midtrans notification for %s with status %s", n.OrderID, n.TransactionStatus)
+		return nil
+	}
+	if status == payment.Status || !canTransitionPayment(payment.Status, status) {
+		return nil
+	}
+
+	var events []*models.OutboxEvent
+	switch status {
+	case models.PaymentStatusPaid:
+		outboxEvent, err := s.paymentSuccessEvent(payment)
+		if err != nil {
+			return err
+		}
+		events = append(events, outboxEvent)
+	case models.PaymentStatusFailed, models.PaymentStatusCancelled, models.PaymentStatusExpired:
+		outboxEvent, err := s.paymentFailedEvent(payment, status)
+		if err != nil {
+			return err
+		}
+		events = append(events, outboxEvent)
+	}
+
+	// Only the delivery that actually moves the payment enqueues its event,
+	// in the same transaction, so concurrent retries cannot double-confirm
+	// an order and a broker outage cannot lose the confirmation.
+	_, err = s.paymentRepo.TransitionPaymentStatus(payment.ID, payment.Status, status, n.TransactionID, events...)
+	return err
+}
+
+// mapTransactionStatus translates a Midtrans transaction_status/fraud_status
+// pair into a payment status. It returns an empty string for statuses that do
+// not change the payment.
+func mapTransactionStatus(transactionStatus, fraudStatus string) string {
+	switch transactionStatus {
+	case "capture":
+		switch fraudStatus {
+		case "accept", "":
+			return models.PaymentStatusPaid
+		case "deny":
+			return models.PaymentStatusFailed
+		}
+		// "challenge" stays pending until the merchant reviews it
+		return models.PaymentStatusPending
+	case "settlement":
+		return models.PaymentStatusPaid
+	case "pending":
+		return models.PaymentStatusPending
+	case "deny", "failure":
+		return models.PaymentStatusFailed
+	case "cancel":
+		return models.PaymentStatusCancelled
+	case "expire":
+		return models.PaymentStatusExpired
+	case "refund", "partial_refund":
+		return models.PaymentStatusRefunded
+	}
+	return ""
+}
+
+// canTransitionPayment reports whether a notification may move a payment
+// from one status to another. Out-of-order notifications must not resurrect
+// a settled payment, so only pending payments move freely. A payment voided
+// without reaching Midtrans can still be paid on its Snap page; that
+// settlement is recorded so the cancelled order's saga refunds it.
+func canTransitionPayment(from, to string) bool {
+	switch from {
+	case models.PaymentStatusPending:
+		return true
+	case models.PaymentStatusPaid:
+		return to == models.PaymentStatusRefunded
+	case models.PaymentStatusCancelled:
+		return to == models.PaymentStatusPaid
+	}
+	return false
+}
+
+// CommandHandlers returns the handlers for the checkout saga commands
+// payment-service consumes.
+func (s *PaymentService) CommandHandlers() map[string]rabbitmq.EventHandler {
+	return map[string]rabbitmq.EventHandler{
+		messages.CommandCreatePayment: func(event *messages.RawEventMessage) error {
+			var data messages.CreatePaymentCommand
+			if err := event.Decode(&data); err != nil {
+				return err
+			}
+			return s.HandleCreatePayment(&data)
+		},
+		messages.CommandVoidPayment: func(event *messages.RawEventMessage) error {
+			var data messages.VoidPaymentCommand
+			if err := event.Decode(&data); err != nil {
+				return err
+			}
+			return s.HandleVoidPayment(&data)
+		},
+	}
+}
+
+// HandleCreatePayment opens a pending payment for an order and replies with
+// payment.opened, stored with the payment. Redelivered commands find the
+// existing payment and only repeat the reply.
+func (s *PaymentService) HandleCreatePayment(command *messages.CreatePaymentCommand) error {
+	orderID, err := uuid.Parse(command.OrderID)
+	if err != nil {
+		return fmt.Errorf("invalid order ID %q: %w", command.OrderID, err)
+	}
+	userID, err := uuid.Parse(command.UserID)
+	if err != nil {
+		return fmt.Errorf("invalid user ID %q: %w", command.UserID, err)
+	}
+
+	payment, err := s.paymentRepo.GetPaymentByOrderID(orderID)
+	if err != nil {
+		return err
+	}
+	if payment != nil {
+		outboxEvent, err := s.paymentOpenedEvent(payment)
+		if err != nil {
+			return err
+		}
+		return s.outboxRepo.Enqueue(outboxEvent)
+	}
+
+	payment = &models.Payment{
+		ID:          uuid.New(),
+		OrderID:     orderID,
+		OrderNumber: command.OrderNumber,
+		UserID:      userID,
+		Amount:      command.Amount,
+		Status:      models.PaymentStatusPending,
+		ExpiredAt:   time.Now().Add(paymentExpiry),
+	}
+	outboxEvent, err := s.paymentOpenedEvent(payment)
+	if err != nil {
+		return err
+	}
+	return s.paymentRepo.CreatePayment(payment, outboxEvent)
+}
+
+// HandleVoidPayment cancels the order's outstanding payment, at Midtrans too
+// if the customer already started it, so it can no longer be paid. A payment
+// that already settled, e.g. of a confirmed order, is refunded in full.
+func (s *PaymentService) HandleVoidPayment(command *messages.VoidPaymentCommand) error {
+	orderID, err := uuid.Parse(command.OrderID)
+	if err != nil {
+		return fmt.Errorf("invalid order ID %q: %w", command.OrderID, err)
+	}
+
+	settled, err := s.paymentRepo.GetSettledPaymentByOrderID(orderID)
+	if err != nil {
+		return err
+	}
+	if settled != nil {
+		return s.refundCancelledPayment(settled, command.Reason)
+	}
+
+	payment, err := s.paymentRepo.GetPaymentByOrderID(orderID)
+	if err != nil {
+		return err
+	}
+	if payment == nil || payment.Status != models.PaymentStatusPending {
+		return nil
+	}
+
+	if payment.Method != "" {
+		if _, err := s.midtrans.Cancel(payment.MidtransID); err != nil {
+			var midtransErr *midtrans.Error
+			if !errors.As(err, &midtransErr) {
+				return fmt.Errorf("failed to cancel Midtrans transaction: %w", err)
+			}
+			switch midtransErr.StatusCode {
+			case http.StatusNotFound:
+				// The customer never picked a payment method on the Snap
+				// page, so Midtrans has no transaction to cancel. The page
+				// stays payable until it expires; a payment made there
+				// anyway is let through by canTransitionPayment and refunded
+			case http.StatusPreconditionFailed:
+				// Already settled or expired; the notification decides
+				log.Printf("Midtrans transaction %s could not be voided: %v", payment.MidtransID, err)
+				return nil
+			default:
+				return fmt.Errorf("failed to cancel Midtrans transaction: %w", err)
+			}
+		}
+	}
+
+	_, err = s.paymentRepo.TransitionPaymentStatus(payment.ID, models.PaymentStatusPending, models.PaymentStatusCancelled, "")
+	return err
+}
+
+// refundCancelledPayment refunds what is left of a settled payment whose
+// order was cancelled and replies with payment.refunded. The payment ID is
+// the refund key, so redelivered commands do not refund twice.
+func (s *PaymentService) refundCancelledPayment(payment *models.Payment, reason string) error {
+	refund, err := s.paymentRepo.GetRefundByKey(payment.ID.String())
+	if err != nil {
+		return err
+	}
+	if refund == nil {
+		refunded, err := s.paymentRepo.GetRefundedTotal(payment.ID)
+		if err != nil {
+			return err
+		}
+		if grossAmount(refunded) >= grossAmount(payment.Amount) {
+			return nil
+		}
+
+		refund = &models.Refund{
+			ID:        uuid.New(),
+			PaymentID: payment.ID,
+			OrderID:   payment.OrderID,
+			RefundKey: payment.ID.String(),
+			Amount:    payment.Amount - refunded,
+			Reason:    fmt.Sprintf("Order cancelled (%s)", reason),
+			Status:    models.RefundStatusPending,
+		}
+		if err := s.paymentRepo.CreateRefund(refund); err != nil {
+			return err
+		}
+	}
+
+	if refund.Status != models.RefundStatusSucceeded {
+		_, err = s.midtrans.Refund(payment.MidtransID, &midtrans.RefundRequest{
+			RefundKey: refund.RefundKey,
+			Amount:    grossAmount(refund.Amount),
+			Reason:    refund.Reason,
+		})
+		if err != nil {
+			var midtransErr *midtrans.Error
+			if !errors.As(err, &midtransErr) {
+				return fmt.Errorf("failed to refund Midtrans transaction: %w", err)
+			}
+			// Retrying will not help; the refund is left for an admin
+			log.Printf("Midtrans rejected the refund of payment %s of cancelled order %s: %v", payment.ID, payment.OrderID, err)
+			return s.paymentRepo.UpdateRefundStatus(refund.ID, models.RefundStatusFailed, midtransErr.Error())
+		}
+
+		if err := s.paymentRepo.CompleteRefund(refund); err != nil {
+			return err
+		}
+	}
+
+	outboxEvent, err := s.paymentRefundedEvent(refund)
+	if err != nil {
+		return err
+	}
+	return s.outboxRepo.Enqueue(outboxEvent)
+}
+
+// ReturnEventHandlers returns the handlers for the order events that
+// refund returns.
+func (s *PaymentService) ReturnEventHandlers() map[string]rabbitmq.EventHandler {
+	return map[string]rabbitmq.EventHandler{
+		messages.EventReturnApproved: func(event *messages.RawEventMessage) error {
+			var data messages.ReturnApprovedEvent
+			if err := event.Decode(&data); err != nil {
+				return err
+			}
+			return s.HandleReturnApproved(&data)
+		},
+	}
+}
+
+// HandleReturnApproved refunds an approved return through Midtrans, takes
+// the refund back from the sellers' earnings and replies with
+// payment.refunded, or payment.refund_failed if Midtrans rejects the refund.
+// The return ID is the Midtrans refund key, and redelivered events find the
+// refund already made and only repeat the rest. Replies go through the
+// outbox, and may be repeated too.
+func (s *PaymentService) HandleReturnApproved(event *messages.ReturnApprovedEvent) error {
+	orderID, err := uuid.Parse(event.OrderID)
+	if err != nil {
+		return fmt.Errorf("invalid order ID %q: %w", event.OrderID, err)
+	}
+	returnID, err := uuid.Parse(event.ReturnID)
+	if err != nil {
+		return fmt.Errorf("invalid return ID %q: %w", event.ReturnID, err)
+	}
+
+	refund, err := s.paymentRepo.GetRefundByKey(returnID.String())
+	if err != nil {
+		return err
+	}
+	if refund == nil || refund.Status != models.RefundStatusSucceeded {
+		payment, err := s.paymentRepo.GetSettledPaymentByOrderID(orderID)
+		if err != nil {
+			return err
+		}
+		if payment == nil {
+			outboxEvent, err := s.refundFailedEvent(event, "order has no settled payment")
+			if err != nil {
+				return err
+			}
+			return s.outboxRepo.Enqueue(outboxEvent)
+		}
+
+		if refund == nil {
+			refund = &models.Refund{
+				ID:        uuid.New(),
+				PaymentID: payment.ID,
+				OrderID:   orderID,
+				ReturnID:  &returnID,
+				RefundKey: returnID.String(),
+				Amount:    event.Amount,
+				Reason:    event.Reason,
+				Status:    models.RefundStatusPending,
+			}
+			if err := s.paymentRepo.CreateRefund(refund); err != nil {
+				return err
+			}
+		}
+
+		refunded, err := s.paymentRepo.GetRefundedTotal(payment.ID)
+		if err != nil {
+			return err
+		}
+		if grossAmount(refunded+refund.Amount) > grossAmount(payment.Amount) {
+			return s.failRefund(refund, event, "refund exceeds the amount left on the payment")
+		}
+
+		_, err = s.midtrans.Refund(payment.MidtransID, &midtrans.RefundRequest{
+			RefundKey: refund.RefundKey,
+			Amount:    grossAmount(refund.Amount),
+			Reason:    refund.Reason,
+		})
+		if err != nil {
+			// Midtrans answered, so retrying will not help; anything else
+			// is retried with the event
+			var midtransErr *midtrans.Error
+			if !errors.As(err, &midtransErr) {
+				return fmt.Errorf("failed to refund Midtrans transaction: %w", err)
+			}
+			return s.failRefund(refund, event, midtransErr.Error())
+		}
+
+		if err := s.paymentRepo.CompleteRefund(refund); err != nil {
+			return err
+		}
+	}
+
+	items := make([]repository.RefundedItem, 0, len(event.Items))
+	for _, item := range event.Items {
+		orderItemID, err := uuid.Parse(item.OrderItemID)
+		if err != nil {
+			return fmt.Errorf("invalid order item ID %q: %w", item.OrderItemID, err)
+		}
+		items = append(items, repository.RefundedItem{OrderItemID: orderItemID, Quantity: item.Quantity})
+	}
+	if err := s.ledgerRepo.RecordRefund(returnID, items); err != nil {
+		return err
+	}
+
+	outboxEvent, err := s.paymentRefundedEvent(refund)
+	if err != nil {
+		return err
+	}
+	return s.outboxRepo.Enqueue(outboxEvent)
+}
+
+// failRefund records why the refund failed and tells order-service.
+func (s *PaymentService) failRefund(refund *models.Refund, event *messages.ReturnApprovedEvent, reason string) error {
+	outboxEvent, err := s.refundFailedEvent(event, reason)
+	if err != nil {
+		return err
+	}
+	return s.paymentRepo.UpdateRefundStatus(refund.ID, models.RefundStatusFailed, reason, outboxEvent)
+}
+
+func (s *PaymentService) paymentOpenedEvent(payment *models.Payment) (*models.OutboxEvent, error) {
+	event := messages.NewEvent(messages.EventPaymentOpened, "payment-service", messages.PaymentOpenedEvent{
+		PaymentID: payment.ID.String(),
+		OrderID:   payment.OrderID.String(),
+		ExpiredAt: payment.ExpiredAt,
+	})
+
+	return models.NewOutboxEvent(messages.ExchangePayment, event)
+}
+
+func (s *PaymentService) paymentCreatedEvent(payment *models.Payment) (*models.OutboxEvent, error) {
+	event := messages.NewEvent(messages.EventPaymentCreated, "payment-service", messages.PaymentCreatedEvent{
+		PaymentID:  payment.ID.String(),
+		OrderID:    payment.OrderID.String(),
+		UserID:     payment.UserID.String(),
+		Amount:     payment.Amount,
+		Method:     payment.Method,
+		Status:     payment.Status,
+		MidtransID: payment.MidtransID,
+		ExpiredAt:  payment.ExpiredAt,
+	})
+
+	return models.NewOutboxEvent(messages.ExchangePayment, event)
+}
+
+func (s *PaymentService) paymentSuccessEvent(payment *models.Payment) (*models.OutboxEvent, error) {
+	event := messages.NewEvent(messages.EventPaymentSuccess, "payment-service", messages.PaymentSuccessEvent{
+		PaymentID: payment.ID.String(),
+		OrderID:   payment.OrderID.String(),
+		Amount:    payment.Amount,
+	})
+
+	return models.NewOutboxEvent(messages.ExchangePayment, event)
+}
+
+func (s *PaymentService) paymentFailedEvent(payment *models.Payment, reason string) (*models.OutboxEvent, error) {
+	event := messages.NewEvent(messages.EventPaymentFailed, "payment-service", messages.PaymentFailedEvent{
+		PaymentID: payment.ID.String(),
+		OrderID:   payment.OrderID.String(),
+		Amount:    payment.Amount,
+		Reason:    reason,
+	})
+
+	return models.NewOutboxEvent(messages.ExchangePayment, event)
+}
+
+func (s *PaymentService) paymentRefundedEvent(refund *models.Refund) (*models.OutboxEvent, error) {
+	data := messages.PaymentRefundedEvent{
+		PaymentID: refund.PaymentID.String(),
+		OrderID:   refund.OrderID.String(),
+		Amount:    refund.Amount,
+	}
+	// Refunds of cancelled orders have no return
+	if refund.ReturnID != nil {
+		data.ReturnID = refund.ReturnID.String()
+	}
+	event := messages.NewEvent(messages.EventPaymentRefunded, "payment-service", data)
+
+	return models.NewOutboxEvent(messages.ExchangePayment, event)
+}
+
+func (s *PaymentService) refundFailedEvent(returnEvent *messages.ReturnApprovedEvent, reason string) (*models.OutboxEvent, error) {
+	event := messages.NewEvent(messages.EventRefundFailed, "payment-service", messages.RefundFailedEvent{
+		OrderID:  returnEvent.OrderID,
+		ReturnID: returnEvent.ReturnID,
+		Amount:   returnEvent.Amount,
+		Reason:   reason,
+	})
+
+	return models.NewOutboxEvent(messages.ExchangePayment, event)
+}
@@ -0,0 +1,431 @@
+package service
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/be-bcv/ecommerce-backend/internal/models"
+	"github.com/be-bcv/ecommerce-backend/internal/repository"
+	"github.com/be-bcv/ecommerce-backend/pkg/messages"
+	"github.com/be-bcv/ecommerce-backend/pkg/midtrans"
+	"github.com/google/uuid"
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+// testDB returns a transaction on the Postgres database in TEST_DATABASE_URL
+// with tables migrated, rolled back when the test ends, or skips the test if
+// none is configured.
+func testDB(t *testing.T, tables ...interface{}) *gorm.DB {
+	t.Helper()
+	dsn := os.Getenv("TEST_DATABASE_URL")
+	if dsn == "" {
+		t.Skip("TEST_DATABASE_URL is not set")
+	}
+
+	db, err := gorm.Open(postgres.Open(dsn), &gorm.Config{
+		DisableForeignKeyConstraintWhenMigrating: true,
+		Logger:                                   logger.Default.LogMode(logger.Silent),
+	})
+	if err != nil {
+		t.Fatalf("failed to connect to test database: %v", err)
+	}
+	if err := db.AutoMigrate(tables...); err != nil {
+		t.Fatalf("failed to migrate test database: %v", err)
+	}
+
+	tx := db.Begin()
+	if tx.Error != nil {
+		t.Fatalf("failed to begin transaction: %v", tx.Error)
+	}
+	t.Cleanup(func() {
+		tx.Rollback()
+		if sqlDB, err := db.DB(); err == nil {
+			sqlDB.Close()
+		}
+	})
+	return tx
+}
+
+// fakeMidtransRefunds is a Midtrans Core API that records refund requests
+// and answers them with status.
+type fakeMidtransRefunds struct {
+	mu       sync.Mutex
+	status   int
+	requests []midtrans.RefundRequest
+}
+
+func (f *fakeMidtransRefunds) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost || !strings.HasSuffix(r.URL.Path, "/refund") {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+
+	var req midtrans.RefundRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.requests = append(f.requests, req)
+
+	// Core API errors come with HTTP 200 and the status in the body
+	if f.status != http.StatusOK {
+		json.NewEncoder(w).Encode(map[string]string{
+			"status_code":    strconv.Itoa(f.status),
+			"status_message": "Merchant cannot modify the status of the transaction",
+		})
+		return
+	}
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"status_code":        "200",
+		"status_message":     "Success, refund request is approved",
+		"transaction_status": "partial_refund",
+		"refund_amount":      strconv.FormatInt(req.Amount, 10) + ".00",
+		"refund_key":         req.RefundKey,
+	})
+}
+
+func (f *fakeMidtransRefunds) requestCount() int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return len(f.requests)
+}
+
+type refundFixture struct {
+	db       *gorm.DB
+	service  *PaymentService
+	midtrans *fakeMidtransRefunds
+	payment  *models.Payment
+	earning  *models.SellerEarning
+}
+
+// newRefundFixture sets up a settled payment of 200000 for an order with one
+// delivered item, 2 units for 100000 in total, and a payment-service talking
+// to a fake Midtrans.
+func newRefundFixture(t *testing.T) *refundFixture {
+	t.Helper()
+	db := testDB(t, &models.Payment{}, &models.Refund{}, &models.LedgerTransaction{}, &models.LedgerEntry{}, &models.SellerEarning{}, &models.OutboxEvent{})
+
+	fake := &fakeMidtransRefunds{status: http.StatusOK}
+	server := httptest.NewServer(fake)
+	t.Cleanup(server.Close)
+
+	now := time.Now()
+	payment := &models.Payment{
+		ID:          uuid.New(),
+		OrderID:     uuid.New(),
+		OrderNumber: "ORD-20240101-TEST",
+		UserID:      uuid.New(),
+		Amount:      200000,
+		Method:      PaymentMethodSnap,
+		Status:      models.PaymentStatusPaid,
+		MidtransID:  "ORD-20240101-TEST-abcd1234",
+		ExpiredAt:   now.Add(paymentExpiry),
+		PaidAt:      &now,
+	}
+	if err := db.Create(payment).Error; err != nil {
+		t.Fatalf("failed to create payment: %v", err)
+	}
+
+	earning := &models.SellerEarning{
+		ID:             uuid.New(),
+		OrderItemID:    uuid.New(),
+		OrderID:        payment.OrderID,
+		OrderNumber:    payment.OrderNumber,
+		FulfillmentID:  uuid.New(),
+		SellerID:       uuid.New(),
+		ProductID:      uuid.New(),
+		Quantity:       2,
+		Gross:          100000,
+		CommissionRate: 0.05,
+		Commission:     5000,
+		Net:            95000,
+		Status:         models.SellerEarningHeld,
+		DeliveredAt:    now,
+		AvailableAt:    now.Add(7 * 24 * time.Hour),
+	}
+	if err := db.Create(earning).Error; err != nil {
+		t.Fatalf("failed to create seller earning: %v", err)
+	}
+
+	return &refundFixture{
+		db: db,
+		service: &PaymentService{
+			paymentRepo: repository.NewPaymentRepository(db),
+			ledgerRepo:  repository.NewLedgerRepository(db),
+			outboxRepo:  repository.NewOutboxRepository(db),
+			midtrans: midtrans.NewClient(midtrans.Config{
+				ServerKey: "SB-Mid-server-test",
+				APIURL:    server.URL,
+			}),
+		},
+		midtrans: fake,
+		payment:  payment,
+		earning:  earning,
+	}
+}
+
+// returnApproved returns a return of one unit of the fixture's item.
+func (f *refundFixture) returnApproved() *messages.ReturnApprovedEvent {
+	return &messages.ReturnApprovedEvent{
+		ReturnID:    uuid.New().String(),
+		OrderID:     f.payment.OrderID.String(),
+		OrderNumber: f.payment.OrderNumber,
+		SellerID:    f.earning.SellerID.String(),
+		Amount:      50000,
+		Reason:      "Damaged",
+		Items: []messages.ReturnedItemEvent{{
+			OrderItemID: f.earning.OrderItemID.String(),
+			ProductID:   f.earning.ProductID.String(),
+			Quantity:    1,
+			Amount:      50000,
+		}},
+	}
+}
+
+func (f *refundFixture) refund(t *testing.T, refundKey string) *models.Refund {
+	t.Helper()
+	refund, err := f.service.paymentRepo.GetRefundByKey(refundKey)
+	if err != nil || refund == nil {
+		t.Fatalf("GetRefundByKey() = %v, %v", refund, err)
+	}
+	return refund
+}
+
+func (f *refundFixture) outboxCount(t *testing.T, eventName string) int64 {
+	t.Helper()
+	var count int64
+	if err := f.db.Model(&models.OutboxEvent{}).Where("event_name = ?", eventName).Count(&count).Error; err != nil {
+		t.Fatalf("failed to count outbox events: %v", err)
+	}
+	return count
+}
+
+func (f *refundFixture) reloadEarning(t *testing.T) *models.SellerEarning {
+	t.Helper()
+	var earning models.SellerEarning
+	if err := f.db.First(&earning, "id = ?", f.earning.ID).Error; err != nil {
+		t.Fatalf("failed to load seller earning: %v", err)
+	}
+	return &earning
+}
+
+func TestHandleReturnApprovedRefunds(t *testing.T) {
+	f := newRefundFixture(t)
+	event := f.returnApproved()
+
+	if err := f.service.HandleReturnApproved(event); err != nil {
+		t.Fatalf("HandleReturnApproved() error = %v", err)
+	}
+
+	if n := f.midtrans.requestCount(); n != 1 {
+		t.Fatalf("Midtrans refund requests = %d, want 1", n)
+	}
+	if req := f.midtrans.requests[0]; req.RefundKey != event.ReturnID || req.Amount != 50000 {
+		t.Errorf("Midtrans refund request = %+v, want key %s and amount 50000", req, event.ReturnID)
+	}
+
+	refund := f.refund(t, event.ReturnID)
+	if refund.Status != models.RefundStatusSucceeded || refund.RefundedAt == nil {
+		t.Errorf("refund status = %s, refunded at %v, want succeeded", refund.Status, refund.RefundedAt)
+	}
+	payment, err := f.service.paymentRepo.GetPaymentByID(f.payment.ID)
+	if err != nil || payment == nil || payment.Status != models.PaymentStatusRefunded {
+		t.Errorf("payment = %+v, %v, want refunded", payment, err)
+	}
+
+	earning := f.reloadEarning(t)
+	if earning.ReturnedQuantity != 1 || earning.RefundedGross != 50000 || earning.RefundedNet != 47500 {
+		t.Errorf("earning returned %d, refunded gross %.2f and net %.2f, want 1, 50000 and 47500", earning.ReturnedQuantity, earning.RefundedGross, earning.RefundedNet)
+	}
+
+	if n := f.outboxCount(t, messages.EventPaymentRefunded); n != 1 {
+		t.Errorf("payment.refunded events = %d, want 1", n)
+	}
+	if n := f.outboxCount(t, messages.EventRefundFailed); n != 0 {
+		t.Errorf("payment.refund_failed events = %d, want 0", n)
+	}
+}
+
+func TestHandleReturnApprovedRedelivered(t *testing.T) {
+	f := newRefundFixture(t)
+	event := f.returnApproved()
+
+	for i := 0; i < 2; i++ {
+		if err := f.service.HandleReturnApproved(event); err != nil {
+			t.Fatalf("HandleReturnApproved() delivery %d error = %v", i+1, err)
+		}
+	}
+
+	if n := f.midtrans.requestCount(); n != 1 {
+		t.Errorf("Midtrans refund requests = %d, want 1", n)
+	}
+
+	var refunds int64
+	f.db.Model(&models.Refund{}).Where("payment_id = ?", f.payment.ID).Count(&refunds)
+	if refunds != 1 {
+		t.Errorf("refunds = %d, want 1", refunds)
+	}
+
+	var postings int64
+	f.db.Model(&models.LedgerTransaction{}).Where("type = ? AND reference LIKE ?", models.LedgerTransactionRefund, "refund:"+event.ReturnID+":%").Count(&postings)
+	if postings != 1 {
+		t.Errorf("ledger refund transactions = %d, want 1", postings)
+	}
+	if earning := f.reloadEarning(t); earning.ReturnedQuantity != 1 || earning.RefundedGross != 50000 {
+		t.Errorf("earning returned %d and refunded %.2f, want 1 and 50000", earning.ReturnedQuantity, earning.RefundedGross)
+	}
+
+	// Replies are repeated, and order-service ignores the duplicate
+	if n := f.outboxCount(t, messages.EventRefundFailed); n != 0 {
+		t.Errorf("payment.refund_failed events = %d, want 0", n)
+	}
+}
+
+func TestHandleReturnApprovedMidtransRejects(t *testing.T) {
+	f := newRefundFixture(t)
+	f.midtrans.status = http.StatusPreconditionFailed
+	event := f.returnApproved()
+
+	if err := f.service.HandleReturnApproved(event); err != nil {
+		t.Fatalf("HandleReturnApproved() error = %v", err)
+	}
+
+	refund := f.refund(t, event.ReturnID)
+	if refund.Status != models.RefundStatusFailed || !strings.Contains(refund.FailureReason, "412") {
+		t.Errorf("refund status = %s with reason %q, want failed with the Midtrans status", refund.Status, refund.FailureReason)
+	}
+	payment, err := f.service.paymentRepo.GetPaymentByID(f.payment.ID)
+	if err != nil || payment == nil || payment.Status != models.PaymentStatusPaid {
+		t.Errorf("payment = %+v, %v, want still paid", payment, err)
+	}
+	if earning := f.reloadEarning(t); earning.ReturnedQuantity != 0 {
+		t.Errorf("earning returned quantity = %d, want 0", earning.ReturnedQuantity)
+	}
+
+	if n := f.outboxCount(t, messages.EventRefundFailed); n != 1 {
+		t.Errorf("payment.refund_failed events = %d, want 1", n)
+	}
+	if n := f.outboxCount(t, messages.EventPaymentRefunded); n != 0 {
+		t.Errorf("payment.refunded events = %d, want 0", n)
+	}
+}
+
+func TestHandleReturnApprovedOverRefund(t *testing.T) {
+	f := newRefundFixture(t)
+
+	// An earlier return already refunded most of the payment
+	now := time.Now()
+	earlierReturnID := uuid.New()
+	earlier := &models.Refund{
+		ID:         uuid.New(),
+		PaymentID:  f.payment.ID,
+		OrderID:    f.payment.OrderID,
+		ReturnID:   &earlierReturnID,
+		RefundKey:  earlierReturnID.String(),
+		Amount:     180000,
+		Status:     models.RefundStatusSucceeded,
+		RefundedAt: &now,
+	}
+	if err := f.db.Create(earlier).Error; err != nil {
+		t.Fatalf("failed to create refund: %v", err)
+	}
+
+	event := f.returnApproved()
+	if err := f.service.HandleReturnApproved(event); err != nil {
+		t.Fatalf("HandleReturnApproved() error = %v", err)
+	}
+
+	if n := f.midtrans.requestCount(); n != 0 {
+		t.Errorf("Midtrans refund requests = %d, want 0", n)
+	}
+	refund := f.refund(t, event.ReturnID)
+	if refund.Status != models.RefundStatusFailed || !strings.Contains(refund.FailureReason, "exceeds") {
+		t.Errorf("refund status = %s with reason %q, want failed for exceeding the payment", refund.Status, refund.FailureReason)
+	}
+	if n := f.outboxCount(t, messages.EventRefundFailed); n != 1 {
+		t.Errorf("payment.refund_failed events = %d, want 1", n)
+	}
+}
+
+func TestRefundCancelledPayment(t *testing.T) {
+	f := newRefundFixture(t)
+
+	// A redelivered cancel command must not refund twice
+	for i := 0; i < 2; i++ {
+		if err := f.service.refundCancelledPayment(f.payment, "customer request"); err != nil {
+			t.Fatalf("refundCancelledPayment() error = %v", err)
+		}
+	}
+
+	if n := f.midtrans.requestCount(); n != 1 {
+		t.Fatalf("Midtrans refund requests = %d, want 1", n)
+	}
+	if req := f.midtrans.requests[0]; req.RefundKey != f.payment.ID.String() || req.Amount != 200000 {
+		t.Errorf("Midtrans refund request = %+v, want key %s and amount 200000", req, f.payment.ID)
+	}
+
+	refund := f.refund(t, f.payment.ID.String())
+	if refund.Status != models.RefundStatusSucceeded {
+		t.Errorf("refund status = %s, want succeeded", refund.Status)
+	}
+	if refund.ReturnID != nil {
+		t.Errorf("refund return ID = %s, want nil", refund.ReturnID)
+	}
+
+	var outboxEvent models.OutboxEvent
+	if err := f.db.Where("event_name = ?", messages.EventPaymentRefunded).First(&outboxEvent).Error; err != nil {
+		t.Fatalf("failed to load payment.refunded event: %v", err)
+	}
+	var event struct {
+		Data messages.PaymentRefundedEvent `json:"data"`
+	}
+	if err := json.Unmarshal([]byte(outboxEvent.Payload), &event); err != nil {
+		t.Fatalf("failed to decode payment.refunded event: %v", err)
+	}
+	if event.Data.ReturnID != "" {
+		t.Errorf("payment.refunded return ID = %q, want none", event.Data.ReturnID)
+	}
+}
+
+func TestHandleCreatePayment(t *testing.T) {
+	db := testDB(t, &models.Payment{}, &models.OutboxEvent{})
+	service := &PaymentService{
+		paymentRepo: repository.NewPaymentRepository(db),
+		outboxRepo:  repository.NewOutboxRepository(db),
+	}
+
+	command := &messages.CreatePaymentCommand{
+		OrderID:     uuid.New().String(),
+		OrderNumber: "ORD-20240101-OPEN",
+		UserID:      uuid.New().String(),
+		Amount:      150000,
+	}
+	// The second call is a redelivery, which only repeats the reply
+	for i := 0; i < 2; i++ {
+		if err := service.HandleCreatePayment(command); err != nil {
+			t.Fatalf("HandleCreatePayment() call %d error = %v", i+1, err)
+		}
+	}
+
+	var payments, events int64
+	db.Model(&models.Payment{}).Where("order_id = ?", command.OrderID).Count(&payments)
+	if payments != 1 {
+		t.Errorf("payments = %d, want 1", payments)
+	}
+	db.Model(&models.OutboxEvent{}).Where("event_name = ? AND payload::jsonb -> 'data' ->> 'order_id' = ?", messages.EventPaymentOpened, command.OrderID).Count(&events)
+	if events != 2 {
+		t.Errorf("payment.opened events = %d, want 2", events)
+	}
+}
@@ -2,31 +2,37 @@ package service
 
 import (
 	"context"
+	"errors"
 	"fmt"
-	"strconv"
+	"log"
 	"time"
 
 	"github.com/be-bcv/ecommerce-backend/internal/models"
 	"github.com/be-bcv/ecommerce-backend/internal/repository"
 	"github.com/be-bcv/ecommerce-backend/pkg/messages"
+	"github.com/be-bcv/ecommerce-backend/pkg/middleware"
 	"github.com/be-bcv/ecommerce-backend/pkg/rabbitmq"
 	"github.com/be-bcv/ecommerce-backend/pkg/redis"
 	"github.com/google/uuid"
 )
 
+// ErrNotProductOwner is returned when a seller changes a product listed by
+// another seller.
+var ErrNotProductOwner = errors.New("product belongs to another seller")
+
 type ProductService struct {
 	productRepo  *repository.ProductRepository
 	categoryRepo *repository.CategoryRepository
-	redis        *redis.RedisClient
-	rabbitmq     *rabbitmq.RabbitMQ
+	products     *redis.Cache[models.Product]
+	productPages *redis.Cache[productPage]
 }
 
-func NewProductService(productRepo *repository.ProductRepository, categoryRepo *repository.CategoryRepository, redis *redis.RedisClient, rabbitmq *rabbitmq.RabbitMQ) *ProductService {
+func NewProductService(productRepo *repository.ProductRepository, categoryRepo *repository.CategoryRepository, redis *redis.RedisClient) *ProductService {
 	return &ProductService{
 		productRepo:  productRepo,
 		categoryRepo: categoryRepo,
-		redis:        redis,
-		rabbitmq:     rabbitmq,
+		products:     newProductCache(redis),
+		productPages: newProductPageCache(redis),
 	}
 }
 
@@ -36,7 +42,6 @@ type CreateProductRequest struct {
 	Price       float64   `json:"price" binding:"required,min=0"`
 	Stock       int       `json:"stock" binding:"required,min=0"`
 	CategoryID  uuid.UUID `json:"category_id" binding:"required"`
-	SellerID    uuid.UUID `json:"seller_id" binding:"required"`
 	Weight      float64   `json:"weight"`
 	Dimensions  string    `json:"dimensions"`
 	Images      []string  `json:"images"`
@@ -46,7 +51,7 @@ type UpdateProductRequest struct {
 	Name        string    `json:"name"`
 	Description string    `json:"description"`
 	Price       float64   `json:"price"`
-	Stock       int       `json:"stock"`
+	Stock       *int      `json:"stock" binding:"omitempty,min=0"`
 	CategoryID  uuid.UUID `json:"category_id"`
 	Weight      float64   `json:"weight"`
 	Dimensions  string    `json:"dimensions"`
@@ -63,7 +68,8 @@ type ProductResponse struct {
 	ReviewCount   int64   `json:"review_count"`
 }
 
-func (s *ProductService) CreateProduct(req *CreateProductRequest) (*models.Product, error) {
+// CreateProduct lists a new product for the given seller.
+func (s *ProductService) CreateProduct(sellerID uuid.UUID, req *CreateProductRequest) (*models.Product, error) {
 	// Check if category exists
 	category, err := s.categoryRepo.GetByID(req.CategoryID)
 	if err != nil {
@@ -85,35 +91,34 @@ func (s *ProductService) CreateProduct(req *CreateProductRequest) (*models.Produ
 		Stock:       req.Stock,
 		SKU:         sku,
 		CategoryID:  req.CategoryID,
-		SellerID:    req.SellerID,
+		SellerID:    sellerID,
 		Weight:      req.Weight,
 		Dimensions:  req.Dimensions,
 		Images:      req.Images,
 		IsActive:    true,
 	}
 
-	if err := s.productRepo.Create(product); err != nil {
+	// Product created event, stored with the product
+	event, err := s.productCreatedEvent(product)
+	if err != nil {
 		return nil, err
 	}
 
-	// Cache product
-	s.cacheProduct(product)
+	if err := s.productRepo.Create(product, event); err != nil {
+		return nil, err
+	}
 
-	// Publish product created event
-	s.publishProductCreatedEvent(product)
+	// Drop the cached miss and listing pages
+	s.invalidateProduct(product.ID)
 
 	return product, nil
 }
 
 func (s *ProductService) GetProductByID(id uuid.UUID) (*ProductResponse, error) {
-	// Try to get from cache first
-	cachedProduct, err := s.getCachedProduct(id)
-	if err == nil && cachedProduct != nil {
-		return s.buildProductResponse(cachedProduct)
-	}
-
-	// Get from database
-	product, err := s.productRepo.GetByID(id)
+	// Read through the cache, which also remembers missing products
+	product, err := s.products.GetOrLoad(context.Background(), id.String(), func() (*models.Product, error) {
+		return s.productRepo.GetByID(id)
+	})
 	if err != nil {
 		return nil, err
 	}
@@ -121,17 +126,22 @@ func (s *ProductService) GetProductByID(id uuid.UUID) (*ProductResponse, error)
 		return nil, fmt.Errorf("product not found")
 	}
 
-	// Cache product
-	s.cacheProduct(product)
-
 	return s.buildProductResponse(product)
 }
 
 func (s *ProductService) GetAllProducts(page, limit int, categoryID uuid.UUID, sortBy, sortOrder string) ([]ProductResponse, int64, error) {
-	products, total, err := s.productRepo.GetAll(page, limit, categoryID, sortBy, sortOrder)
+	key := productPageKey(page, limit, categoryID, sortBy, sortOrder)
+	cached, err := s.productPages.GetOrLoad(context.Background(), key, func() (*productPage, error) {
+		products, total, err := s.productRepo.GetAll(page, limit, categoryID, sortBy, sortOrder)
+		if err != nil {
+			return nil, err
+		}
+		return &productPage{Products: products, Total: total}, nil
+	})
 	if err != nil {
 		return nil, 0, err
 	}
+	products, total := cached.Products, cached.Total
 
 	var responses []ProductResponse
 	for _, product := range products {
@@ -199,27 +209,22 @@ func (s *ProductService) GetProductsBySeller(sellerID uuid.UUID, page, limit int
 	return responses, total, nil
 }
 
-func (s *ProductService) UpdateProduct(id uuid.UUID, req *UpdateProductRequest) (*models.Product, error) {
-	product, err := s.productRepo.GetByID(id)
+func (s *ProductService) UpdateProduct(id, userID uuid.UUID, role string, req *UpdateProductRequest) (*models.Product, error) {
+	product, err := s.getOwnedProduct(id, userID, role)
 	if err != nil {
 		return nil, err
 	}
-	if product == nil {
-		return nil, fmt.Errorf("product not found")
-	}
 
-	// Update fields
+	// Only the fields in the request are written
+	updates := map[string]interface{}{}
 	if req.Name != "" {
-		product.Name = req.Name
+		updates["name"] = req.Name
 	}
 	if req.Description != "" {
-		product.Description = req.Description
+		updates["description"] = req.Description
 	}
 	if req.Price > 0 {
-		product.Price = req.Price
-	}
-	if req.Stock >= 0 {
-		product.Stock = req.Stock
+		updates["price"] = req.Price
 	}
 	if req.CategoryID != uuid.Nil {
 		// Check if category exists
@@ -230,80 +235,305 @@ func (s *ProductService) UpdateProduct(id uuid.UUID, req *UpdateProductRequest)
 		if category == nil {
 			return nil, fmt.Errorf("category not found")
 		}
-		product.CategoryID = req.CategoryID
+		updates["category_id"] = req.CategoryID
 	}
 	if req.Weight > 0 {
-		product.Weight = req.Weight
+		updates["weight"] = req.Weight
 	}
 	if req.Dimensions != "" {
-		product.Dimensions = req.Dimensions
+		updates["dimensions"] = req.Dimensions
 	}
 	if req.Images != nil {
-		product.Images = req.Images
+		updates["images"] = req.Images
 	}
 
-	if err := s.productRepo.Update(product); err != nil {
-		return nil, err
+	// Stock is moved by the difference to what the seller saw, so
+	// reservations made in the meantime are not overwritten
+	stockDelta := 0
+	if req.Stock != nil {
+		stockDelta = *req.Stock - product.Stock
 	}
 
-	// Update cache
-	s.cacheProduct(product)
+	// Product updated event, stored with the update
+	updated, err := s.productRepo.Update(id, updates, stockDelta, func(product *models.Product, change repository.StockChange) ([]*models.OutboxEvent, error) {
+		event, err := s.productUpdatedEvent(product)
+		if err != nil {
+			return nil, err
+		}
+		return []*models.OutboxEvent{event}, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	if updated == nil {
+		return nil, fmt.Errorf("product not found")
+	}
 
-	// Publish product updated event
-	s.publishProductUpdatedEvent(product)
+	s.invalidateProduct(id)
 
-	return product, nil
+	return updated, nil
 }
 
-func (s *ProductService) UpdateStock(id uuid.UUID, req *UpdateStockRequest) error {
-	product, err := s.productRepo.GetByID(id)
+func (s *ProductService) UpdateStock(id, userID uuid.UUID, role string, req *UpdateStockRequest) error {
+	product, err := s.getOwnedProduct(id, userID, role)
 	if err != nil {
 		return err
 	}
-	if product == nil {
+
+	// Stock updated event, stored with the new stock
+	updated, err := s.productRepo.Update(id, nil, req.Stock-product.Stock, func(product *models.Product, change repository.StockChange) ([]*models.OutboxEvent, error) {
+		event, err := s.stockUpdatedEvent(id, change.OldStock, change.NewStock)
+		if err != nil {
+			return nil, err
+		}
+		return []*models.OutboxEvent{event}, nil
+	})
+	if err != nil {
+		return err
+	}
+	if updated == nil {
 		return fmt.Errorf("product not found")
 	}
 
-	oldStock := product.Stock
+	s.invalidateProduct(id)
+
+	return nil
+}
 
-	if err := s.productRepo.UpdateStock(id, req.Stock); err != nil {
+func (s *ProductService) DeleteProduct(id, userID uuid.UUID, role string) error {
+	if _, err := s.getOwnedProduct(id, userID, role); err != nil {
 		return err
 	}
 
-	// Update product in cache
-	product.Stock = req.Stock
-	s.cacheProduct(product)
+	// Product deleted event, stored with the deletion
+	event, err := s.productDeletedEvent(id)
+	if err != nil {
+		return err
+	}
+
+	if err := s.productRepo.Delete(id, event); err != nil {
+		return err
+	}
 
-	// Publish stock updated event
-	s.publishStockUpdatedEvent(id, oldStock, req.Stock)
+	s.invalidateProduct(id)
 
 	return nil
 }
 
-func (s *ProductService) DeleteProduct(id uuid.UUID) error {
+// getOwnedProduct loads a product the caller may change: sellers may only
+// change their own products, admins any product.
+func (s *ProductService) getOwnedProduct(id, userID uuid.UUID, role string) (*models.Product, error) {
 	product, err := s.productRepo.GetByID(id)
 	if err != nil {
-		return err
+		return nil, err
 	}
 	if product == nil {
-		return fmt.Errorf("product not found")
+		return nil, fmt.Errorf("product not found")
+	}
+	if role != middleware.RoleAdmin && product.SellerID != userID {
+		return nil, ErrNotProductOwner
+	}
+	return product, nil
+}
+
+// CommandHandlers returns the handlers for the checkout saga commands
+// product-service consumes to reserve and release stock.
+func (s *ProductService) CommandHandlers() map[string]rabbitmq.EventHandler {
+	return map[string]rabbitmq.EventHandler{
+		messages.CommandReserveStock: func(event *messages.RawEventMessage) error {
+			var data messages.ReserveStockCommand
+			if err := event.Decode(&data); err != nil {
+				return err
+			}
+			return s.HandleReserveStock(&data)
+		},
+		messages.CommandReleaseStock: func(event *messages.RawEventMessage) error {
+			var data messages.ReleaseStockCommand
+			if err := event.Decode(&data); err != nil {
+				return err
+			}
+			return s.HandleReleaseStock(&data)
+		},
+	}
+}
+
+// HandleReserveStock takes the order's items out of stock and replies with
+// product.stock_reserved. If any product is short, no stock is taken and
+// product.stock_rejected is published instead so order-service can cancel
+// the order.
+func (s *ProductService) HandleReserveStock(event *messages.ReserveStockCommand) error {
+	orderID, err := uuid.Parse(event.OrderID)
+	if err != nil {
+		return fmt.Errorf("invalid order ID %q: %w", event.OrderID, err)
 	}
 
-	if err := s.productRepo.Delete(id); err != nil {
+	quantities := make(map[uuid.UUID]int, len(event.Items))
+	for _, item := range event.Items {
+		productID, err := uuid.Parse(item.ProductID)
+		if err != nil {
+			return fmt.Errorf("invalid product ID %q: %w", item.ProductID, err)
+		}
+		quantities[productID] += item.Quantity
+	}
+
+	changes, err := s.productRepo.ReserveStock(orderID, quantities, func(changes []repository.StockChange) ([]*models.OutboxEvent, error) {
+		return s.stockReservedEvents(orderID, changes)
+	})
+
+	var insufficient *repository.InsufficientStockError
+	if errors.As(err, &insufficient) {
+		log.Printf("Rejecting order %s: %v", orderID, insufficient)
+		outboxEvent, err := s.stockRejectedEvent(orderID, insufficient)
+		if err != nil {
+			return err
+		}
+		return s.productRepo.RejectStockReservation(orderID, insufficient.ProductID, insufficient.Requested, outboxEvent)
+	}
+	if err != nil {
 		return err
 	}
 
-	// Remove from cache
-	ctx := context.Background()
-	key := fmt.Sprintf("product:%s", id.String())
-	s.redis.Del(ctx, key)
+	s.invalidateStock(changes)
+	return nil
+}
 
-	// Publish product deleted event
-	s.publishProductDeletedEvent(id)
+// HandleReleaseStock puts back the stock reserved for the order.
+func (s *ProductService) HandleReleaseStock(event *messages.ReleaseStockCommand) error {
+	orderID, err := uuid.Parse(event.OrderID)
+	if err != nil {
+		return fmt.Errorf("invalid order ID %q: %w", event.OrderID, err)
+	}
+
+	changes, err := s.productRepo.ReleaseStock(orderID, func(changes []repository.StockChange) ([]*models.OutboxEvent, error) {
+		return s.stockChangedEvents(orderID, changes)
+	})
+	if err != nil {
+		return err
+	}
 
+	s.invalidateStock(changes)
 	return nil
 }
 
+// OrderEventHandlers returns the handlers for the order events
+// product-service consumes.
+func (s *ProductService) OrderEventHandlers() map[string]rabbitmq.EventHandler {
+	return map[string]rabbitmq.EventHandler{
+		messages.EventReturnApproved: func(event *messages.RawEventMessage) error {
+			var data messages.ReturnApprovedEvent
+			if err := event.Decode(&data); err != nil {
+				return err
+			}
+			return s.HandleReturnApproved(&data)
+		},
+	}
+}
+
+// HandleReturnApproved puts the items of an approved return back on sale.
+func (s *ProductService) HandleReturnApproved(event *messages.ReturnApprovedEvent) error {
+	returnID, err := uuid.Parse(event.ReturnID)
+	if err != nil {
+		return fmt.Errorf("invalid return ID %q: %w", event.ReturnID, err)
+	}
+	orderID, err := uuid.Parse(event.OrderID)
+	if err != nil {
+		return fmt.Errorf("invalid order ID %q: %w", event.OrderID, err)
+	}
+
+	quantities := make(map[uuid.UUID]int, len(event.Items))
+	for _, item := range event.Items {
+		productID, err := uuid.Parse(item.ProductID)
+		if err != nil {
+			return fmt.Errorf("invalid product ID %q: %w", item.ProductID, err)
+		}
+		quantities[productID] += item.Quantity
+	}
+
+	changes, err := s.productRepo.RestockReturn(returnID, orderID, quantities, func(changes []repository.StockChange) ([]*models.OutboxEvent, error) {
+		return s.stockChangedEvents(orderID, changes)
+	})
+	if err != nil {
+		return err
+	}
+
+	s.invalidateStock(changes)
+	return nil
+}
+
+// ProductEventHandlers returns the handlers for product-service's own
+// product events, which invalidate the catalog cache. Changes are
+// invalidated right after they are committed as well; the events catch
+// entries a concurrent read cached from before the change.
+func (s *ProductService) ProductEventHandlers() map[string]rabbitmq.EventHandler {
+	return map[string]rabbitmq.EventHandler{
+		messages.EventProductCreated: func(event *messages.RawEventMessage) error {
+			var data messages.ProductCreatedEvent
+			if err := event.Decode(&data); err != nil {
+				return err
+			}
+			return s.handleProductChanged(data.ProductID)
+		},
+		messages.EventProductUpdated: func(event *messages.RawEventMessage) error {
+			var data messages.ProductUpdatedEvent
+			if err := event.Decode(&data); err != nil {
+				return err
+			}
+			return s.handleProductChanged(data.ProductID)
+		},
+		messages.EventProductDeleted: func(event *messages.RawEventMessage) error {
+			var data messages.ProductDeletedEvent
+			if err := event.Decode(&data); err != nil {
+				return err
+			}
+			return s.handleProductChanged(data.ProductID)
+		},
+	}
+}
+
+func (s *ProductService) handleProductChanged(productID string) error {
+	id, err := uuid.Parse(productID)
+	if err != nil {
+		return fmt.Errorf("invalid product ID %q: %w", productID, err)
+	}
+
+	ctx := context.Background()
+	if err := s.products.Delete(ctx, id.String()); err != nil {
+		return err
+	}
+	return s.productPages.Flush(ctx)
+}
+
+// CacheStats returns the counters of the product caches for monitoring.
+func (s *ProductService) CacheStats() map[string]redis.CacheStats {
+	return map[string]redis.CacheStats{
+		"products":      s.products.Stats(),
+		"product_pages": s.productPages.Stats(),
+	}
+}
+
+// invalidateProduct drops the cached product and the listing pages.
+func (s *ProductService) invalidateProduct(id uuid.UUID) {
+	if err := s.handleProductChanged(id.String()); err != nil {
+		log.Printf("Failed to invalidate cached product %s: %v", id, err)
+	}
+}
+
+// invalidateStock drops cached products whose stock changed. Listing pages
+// are left to expire.
+func (s *ProductService) invalidateStock(changes []repository.StockChange) {
+	if len(changes) == 0 {
+		return
+	}
+
+	ids := make([]string, 0, len(changes))
+	for _, change := range changes {
+		ids = append(ids, change.ProductID.String())
+	}
+	if err := s.products.Delete(context.Background(), ids...); err != nil {
+		log.Printf("Failed to invalidate cached stock: %v", err)
+	}
+}
+
 func (s *ProductService) buildProductResponse(product *models.Product) (*ProductResponse, error) {
 	// TODO: Get average rating and review count from review service
 	return &ProductResponse{
@@ -319,105 +549,121 @@ func (s *ProductService) generateSKU(name string) string {
 	return fmt.Sprintf("PRD-%d", timestamp)
 }
 
-func (s *ProductService) cacheProduct(product *models.Product) {
-	ctx := context.Background()
-	key := fmt.Sprintf("product:%s", product.ID.String())
-	// Cache for 1 hour
-	s.redis.Set(ctx, key, product, time.Hour)
+func (s *ProductService) productCreatedEvent(product *models.Product) (*models.OutboxEvent, error) {
+	event := messages.NewEvent(messages.EventProductCreated, "product-service", messages.ProductCreatedEvent{
+		ProductID:  product.ID.String(),
+		Name:       product.Name,
+		Price:      product.Price,
+		Stock:      product.Stock,
+		CategoryID: product.CategoryID.String(),
+		SellerID:   product.SellerID.String(),
+	})
+
+	return models.NewOutboxEvent(messages.ExchangeProduct, event)
 }
 
-func (s *ProductService) getCachedProduct(id uuid.UUID) (*models.Product, error) {
-	ctx := context.Background()
-	key := fmt.Sprintf("product:%s", id.String())
+func (s *ProductService) productUpdatedEvent(product *models.Product) (*models.OutboxEvent, error) {
+	event := messages.NewEvent(messages.EventProductUpdated, "product-service", messages.ProductUpdatedEvent{
+		ProductID: product.ID.String(),
+		Name:      product.Name,
+		Price:     product.Price,
+		Stock:     product.Stock,
+	})
 
-	// This is a simplified version - in production, you'd want proper deserialization
-	_, err := s.redis.Get(ctx, key)
-	if err != nil {
-		return nil, err
-	}
+	return models.NewOutboxEvent(messages.ExchangeProduct, event)
+}
 
-	// For now, return nil to always fetch from DB
-	// TODO: Implement proper caching with serialization
-	return nil, nil
+func (s *ProductService) stockUpdatedEvent(productID uuid.UUID, oldStock, newStock int) (*models.OutboxEvent, error) {
+	event := messages.NewEvent(messages.EventProductStockUpdated, "product-service", messages.StockUpdatedEvent{
+		ProductID: productID.String(),
+		OldStock:  oldStock,
+		NewStock:  newStock,
+	})
+
+	return models.NewOutboxEvent(messages.ExchangeProduct, event)
 }
 
-func (s *ProductService) publishProductCreatedEvent(product *models.Product) {
-	event := messages.EventMessage{
-		EventID:   uuid.New().String(),
-		EventName: "product.created",
-		Timestamp: time.Now(),
-		Data: messages.ProductCreatedEvent{
-			ProductID:  product.ID.String(),
-			Name:       product.Name,
-			Price:      product.Price,
-			Stock:      product.Stock,
-			CategoryID: product.CategoryID.String(),
-			SellerID:   product.SellerID.String(),
-		},
-		Service: "product-service",
-	}
+// stockChangedEvents returns a stock updated event for every change made on
+// behalf of the order.
+func (s *ProductService) stockChangedEvents(orderID uuid.UUID, changes []repository.StockChange) ([]*models.OutboxEvent, error) {
+	events := make([]*models.OutboxEvent, 0, len(changes))
+	for _, change := range changes {
+		event := messages.NewEvent(messages.EventProductStockUpdated, "product-service", messages.StockUpdatedEvent{
+			ProductID: change.ProductID.String(),
+			OldStock:  change.OldStock,
+			NewStock:  change.NewStock,
+			OrderID:   orderID.String(),
+		})
 
-	// TODO: Publish to RabbitMQ
-	// s.rabbitmq.Publish("product_events", "product.created", event)
+		outboxEvent, err := models.NewOutboxEvent(messages.ExchangeProduct, event)
+		if err != nil {
+			return nil, err
+		}
+		events = append(events, outboxEvent)
+	}
+	return events, nil
 }
 
-func (s *ProductService) publishProductUpdatedEvent(product *models.Product) {
-	event := messages.EventMessage{
-		EventID:   uuid.New().String(),
-		EventName: "product.updated",
-		Timestamp: time.Now(),
-		Data: messages.ProductUpdatedEvent{
-			ProductID: product.ID.String(),
-			Name:      product.Name,
-			Price:     product.Price,
-			Stock:     product.Stock,
-		},
-		Service: "product-service",
+// stockReservedEvents returns the stock updated events for the changes
+// followed by the event announcing the order was reserved. Nothing is
+// returned for an order that was handled before.
+func (s *ProductService) stockReservedEvents(orderID uuid.UUID, changes []repository.StockChange) ([]*models.OutboxEvent, error) {
+	if len(changes) == 0 {
+		return nil, nil
 	}
 
-	// TODO: Publish to RabbitMQ
-	// s.rabbitmq.Publish("product_events", "product.updated", event)
-}
+	events, err := s.stockChangedEvents(orderID, changes)
+	if err != nil {
+		return nil, err
+	}
 
-func (s *ProductService) publishStockUpdatedEvent(productID uuid.UUID, oldStock, newStock int) {
-	event := messages.EventMessage{
-		EventID:   uuid.New().String(),
-		EventName: "product.stock_updated",
-		Timestamp: time.Now(),
-		Data: messages.StockUpdatedEvent{
-			ProductID: productID.String(),
-			OldStock:  oldStock,
-			NewStock:  newStock,
-		},
-		Service: "product-service",
+	event := messages.NewEvent(messages.EventStockReserved, "product-service", messages.StockReservedEvent{
+		OrderID: orderID.String(),
+	})
+	outboxEvent, err := models.NewOutboxEvent(messages.ExchangeProduct, event)
+	if err != nil {
+		return nil, err
 	}
+	return append(events, outboxEvent), nil
+}
 
-	// TODO: Publish to RabbitMQ
-	// s.rabbitmq.Publish("product_events", "product.stock_updated", event)
+func (s *ProductService) stockRejectedEvent(orderID uuid.UUID, insufficient *repository.InsufficientStockError) (*models.OutboxEvent, error) {
+	event := messages.NewEvent(messages.EventStockRejected, "product-service", messages.StockRejectedEvent{
+		OrderID:   orderID.String(),
+		ProductID: insufficient.ProductID.String(),
+		Requested: insufficient.Requested,
+		Available: insufficient.Available,
+		Reason:    "insufficient_stock",
+	})
+
+	return models.NewOutboxEvent(messages.ExchangeProduct, event)
 }
 
-func (s *ProductService) publishProductDeletedEvent(productID uuid.UUID) {
-	event := messages.EventMessage{
-		EventID:   uuid.New().String(),
-		EventName: "product.deleted",
-		Timestamp: time.Now(),
-		Data: messages.ProductDeletedEvent{
-			ProductID: productID.String(),
-		},
-		Service: "product-service",
-	}
+func (s *ProductService) productDeletedEvent(productID uuid.UUID) (*models.OutboxEvent, error) {
+	event := messages.NewEvent(messages.EventProductDeleted, "product-service", messages.ProductDeletedEvent{
+		ProductID: productID.String(),
+	})
 
-	// TODO: Publish to RabbitMQ
-	// s.rabbitmq.Publish("product_events", "product.deleted", event)
+	return models.NewOutboxEvent(messages.ExchangeProduct, event)
 }
 
 // Category Service
 type CategoryService struct {
 	categoryRepo *repository.CategoryRepository
+	categories   *redis.Cache[models.Category]
+	categoryList *redis.Cache[[]models.Category]
+	products     *redis.Cache[models.Product]
+	productPages *redis.Cache[productPage]
 }
 
-func NewCategoryService(categoryRepo *repository.CategoryRepository) *CategoryService {
-	return &CategoryService{categoryRepo: categoryRepo}
+func NewCategoryService(categoryRepo *repository.CategoryRepository, redis *redis.RedisClient) *CategoryService {
+	return &CategoryService{
+		categoryRepo: categoryRepo,
+		categories:   newCategoryCache(redis),
+		categoryList: newCategoryListCache(redis),
+		products:     newProductCache(redis),
+		productPages: newProductPageCache(redis),
+	}
 }
 
 type CreateCategoryRequest struct {
@@ -441,15 +687,29 @@ func (s *CategoryService) CreateCategory(req *CreateCategoryRequest) (*models.Ca
 		return nil, err
 	}
 
+	s.invalidateCategory(category.ID, false)
+
 	return category, nil
 }
 
 func (s *CategoryService) GetCategoryByID(id uuid.UUID) (*models.Category, error) {
-	return s.categoryRepo.GetByID(id)
+	return s.categories.GetOrLoad(context.Background(), id.String(), func() (*models.Category, error) {
+		return s.categoryRepo.GetByID(id)
+	})
 }
 
 func (s *CategoryService) GetAllCategories() ([]models.Category, error) {
-	return s.categoryRepo.GetAll()
+	categories, err := s.categoryList.GetOrLoad(context.Background(), "all", func() (*[]models.Category, error) {
+		categories, err := s.categoryRepo.GetAll()
+		if err != nil {
+			return nil, err
+		}
+		return &categories, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return *categories, nil
 }
 
 func (s *CategoryService) UpdateCategory(id uuid.UUID, req *UpdateCategoryRequest) (*models.Category, error) {
@@ -472,11 +732,49 @@ func (s *CategoryService) UpdateCategory(id uuid.UUID, req *UpdateCategoryReques
 		return nil, err
 	}
 
+	s.invalidateCategory(id, true)
+
 	return category, nil
 }
 
 func (s *CategoryService) DeleteCategory(id uuid.UUID) error {
-	return s.categoryRepo.Delete(id)
+	if err := s.categoryRepo.Delete(id); err != nil {
+		return err
+	}
+
+	s.invalidateCategory(id, true)
+
+	return nil
+}
+
+// CacheStats returns the counters of the category caches for monitoring.
+func (s *CategoryService) CacheStats() map[string]redis.CacheStats {
+	return map[string]redis.CacheStats{
+		"categories":    s.categories.Stats(),
+		"category_list": s.categoryList.Stats(),
+	}
+}
+
+// invalidateCategory drops the cached category and the category list. Cached
+// products embed their category, so they are flushed too when an existing
+// category changes.
+func (s *CategoryService) invalidateCategory(id uuid.UUID, flushProducts bool) {
+	ctx := context.Background()
+	if err := s.categories.Delete(ctx, id.String()); err != nil {
+		log.Printf("Failed to invalidate cached category %s: %v", id, err)
+	}
+	if err := s.categoryList.Delete(ctx, "all"); err != nil {
+		log.Printf("Failed to invalidate cached categories: %v", err)
+	}
+	if !flushProducts {
+		return
+	}
+	if err := s.products.Flush(ctx); err != nil {
+		log.Printf("Failed to invalidate cached products: %v", err)
+	}
+	if err := s.productPages.Flush(ctx); err != nil {
+		log.Printf("Failed to invalidate cached product pages: %v", err)
+	}
 }
 
 // Product Review Service
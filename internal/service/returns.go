@@ -0,0 +1,351 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/be-bcv/ecommerce-backend/internal/models"
+	"github.com/be-bcv/ecommerce-backend/internal/repository"
+	"github.com/be-bcv/ecommerce-backend/pkg/messages"
+	"github.com/be-bcv/ecommerce-backend/pkg/middleware"
+	"github.com/google/uuid"
+)
+
+type ReturnItemRequest struct {
+	OrderItemID uuid.UUID `json:"order_item_id" binding:"required"`
+	Quantity    int       `json:"quantity" binding:"required,min=1"`
+}
+
+type CreateReturnRequest struct {
+	Items  []ReturnItemRequest `json:"items" binding:"required,min=1,dive"`
+	Reason string              `json:"reason" binding:"required"`
+	Photos []string            `json:"photos" binding:"max=10,dive,url"`
+}
+
+type RejectReturnRequest struct {
+	Reason string `json:"reason" binding:"required"`
+}
+
+// CreateReturn opens a return for items of one seller's delivered part of
+// the buyer's order. Items can be returned until the return window, the
+// settlement hold period, has passed since that part was delivered.
+func (s *OrderService) CreateReturn(orderID, userID uuid.UUID, req *CreateReturnRequest) (*models.ReturnRequest, error) {
+	order, err := s.GetOrderByID(orderID, userID)
+	if err != nil {
+		return nil, err
+	}
+	if order.Status != models.OrderStatusDelivered && order.Status != models.OrderStatusRefunded {
+		return nil, fmt.Errorf("items cannot be returned from an order in %s status", order.Status)
+	}
+
+	returnWindow, err := time.ParseDuration(s.config.SettlementHoldPeriod)
+	if err != nil {
+		return nil, fmt.Errorf("invalid return window %q: %w", s.config.SettlementHoldPeriod, err)
+	}
+
+	orderItems := make(map[uuid.UUID]*models.OrderItem, len(order.Items))
+	for i := range order.Items {
+		orderItems[order.Items[i].ID] = &order.Items[i]
+	}
+
+	ret := &models.ReturnRequest{
+		ID:      uuid.New(),
+		OrderID: order.ID,
+		UserID:  userID,
+		Status:  models.ReturnStatusRequested,
+		Reason:  req.Reason,
+		Photos:  req.Photos,
+	}
+	quantities := make(map[uuid.UUID]int, len(req.Items))
+	for _, itemReq := range req.Items {
+		item, ok := orderItems[itemReq.OrderItemID]
+		if !ok {
+			return nil, fmt.Errorf("order item %s not found", itemReq.OrderItemID)
+		}
+		if ret.FulfillmentID == uuid.Nil {
+			ret.FulfillmentID = item.FulfillmentID
+			ret.SellerID = item.SellerID
+		} else if item.FulfillmentID != ret.FulfillmentID {
+			return nil, errors.New("a return can only contain items from one seller")
+		}
+		quantities[item.ID] += itemReq.Quantity
+	}
+
+	var fulfillment *models.Fulfillment
+	for i := range order.Fulfillments {
+		if order.Fulfillments[i].ID == ret.FulfillmentID {
+			fulfillment = &order.Fulfillments[i]
+			break
+		}
+	}
+	if fulfillment == nil || fulfillment.Status != models.OrderStatusDelivered || fulfillment.DeliveryDate == nil {
+		return nil, errors.New("only delivered items can be returned")
+	}
+	if time.Now().After(fulfillment.DeliveryDate.Add(returnWindow)) {
+		return nil, errors.New("the return window for these items has closed")
+	}
+
+	for orderItemID, quantity := range quantities {
+		item := orderItems[orderItemID]
+		if quantity > item.Quantity {
+			return nil, fmt.Errorf("cannot return %d of %s, only %d were ordered", quantity, item.ProductName, item.Quantity)
+		}
+
+		amount := roundCents(item.Price * float64(quantity))
+		ret.RefundAmount += amount
+		ret.Items = append(ret.Items, models.ReturnItem{
+			ID:          uuid.New(),
+			ReturnID:    ret.ID,
+			OrderItemID: item.ID,
+			ProductID:   item.ProductID,
+			ProductName: item.ProductName,
+			Quantity:    quantity,
+			Amount:      amount,
+		})
+	}
+	ret.RefundAmount = roundCents(ret.RefundAmount)
+
+	if err := s.returnRepo.Create(ret); err != nil {
+		return nil, err
+	}
+	return ret, nil
+}
+
+// GetUserReturns lists the buyer's returns, newest first.
+func (s *OrderService) GetUserReturns(userID uuid.UUID, page, limit int) ([]models.ReturnRequest, int64, error) {
+	return s.returnRepo.GetAll(userID, uuid.Nil, "", page, limit)
+}
+
+func (s *OrderService) GetUserReturn(returnID, userID uuid.UUID) (*models.ReturnRequest, error) {
+	ret, err := s.returnRepo.GetByID(returnID)
+	if err != nil {
+		return nil, err
+	}
+	if ret == nil || ret.UserID != userID {
+		return nil, errors.New("return not found")
+	}
+	return ret, nil
+}
+
+// GetSellerReturns lists the returns of the seller's items, optionally
+// filtered by status.
+func (s *OrderService) GetSellerReturns(sellerID uuid.UUID, status string, page, limit int) ([]models.ReturnRequest, int64, error) {
+	return s.returnRepo.GetAll(uuid.Nil, sellerID, status, page, limit)
+}
+
+// GetAllReturns lists every return, optionally filtered by status, for
+// back-office use.
+func (s *OrderService) GetAllReturns(status string, page, limit int) ([]models.ReturnRequest, int64, error) {
+	return s.returnRepo.GetAll(uuid.Nil, uuid.Nil, status, page, limit)
+}
+
+// ApproveReturn accepts a requested return on behalf of its seller or an
+// admin. The order becomes returned, and order.return_approved has
+// product-service restock the items and payment-service refund them.
+func (s *OrderService) ApproveReturn(returnID, reviewerID uuid.UUID, reviewerRole string) error {
+	ret, err := s.getReviewableReturn(returnID, reviewerID, reviewerRole)
+	if err != nil {
+		return err
+	}
+
+	order, err := s.orderRepo.GetOrderByIDForAdmin(ret.OrderID)
+	if err != nil {
+		return err
+	}
+	if order == nil {
+		return errors.New("order not found")
+	}
+
+	now := time.Now()
+	update := &repository.ReturnUpdate{
+		ReturnID: returnID,
+		From:     []string{models.ReturnStatusRequested},
+		Status:   models.ReturnStatusApproved,
+		Updates: map[string]interface{}{
+			"reviewed_by":      reviewerID,
+			"reviewed_by_role": reviewerRole,
+			"reviewed_at":      &now,
+		},
+		OrderStatus:   models.OrderStatusReturned,
+		Notes:         "Return approved: " + ret.Reason,
+		UpdatedBy:     reviewerID,
+		UpdatedByRole: reviewerRole,
+	}
+	return s.returnRepo.UpdateStatus(update, func(ret *models.ReturnRequest) ([]*models.OutboxEvent, error) {
+		approvedEvent, err := s.returnApprovedEvent(ret, order.OrderNumber)
+		if err != nil {
+			return nil, err
+		}
+		statusEvent, err := s.orderStatusEvent(ret.OrderID, models.OrderStatusReturned)
+		if err != nil {
+			return nil, err
+		}
+		return []*models.OutboxEvent{approvedEvent, statusEvent}, nil
+	})
+}
+
+// RejectReturn turns down a requested return, leaving the order as it is.
+func (s *OrderService) RejectReturn(returnID, reviewerID uuid.UUID, reviewerRole string, req *RejectReturnRequest) error {
+	if _, err := s.getReviewableReturn(returnID, reviewerID, reviewerRole); err != nil {
+		return err
+	}
+
+	now := time.Now()
+	update := &repository.ReturnUpdate{
+		ReturnID: returnID,
+		From:     []string{models.ReturnStatusRequested},
+		Status:   models.ReturnStatusRejected,
+		Updates: map[string]interface{}{
+			"reviewed_by":      reviewerID,
+			"reviewed_by_role": reviewerRole,
+			"reviewed_at":      &now,
+			"rejection_reason": req.Reason,
+		},
+	}
+	return s.returnRepo.UpdateStatus(update, func(*models.ReturnRequest) ([]*models.OutboxEvent, error) {
+		return nil, nil
+	})
+}
+
+// RetryRefund asks payment-service again to refund a return whose refund
+// failed, e.g. after the problem was fixed with Midtrans.
+func (s *OrderService) RetryRefund(returnID, adminID uuid.UUID) error {
+	ret, err := s.returnRepo.GetByID(returnID)
+	if err != nil {
+		return err
+	}
+	if ret == nil {
+		return errors.New("return not found")
+	}
+	order, err := s.orderRepo.GetOrderByIDForAdmin(ret.OrderID)
+	if err != nil {
+		return err
+	}
+	if order == nil {
+		return errors.New("order not found")
+	}
+
+	update := &repository.ReturnUpdate{
+		ReturnID: returnID,
+		From:     []string{models.ReturnStatusRefundFailed},
+		Status:   models.ReturnStatusApproved,
+		Updates: map[string]interface{}{
+			"refund_failure_reason": "",
+		},
+	}
+	return s.returnRepo.UpdateStatus(update, func(ret *models.ReturnRequest) ([]*models.OutboxEvent, error) {
+		event, err := s.returnApprovedEvent(ret, order.OrderNumber)
+		if err != nil {
+			return nil, err
+		}
+		return []*models.OutboxEvent{event}, nil
+	})
+}
+
+// HandlePaymentRefunded completes a return once its refund went through and
+// moves the order to refunded. A cancelled order whose payment was refunded
+// only has its payment status updated.
+func (s *OrderService) HandlePaymentRefunded(event *messages.PaymentRefundedEvent) error {
+	if event.ReturnID == "" {
+		order, paymentID, err := s.getPaymentOrder(event.OrderID, event.PaymentID)
+		if err != nil || order == nil {
+			return err
+		}
+		return s.orderRepo.UpdatePaymentStatus(order.ID, paymentID, models.PaymentStatusRefunded)
+	}
+
+	returnID, err := uuid.Parse(event.ReturnID)
+	if err != nil {
+		return fmt.Errorf("invalid return ID %q: %w", event.ReturnID, err)
+	}
+
+	now := time.Now()
+	update := &repository.ReturnUpdate{
+		ReturnID: returnID,
+		From:     []string{models.ReturnStatusApproved},
+		Status:   models.ReturnStatusRefunded,
+		Updates: map[string]interface{}{
+			"refunded_at": &now,
+		},
+		OrderStatus:   models.OrderStatusRefunded,
+		PaymentStatus: models.PaymentStatusRefunded,
+		Notes:         fmt.Sprintf("Refunded %.2f", event.Amount),
+		UpdatedBy:     uuid.Nil,
+		UpdatedByRole: models.ActorSystem,
+	}
+	err = s.returnRepo.UpdateStatus(update, func(ret *models.ReturnRequest) ([]*models.OutboxEvent, error) {
+		event, err := s.orderStatusEvent(ret.OrderID, models.OrderStatusRefunded)
+		if err != nil {
+			return nil, err
+		}
+		return []*models.OutboxEvent{event}, nil
+	})
+	// A redelivered event finds the return already refunded
+	if errors.Is(err, models.ErrReturnNotPending) {
+		return nil
+	}
+	return err
+}
+
+// HandleRefundFailed records why a return could not be refunded. The order
+// stays returned until an admin retries the refund.
+func (s *OrderService) HandleRefundFailed(event *messages.RefundFailedEvent) error {
+	returnID, err := uuid.Parse(event.ReturnID)
+	if err != nil {
+		return fmt.Errorf("invalid return ID %q: %w", event.ReturnID, err)
+	}
+
+	update := &repository.ReturnUpdate{
+		ReturnID: returnID,
+		From:     []string{models.ReturnStatusApproved},
+		Status:   models.ReturnStatusRefundFailed,
+		Updates: map[string]interface{}{
+			"refund_failure_reason": event.Reason,
+		},
+	}
+	err = s.returnRepo.UpdateStatus(update, func(*models.ReturnRequest) ([]*models.OutboxEvent, error) {
+		return nil, nil
+	})
+	if errors.Is(err, models.ErrReturnNotPending) {
+		return nil
+	}
+	return err
+}
+
+// getReviewableReturn loads a return the reviewer may approve or reject:
+// sellers only their own, admins any.
+func (s *OrderService) getReviewableReturn(returnID, reviewerID uuid.UUID, reviewerRole string) (*models.ReturnRequest, error) {
+	ret, err := s.returnRepo.GetByID(returnID)
+	if err != nil {
+		return nil, err
+	}
+	if ret == nil || (reviewerRole != middleware.RoleAdmin && ret.SellerID != reviewerID) {
+		return nil, errors.New("return not found")
+	}
+	return ret, nil
+}
+
+func (s *OrderService) returnApprovedEvent(ret *models.ReturnRequest, orderNumber string) (*models.OutboxEvent, error) {
+	items := make([]messages.ReturnedItemEvent, 0, len(ret.Items))
+	for _, item := range ret.Items {
+		items = append(items, messages.ReturnedItemEvent{
+			OrderItemID: item.OrderItemID.String(),
+			ProductID:   item.ProductID.String(),
+			Quantity:    item.Quantity,
+			Amount:      item.Amount,
+		})
+	}
+
+	event := messages.NewEvent(messages.EventReturnApproved, "order-service", messages.ReturnApprovedEvent{
+		ReturnID:    ret.ID.String(),
+		OrderID:     ret.OrderID.String(),
+		OrderNumber: orderNumber,
+		SellerID:    ret.SellerID.String(),
+		Amount:      ret.RefundAmount,
+		Reason:      ret.Reason,
+		Items:       items,
+	})
+
+	return models.NewOutboxEvent(messages.ExchangeOrder, event)
+}
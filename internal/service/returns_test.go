@@ -0,0 +1,326 @@
+package service
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/be-bcv/ecommerce-backend/internal/models"
+	"github.com/be-bcv/ecommerce-backend/internal/repository"
+	"github.com/be-bcv/ecommerce-backend/pkg/config"
+	"github.com/be-bcv/ecommerce-backend/pkg/messages"
+	"github.com/be-bcv/ecommerce-backend/pkg/middleware"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+type returnFixture struct {
+	db          *gorm.DB
+	service     *OrderService
+	order       *models.Order
+	fulfillment *models.Fulfillment
+	item        *models.OrderItem
+}
+
+// newReturnFixture sets up a delivered order with one seller's fulfillment
+// of 2 units at 50000, delivered deliveredAgo ago, and an order-service with
+// a return window of 7 days.
+func newReturnFixture(t *testing.T, deliveredAgo time.Duration) *returnFixture {
+	t.Helper()
+	db := testDB(t, &models.Order{}, &models.OrderItem{}, &models.Fulfillment{}, &models.OrderStatusHistory{}, &models.ReturnRequest{}, &models.ReturnItem{}, &models.OutboxEvent{})
+
+	deliveredAt := time.Now().Add(-deliveredAgo)
+	order := &models.Order{
+		ID:            uuid.New(),
+		UserID:        uuid.New(),
+		OrderNumber:   "ORD-TEST-" + uuid.NewString()[:8],
+		Status:        models.OrderStatusDelivered,
+		TotalAmount:   100000,
+		Subtotal:      100000,
+		Address:       "Jl. Test 1",
+		PaymentID:     uuid.New(),
+		PaymentStatus: models.PaymentStatusPaid,
+		DeliveryDate:  &deliveredAt,
+	}
+	fulfillment := &models.Fulfillment{
+		ID:           uuid.New(),
+		OrderID:      order.ID,
+		SellerID:     uuid.New(),
+		Status:       models.OrderStatusDelivered,
+		Subtotal:     100000,
+		DeliveryDate: &deliveredAt,
+	}
+	item := &models.OrderItem{
+		ID:            uuid.New(),
+		OrderID:       order.ID,
+		FulfillmentID: fulfillment.ID,
+		SellerID:      fulfillment.SellerID,
+		ProductID:     uuid.New(),
+		ProductName:   "Test Product",
+		Quantity:      2,
+		Price:         50000,
+		Subtotal:      100000,
+	}
+	for _, record := range []interface{}{order, fulfillment, item} {
+		if err := db.Create(record).Error; err != nil {
+			t.Fatalf("failed to create %T: %v", record, err)
+		}
+	}
+
+	return &returnFixture{
+		db: db,
+		service: &OrderService{
+			orderRepo:  repository.NewOrderRepository(db),
+			returnRepo: repository.NewReturnRepository(db),
+			outboxRepo: repository.NewOutboxRepository(db),
+			config:     &config.Config{SettlementHoldPeriod: "168h"},
+		},
+		order:       order,
+		fulfillment: fulfillment,
+		item:        item,
+	}
+}
+
+// requestReturn opens a return of quantity units of the fixture's item.
+func (f *returnFixture) requestReturn(t *testing.T, quantity int) *models.ReturnRequest {
+	t.Helper()
+	ret, err := f.service.CreateReturn(f.order.ID, f.order.UserID, &CreateReturnRequest{
+		Items:  []ReturnItemRequest{{OrderItemID: f.item.ID, Quantity: quantity}},
+		Reason: "Damaged",
+	})
+	if err != nil {
+		t.Fatalf("CreateReturn() error = %v", err)
+	}
+	return ret
+}
+
+func (f *returnFixture) reloadReturn(t *testing.T, returnID uuid.UUID) *models.ReturnRequest {
+	t.Helper()
+	var ret models.ReturnRequest
+	if err := f.db.First(&ret, "id = ?", returnID).Error; err != nil {
+		t.Fatalf("failed to load return: %v", err)
+	}
+	return &ret
+}
+
+func TestCreateReturn(t *testing.T) {
+	tests := []struct {
+		name         string
+		deliveredAgo time.Duration
+		setup        func(t *testing.T, f *returnFixture)
+		quantities   []int
+		wantErr      string
+		wantAmount   float64
+	}{
+		{
+			name:         "within the return window",
+			deliveredAgo: 24 * time.Hour,
+			quantities:   []int{1},
+			wantAmount:   50000,
+		},
+		{
+			name:         "return window closed",
+			deliveredAgo: 8 * 24 * time.Hour,
+			quantities:   []int{1},
+			wantErr:      "return window",
+		},
+		{
+			name:         "more than ordered",
+			deliveredAgo: 24 * time.Hour,
+			quantities:   []int{3},
+			wantErr:      "only 2 were ordered",
+		},
+		{
+			name:         "duplicate lines add up to more than ordered",
+			deliveredAgo: 24 * time.Hour,
+			quantities:   []int{2, 1},
+			wantErr:      "only 2 were ordered",
+		},
+		{
+			name:         "duplicate lines within the ordered quantity",
+			deliveredAgo: 24 * time.Hour,
+			quantities:   []int{1, 1},
+			wantAmount:   100000,
+		},
+		{
+			name:         "another return is open",
+			deliveredAgo: 24 * time.Hour,
+			setup: func(t *testing.T, f *returnFixture) {
+				f.requestReturn(t, 1)
+			},
+			quantities: []int{1},
+			wantErr:    models.ErrReturnInProgress.Error(),
+		},
+		{
+			name:         "more than left after an earlier return",
+			deliveredAgo: 24 * time.Hour,
+			setup: func(t *testing.T, f *returnFixture) {
+				ret := f.requestReturn(t, 2)
+				if err := f.db.Model(ret).Update("status", models.ReturnStatusRefunded).Error; err != nil {
+					t.Fatalf("failed to refund return: %v", err)
+				}
+			},
+			quantities: []int{1},
+			wantErr:    models.ErrReturnQuantityExceeded.Error(),
+		},
+		{
+			name:         "rejected returns do not count",
+			deliveredAgo: 24 * time.Hour,
+			setup: func(t *testing.T, f *returnFixture) {
+				ret := f.requestReturn(t, 2)
+				if err := f.db.Model(ret).Update("status", models.ReturnStatusRejected).Error; err != nil {
+					t.Fatalf("failed to reject return: %v", err)
+				}
+			},
+			quantities: []int{2},
+			wantAmount: 100000,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := newReturnFixture(t, tt.deliveredAgo)
+			if tt.setup != nil {
+				tt.setup(t, f)
+			}
+
+			req := &CreateReturnRequest{Reason: "Damaged"}
+			for _, quantity := range tt.quantities {
+				req.Items = append(req.Items, ReturnItemRequest{OrderItemID: f.item.ID, Quantity: quantity})
+			}
+			ret, err := f.service.CreateReturn(f.order.ID, f.order.UserID, req)
+
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("CreateReturn() error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("CreateReturn() error = %v", err)
+			}
+			if ret.RefundAmount != tt.wantAmount || ret.SellerID != f.fulfillment.SellerID {
+				t.Errorf("return amount = %v for seller %s, want %v for %s", ret.RefundAmount, ret.SellerID, tt.wantAmount, f.fulfillment.SellerID)
+			}
+			if len(ret.Items) != 1 {
+				t.Errorf("return items = %d, want 1", len(ret.Items))
+			}
+		})
+	}
+}
+
+func TestReviewReturnAuthorization(t *testing.T) {
+	reviews := []struct {
+		name       string
+		review     func(s *OrderService, returnID, reviewerID uuid.UUID, role string) error
+		wantStatus string
+	}{
+		{
+			name: "approve",
+			review: func(s *OrderService, returnID, reviewerID uuid.UUID, role string) error {
+				return s.ApproveReturn(returnID, reviewerID, role)
+			},
+			wantStatus: models.ReturnStatusApproved,
+		},
+		{
+			name: "reject",
+			review: func(s *OrderService, returnID, reviewerID uuid.UUID, role string) error {
+				return s.RejectReturn(returnID, reviewerID, role, &RejectReturnRequest{Reason: "Used"})
+			},
+			wantStatus: models.ReturnStatusRejected,
+		},
+	}
+	reviewers := []struct {
+		name       string
+		reviewerID func(f *returnFixture) uuid.UUID
+		role       string
+		wantErr    bool
+	}{
+		{"owning seller", func(f *returnFixture) uuid.UUID { return f.fulfillment.SellerID }, middleware.RoleSeller, false},
+		{"other seller", func(*returnFixture) uuid.UUID { return uuid.New() }, middleware.RoleSeller, true},
+		{"buyer", func(f *returnFixture) uuid.UUID { return f.order.UserID }, middleware.RoleUser, true},
+		{"admin", func(*returnFixture) uuid.UUID { return uuid.New() }, middleware.RoleAdmin, false},
+	}
+
+	for _, review := range reviews {
+		for _, reviewer := range reviewers {
+			t.Run(review.name+" by "+reviewer.name, func(t *testing.T) {
+				f := newReturnFixture(t, 24*time.Hour)
+				ret := f.requestReturn(t, 1)
+
+				err := review.review(f.service, ret.ID, reviewer.reviewerID(f), reviewer.role)
+
+				wantStatus := review.wantStatus
+				if reviewer.wantErr {
+					if err == nil || err.Error() != "return not found" {
+						t.Errorf("error = %v, want return not found", err)
+					}
+					wantStatus = models.ReturnStatusRequested
+				} else if err != nil {
+					t.Fatalf("error = %v", err)
+				}
+				if got := f.reloadReturn(t, ret.ID).Status; got != wantStatus {
+					t.Errorf("return status = %s, want %s", got, wantStatus)
+				}
+			})
+		}
+	}
+}
+
+func TestApproveReturnTwice(t *testing.T) {
+	f := newReturnFixture(t, 24*time.Hour)
+	ret := f.requestReturn(t, 1)
+
+	if err := f.service.ApproveReturn(ret.ID, f.fulfillment.SellerID, middleware.RoleSeller); err != nil {
+		t.Fatalf("ApproveReturn() error = %v", err)
+	}
+	if err := f.service.ApproveReturn(ret.ID, f.fulfillment.SellerID, middleware.RoleSeller); !errors.Is(err, models.ErrReturnNotPending) {
+		t.Errorf("second ApproveReturn() error = %v, want %v", err, models.ErrReturnNotPending)
+	}
+}
+
+func TestHandlePaymentRefundedIdempotent(t *testing.T) {
+	f := newReturnFixture(t, 24*time.Hour)
+	ret := f.requestReturn(t, 1)
+	if err := f.service.ApproveReturn(ret.ID, f.fulfillment.SellerID, middleware.RoleSeller); err != nil {
+		t.Fatalf("ApproveReturn() error = %v", err)
+	}
+
+	event := &messages.PaymentRefundedEvent{
+		PaymentID: f.order.PaymentID.String(),
+		OrderID:   f.order.ID.String(),
+		ReturnID:  ret.ID.String(),
+		Amount:    ret.RefundAmount,
+	}
+	// The second call is a redelivery
+	for i := 0; i < 2; i++ {
+		if err := f.service.HandlePaymentRefunded(event); err != nil {
+			t.Fatalf("HandlePaymentRefunded() call %d error = %v", i+1, err)
+		}
+	}
+
+	if got := f.reloadReturn(t, ret.ID); got.Status != models.ReturnStatusRefunded || got.RefundedAt == nil {
+		t.Errorf("return status = %s, refunded at %v, want refunded", got.Status, got.RefundedAt)
+	}
+
+	var order models.Order
+	if err := f.db.First(&order, "id = ?", f.order.ID).Error; err != nil {
+		t.Fatalf("failed to load order: %v", err)
+	}
+	if order.Status != models.OrderStatusRefunded || order.PaymentStatus != models.PaymentStatusRefunded {
+		t.Errorf("order status = %s, payment status = %s, want refunded", order.Status, order.PaymentStatus)
+	}
+
+	var histories int64
+	f.db.Model(&models.OrderStatusHistory{}).Where("order_id = ? AND to_status = ?", f.order.ID, models.OrderStatusRefunded).Count(&histories)
+	if histories != 1 {
+		t.Errorf("refunded status history entries = %d, want 1", histories)
+	}
+
+	var events int64
+	f.db.Model(&models.OutboxEvent{}).Where("event_name = ? AND payload::jsonb -> 'data' ->> 'status' = ?", messages.EventOrderUpdated, models.OrderStatusRefunded).Count(&events)
+	if events != 1 {
+		t.Errorf("order.updated refunded events = %d, want 1", events)
+	}
+}
@@ -0,0 +1,321 @@
+package service
+
+import (
+	"context"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"math"
+	"strconv"
+	"time"
+
+	"github.com/be-bcv/ecommerce-backend/internal/models"
+	"github.com/be-bcv/ecommerce-backend/internal/repository"
+	"github.com/be-bcv/ecommerce-backend/pkg/config"
+	"github.com/be-bcv/ecommerce-backend/pkg/messages"
+	"github.com/be-bcv/ecommerce-backend/pkg/rabbitmq"
+	"github.com/google/uuid"
+)
+
+const (
+	earningsReleaseInterval = time.Minute
+	earningsReleaseBatch    = 100
+)
+
+// SettlementService keeps the seller settlement ledger in payment-service.
+// Sellers earn each delivered order item's subtotal less the platform
+// commission; the earnings are held for the return window and then become
+// available to be paid out in batches.
+type SettlementService struct {
+	ledgerRepo     *repository.LedgerRepository
+	commissionRate float64
+	holdPeriod     time.Duration
+}
+
+func NewSettlementService(ledgerRepo *repository.LedgerRepository, config *config.Config) (*SettlementService, error) {
+	commissionRate, err := strconv.ParseFloat(config.PlatformCommissionRate, 64)
+	if err != nil || commissionRate < 0 || commissionRate > 1 {
+		return nil, fmt.Errorf("invalid platform commission rate %q", config.PlatformCommissionRate)
+	}
+	holdPeriod, err := time.ParseDuration(config.SettlementHoldPeriod)
+	if err != nil || holdPeriod < 0 {
+		return nil, fmt.Errorf("invalid settlement hold period %q", config.SettlementHoldPeriod)
+	}
+
+	return &SettlementService{
+		ledgerRepo:     ledgerRepo,
+		commissionRate: commissionRate,
+		holdPeriod:     holdPeriod,
+	}, nil
+}
+
+type UpdatePayoutStatusRequest struct {
+	Status        string `json:"status" binding:"required,oneof=paid failed"`
+	Reference     string `json:"reference"`
+	FailureReason string `json:"failure_reason"`
+}
+
+// SellerBalanceResponse summarizes a seller's settlement balances.
+type SellerBalanceResponse struct {
+	SellerID  uuid.UUID `json:"seller_id"`
+	Held      float64   `json:"held"`
+	Available float64   `json:"available"`
+	PaidOut   float64   `json:"paid_out"`
+}
+
+// OrderEventHandlers returns the handlers for the order events that post
+// seller earnings.
+func (s *SettlementService) OrderEventHandlers() map[string]rabbitmq.EventHandler {
+	return map[string]rabbitmq.EventHandler{
+		messages.EventFulfillmentDelivered: func(event *messages.RawEventMessage) error {
+			var data messages.FulfillmentDeliveredEvent
+			if err := event.Decode(&data); err != nil {
+				return err
+			}
+			return s.HandleFulfillmentDelivered(&data)
+		},
+	}
+}
+
+// StoreEventHandlers returns the handlers for the user-service store events
+// that keep the sellers' payout bank accounts up to date.
+func (s *SettlementService) StoreEventHandlers() map[string]rabbitmq.EventHandler {
+	return map[string]rabbitmq.EventHandler{
+		messages.EventStoreBankAccountUpdated: func(event *messages.RawEventMessage) error {
+			var data messages.StoreBankAccountUpdatedEvent
+			if err := event.Decode(&data); err != nil {
+				return err
+			}
+			return s.HandleStoreBankAccountUpdated(&data)
+		},
+	}
+}
+
+// HandleStoreBankAccountUpdated records the bank account the seller's next
+// payouts go to.
+func (s *SettlementService) HandleStoreBankAccountUpdated(event *messages.StoreBankAccountUpdatedEvent) error {
+	sellerID, err := uuid.Parse(event.SellerID)
+	if err != nil {
+		return fmt.Errorf("invalid seller ID %q: %w", event.SellerID, err)
+	}
+	storeID, err := uuid.Parse(event.StoreID)
+	if err != nil {
+		return fmt.Errorf("invalid store ID %q: %w", event.StoreID, err)
+	}
+
+	return s.ledgerRepo.UpsertSellerBankAccount(&models.SellerBankAccount{
+		SellerID:          sellerID,
+		StoreID:           storeID,
+		BankName:          event.BankName,
+		BankAccountNumber: event.BankAccountNumber,
+		BankAccountName:   event.BankAccountName,
+		UpdatedAt:         event.UpdatedAt,
+	})
+}
+
+// HandleFulfillmentDelivered records the seller's earning on each delivered
+// item, held until the return window ends.
+func (s *SettlementService) HandleFulfillmentDelivered(event *messages.FulfillmentDeliveredEvent) error {
+	orderID, err := uuid.Parse(event.OrderID)
+	if err != nil {
+		return fmt.Errorf("invalid order ID %q: %w", event.OrderID, err)
+	}
+	fulfillmentID, err := uuid.Parse(event.FulfillmentID)
+	if err != nil {
+		return fmt.Errorf("invalid fulfillment ID %q: %w", event.FulfillmentID, err)
+	}
+	sellerID, err := uuid.Parse(event.SellerID)
+	if err != nil {
+		return fmt.Errorf("invalid seller ID %q: %w", event.SellerID, err)
+	}
+
+	earnings := make([]*models.SellerEarning, 0, len(event.Items))
+	for _, item := range event.Items {
+		orderItemID, err := uuid.Parse(item.OrderItemID)
+		if err != nil {
+			return fmt.Errorf("invalid order item ID %q: %w", item.OrderItemID, err)
+		}
+		productID, err := uuid.Parse(item.ProductID)
+		if err != nil {
+			return fmt.Errorf("invalid product ID %q: %w", item.ProductID, err)
+		}
+
+		gross := roundCents(item.Subtotal)
+		commission := roundCents(gross * s.commissionRate)
+		earnings = append(earnings, &models.SellerEarning{
+			ID:             uuid.New(),
+			OrderItemID:    orderItemID,
+			OrderID:        orderID,
+			OrderNumber:    event.OrderNumber,
+			FulfillmentID:  fulfillmentID,
+			SellerID:       sellerID,
+			ProductID:      productID,
+			Quantity:       item.Quantity,
+			Gross:          gross,
+			CommissionRate: s.commissionRate,
+			Commission:     commission,
+			Net:            gross - commission,
+			Status:         models.SellerEarningHeld,
+			DeliveredAt:    event.DeliveredAt,
+			AvailableAt:    event.DeliveredAt.Add(s.holdPeriod),
+		})
+	}
+
+	return s.ledgerRepo.RecordSales(earnings)
+}
+
+// RunEarningsRelease makes held earnings available once their return window
+// ends, polling until ctx is cancelled.
+func (s *SettlementService) RunEarningsRelease(ctx context.Context) {
+	ticker := time.NewTicker(earningsReleaseInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		// Keep releasing until a batch comes back short
+		for ctx.Err() == nil {
+			released, err := s.ledgerRepo.ReleaseDue(time.Now(), earningsReleaseBatch)
+			if err != nil {
+				log.Printf("Failed to release seller earnings: %v", err)
+				break
+			}
+			if released < earningsReleaseBatch {
+				break
+			}
+		}
+	}
+}
+
+func (s *SettlementService) GetSellerBalance(sellerID uuid.UUID) (*SellerBalanceResponse, error) {
+	balances, err := s.ledgerRepo.GetSellerBalances(sellerID)
+	if err != nil {
+		return nil, err
+	}
+	paidOut, err := s.ledgerRepo.GetPaidOutTotal(sellerID)
+	if err != nil {
+		return nil, err
+	}
+
+	return &SellerBalanceResponse{
+		SellerID:  sellerID,
+		Held:      roundCents(balances[models.LedgerAccountSellerHeld]),
+		Available: roundCents(balances[models.LedgerAccountSellerAvailable]),
+		PaidOut:   roundCents(paidOut),
+	}, nil
+}
+
+// GetSellerLedger lists the entries on the seller's accounts, newest first.
+func (s *SettlementService) GetSellerLedger(sellerID uuid.UUID, page, limit int) ([]models.LedgerEntry, int64, error) {
+	return s.ledgerRepo.GetSellerEntries(sellerID, page, limit)
+}
+
+// CreatePayoutBatch batches the available balance of every seller whose bank
+// account is known for payout.
+func (s *SettlementService) CreatePayoutBatch(adminID uuid.UUID) (*models.PayoutBatch, error) {
+	batch, err := s.ledgerRepo.CreatePayoutBatch(adminID)
+	if err != nil {
+		return nil, err
+	}
+	if batch == nil {
+		return nil, errors.New("no seller with a bank account has an available balance to pay out")
+	}
+	return batch, nil
+}
+
+func (s *SettlementService) GetPayoutBatches(page, limit int, status string) ([]models.PayoutBatch, int64, error) {
+	return s.ledgerRepo.GetPayoutBatches(page, limit, status)
+}
+
+func (s *SettlementService) GetPayoutBatch(batchID uuid.UUID) (*models.PayoutBatch, error) {
+	batch, err := s.ledgerRepo.GetPayoutBatchByID(batchID)
+	if err != nil {
+		return nil, err
+	}
+	if batch == nil {
+		return nil, errors.New("payout batch not found")
+	}
+	return batch, nil
+}
+
+// ProcessPayoutBatch marks a pending batch as sent to the bank, after which
+// its payouts can be settled.
+func (s *SettlementService) ProcessPayoutBatch(batchID uuid.UUID) (*models.PayoutBatch, error) {
+	started, err := s.ledgerRepo.StartPayoutBatch(batchID)
+	if err != nil {
+		return nil, err
+	}
+
+	batch, err := s.GetPayoutBatch(batchID)
+	if err != nil {
+		return nil, err
+	}
+	if !started {
+		return nil, fmt.Errorf("cannot process a payout batch with %s status", batch.Status)
+	}
+	return batch, nil
+}
+
+// UpdatePayoutStatus records the bank's result for a payout. Failed payouts
+// return to the seller's available balance.
+func (s *SettlementService) UpdatePayoutStatus(payoutID uuid.UUID, req *UpdatePayoutStatusRequest) (*models.Payout, error) {
+	if req.Status == models.PayoutFailed && req.FailureReason == "" {
+		return nil, errors.New("failure reason is required for a failed payout")
+	}
+
+	payout, err := s.ledgerRepo.SettlePayout(payoutID, req.Status, req.Reference, req.FailureReason)
+	if err != nil {
+		return nil, err
+	}
+	if payout == nil {
+		return nil, errors.New("payout not found")
+	}
+	return payout, nil
+}
+
+// ExportPayoutBatchCSV writes the batch's payouts as CSV for finance, with
+// the bank account each payout goes to.
+func (s *SettlementService) ExportPayoutBatchCSV(batchID uuid.UUID, w io.Writer) error {
+	batch, err := s.GetPayoutBatch(batchID)
+	if err != nil {
+		return err
+	}
+
+	writer := csv.NewWriter(w)
+	if err := writer.Write([]string{"payout_id", "batch_id", "seller_id", "bank_name", "bank_account_number", "bank_account_name", "amount", "status", "reference", "failure_reason", "paid_at"}); err != nil {
+		return err
+	}
+	for _, payout := range batch.Payouts {
+		paidAt := ""
+		if payout.PaidAt != nil {
+			paidAt = payout.PaidAt.Format(time.RFC3339)
+		}
+		if err := writer.Write([]string{
+			payout.ID.String(),
+			batch.ID.String(),
+			payout.SellerID.String(),
+			payout.BankName,
+			payout.BankAccountNumber,
+			payout.BankAccountName,
+			strconv.FormatFloat(payout.Amount, 'f', 2, 64),
+			payout.Status,
+			payout.Reference,
+			payout.FailureReason,
+			paidAt,
+		}); err != nil {
+			return err
+		}
+	}
+	writer.Flush()
+	return writer.Error()
+}
+
+func roundCents(amount float64) float64 {
+	return math.Round(amount*100) / 100
+}
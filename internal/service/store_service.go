@@ -0,0 +1,468 @@
+package service
+
+import (
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/be-bcv/ecommerce-backend/internal/models"
+	"github.com/be-bcv/ecommerce-backend/internal/repository"
+	"github.com/be-bcv/ecommerce-backend/pkg/messages"
+	"github.com/be-bcv/ecommerce-backend/pkg/middleware"
+	"github.com/google/uuid"
+)
+
+// StoreService handles seller onboarding: users apply with a store profile
+// and become sellers once an admin approves it.
+type StoreService struct {
+	storeRepo *repository.StoreRepository
+	userRepo  *repository.UserRepository
+}
+
+func NewStoreService(storeRepo *repository.StoreRepository, userRepo *repository.UserRepository) *StoreService {
+	return &StoreService{
+		storeRepo: storeRepo,
+		userRepo:  userRepo,
+	}
+}
+
+type ApplyStoreRequest struct {
+	Name              string `json:"name" binding:"required"`
+	Description       string `json:"description"`
+	LogoURL           string `json:"logo_url"`
+	Address           string `json:"address" binding:"required"`
+	BankName          string `json:"bank_name" binding:"required"`
+	BankAccountNumber string `json:"bank_account_number" binding:"required"`
+	BankAccountName   string `json:"bank_account_name" binding:"required"`
+}
+
+type UpdateStoreRequest struct {
+	Name              string `json:"name"`
+	Description       string `json:"description"`
+	LogoURL           string `json:"logo_url"`
+	Address           string `json:"address"`
+	BankName          string `json:"bank_name"`
+	BankAccountNumber string `json:"bank_account_number"`
+	BankAccountName   string `json:"bank_account_name"`
+}
+
+type RejectStoreRequest struct {
+	Reason string `json:"reason" binding:"required"`
+}
+
+// Apply submits the user's application to become a seller. A rejected
+// application is resubmitted with the new details.
+func (s *StoreService) Apply(userID uuid.UUID, req *ApplyStoreRequest) (*models.Store, error) {
+	user, err := s.userRepo.GetByID(userID)
+	if err != nil {
+		return nil, err
+	}
+	if user == nil {
+		return nil, errors.New("user not found")
+	}
+	if user.Role == middleware.RoleAdmin {
+		return nil, errors.New("admins cannot apply to become sellers")
+	}
+
+	store, err := s.storeRepo.GetByUserID(userID)
+	if err != nil {
+		return nil, err
+	}
+
+	if store == nil {
+		store = &models.Store{ID: uuid.New(), UserID: userID}
+	} else {
+		switch store.Status {
+		case models.StoreStatusPending:
+			return nil, errors.New("store application is already pending")
+		case models.StoreStatusApproved:
+			return nil, errors.New("user is already a seller")
+		}
+	}
+
+	store.Name = req.Name
+	store.Description = req.Description
+	store.LogoURL = req.LogoURL
+	store.Address = req.Address
+	store.BankName = req.BankName
+	store.BankAccountNumber = req.BankAccountNumber
+	store.BankAccountName = req.BankAccountName
+	store.Status = models.StoreStatusPending
+	store.RejectionReason = ""
+	store.ReviewedBy = nil
+	store.ReviewedAt = nil
+
+	// The slug is only public once the store is approved, so it follows the
+	// name until then
+	store.Slug, err = s.uniqueSlug(req.Name, store.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	if store.CreatedAt.IsZero() {
+		err = s.storeRepo.Create(store)
+	} else {
+		err = s.storeRepo.Update(store)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return store, nil
+}
+
+// GetMyStore returns the user's store with its pending bank account change.
+func (s *StoreService) GetMyStore(userID uuid.UUID) (*models.Store, error) {
+	store, err := s.storeRepo.GetByUserID(userID)
+	if err != nil {
+		return nil, err
+	}
+	if store == nil {
+		return nil, errors.New("store not found")
+	}
+
+	store.PendingBankChange, err = s.storeRepo.GetPendingBankChange(store.ID)
+	if err != nil {
+		return nil, err
+	}
+	return store, nil
+}
+
+// UpdateMyStore changes the user's store profile. The slug of an approved
+// store stays the same so links to the storefront keep working. New bank
+// details of an approved store are only used once an admin approves them,
+// so a taken-over seller account can't redirect payouts; until then they
+// are the store's pending bank change.
+func (s *StoreService) UpdateMyStore(userID uuid.UUID, req *UpdateStoreRequest) (*models.Store, error) {
+	store, err := s.storeRepo.GetByUserID(userID)
+	if err != nil {
+		return nil, err
+	}
+	if store == nil {
+		return nil, errors.New("store not found")
+	}
+
+	// Update fields
+	if req.Name != "" {
+		store.Name = req.Name
+		if store.Status != models.StoreStatusApproved {
+			if store.Slug, err = s.uniqueSlug(req.Name, store.ID); err != nil {
+				return nil, err
+			}
+		}
+	}
+	if req.Description != "" {
+		store.Description = req.Description
+	}
+	if req.LogoURL != "" {
+		store.LogoURL = req.LogoURL
+	}
+	if req.Address != "" {
+		store.Address = req.Address
+	}
+
+	bank := &models.StoreBankChange{
+		BankName:          store.BankName,
+		BankAccountNumber: store.BankAccountNumber,
+		BankAccountName:   store.BankAccountName,
+	}
+	if req.BankName != "" {
+		bank.BankName = req.BankName
+	}
+	if req.BankAccountNumber != "" {
+		bank.BankAccountNumber = req.BankAccountNumber
+	}
+	if req.BankAccountName != "" {
+		bank.BankAccountName = req.BankAccountName
+	}
+	bankChanged := bank.BankName != store.BankName ||
+		bank.BankAccountNumber != store.BankAccountNumber ||
+		bank.BankAccountName != store.BankAccountName
+
+	if store.Status != models.StoreStatusApproved {
+		// Reviewed together with the application
+		store.BankName = bank.BankName
+		store.BankAccountNumber = bank.BankAccountNumber
+		store.BankAccountName = bank.BankAccountName
+
+		if err := s.storeRepo.Update(store); err != nil {
+			return nil, err
+		}
+		return store, nil
+	}
+
+	// Approved stores are public; tell product-service about the change
+	store.UpdatedAt = time.Now()
+	event, err := s.storeUpdatedEvent(store)
+	if err != nil {
+		return nil, err
+	}
+
+	if !bankChanged {
+		if err := s.storeRepo.Update(store, event); err != nil {
+			return nil, err
+		}
+		store.PendingBankChange, err = s.storeRepo.GetPendingBankChange(store.ID)
+		if err != nil {
+			return nil, err
+		}
+		return store, nil
+	}
+
+	bank.ID = uuid.New()
+	bank.StoreID = store.ID
+	bank.Status = models.BankChangeStatusPending
+	bank.RequestedBy = userID
+	if err := s.storeRepo.RequestBankChange(store, bank, event); err != nil {
+		return nil, err
+	}
+	store.PendingBankChange = bank
+
+	return store, nil
+}
+
+func (s *StoreService) GetAllStores(status string, page, limit int) ([]models.Store, int64, error) {
+	stores, total, err := s.storeRepo.GetAll(status, page, limit)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	// Clear passwords
+	for i := range stores {
+		stores[i].User.Password = ""
+	}
+
+	return stores, total, nil
+}
+
+// ApproveStore approves a pending application and makes its owner a seller.
+// The seller role is in the owner's tokens from their next login or refresh.
+func (s *StoreService) ApproveStore(storeID, adminID uuid.UUID) (*models.Store, error) {
+	store, err := s.getPendingStore(storeID)
+	if err != nil {
+		return nil, err
+	}
+
+	owner, err := s.userRepo.GetByID(store.UserID)
+	if err != nil {
+		return nil, err
+	}
+	if owner == nil {
+		return nil, errors.New("store owner not found")
+	}
+
+	now := time.Now()
+	store.Status = models.StoreStatusApproved
+	store.ReviewedBy = &adminID
+	store.ReviewedAt = &now
+	store.UpdatedAt = now
+	owner.Role = middleware.RoleSeller
+
+	// Store approved and bank account events, stored with the approval
+	event, err := s.storeApprovedEvent(store)
+	if err != nil {
+		return nil, err
+	}
+	bankEvent, err := s.storeBankAccountEvent(store, now)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.storeRepo.Approve(store, owner, event, bankEvent); err != nil {
+		return nil, err
+	}
+
+	return store, nil
+}
+
+func (s *StoreService) RejectStore(storeID, adminID uuid.UUID, req *RejectStoreRequest) (*models.Store, error) {
+	store, err := s.getPendingStore(storeID)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	store.Status = models.StoreStatusRejected
+	store.RejectionReason = req.Reason
+	store.ReviewedBy = &adminID
+	store.ReviewedAt = &now
+
+	if err := s.storeRepo.Update(store); err != nil {
+		return nil, err
+	}
+
+	return store, nil
+}
+
+func (s *StoreService) GetBankChanges(status string, page, limit int) ([]models.StoreBankChange, int64, error) {
+	return s.storeRepo.GetBankChanges(status, page, limit)
+}
+
+// ApproveBankChange makes a store's requested bank account the one its
+// payouts go to.
+func (s *StoreService) ApproveBankChange(changeID, adminID uuid.UUID) (*models.StoreBankChange, error) {
+	change, err := s.getPendingBankChange(changeID)
+	if err != nil {
+		return nil, err
+	}
+
+	store, err := s.storeRepo.GetByID(change.StoreID)
+	if err != nil {
+		return nil, err
+	}
+	if store == nil {
+		return nil, errors.New("store not found")
+	}
+
+	now := time.Now()
+	change.Status = models.BankChangeStatusApproved
+	change.ReviewedBy = &adminID
+	change.ReviewedAt = &now
+	store.BankName = change.BankName
+	store.BankAccountNumber = change.BankAccountNumber
+	store.BankAccountName = change.BankAccountName
+
+	// Bank account event for payment-service, stored with the approval
+	event, err := s.storeBankAccountEvent(store, now)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.storeRepo.ReviewBankChange(change, store, event); err != nil {
+		return nil, err
+	}
+
+	return change, nil
+}
+
+// RejectBankChange rejects a store's requested bank account; payouts keep
+// going to the current one.
+func (s *StoreService) RejectBankChange(changeID, adminID uuid.UUID, req *RejectStoreRequest) (*models.StoreBankChange, error) {
+	change, err := s.getPendingBankChange(changeID)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	change.Status = models.BankChangeStatusRejected
+	change.RejectionReason = req.Reason
+	change.ReviewedBy = &adminID
+	change.ReviewedAt = &now
+
+	if err := s.storeRepo.ReviewBankChange(change, nil); err != nil {
+		return nil, err
+	}
+
+	return change, nil
+}
+
+func (s *StoreService) getPendingBankChange(changeID uuid.UUID) (*models.StoreBankChange, error) {
+	change, err := s.storeRepo.GetBankChangeByID(changeID)
+	if err != nil {
+		return nil, err
+	}
+	if change == nil {
+		return nil, errors.New("bank account change not found")
+	}
+	if change.Status != models.BankChangeStatusPending {
+		return nil, errors.New("bank account change is not pending")
+	}
+	return change, nil
+}
+
+func (s *StoreService) getPendingStore(storeID uuid.UUID) (*models.Store, error) {
+	store, err := s.storeRepo.GetByID(storeID)
+	if err != nil {
+		return nil, err
+	}
+	if store == nil {
+		return nil, errors.New("store not found")
+	}
+	if store.Status != models.StoreStatusPending {
+		return nil, errors.New("store application is not pending")
+	}
+	return store, nil
+}
+
+// uniqueSlug derives a URL slug from the store name, adding a short suffix
+// if another store already uses it.
+func (s *StoreService) uniqueSlug(name string, storeID uuid.UUID) (string, error) {
+	base := slugify(name)
+	slug := base
+	for i := 0; i < 5; i++ {
+		taken, err := s.storeRepo.SlugTaken(slug, storeID)
+		if err != nil {
+			return "", err
+		}
+		if !taken {
+			return slug, nil
+		}
+		slug = base + "-" + uuid.New().String()[:6]
+	}
+	return "", errors.New("failed to generate a unique store slug")
+}
+
+// slugify lowercases name and joins its letters and digits with hyphens,
+// e.g. "Toko Buku & Alat Tulis" becomes "toko-buku-alat-tulis".
+func slugify(name string) string {
+	var b strings.Builder
+	hyphen := false
+	for _, r := range strings.ToLower(name) {
+		if (r >= 'a' && r <= 'z') || (r >= '0' && r <= '9') {
+			if hyphen && b.Len() > 0 {
+				b.WriteByte('-')
+			}
+			b.WriteRune(r)
+			hyphen = false
+		} else {
+			hyphen = true
+		}
+	}
+	if b.Len() == 0 {
+		return "store"
+	}
+	return b.String()
+}
+
+func (s *StoreService) storeApprovedEvent(store *models.Store) (*models.OutboxEvent, error) {
+	event := messages.NewEvent(messages.EventStoreApproved, "user-service", messages.StoreApprovedEvent{
+		StoreID:     store.ID.String(),
+		SellerID:    store.UserID.String(),
+		Name:        store.Name,
+		Slug:        store.Slug,
+		Description: store.Description,
+		LogoURL:     store.LogoURL,
+		Address:     store.Address,
+		UpdatedAt:   store.UpdatedAt,
+	})
+
+	return models.NewOutboxEvent(messages.ExchangeUser, event)
+}
+
+func (s *StoreService) storeUpdatedEvent(store *models.Store) (*models.OutboxEvent, error) {
+	event := messages.NewEvent(messages.EventStoreUpdated, "user-service", messages.StoreUpdatedEvent{
+		StoreID:     store.ID.String(),
+		SellerID:    store.UserID.String(),
+		Name:        store.Name,
+		Slug:        store.Slug,
+		Description: store.Description,
+		LogoURL:     store.LogoURL,
+		Address:     store.Address,
+		UpdatedAt:   store.UpdatedAt,
+	})
+
+	return models.NewOutboxEvent(messages.ExchangeUser, event)
+}
+
+func (s *StoreService) storeBankAccountEvent(store *models.Store, updatedAt time.Time) (*models.OutboxEvent, error) {
+	event := messages.NewEvent(messages.EventStoreBankAccountUpdated, "user-service", messages.StoreBankAccountUpdatedEvent{
+		StoreID:           store.ID.String(),
+		SellerID:          store.UserID.String(),
+		BankName:          store.BankName,
+		BankAccountNumber: store.BankAccountNumber,
+		BankAccountName:   store.BankAccountName,
+		UpdatedAt:         updatedAt,
+	})
+
+	return models.NewOutboxEvent(messages.ExchangeUser, event)
+}
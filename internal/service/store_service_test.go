@@ -0,0 +1,28 @@
+package service
+
+import "testing"
+
+func TestSlugify(t *testing.T) {
+	tests := []struct {
+		name string
+		in   string
+		want string
+	}{
+		{"words", "Toko Buku & Alat Tulis", "toko-buku-alat-tulis"},
+		{"digits", "Gadget 24 Jam", "gadget-24-jam"},
+		{"leading and trailing punctuation", "  --Toko Maju!!  ", "toko-maju"},
+		{"repeated separators", "Toko___Maju...Jaya", "toko-maju-jaya"},
+		{"already a slug", "toko-maju", "toko-maju"},
+		{"non-ASCII letters separate words", "Kopi Café Ñusa", "kopi-caf-usa"},
+		{"nothing usable", "!!! ???", "store"},
+		{"empty", "", "store"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := slugify(tt.in); got != tt.want {
+				t.Errorf("slugify(%q) = %q, want %q", tt.in, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,117 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/be-bcv/ecommerce-backend/internal/models"
+	"github.com/be-bcv/ecommerce-backend/internal/repository"
+	"github.com/be-bcv/ecommerce-backend/pkg/messages"
+	"github.com/be-bcv/ecommerce-backend/pkg/rabbitmq"
+	"github.com/google/uuid"
+)
+
+// StorefrontService serves public store pages in product-service. Stores
+// are managed in user-service; approved ones are copied here from store
+// events so a storefront can be shown together with its products.
+type StorefrontService struct {
+	storefrontRepo *repository.StorefrontRepository
+	productRepo    *repository.ProductRepository
+	reviewRepo     *repository.ProductReviewRepository
+}
+
+func NewStorefrontService(storefrontRepo *repository.StorefrontRepository, productRepo *repository.ProductRepository, reviewRepo *repository.ProductReviewRepository) *StorefrontService {
+	return &StorefrontService{
+		storefrontRepo: storefrontRepo,
+		productRepo:    productRepo,
+		reviewRepo:     reviewRepo,
+	}
+}
+
+type StorefrontResponse struct {
+	*models.Storefront
+	AverageRating float64          `json:"average_rating"`
+	ReviewCount   int64            `json:"review_count"`
+	Products      []models.Product `json:"products"`
+}
+
+// GetStorefront returns the store with the given slug, its rating across all
+// its products and a page of its active products.
+func (s *StorefrontService) GetStorefront(slug string, page, limit int) (*StorefrontResponse, int64, error) {
+	storefront, err := s.storefrontRepo.GetBySlug(slug)
+	if err != nil {
+		return nil, 0, err
+	}
+	if storefront == nil {
+		return nil, 0, errors.New("store not found")
+	}
+
+	averageRating, reviewCount, err := s.reviewRepo.GetSellerRating(storefront.SellerID)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	products, total, err := s.productRepo.GetActiveBySeller(storefront.SellerID, page, limit)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	return &StorefrontResponse{
+		Storefront:    storefront,
+		AverageRating: averageRating,
+		ReviewCount:   reviewCount,
+		Products:      products,
+	}, total, nil
+}
+
+// StoreEventHandlers returns the handlers for the user-service store events
+// that keep storefronts up to date.
+func (s *StorefrontService) StoreEventHandlers() map[string]rabbitmq.EventHandler {
+	return map[string]rabbitmq.EventHandler{
+		messages.EventStoreApproved: func(event *messages.RawEventMessage) error {
+			var data messages.StoreApprovedEvent
+			if err := event.Decode(&data); err != nil {
+				return err
+			}
+			return s.saveStorefront(data.StoreID, data.SellerID, &models.Storefront{
+				Name:        data.Name,
+				Slug:        data.Slug,
+				Description: data.Description,
+				LogoURL:     data.LogoURL,
+				Address:     data.Address,
+				UpdatedAt:   data.UpdatedAt,
+			})
+		},
+		messages.EventStoreUpdated: func(event *messages.RawEventMessage) error {
+			var data messages.StoreUpdatedEvent
+			if err := event.Decode(&data); err != nil {
+				return err
+			}
+			return s.saveStorefront(data.StoreID, data.SellerID, &models.Storefront{
+				Name:        data.Name,
+				Slug:        data.Slug,
+				Description: data.Description,
+				LogoURL:     data.LogoURL,
+				Address:     data.Address,
+				UpdatedAt:   data.UpdatedAt,
+			})
+		},
+	}
+}
+
+// saveStorefront stores the profile carried by a store event under the
+// store's and seller's IDs.
+func (s *StorefrontService) saveStorefront(storeID, sellerID string, storefront *models.Storefront) error {
+	id, err := uuid.Parse(storeID)
+	if err != nil {
+		return fmt.Errorf("invalid store ID %q: %w", storeID, err)
+	}
+	seller, err := uuid.Parse(sellerID)
+	if err != nil {
+		return fmt.Errorf("invalid seller ID %q: %w", sellerID, err)
+	}
+
+	storefront.ID = id
+	storefront.SellerID = seller
+	return s.storefrontRepo.Upsert(storefront)
+}
@@ -2,48 +2,86 @@ package service
 
 import (
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
 	"errors"
-	"fmt"
+	"strings"
+	"sync"
 	"time"
 
 	"github.com/be-bcv/ecommerce-backend/internal/models"
 	"github.com/be-bcv/ecommerce-backend/internal/repository"
 	"github.com/be-bcv/ecommerce-backend/pkg/config"
 	"github.com/be-bcv/ecommerce-backend/pkg/messages"
-	"github.com/be-bcv/ecommerce-backend/pkg/rabbitmq"
+	"github.com/be-bcv/ecommerce-backend/pkg/middleware"
 	"github.com/be-bcv/ecommerce-backend/pkg/redis"
 	"github.com/golang-jwt/jwt/v5"
 	"github.com/google/uuid"
 	"golang.org/x/crypto/bcrypt"
 )
 
+// refreshTokenTTL is how long refresh tokens, and so idle sessions, last.
+const refreshTokenTTL = 7 * 24 * time.Hour
+
 type UserService struct {
-	userRepo  *repository.UserRepository
-	redis     *redis.RedisClient
-	rabbitmq  *rabbitmq.RabbitMQ
-	config    *config.Config
+	userRepo    *repository.UserRepository
+	loginGuard  *LoginGuard
+	revocations *middleware.TokenRevocations
+	config      *config.Config
 }
 
-func NewUserService(userRepo *repository.UserRepository, redis *redis.RedisClient, rabbitmq *rabbitmq.RabbitMQ, config *config.Config) *UserService {
+func NewUserService(userRepo *repository.UserRepository, redis *redis.RedisClient, config *config.Config) *UserService {
 	return &UserService{
-		userRepo: userRepo,
-		redis:    redis,
-		rabbitmq: rabbitmq,
-		config:   config,
+		userRepo:    userRepo,
+		loginGuard:  NewLoginGuard(redis),
+		revocations: middleware.NewTokenRevocations(redis),
+		config:      config,
 	}
 }
 
+var (
+	dummyHashOnce sync.Once
+	dummyHash     []byte
+)
+
+// dummyPasswordHash is compared against on logins to unknown emails, so that
+// they take as long as logins with a wrong password.
+func dummyPasswordHash() []byte {
+	dummyHashOnce.Do(func() {
+		dummyHash, _ = bcrypt.GenerateFromPassword([]byte("not-a-password"), bcrypt.DefaultCost)
+	})
+	return dummyHash
+}
+
 type RegisterRequest struct {
 	Name     string `json:"name" binding:"required"`
 	Email    string `json:"email" binding:"required,email"`
 	Password string `json:"password" binding:"required,min=6"`
 	Phone    string `json:"phone"`
 	Address  string `json:"address"`
+	Device   string `json:"device"`
 }
 
 type LoginRequest struct {
 	Email    string `json:"email" binding:"required,email"`
 	Password string `json:"password" binding:"required"`
+	// Device optionally names the device signing in, e.g. "Work laptop";
+	// otherwise it is derived from the user agent
+	Device string `json:"device"`
+}
+
+// ClientInfo describes where a request comes from, recorded on the session
+// it signs in.
+type ClientInfo struct {
+	IPAddress string
+	UserAgent string
+}
+
+// SessionResponse is a session as listed to its user.
+type SessionResponse struct {
+	models.UserSession
+	// Current marks the session the request was made with
+	Current bool `json:"current"`
 }
 
 type AuthResponse struct {
@@ -52,7 +90,7 @@ type AuthResponse struct {
 	RefreshToken string      `json:"refresh_token"`
 }
 
-func (s *UserService) Register(req *RegisterRequest) (*AuthResponse, error) {
+func (s *UserService) Register(req *RegisterRequest, client ClientInfo) (*AuthResponse, error) {
 	// Check if user already exists
 	existingUser, err := s.userRepo.GetByEmail(req.Email)
 	if err != nil {
@@ -76,28 +114,25 @@ func (s *UserService) Register(req *RegisterRequest) (*AuthResponse, error) {
 		Password: string(hashedPassword),
 		Phone:    req.Phone,
 		Address:  req.Address,
-		Role:     "user",
+		Role:     middleware.RoleUser,
 		IsActive: true,
 	}
 
-	if err := s.userRepo.Create(user); err != nil {
+	// User registered event, stored with the user
+	event, err := s.userRegisteredEvent(user)
+	if err != nil {
 		return nil, err
 	}
 
-	// Generate tokens
-	accessToken, refreshToken, err := s.generateTokens(user)
-	if err != nil {
+	if err := s.userRepo.Create(user, event); err != nil {
 		return nil, err
 	}
 
-	// Store refresh token
-	if err := s.storeRefreshToken(user.ID, refreshToken); err != nil {
+	accessToken, refreshToken, err := s.startSession(user, req.Device, client)
+	if err != nil {
 		return nil, err
 	}
 
-	// Publish user registered event
-	s.publishUserRegisteredEvent(user)
-
 	// Clear password for response
 	user.Password = ""
 
@@ -108,34 +143,42 @@ func (s *UserService) Register(req *RegisterRequest) (*AuthResponse, error) {
 	}, nil
 }
 
-func (s *UserService) Login(req *LoginRequest) (*AuthResponse, error) {
+// Login checks the credentials. Failed logins are counted per account and
+// per client IP; past a few failures further attempts are delayed and then
+// locked out with a *LoginLockedError. Unknown emails and wrong passwords
+// both fail with ErrInvalidCredentials.
+func (s *UserService) Login(req *LoginRequest, client ClientInfo) (*AuthResponse, error) {
+	ctx := context.Background()
+
+	// Locked accounts and IPs may not try passwords at all. The attempt is
+	// counted as a failure before the password is checked, so parallel
+	// guesses are all counted
+	if err := s.loginGuard.Attempt(ctx, req.Email, client.IPAddress); err != nil {
+		return nil, err
+	}
+
 	// Get user by email
 	user, err := s.userRepo.GetByEmail(req.Email)
 	if err != nil {
 		return nil, err
 	}
-	if user == nil {
-		return nil, errors.New("user not found")
-	}
 
-	// Check password
-	if err := bcrypt.CompareHashAndPassword([]byte(user.Password), []byte(req.Password)); err != nil {
-		return nil, errors.New("invalid credentials")
+	// Check password, against a dummy hash for unknown emails. Deactivated
+	// accounts fail like a wrong password, so they can't be told apart
+	hash := dummyPasswordHash()
+	if user != nil {
+		hash = []byte(user.Password)
 	}
-
-	// Check if user is active
-	if !user.IsActive {
-		return nil, errors.New("user account is deactivated")
+	if err := bcrypt.CompareHashAndPassword(hash, []byte(req.Password)); err != nil || user == nil || !user.IsActive {
+		return nil, ErrInvalidCredentials
 	}
 
-	// Generate tokens
-	accessToken, refreshToken, err := s.generateTokens(user)
-	if err != nil {
+	if err := s.loginGuard.RecordSuccess(ctx, req.Email, client.IPAddress); err != nil {
 		return nil, err
 	}
 
-	// Store refresh token
-	if err := s.storeRefreshToken(user.ID, refreshToken); err != nil {
+	accessToken, refreshToken, err := s.startSession(user, req.Device, client)
+	if err != nil {
 		return nil, err
 	}
 
@@ -149,14 +192,27 @@ func (s *UserService) Login(req *LoginRequest) (*AuthResponse, error) {
 	}, nil
 }
 
+// Logout ends the session of the refresh token, including the access
+// tokens issued for it.
 func (s *UserService) Logout(refreshToken string) error {
-	// Remove refresh token from Redis
-	ctx := context.Background()
-	key := fmt.Sprintf("refresh_token:%s", refreshToken)
-	return s.redis.Del(ctx, key)
+	session, err := s.userRepo.GetSessionByToken(hashToken(refreshToken))
+	if err != nil {
+		return err
+	}
+	if session == nil {
+		// Signed out already, or the session expired
+		return nil
+	}
+
+	if err := s.revocations.RevokeSession(context.Background(), session.ID.String()); err != nil {
+		return err
+	}
+	return s.userRepo.DeleteSessionByToken(session.Token)
 }
 
-func (s *UserService) RefreshToken(refreshToken string) (*AuthResponse, error) {
+// RefreshToken issues new tokens for the session of a refresh token. Each
+// refresh token can be used once; the session keeps the new one.
+func (s *UserService) RefreshToken(refreshToken string, client ClientInfo) (*AuthResponse, error) {
 	// Validate refresh token
 	token, err := jwt.ParseWithClaims(refreshToken, &jwt.MapClaims{}, func(token *jwt.Token) (interface{}, error) {
 		return []byte(s.config.JWTSecret), nil
@@ -181,14 +237,12 @@ func (s *UserService) RefreshToken(refreshToken string) (*AuthResponse, error) {
 		return nil, errors.New("invalid user ID format")
 	}
 
-	// Check if refresh token exists in Redis
-	ctx := context.Background()
-	key := fmt.Sprintf("refresh_token:%s", refreshToken)
-	exists, err := s.redis.Exists(ctx, key)
+	// The refresh token must still belong to a session
+	session, err := s.userRepo.GetSessionByToken(hashToken(refreshToken))
 	if err != nil {
 		return nil, err
 	}
-	if !exists {
+	if session == nil || session.UserID != userID {
 		return nil, errors.New("refresh token not found")
 	}
 
@@ -200,20 +254,24 @@ func (s *UserService) RefreshToken(refreshToken string) (*AuthResponse, error) {
 	if user == nil {
 		return nil, errors.New("user not found")
 	}
+	if !user.IsActive {
+		return nil, errors.New("user account is deactivated")
+	}
 
 	// Generate new tokens
-	accessToken, newRefreshToken, err := s.generateTokens(user)
+	accessToken, newRefreshToken, err := s.generateTokens(user, session.ID)
 	if err != nil {
 		return nil, err
 	}
 
-	// Remove old refresh token
-	s.redis.Del(ctx, key)
-
-	// Store new refresh token
-	if err := s.storeRefreshToken(user.ID, newRefreshToken); err != nil {
+	// Swap the session's refresh token, unless it was used concurrently
+	rotated, err := s.userRepo.RotateSessionToken(session.ID, session.Token, hashToken(newRefreshToken), client.IPAddress, time.Now().Add(refreshTokenTTL))
+	if err != nil {
 		return nil, err
 	}
+	if !rotated {
+		return nil, errors.New("refresh token already used")
+	}
 
 	// Clear password for response
 	user.Password = ""
@@ -310,16 +368,117 @@ func (s *UserService) UpdateUserStatus(userID uuid.UUID, isActive bool) error {
 	}
 
 	user.IsActive = isActive
-	return s.userRepo.Update(user)
+	if err := s.userRepo.Update(user); err != nil {
+		return err
+	}
+
+	// Deactivated users are signed out of every session at once
+	if !isActive {
+		return s.LogoutEverywhere(userID)
+	}
+	return nil
+}
+
+// GetLoginLockouts lists the accounts and IPs currently locked out of
+// logging in.
+func (s *UserService) GetLoginLockouts() ([]LoginLockout, error) {
+	return s.loginGuard.GetLockouts(context.Background())
+}
+
+// ClearLoginLockout lets an account (by email) or IP log in again.
+func (s *UserService) ClearLoginLockout(scope, key string) error {
+	return s.loginGuard.ClearLockout(context.Background(), scope, key)
+}
+
+// GetSessions lists the user's active sessions, marking the current one.
+func (s *UserService) GetSessions(userID, currentSessionID uuid.UUID) ([]SessionResponse, error) {
+	sessions, err := s.userRepo.GetUserSessions(userID)
+	if err != nil {
+		return nil, err
+	}
+
+	responses := make([]SessionResponse, 0, len(sessions))
+	for _, session := range sessions {
+		responses = append(responses, SessionResponse{
+			UserSession: session,
+			Current:     session.ID == currentSessionID,
+		})
+	}
+	return responses, nil
+}
+
+// RevokeSession signs one of the user's devices out: its refresh token stops
+// working and its access tokens are rejected.
+func (s *UserService) RevokeSession(userID, sessionID uuid.UUID) error {
+	deleted, err := s.userRepo.DeleteUserSession(userID, sessionID)
+	if err != nil {
+		return err
+	}
+	if !deleted {
+		return errors.New("session not found")
+	}
+	return s.revocations.RevokeSession(context.Background(), sessionID.String())
+}
+
+// LogoutEverywhere ends all of the user's sessions and rejects every access
+// token issued to them so far.
+func (s *UserService) LogoutEverywhere(userID uuid.UUID) error {
+	ctx := context.Background()
+
+	sessions, err := s.userRepo.GetUserSessions(userID)
+	if err != nil {
+		return err
+	}
+	for _, session := range sessions {
+		if err := s.revocations.RevokeSession(ctx, session.ID.String()); err != nil {
+			return err
+		}
+	}
+	// Also covers tokens of sessions that expired but whose access tokens
+	// have not
+	if err := s.revocations.RevokeUserTokens(ctx, userID.String(), time.Now()); err != nil {
+		return err
+	}
+
+	return s.userRepo.DeleteAllUserSessions(userID)
 }
 
-func (s *UserService) generateTokens(user *models.User) (string, string, error) {
+// startSession records a new session for the user on the client's device
+// and issues its tokens.
+func (s *UserService) startSession(user *models.User, device string, client ClientInfo) (string, string, error) {
+	session := &models.UserSession{
+		ID:         uuid.New(),
+		UserID:     user.ID,
+		Device:     device,
+		IPAddress:  client.IPAddress,
+		UserAgent:  client.UserAgent,
+		LastUsedAt: time.Now(),
+		ExpiresAt:  time.Now().Add(refreshTokenTTL),
+	}
+	if session.Device == "" {
+		session.Device = deviceName(client.UserAgent)
+	}
+
+	accessToken, refreshToken, err := s.generateTokens(user, session.ID)
+	if err != nil {
+		return "", "", err
+	}
+	session.Token = hashToken(refreshToken)
+
+	if err := s.userRepo.CreateSession(session); err != nil {
+		return "", "", err
+	}
+	return accessToken, refreshToken, nil
+}
+
+func (s *UserService) generateTokens(user *models.User, sessionID uuid.UUID) (string, string, error) {
 	// Generate access token
 	accessClaims := jwt.MapClaims{
 		"user_id": user.ID.String(),
 		"email":   user.Email,
 		"role":    user.Role,
-		"exp":     time.Now().Add(time.Hour * 24).Unix(), // 24 hours
+		"sid":     sessionID.String(),
+		"exp":     time.Now().Add(middleware.AccessTokenTTL).Unix(),
 		"iat":     time.Now().Unix(),
 	}
 
@@ -329,10 +488,12 @@ func (s *UserService) generateTokens(user *models.User) (string, string, error)
 		return "", "", err
 	}
 
-	// Generate refresh token
+	// Generate refresh token, unique even when issued twice in a second
 	refreshClaims := jwt.MapClaims{
 		"user_id": user.ID.String(),
-		"exp":     time.Now().Add(time.Hour * 24 * 7).Unix(), // 7 days
+		"sid":     sessionID.String(),
+		"jti":     uuid.NewString(),
+		"exp":     time.Now().Add(refreshTokenTTL).Unix(),
 		"iat":     time.Now().Unix(),
 	}
 
@@ -345,25 +506,51 @@ func (s *UserService) generateTokens(user *models.User) (string, string, error)
 	return accessTokenString, refreshTokenString, nil
 }
 
-func (s *UserService) storeRefreshToken(userID uuid.UUID, refreshToken string) error {
-	ctx := context.Background()
-	key := fmt.Sprintf("refresh_token:%s", refreshToken)
-	return s.redis.Set(ctx, key, userID.String(), time.Hour*24*7)
+// hashToken is how refresh tokens are stored, so that a leaked sessions
+// table cannot be used to sign in.
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
 }
 
-func (s *UserService) publishUserRegisteredEvent(user *models.User) {
-	event := messages.EventMessage{
-		EventID:   uuid.New().String(),
-		EventName: "user.registered",
-		Timestamp: time.Now(),
-		Data: messages.UserRegisteredEvent{
-			UserID: user.ID.String(),
-			Email:  user.Email,
-			Name:   user.Name,
-		},
-		Service: "user-service",
-	}
-
-	// Publish to RabbitMQ
-	// s.rabbitmq.Publish("user_events", "user.registered", event)
+// deviceName describes a device by its user agent, e.g. "Chrome on Windows".
+func deviceName(userAgent string) string {
+	var browser, os string
+	for _, b := range []struct{ token, name string }{
+		{"Edg/", "Edge"}, {"OPR/", "Opera"}, {"Firefox/", "Firefox"}, {"Chrome/", "Chrome"}, {"Safari/", "Safari"},
+	} {
+		if strings.Contains(userAgent, b.token) {
+			browser = b.name
+			break
+		}
+	}
+	for _, o := range []struct{ token, name string }{
+		{"iPhone", "iPhone"}, {"iPad", "iPad"}, {"Android", "Android"}, {"Windows", "Windows"}, {"Mac OS X", "macOS"}, {"Linux", "Linux"},
+	} {
+		if strings.Contains(userAgent, o.token) {
+			os = o.name
+			break
+		}
+	}
+
+	switch {
+	case browser != "" && os != "":
+		return browser + " on " + os
+	case browser != "":
+		return browser
+	case os != "":
+		return os
+	default:
+		return "Unknown device"
+	}
+}
+
+func (s *UserService) userRegisteredEvent(user *models.User) (*models.OutboxEvent, error) {
+	event := messages.NewEvent(messages.EventUserRegistered, "user-service", messages.UserRegisteredEvent{
+		UserID: user.ID.String(),
+		Email:  user.Email,
+		Name:   user.Name,
+	})
+
+	return models.NewOutboxEvent(messages.ExchangeUser, event)
 }
\ No newline at end of file
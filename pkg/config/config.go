@@ -32,6 +32,25 @@ type Config struct {
 	MidtransClientKey  string
 	MidtransEnvironment string
 	MidtransMerchantID string
+	// Optional overrides of the Midtrans endpoints, e.g. for a local fake server
+	MidtransAPIURL  string
+	MidtransSnapURL string
+
+	// Seller settlement
+	// PlatformCommissionRate is the share of every sale the platform keeps,
+	// e.g. 0.05 for 5%
+	PlatformCommissionRate string
+	// SettlementHoldPeriod is how long seller earnings are held after
+	// delivery before they can be paid out, i.e. the return window
+	SettlementHoldPeriod string
+
+	// Rate limits as "<requests>/<window>", per client and route. The auth
+	// limit applies to login and registration on top of the default one
+	RateLimitDefault string
+	RateLimitAuth    string
+	// TrustedProxies lists the addresses or CIDRs, comma-separated, whose
+	// X-Forwarded-For is believed, e.g. the gateway. Empty trusts none
+	TrustedProxies   string
 
 	// Service URLs
 	ProductServiceURL string
@@ -69,6 +88,15 @@ func LoadConfig() *Config {
 		MidtransClientKey:   getEnv("MIDTRANS_CLIENT_KEY", ""),
 		MidtransEnvironment: getEnv("MIDTRANS_ENVIRONMENT", "sandbox"),
 		MidtransMerchantID:  getEnv("MIDTRANS_MERCHANT_ID", ""),
+		MidtransAPIURL:      getEnv("MIDTRANS_API_URL", ""),
+		MidtransSnapURL:     getEnv("MIDTRANS_SNAP_URL", ""),
+
+		PlatformCommissionRate: getEnv("PLATFORM_COMMISSION_RATE", "0.05"),
+		SettlementHoldPeriod:   getEnv("SETTLEMENT_HOLD_PERIOD", "168h"),
+
+		RateLimitDefault: getEnv("RATE_LIMIT_DEFAULT", "100/1m"),
+		RateLimitAuth:    getEnv("RATE_LIMIT_AUTH", "10/1m"),
+		TrustedProxies:   getEnv("TRUSTED_PROXIES", ""),
 
 		ProductServiceURL: getEnv("PRODUCT_SERVICE_URL", "http://localhost:8001"),
 		UserServiceURL:    getEnv("USER_SERVICE_URL", "http://localhost:8002"),
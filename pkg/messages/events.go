@@ -1,6 +1,67 @@
 package messages
 
-import "time"
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Topic exchanges, one per publishing service. Events are routed by name.
+const (
+	ExchangeUser    = "user_events"
+	ExchangeProduct = "product_events"
+	ExchangeOrder   = "order_events"
+	ExchangePayment = "payment_events"
+	// ExchangeCheckout carries the commands order-service sends as checkout
+	// saga orchestrator; the other services answer with events.
+	ExchangeCheckout = "checkout_commands"
+)
+
+// Event names, used as the routing key of every published event.
+const (
+	EventUserRegistered = "user.registered"
+	EventUserUpdated    = "user.updated"
+
+	EventStoreApproved = "store.approved"
+	EventStoreUpdated  = "store.updated"
+	// EventStoreBankAccountUpdated carries an approved store's payout bank
+	// account to payment-service
+	EventStoreBankAccountUpdated = "store.bank_account_updated"
+
+	EventProductCreated      = "product.created"
+	EventProductUpdated      = "product.updated"
+	EventProductDeleted      = "product.deleted"
+	EventProductStockUpdated = "product.stock_updated"
+	EventStockReserved       = "product.stock_reserved"
+	EventStockRejected       = "product.stock_rejected"
+
+	EventOrderCreated   = "order.created"
+	EventOrderUpdated   = "order.updated"
+	EventOrderCancelled = "order.cancelled"
+	// EventFulfillmentDelivered reports that one seller's part of an order
+	// was delivered
+	EventFulfillmentDelivered = "order.fulfillment_delivered"
+	// EventReturnApproved asks for a return's items to be restocked and
+	// refunded
+	EventReturnApproved = "order.return_approved"
+
+	EventPaymentOpened  = "payment.opened"
+	EventPaymentCreated = "payment.created"
+	EventPaymentSuccess = "payment.success"
+	EventPaymentFailed  = "payment.failed"
+	// EventPaymentRefunded and EventRefundFailed answer EventReturnApproved
+	EventPaymentRefunded = "payment.refunded"
+	EventRefundFailed    = "payment.refund_failed"
+)
+
+// Checkout saga commands, routed by name on ExchangeCheckout.
+const (
+	CommandReserveStock  = "checkout.reserve_stock"
+	CommandReleaseStock  = "checkout.release_stock"
+	CommandCreatePayment = "checkout.create_payment"
+	CommandVoidPayment   = "checkout.void_payment"
+)
 
 type EventMessage struct {
 	EventID   string      `json:"event_id"`
@@ -10,6 +71,32 @@ type EventMessage struct {
 	Service   string      `json:"service"`
 }
 
+// NewEvent wraps data in an EventMessage with a fresh ID and timestamp.
+func NewEvent(name, service string, data interface{}) EventMessage {
+	return EventMessage{
+		EventID:   uuid.New().String(),
+		EventName: name,
+		Timestamp: time.Now(),
+		Data:      data,
+		Service:   service,
+	}
+}
+
+// RawEventMessage is an EventMessage as received from the broker, with the
+// payload left undecoded until the consumer knows which event it is.
+type RawEventMessage struct {
+	EventID   string          `json:"event_id"`
+	EventName string          `json:"event_name"`
+	Timestamp time.Time       `json:"timestamp"`
+	Data      json.RawMessage `json:"data"`
+	Service   string          `json:"service"`
+}
+
+// Decode unmarshals the event payload into v, e.g. a *OrderCreatedEvent.
+func (m *RawEventMessage) Decode(v interface{}) error {
+	return json.Unmarshal(m.Data, v)
+}
+
 // User Events
 type UserRegisteredEvent struct {
 	UserID string `json:"user_id"`
@@ -23,6 +110,42 @@ type UserUpdatedEvent struct {
 	Name   string `json:"name"`
 }
 
+// Store Events. Both carry the store's full public profile; UpdatedAt orders
+// them, so consumers can ignore one that is older than what they have.
+type StoreApprovedEvent struct {
+	StoreID     string    `json:"store_id"`
+	SellerID    string    `json:"seller_id"`
+	Name        string    `json:"name"`
+	Slug        string    `json:"slug"`
+	Description string    `json:"description"`
+	LogoURL     string    `json:"logo_url"`
+	Address     string    `json:"address"`
+	UpdatedAt   time.Time `json:"updated_at"`
+}
+
+type StoreUpdatedEvent struct {
+	StoreID     string    `json:"store_id"`
+	SellerID    string    `json:"seller_id"`
+	Name        string    `json:"name"`
+	Slug        string    `json:"slug"`
+	Description string    `json:"description"`
+	LogoURL     string    `json:"logo_url"`
+	Address     string    `json:"address"`
+	UpdatedAt   time.Time `json:"updated_at"`
+}
+
+// StoreBankAccountUpdatedEvent is published when a store is approved and
+// when a change of its bank account is approved. It is not part of the
+// public profile. UpdatedAt orders the events like the other store events.
+type StoreBankAccountUpdatedEvent struct {
+	StoreID           string    `json:"store_id"`
+	SellerID          string    `json:"seller_id"`
+	BankName          string    `json:"bank_name"`
+	BankAccountNumber string    `json:"bank_account_number"`
+	BankAccountName   string    `json:"bank_account_name"`
+	UpdatedAt         time.Time `json:"updated_at"`
+}
+
 // Product Events
 type ProductCreatedEvent struct {
 	ProductID   string  `json:"product_id"`
@@ -48,15 +171,38 @@ type StockUpdatedEvent struct {
 	ProductID string `json:"product_id"`
 	OldStock  int    `json:"old_stock"`
 	NewStock  int    `json:"new_stock"`
+	OrderID   string `json:"order_id,omitempty"` // set when an order reserved or released the stock
+}
+
+// StockReservedEvent reports that stock was set aside for every item of an order.
+type StockReservedEvent struct {
+	OrderID string `json:"order_id"`
+}
+
+// StockRejectedEvent reports that an order could not be reserved because a
+// product is unavailable or short of stock; no stock was changed.
+type StockRejectedEvent struct {
+	OrderID   string `json:"order_id"`
+	ProductID string `json:"product_id"`
+	Requested int    `json:"requested"`
+	Available int    `json:"available"`
+	Reason    string `json:"reason"`
 }
 
 // Order Events
 type OrderCreatedEvent struct {
-	OrderID   string    `json:"order_id"`
-	UserID    string    `json:"user_id"`
-	Total     float64   `json:"total"`
-	Status    string    `json:"status"`
-	CreatedAt time.Time `json:"created_at"`
+	OrderID     string           `json:"order_id"`
+	OrderNumber string           `json:"order_number"`
+	UserID      string           `json:"user_id"`
+	Total       float64          `json:"total"`
+	Status      string           `json:"status"`
+	CreatedAt   time.Time        `json:"created_at"`
+	Items       []OrderItemEvent `json:"items"`
+}
+
+type OrderItemEvent struct {
+	ProductID string `json:"product_id"`
+	Quantity  int    `json:"quantity"`
 }
 
 type OrderUpdatedEvent struct {
@@ -66,18 +212,66 @@ type OrderUpdatedEvent struct {
 
 type OrderCancelledEvent struct {
 	OrderID string `json:"order_id"`
-	Reason  string `json:"reason"`
+	Reason  string `json:"reason"`          // e.g. cancelled, insufficient_stock, payment_expired
+	Notes   string `json:"notes,omitempty"` // as recorded in the order status history
+}
+
+// FulfillmentDeliveredEvent lists what a seller delivered, so the seller
+// can be credited for it.
+type FulfillmentDeliveredEvent struct {
+	FulfillmentID string                    `json:"fulfillment_id"`
+	OrderID       string                    `json:"order_id"`
+	OrderNumber   string                    `json:"order_number"`
+	SellerID      string                    `json:"seller_id"`
+	DeliveredAt   time.Time                 `json:"delivered_at"`
+	Items         []DeliveredOrderItemEvent `json:"items"`
+}
+
+type DeliveredOrderItemEvent struct {
+	OrderItemID string  `json:"order_item_id"`
+	ProductID   string  `json:"product_id"`
+	Quantity    int     `json:"quantity"`
+	Subtotal    float64 `json:"subtotal"`
+}
+
+// ReturnApprovedEvent lists the items of an approved return and the amount
+// to refund for them.
+type ReturnApprovedEvent struct {
+	ReturnID    string              `json:"return_id"`
+	OrderID     string              `json:"order_id"`
+	OrderNumber string              `json:"order_number"`
+	SellerID    string              `json:"seller_id"`
+	Amount      float64             `json:"amount"`
+	Reason      string              `json:"reason"`
+	Items       []ReturnedItemEvent `json:"items"`
+}
+
+type ReturnedItemEvent struct {
+	OrderItemID string  `json:"order_item_id"`
+	ProductID   string  `json:"product_id"`
+	Quantity    int     `json:"quantity"`
+	Amount      float64 `json:"amount"`
 }
 
 // Payment Events
+
+// PaymentOpenedEvent reports that an order has a pending payment waiting for
+// the customer to pay it.
+type PaymentOpenedEvent struct {
+	PaymentID string    `json:"payment_id"`
+	OrderID   string    `json:"order_id"`
+	ExpiredAt time.Time `json:"expired_at"`
+}
+
 type PaymentCreatedEvent struct {
-	PaymentID    string  `json:"payment_id"`
-	OrderID      string  `json:"order_id"`
-	UserID       string  `json:"user_id"`
-	Amount       float64 `json:"amount"`
-	Method       string  `json:"method"`
-	Status       string  `json:"status"`
-	MidtransID   string  `json:"midtrans_id"`
+	PaymentID  string    `json:"payment_id"`
+	OrderID    string    `json:"order_id"`
+	UserID     string    `json:"user_id"`
+	Amount     float64   `json:"amount"`
+	Method     string    `json:"method"`
+	Status     string    `json:"status"`
+	MidtransID string    `json:"midtrans_id"`
+	ExpiredAt  time.Time `json:"expired_at"`
 }
 
 type PaymentSuccessEvent struct {
@@ -91,4 +285,45 @@ type PaymentFailedEvent struct {
 	OrderID   string `json:"order_id"`
 	Amount    float64 `json:"amount"`
 	Reason    string  `json:"reason"`
+}
+
+// PaymentRefundedEvent reports a refund for a return, or, without a return
+// ID, the full refund of a cancelled order's payment.
+type PaymentRefundedEvent struct {
+	PaymentID string  `json:"payment_id"`
+	OrderID   string  `json:"order_id"`
+	ReturnID  string  `json:"return_id,omitempty"`
+	Amount    float64 `json:"amount"`
+}
+
+// RefundFailedEvent reports that a return could not be refunded, e.g.
+// because Midtrans does not refund the payment method used.
+type RefundFailedEvent struct {
+	OrderID  string  `json:"order_id"`
+	ReturnID string  `json:"return_id"`
+	Amount   float64 `json:"amount"`
+	Reason   string  `json:"reason"`
+}
+
+// Checkout Commands
+type ReserveStockCommand struct {
+	OrderID string           `json:"order_id"`
+	Items   []OrderItemEvent `json:"items"`
+}
+
+type ReleaseStockCommand struct {
+	OrderID string `json:"order_id"`
+	Reason  string `json:"reason"`
+}
+
+type CreatePaymentCommand struct {
+	OrderID     string  `json:"order_id"`
+	OrderNumber string  `json:"order_number"`
+	UserID      string  `json:"user_id"`
+	Amount      float64 `json:"amount"`
+}
+
+type VoidPaymentCommand struct {
+	OrderID string `json:"order_id"`
+	Reason  string `json:"reason"`
 }
\ No newline at end of file
@@ -1,21 +1,40 @@
 package middleware
 
 import (
+	"errors"
+	"fmt"
 	"net/http"
 	"strings"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/golang-jwt/jwt/v5"
 )
 
+// Roles carried in the role claim of access tokens.
+//
+//   - user: customers; may manage their own cart, orders, payments and reviews
+//   - seller: a user who may also create and manage products
+//   - admin: back-office staff; may manage categories, users and all orders
+const (
+	RoleUser   = "user"
+	RoleSeller = "seller"
+	RoleAdmin  = "admin"
+)
+
 type Claims struct {
-	UserID   string `json:"user_id"`
-	Email    string `json:"email"`
-	Role     string `json:"role"`
+	UserID string `json:"user_id"`
+	Email  string `json:"email"`
+	Role   string `json:"role"`
+	// SessionID is the user-service session the token was issued for
+	SessionID string `json:"sid"`
 	jwt.RegisteredClaims
 }
 
-func JWTAuthMiddleware(secretKey string) gin.HandlerFunc {
+// JWTAuthMiddleware authenticates requests by their bearer token. Tokens
+// listed in revocations, e.g. of a signed-out session, are rejected. If the
+// revocations can't be read, the request is let through; see IsRevoked.
+func JWTAuthMiddleware(secretKey string, revocations *TokenRevocations) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		authHeader := c.GetHeader("Authorization")
 		if authHeader == "" {
@@ -31,26 +50,75 @@ func JWTAuthMiddleware(secretKey string) gin.HandlerFunc {
 			return
 		}
 
-		token, err := jwt.ParseWithClaims(tokenString, &Claims{}, func(token *jwt.Token) (interface{}, error) {
-			return []byte(secretKey), nil
-		})
-
-		if err != nil || !token.Valid {
+		claims, err := ParseToken(tokenString, secretKey)
+		if err != nil {
 			c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid token"})
 			c.Abort()
 			return
 		}
 
-		if claims, ok := token.Claims.(*Claims); ok {
-			c.Set("user_id", claims.UserID)
-			c.Set("email", claims.Email)
-			c.Set("role", claims.Role)
+		if revocations.IsRevoked(c.Request.Context(), claims) {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Token has been revoked"})
+			c.Abort()
+			return
 		}
 
+		c.Set("user_id", claims.UserID)
+		c.Set("email", claims.Email)
+		c.Set("role", claims.Role)
+		c.Set("session_id", claims.SessionID)
+
 		c.Next()
 	}
 }
 
+// RequireRoles only lets requests through whose token carries one of roles.
+// It must run after JWTAuthMiddleware, which puts the role claim in the context.
+func RequireRoles(roles ...string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		role := c.GetString("role")
+		for _, allowed := range roles {
+			if role == allowed {
+				c.Next()
+				return
+			}
+		}
+
+		c.JSON(http.StatusForbidden, gin.H{"error": "Insufficient permissions"})
+		c.Abort()
+	}
+}
+
+// ParseToken validates an HS256-signed access token and returns its claims.
+func ParseToken(tokenString, secretKey string) (*Claims, error) {
+	token, err := jwt.ParseWithClaims(tokenString, &Claims{}, func(token *jwt.Token) (interface{}, error) {
+		return []byte(secretKey), nil
+	}, jwt.WithValidMethods([]string{jwt.SigningMethodHS256.Alg()}))
+	if err != nil {
+		return nil, err
+	}
+
+	claims, ok := token.Claims.(*Claims)
+	if !ok || !token.Valid || claims.UserID == "" {
+		return nil, errors.New("invalid token")
+	}
+	return claims, nil
+}
+
+// SetTrustedProxies makes router take the client IP from X-Forwarded-For
+// only on requests from proxies, a comma-separated list of addresses or
+// CIDRs. With none, the client IP is always the address of the connection,
+// so clients can't choose the IP their rate limits and lockouts count.
+func SetTrustedProxies(router *gin.Engine, proxies string) error {
+	var trusted []string
+	for _, proxy := range strings.Split(proxies, ",") {
+		if proxy = strings.TrimSpace(proxy); proxy != "" {
+			trusted = append(trusted, proxy)
+		}
+	}
+	return router.SetTrustedProxies(trusted)
+}
+
 func CORSMiddleware() gin.HandlerFunc {
 	return func(c *gin.Context) {
 		c.Writer.Header().Set("Access-Control-Allow-Origin", "*")
@@ -82,11 +150,3 @@ func LoggerMiddleware() gin.HandlerFunc {
 		)
 	})
 }
-
-func RateLimiterMiddleware() gin.HandlerFunc {
-	// Simple rate limiting implementation
-	return func(c *gin.Context) {
-		// TODO: Implement proper rate limiting with Redis
-		c.Next()
-	}
-}
\ No newline at end of file
@@ -0,0 +1,262 @@
+package middleware
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/be-bcv/ecommerce-backend/pkg/redis"
+	"github.com/gin-gonic/gin"
+	goredis "github.com/redis/go-redis/v9"
+	"golang.org/x/time/rate"
+)
+
+// localSweepInterval is how often idle in-memory limiters are dropped.
+const localSweepInterval = time.Minute
+
+// redisRetryInterval is how long a degraded limiter stays in memory before
+// it tries Redis again.
+const redisRetryInterval = 5 * time.Second
+
+// slidingWindowScript counts a request in the current fixed window unless
+// the sliding window, the current window plus the weighted share of the
+// previous one, is already at the limit. It returns whether the request was
+// allowed and the counts of both windows.
+var slidingWindowScript = goredis.NewScript(`
+local current = tonumber(redis.call("GET", KEYS[1]) or "0")
+local previous = tonumber(redis.call("GET", KEYS[2]) or "0")
+if previous * tonumber(ARGV[2]) + current >= tonumber(ARGV[1]) then
+	return {0, current, previous}
+end
+current = redis.call("INCR", KEYS[1])
+redis.call("PEXPIRE", KEYS[1], ARGV[3])
+return {1, current, previous}
+`)
+
+// RateLimit allows Limit requests per Window from each client on each route.
+type RateLimit struct {
+	// Name keeps the counters of different limits on the same route apart.
+	Name   string
+	Limit  int
+	Window time.Duration
+}
+
+// ParseRateLimit parses a limit written as "<requests>/<window>", e.g.
+// "10/1m" for 10 requests a minute.
+func ParseRateLimit(name, spec string) (RateLimit, error) {
+	requests, window, ok := strings.Cut(spec, "/")
+	if !ok {
+		return RateLimit{}, fmt.Errorf("invalid rate limit %q, expected <requests>/<window>", spec)
+	}
+	limit, err := strconv.Atoi(requests)
+	if err != nil || limit < 1 {
+		return RateLimit{}, fmt.Errorf("invalid request count in rate limit %q", spec)
+	}
+	duration, err := time.ParseDuration(window)
+	if err != nil || duration < time.Second {
+		return RateLimit{}, fmt.Errorf("invalid window in rate limit %q", spec)
+	}
+
+	return RateLimit{Name: name, Limit: limit, Window: duration}, nil
+}
+
+// RateLimitResult is the outcome of counting one request.
+type RateLimitResult struct {
+	Allowed   bool
+	Remaining int
+	// Reset is when the current window ends, and RetryAfter how long a
+	// rejected client has to wait.
+	Reset      time.Time
+	RetryAfter time.Duration
+}
+
+// RateLimiter counts requests in Redis with a sliding window, so that limits
+// hold across every instance of a service. While Redis is unavailable, each
+// instance falls back to an in-memory token bucket per client. Once Redis
+// has failed, requests skip it for redisRetryInterval, and then a single
+// request probes it, so they don't each wait for Redis to time out.
+type RateLimiter struct {
+	redis *redis.RedisClient
+
+	mu           sync.Mutex
+	local        map[string]*localLimiter
+	lastSweep    time.Time
+	degraded     bool
+	retryRedisAt time.Time
+	probing      bool
+}
+
+type localLimiter struct {
+	limiter  *rate.Limiter
+	window   time.Duration
+	lastSeen time.Time
+}
+
+func NewRateLimiter(redis *redis.RedisClient) *RateLimiter {
+	return &RateLimiter{
+		redis:     redis,
+		local:     make(map[string]*localLimiter),
+		lastSweep: time.Now(),
+	}
+}
+
+// Allow counts a request from the client identified by key against limit.
+func (l *RateLimiter) Allow(ctx context.Context, key string, limit RateLimit) RateLimitResult {
+	if !l.tryRedis() {
+		return l.allowLocal(key, limit)
+	}
+
+	result, err := l.allowRedis(ctx, key, limit)
+	l.setDegraded(err)
+	if err != nil {
+		return l.allowLocal(key, limit)
+	}
+	return result
+}
+
+// tryRedis reports whether the request should be counted in Redis. While
+// degraded, only one request at a time probes Redis once the retry interval
+// has passed; the rest are counted in memory.
+func (l *RateLimiter) tryRedis() bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if !l.degraded {
+		return true
+	}
+	if l.probing || time.Now().Before(l.retryRedisAt) {
+		return false
+	}
+	l.probing = true
+	return true
+}
+
+// setDegraded logs when the limiter switches between Redis and memory.
+func (l *RateLimiter) setDegraded(err error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.probing = false
+	if err != nil {
+		l.retryRedisAt = time.Now().Add(redisRetryInterval)
+	}
+
+	if err != nil && !l.degraded {
+		log.Printf("Rate limiting in memory, Redis is unavailable: %v", err)
+	} else if err == nil && l.degraded {
+		log.Printf("Rate limiting in Redis again")
+	}
+	l.degraded = err != nil
+}
+
+func (l *RateLimiter) allowRedis(ctx context.Context, key string, limit RateLimit) (RateLimitResult, error) {
+	now := time.Now()
+	window := int64(limit.Window / time.Millisecond)
+	index := now.UnixMilli() / window
+	windowStart := time.UnixMilli(index * window)
+	reset := windowStart.Add(limit.Window)
+	elapsed := now.Sub(windowStart)
+	weight := 1 - float64(elapsed)/float64(limit.Window)
+
+	prefix := fmt.Sprintf("ratelimit:%s:%s", limit.Name, key)
+	keys := []string{fmt.Sprintf("%s:%d", prefix, index), fmt.Sprintf("%s:%d", prefix, index-1)}
+	counts, err := slidingWindowScript.Run(ctx, l.redis.GetClient(), keys, limit.Limit, weight, 2*window).Int64Slice()
+	if err != nil {
+		return RateLimitResult{}, err
+	}
+	allowed, current, previous := counts[0] == 1, float64(counts[1]), float64(counts[2])
+
+	count := previous*weight + current
+	result := RateLimitResult{
+		Allowed:   allowed,
+		Remaining: int(math.Max(0, float64(limit.Limit)-math.Ceil(count))),
+		Reset:     reset,
+	}
+	if !allowed {
+		if current >= float64(limit.Limit) {
+			result.RetryAfter = reset.Sub(now)
+		} else {
+			// Wait until enough of the previous window has slid out
+			slideOut := time.Duration(float64(limit.Window) * (1 - (float64(limit.Limit)-current)/previous))
+			result.RetryAfter = slideOut - elapsed
+		}
+	}
+	return result, nil
+}
+
+func (l *RateLimiter) allowLocal(key string, limit RateLimit) RateLimitResult {
+	now := time.Now()
+
+	l.mu.Lock()
+	if now.Sub(l.lastSweep) > localSweepInterval {
+		// A bucket idle for a whole window is full again, like a new one
+		for k, local := range l.local {
+			if now.Sub(local.lastSeen) > local.window {
+				delete(l.local, k)
+			}
+		}
+		l.lastSweep = now
+	}
+
+	localKey := limit.Name + ":" + key
+	local, ok := l.local[localKey]
+	if !ok {
+		local = &localLimiter{
+			limiter: rate.NewLimiter(rate.Every(limit.Window/time.Duration(limit.Limit)), limit.Limit),
+			window:  limit.Window,
+		}
+		l.local[localKey] = local
+	}
+	local.lastSeen = now
+	l.mu.Unlock()
+
+	result := RateLimitResult{Allowed: true}
+	reservation := local.limiter.ReserveN(now, 1)
+	if delay := reservation.DelayFrom(now); delay > 0 {
+		reservation.CancelAt(now)
+		result.Allowed = false
+		result.RetryAfter = delay
+	}
+
+	tokens := local.limiter.TokensAt(now)
+	result.Remaining = int(math.Max(0, math.Floor(tokens)))
+	refill := time.Duration((float64(limit.Limit) - tokens) * float64(limit.Window) / float64(limit.Limit))
+	result.Reset = now.Add(refill)
+	return result
+}
+
+// RateLimiterMiddleware applies limit to every route of the group it is used
+// on, counting each route separately. Clients are identified by the user_id
+// JWTAuthMiddleware sets when it runs first, and by IP otherwise. Every
+// response carries X-RateLimit-Limit, X-RateLimit-Remaining and
+// X-RateLimit-Reset (Unix time); rejected requests get 429 with Retry-After.
+func RateLimiterMiddleware(limiter *RateLimiter, limit RateLimit) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		client := "ip:" + c.ClientIP()
+		if userID := c.GetString("user_id"); userID != "" {
+			client = "user:" + userID
+		}
+
+		result := limiter.Allow(c.Request.Context(), c.Request.Method+":"+c.FullPath()+":"+client, limit)
+
+		header := c.Writer.Header()
+		header.Set("X-RateLimit-Limit", strconv.Itoa(limit.Limit))
+		header.Set("X-RateLimit-Remaining", strconv.Itoa(result.Remaining))
+		header.Set("X-RateLimit-Reset", strconv.FormatInt(int64(math.Ceil(float64(result.Reset.UnixMilli())/1000)), 10))
+
+		if !result.Allowed {
+			header.Set("Retry-After", strconv.Itoa(int(math.Ceil(math.Max(1, result.RetryAfter.Seconds())))))
+			c.JSON(http.StatusTooManyRequests, gin.H{"error": "Too many requests"})
+			c.Abort()
+			return
+		}
+
+		c.Next()
+	}
+}
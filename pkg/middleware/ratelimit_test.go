@@ -0,0 +1,206 @@
+package middleware
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// memoryLimiter returns a limiter that counts in memory only, as if Redis
+// had just failed.
+func memoryLimiter() *RateLimiter {
+	l := NewRateLimiter(nil)
+	l.degraded = true
+	l.retryRedisAt = time.Now().Add(time.Hour)
+	return l
+}
+
+func TestRateLimiterMiddlewareClientIP(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	type request struct {
+		remoteAddr   string
+		forwardedFor string
+		wantStatus   int
+	}
+	tests := []struct {
+		name     string
+		proxies  string
+		requests []request
+	}{
+		{
+			name:    "no proxy ignores spoofed header",
+			proxies: "",
+			requests: []request{
+				{"203.0.113.7:40000", "198.51.100.1", http.StatusOK},
+				{"203.0.113.7:40001", "198.51.100.2", http.StatusTooManyRequests},
+			},
+		},
+		{
+			name:    "trusted proxy ignores spoofed entries before the client",
+			proxies: "10.0.0.1",
+			requests: []request{
+				{"10.0.0.1:40000", "198.51.100.1, 203.0.113.7", http.StatusOK},
+				{"10.0.0.1:40001", "198.51.100.2, 203.0.113.7", http.StatusTooManyRequests},
+				{"10.0.0.1:40002", "203.0.113.7", http.StatusTooManyRequests},
+			},
+		},
+		{
+			name:    "trusted proxy tells clients apart",
+			proxies: "10.0.0.0/8",
+			requests: []request{
+				{"10.0.0.1:40000", "203.0.113.7", http.StatusOK},
+				{"10.0.0.2:40000", "203.0.113.8", http.StatusOK},
+			},
+		},
+		{
+			name:    "untrusted peer cannot act as proxy",
+			proxies: "10.0.0.1",
+			requests: []request{
+				{"203.0.113.7:40000", "198.51.100.1", http.StatusOK},
+				{"203.0.113.7:40001", "198.51.100.2", http.StatusTooManyRequests},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			router := gin.New()
+			if err := SetTrustedProxies(router, tt.proxies); err != nil {
+				t.Fatalf("SetTrustedProxies() error = %v", err)
+			}
+			limit := RateLimit{Name: "auth", Limit: 1, Window: time.Minute}
+			router.POST("/auth/login", RateLimiterMiddleware(memoryLimiter(), limit), func(c *gin.Context) {
+				c.Status(http.StatusOK)
+			})
+
+			for i, r := range tt.requests {
+				req := httptest.NewRequest(http.MethodPost, "/auth/login", nil)
+				req.RemoteAddr = r.remoteAddr
+				req.Header.Set("X-Forwarded-For", r.forwardedFor)
+				w := httptest.NewRecorder()
+				router.ServeHTTP(w, req)
+
+				if w.Code != r.wantStatus {
+					t.Errorf("request %d from %s (X-Forwarded-For %q) status = %d, want %d", i, r.remoteAddr, r.forwardedFor, w.Code, r.wantStatus)
+				}
+			}
+		})
+	}
+}
+
+func TestSetTrustedProxiesInvalid(t *testing.T) {
+	if err := SetTrustedProxies(gin.New(), "10.0.0.1, not-an-ip"); err == nil {
+		t.Error("SetTrustedProxies() error = nil, want error")
+	}
+}
+
+func TestParseRateLimit(t *testing.T) {
+	tests := []struct {
+		spec    string
+		want    RateLimit
+		wantErr bool
+	}{
+		{spec: "10/1m", want: RateLimit{Name: "auth", Limit: 10, Window: time.Minute}},
+		{spec: "100/1s", want: RateLimit{Name: "auth", Limit: 100, Window: time.Second}},
+		{spec: "5/1h30m", want: RateLimit{Name: "auth", Limit: 5, Window: 90 * time.Minute}},
+		{spec: "10", wantErr: true},
+		{spec: "", wantErr: true},
+		{spec: "0/1m", wantErr: true},
+		{spec: "-1/1m", wantErr: true},
+		{spec: "ten/1m", wantErr: true},
+		{spec: "10/", wantErr: true},
+		{spec: "10/minute", wantErr: true},
+		{spec: "10/500ms", wantErr: true},
+		{spec: "10/1m/1h", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.spec, func(t *testing.T) {
+			got, err := ParseRateLimit("auth", tt.spec)
+			if tt.wantErr {
+				if err == nil {
+					t.Errorf("ParseRateLimit(%q) = %+v, want error", tt.spec, got)
+				}
+				return
+			}
+			if err != nil || got != tt.want {
+				t.Errorf("ParseRateLimit(%q) = %+v, %v, want %+v", tt.spec, got, err, tt.want)
+			}
+		})
+	}
+}
+
+func TestRateLimiterLocalFallback(t *testing.T) {
+	limit := RateLimit{Name: "auth", Limit: 3, Window: time.Minute}
+	other := RateLimit{Name: "api", Limit: 3, Window: time.Minute}
+
+	tests := []struct {
+		name          string
+		key           string
+		limit         RateLimit
+		wantAllowed   bool
+		wantRemaining int
+	}{
+		{"first request", "ip:203.0.113.7", limit, true, 2},
+		{"second request", "ip:203.0.113.7", limit, true, 1},
+		{"third request", "ip:203.0.113.7", limit, true, 0},
+		{"over the limit", "ip:203.0.113.7", limit, false, 0},
+		{"another client", "ip:203.0.113.8", limit, true, 2},
+		{"another limit for the same client", "ip:203.0.113.7", other, true, 2},
+	}
+
+	// The requests run in order against one limiter
+	l := memoryLimiter()
+	for _, tt := range tests {
+		got := l.Allow(context.Background(), tt.key, tt.limit)
+		if got.Allowed != tt.wantAllowed || got.Remaining != tt.wantRemaining {
+			t.Errorf("%s: Allow() = allowed %v, remaining %d, want %v, %d", tt.name, got.Allowed, got.Remaining, tt.wantAllowed, tt.wantRemaining)
+		}
+		if !got.Allowed {
+			// One token comes back every Window/Limit
+			if got.RetryAfter <= 0 || got.RetryAfter > 20*time.Second {
+				t.Errorf("%s: RetryAfter = %s, want up to 20s", tt.name, got.RetryAfter)
+			}
+		}
+		if got.Reset.Before(time.Now()) {
+			t.Errorf("%s: Reset = %s, want in the future", tt.name, got.Reset)
+		}
+	}
+}
+
+func TestRateLimiterTryRedis(t *testing.T) {
+	tests := []struct {
+		name         string
+		degraded     bool
+		probing      bool
+		retryRedisAt time.Duration // from now
+		want         bool
+		wantProbing  bool
+	}{
+		{"healthy", false, false, 0, true, false},
+		{"degraded before the retry interval", true, false, time.Minute, false, false},
+		{"degraded after the retry interval probes", true, false, -time.Second, true, true},
+		{"degraded while another request probes", true, true, -time.Second, false, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			l := NewRateLimiter(nil)
+			l.degraded = tt.degraded
+			l.probing = tt.probing
+			l.retryRedisAt = time.Now().Add(tt.retryRedisAt)
+
+			if got := l.tryRedis(); got != tt.want {
+				t.Errorf("tryRedis() = %v, want %v", got, tt.want)
+			}
+			if l.probing != tt.wantProbing {
+				t.Errorf("probing = %v, want %v", l.probing, tt.wantProbing)
+			}
+		})
+	}
+}
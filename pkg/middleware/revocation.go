@@ -0,0 +1,181 @@
+package middleware
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/be-bcv/ecommerce-backend/pkg/redis"
+	goredis "github.com/redis/go-redis/v9"
+)
+
+// AccessTokenTTL is how long access tokens are valid. Revocations only need
+// to be remembered this long, after which the tokens expire anyway.
+const AccessTokenTTL = 24 * time.Hour
+
+// TokenRevocations records access tokens revoked before they expire, either
+// every token of a session or every token a user was issued before a time.
+// Revocations read from or written to Redis are also remembered in memory,
+// so they still apply while Redis is unavailable.
+type TokenRevocations struct {
+	redis *redis.RedisClient
+
+	mu        sync.Mutex
+	sessions  map[string]time.Time
+	before    map[string]userRevocation
+	lastSweep time.Time
+	degraded  bool
+}
+
+// userRevocation is a remembered revocation of a user's tokens issued
+// before a time, kept until expires.
+type userRevocation struct {
+	before  time.Time
+	expires time.Time
+}
+
+func NewTokenRevocations(redis *redis.RedisClient) *TokenRevocations {
+	return &TokenRevocations{
+		redis:     redis,
+		sessions:  make(map[string]time.Time),
+		before:    make(map[string]userRevocation),
+		lastSweep: time.Now(),
+	}
+}
+
+func revokedSessionKey(sessionID string) string {
+	return fmt.Sprintf("revoked_session:%s", sessionID)
+}
+
+func revokedBeforeKey(userID string) string {
+	return fmt.Sprintf("tokens_revoked_before:%s", userID)
+}
+
+// RevokeSession revokes the access tokens issued for a session.
+func (r *TokenRevocations) RevokeSession(ctx context.Context, sessionID string) error {
+	r.rememberSession(sessionID)
+	return r.redis.Set(ctx, revokedSessionKey(sessionID), "1", AccessTokenTTL)
+}
+
+// RevokeUserTokens revokes the access tokens issued to a user before the
+// given time.
+func (r *TokenRevocations) RevokeUserTokens(ctx context.Context, userID string, before time.Time) error {
+	r.rememberUser(userID, before)
+	return r.redis.Set(ctx, revokedBeforeKey(userID), before.Unix(), AccessTokenTTL)
+}
+
+// IsRevoked reports whether the token with the given claims was revoked.
+//
+// Revocation checks fail open: if Redis can't be reached, the token is only
+// checked against the revocations remembered in memory, and the outage is
+// logged. Like rate limiting, authentication keeps working without Redis;
+// a token revoked on another instance during the outage stays usable until
+// Redis is back or the token expires.
+func (r *TokenRevocations) IsRevoked(ctx context.Context, claims *Claims) bool {
+	revoked, err := r.lookup(ctx, claims)
+	r.setDegraded(err)
+	if err != nil {
+		return r.isRevokedLocally(claims)
+	}
+	return revoked
+}
+
+// lookup checks the token against the revocations in Redis.
+func (r *TokenRevocations) lookup(ctx context.Context, claims *Claims) (bool, error) {
+	if claims.SessionID != "" {
+		revoked, err := r.redis.Exists(ctx, revokedSessionKey(claims.SessionID))
+		if err != nil {
+			return false, err
+		}
+		if revoked {
+			r.rememberSession(claims.SessionID)
+			return true, nil
+		}
+	}
+
+	value, err := r.redis.Get(ctx, revokedBeforeKey(claims.UserID))
+	if errors.Is(err, goredis.Nil) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	unix, err := strconv.ParseInt(value, 10, 64)
+	if err != nil {
+		return false, fmt.Errorf("invalid token revocation time %q: %w", value, err)
+	}
+	before := time.Unix(unix, 0)
+	r.rememberUser(claims.UserID, before)
+	return issuedBefore(claims, before), nil
+}
+
+// setDegraded logs when revocation checks switch between Redis and memory.
+func (r *TokenRevocations) setDegraded(err error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if err != nil && !r.degraded {
+		log.Printf("Checking token revocations in memory only, Redis is unavailable: %v", err)
+	} else if err == nil && r.degraded {
+		log.Printf("Checking token revocations in Redis again")
+	}
+	r.degraded = err != nil
+}
+
+func (r *TokenRevocations) isRevokedLocally(claims *Claims) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	now := time.Now()
+	if expires, ok := r.sessions[claims.SessionID]; ok && claims.SessionID != "" && now.Before(expires) {
+		return true
+	}
+	if revocation, ok := r.before[claims.UserID]; ok && now.Before(revocation.expires) {
+		return issuedBefore(claims, revocation.before)
+	}
+	return false
+}
+
+func (r *TokenRevocations) rememberSession(sessionID string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.sweep()
+	r.sessions[sessionID] = time.Now().Add(AccessTokenTTL)
+}
+
+func (r *TokenRevocations) rememberUser(userID string, before time.Time) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.sweep()
+	r.before[userID] = userRevocation{before: before, expires: before.Add(AccessTokenTTL)}
+}
+
+// sweep drops remembered revocations whose tokens have all expired, at most
+// once per localSweepInterval. Callers must hold mu.
+func (r *TokenRevocations) sweep() {
+	now := time.Now()
+	if now.Sub(r.lastSweep) <= localSweepInterval {
+		return
+	}
+	r.lastSweep = now
+	for sessionID, expires := range r.sessions {
+		if !now.Before(expires) {
+			delete(r.sessions, sessionID)
+		}
+	}
+	for userID, revocation := range r.before {
+		if !now.Before(revocation.expires) {
+			delete(r.before, userID)
+		}
+	}
+}
+
+func issuedBefore(claims *Claims, before time.Time) bool {
+	return claims.IssuedAt == nil || claims.IssuedAt.Unix() < before.Unix()
+}
@@ -0,0 +1,119 @@
+package middleware
+
+import (
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// claimsIssuedAt returns the claims of a token for user-1 in session-1,
+// issued at issuedAt, or without an issue time if it is zero.
+func claimsIssuedAt(issuedAt time.Time) *Claims {
+	claims := &Claims{UserID: "user-1", SessionID: "session-1"}
+	if !issuedAt.IsZero() {
+		claims.IssuedAt = jwt.NewNumericDate(issuedAt)
+	}
+	return claims
+}
+
+func TestIssuedBefore(t *testing.T) {
+	before := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name     string
+		issuedAt time.Time
+		want     bool
+	}{
+		{"issued earlier", before.Add(-time.Hour), true},
+		{"issued a second earlier", before.Add(-time.Second), true},
+		{"issued in the same second", before.Add(500 * time.Millisecond), false},
+		{"issued at the revocation", before, false},
+		{"issued later", before.Add(time.Second), false},
+		{"no issue time", time.Time{}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := issuedBefore(claimsIssuedAt(tt.issuedAt), before); got != tt.want {
+				t.Errorf("issuedBefore() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestIsRevokedLocally(t *testing.T) {
+	now := time.Now()
+
+	tests := []struct {
+		name   string
+		setup  func(r *TokenRevocations)
+		claims *Claims
+		want   bool
+	}{
+		{
+			name:   "nothing revoked",
+			setup:  func(*TokenRevocations) {},
+			claims: claimsIssuedAt(now),
+			want:   false,
+		},
+		{
+			name:   "session revoked",
+			setup:  func(r *TokenRevocations) { r.rememberSession("session-1") },
+			claims: claimsIssuedAt(now),
+			want:   true,
+		},
+		{
+			name:   "other session revoked",
+			setup:  func(r *TokenRevocations) { r.rememberSession("session-2") },
+			claims: claimsIssuedAt(now),
+			want:   false,
+		},
+		{
+			name:   "empty session is never revoked",
+			setup:  func(r *TokenRevocations) { r.rememberSession("") },
+			claims: &Claims{UserID: "user-1", RegisteredClaims: jwt.RegisteredClaims{IssuedAt: jwt.NewNumericDate(now)}},
+			want:   false,
+		},
+		{
+			name:   "session revocation expired",
+			setup:  func(r *TokenRevocations) { r.sessions["session-1"] = now.Add(-time.Second) },
+			claims: claimsIssuedAt(now),
+			want:   false,
+		},
+		{
+			name:   "user tokens revoked after issue",
+			setup:  func(r *TokenRevocations) { r.rememberUser("user-1", now.Add(time.Minute)) },
+			claims: claimsIssuedAt(now),
+			want:   true,
+		},
+		{
+			name:   "token issued after the user revocation",
+			setup:  func(r *TokenRevocations) { r.rememberUser("user-1", now.Add(-time.Minute)) },
+			claims: claimsIssuedAt(now),
+			want:   false,
+		},
+		{
+			name:   "other user revoked",
+			setup:  func(r *TokenRevocations) { r.rememberUser("user-2", now.Add(time.Minute)) },
+			claims: claimsIssuedAt(now),
+			want:   false,
+		},
+		{
+			name:   "user revocation older than the token lifetime",
+			setup:  func(r *TokenRevocations) { r.rememberUser("user-1", now.Add(-AccessTokenTTL-time.Minute)) },
+			claims: claimsIssuedAt(now.Add(-AccessTokenTTL - 2*time.Minute)),
+			want:   false,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := NewTokenRevocations(nil)
+			tt.setup(r)
+			if got := r.isRevokedLocally(tt.claims); got != tt.want {
+				t.Errorf("isRevokedLocally() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,315 @@
+package midtrans
+
+import (
+	"bytes"
+	"crypto/sha512"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+const (
+	SandboxAPIURL     = "https://api.sandbox.midtrans.com"
+	ProductionAPIURL  = "https://api.midtrans.com"
+	SandboxSnapURL    = "https://app.sandbox.midtrans.com/snap"
+	ProductionSnapURL = "https://app.midtrans.com/snap"
+)
+
+// Midtrans reports times in Western Indonesia Time without a zone suffix.
+var jakarta = time.FixedZone("WIB", 7*60*60)
+
+const timeLayout = "2006-01-02 15:04:05"
+
+type Config struct {
+	ServerKey   string
+	Environment string // sandbox or production
+	// APIURL and SnapURL override the environment defaults, e.g. to point
+	// the client at a local fake Midtrans server.
+	APIURL  string
+	SnapURL string
+}
+
+// Client is a minimal Midtrans Snap and Core API client.
+type Client struct {
+	serverKey  string
+	apiURL     string
+	snapURL    string
+	httpClient *http.Client
+}
+
+func NewClient(cfg Config) *Client {
+	apiURL, snapURL := SandboxAPIURL, SandboxSnapURL
+	if cfg.Environment == "production" {
+		apiURL, snapURL = ProductionAPIURL, ProductionSnapURL
+	}
+	if cfg.APIURL != "" {
+		apiURL = cfg.APIURL
+	}
+	if cfg.SnapURL != "" {
+		snapURL = cfg.SnapURL
+	}
+
+	return &Client{
+		serverKey: cfg.ServerKey,
+		apiURL:    strings.TrimRight(apiURL, "/"),
+		snapURL:   strings.TrimRight(snapURL, "/"),
+		httpClient: &http.Client{
+			Timeout: 30 * time.Second,
+		},
+	}
+}
+
+type TransactionDetails struct {
+	OrderID     string `json:"order_id"`
+	GrossAmount int64  `json:"gross_amount"`
+}
+
+type CustomerDetails struct {
+	FirstName string `json:"first_name,omitempty"`
+	Email     string `json:"email,omitempty"`
+	Phone     string `json:"phone,omitempty"`
+}
+
+type SnapExpiry struct {
+	Unit     string `json:"unit"` // minute, hour or day
+	Duration int    `json:"duration"`
+}
+
+type SnapRequest struct {
+	TransactionDetails TransactionDetails `json:"transaction_details"`
+	CustomerDetails    *CustomerDetails   `json:"customer_details,omitempty"`
+	EnabledPayments    []string           `json:"enabled_payments,omitempty"`
+	Expiry             *SnapExpiry        `json:"expiry,omitempty"`
+}
+
+type SnapResponse struct {
+	Token       string `json:"token"`
+	RedirectURL string `json:"redirect_url"`
+}
+
+type BankTransferDetails struct {
+	Bank string `json:"bank"`
+}
+
+type CustomExpiry struct {
+	ExpiryDuration int    `json:"expiry_duration"`
+	Unit           string `json:"unit"` // second, minute, hour or day
+}
+
+type ChargeRequest struct {
+	PaymentType        string               `json:"payment_type"`
+	TransactionDetails TransactionDetails   `json:"transaction_details"`
+	CustomerDetails    *CustomerDetails     `json:"customer_details,omitempty"`
+	BankTransfer       *BankTransferDetails `json:"bank_transfer,omitempty"`
+	CustomExpiry       *CustomExpiry        `json:"custom_expiry,omitempty"`
+}
+
+type VANumber struct {
+	Bank     string `json:"bank"`
+	VANumber string `json:"va_number"`
+}
+
+// TransactionResponse is returned by the Core API charge, status and
+// cancel endpoints.
+type TransactionResponse struct {
+	StatusCode        string     `json:"status_code"`
+	StatusMessage     string     `json:"status_message"`
+	TransactionID     string     `json:"transaction_id"`
+	OrderID           string     `json:"order_id"`
+	GrossAmount       string     `json:"gross_amount"`
+	PaymentType       string     `json:"payment_type"`
+	TransactionStatus string     `json:"transaction_status"`
+	FraudStatus       string     `json:"fraud_status"`
+	VANumbers         []VANumber `json:"va_numbers"`
+	PermataVANumber   string     `json:"permata_va_number"`
+	ExpiryTime        string     `json:"expiry_time"`
+}
+
+type RefundRequest struct {
+	// RefundKey identifies the refund; Midtrans does not repeat a refund
+	// whose key it has already seen.
+	RefundKey string `json:"refund_key"`
+	Amount    int64  `json:"amount"`
+	Reason    string `json:"reason,omitempty"`
+}
+
+type RefundResponse struct {
+	TransactionResponse
+	RefundChargebackID int64  `json:"refund_chargeback_id"`
+	RefundAmount       string `json:"refund_amount"`
+	RefundKey          string `json:"refund_key"`
+}
+
+// VirtualAccount returns the virtual account number from a bank transfer charge.
+func (r *TransactionResponse) VirtualAccount() string {
+	if r.PermataVANumber != "" {
+		return r.PermataVANumber
+	}
+	if len(r.VANumbers) > 0 {
+		return r.VANumbers[0].VANumber
+	}
+	return ""
+}
+
+// ExpiresAt parses the expiry time reported by Midtrans.
+func (r *TransactionResponse) ExpiresAt() (time.Time, bool) {
+	if r.ExpiryTime == "" {
+		return time.Time{}, false
+	}
+	t, err := time.ParseInLocation(timeLayout, r.ExpiryTime, jakarta)
+	if err != nil {
+		return time.Time{}, false
+	}
+	return t, true
+}
+
+// Error is returned when Midtrans rejects a request.
+type Error struct {
+	StatusCode int
+	Messages   []string
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("midtrans: status %d: %s", e.StatusCode, strings.Join(e.Messages, "; "))
+}
+
+// CreateSnapTransaction creates a Snap transaction and returns the token and
+// redirect URL the customer uses to pay.
+func (c *Client) CreateSnapTransaction(req *SnapRequest) (*SnapResponse, error) {
+	var resp SnapResponse
+	if err := c.do(http.MethodPost, c.snapURL+"/v1/transactions", req, &resp); err != nil {
+		return nil, err
+	}
+	return &resp, nil
+}
+
+// Charge creates a Core API transaction, e.g. a bank transfer virtual account.
+func (c *Client) Charge(req *ChargeRequest) (*TransactionResponse, error) {
+	var resp TransactionResponse
+	if err := c.do(http.MethodPost, c.apiURL+"/v2/charge", req, &resp); err != nil {
+		return nil, err
+	}
+	if err := checkStatusCode(&resp); err != nil {
+		return nil, err
+	}
+	return &resp, nil
+}
+
+// Cancel cancels a transaction that has not settled yet, so it can no longer
+// be paid. orderID is the Midtrans order ID of the transaction.
+func (c *Client) Cancel(orderID string) (*TransactionResponse, error) {
+	var resp TransactionResponse
+	if err := c.do(http.MethodPost, c.apiURL+"/v2/"+url.PathEscape(orderID)+"/cancel", nil, &resp); err != nil {
+		return nil, err
+	}
+	if err := checkStatusCode(&resp); err != nil {
+		return nil, err
+	}
+	return &resp, nil
+}
+
+// Refund refunds all or part of a settled transaction. orderID is the
+// Midtrans order ID of the transaction. Not every payment method can be
+// refunded through the API; Midtrans rejects those.
+func (c *Client) Refund(orderID string, req *RefundRequest) (*RefundResponse, error) {
+	var resp RefundResponse
+	if err := c.do(http.MethodPost, c.apiURL+"/v2/"+url.PathEscape(orderID)+"/refund", req, &resp); err != nil {
+		return nil, err
+	}
+	if err := checkStatusCode(&resp.TransactionResponse); err != nil {
+		return nil, err
+	}
+	return &resp, nil
+}
+
+func (c *Client) do(method, url string, body interface{}, result interface{}) error {
+	var payload []byte
+	if body != nil {
+		var err error
+		payload, err = json.Marshal(body)
+		if err != nil {
+			return fmt.Errorf("midtrans: failed to encode request: %w", err)
+		}
+	}
+
+	req, err := http.NewRequest(method, url, bytes.NewReader(payload))
+	if err != nil {
+		return fmt.Errorf("midtrans: failed to build request: %w", err)
+	}
+	req.SetBasicAuth(c.serverKey, "")
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Accept", "application/json")
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("midtrans: request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	respBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return fmt.Errorf("midtrans: failed to read response: %w", err)
+	}
+
+	if resp.StatusCode >= http.StatusBadRequest {
+		var errResp struct {
+			ErrorMessages []string `json:"error_messages"`
+			StatusMessage string   `json:"status_message"`
+		}
+		json.Unmarshal(respBody, &errResp)
+		messages := errResp.ErrorMessages
+		if len(messages) == 0 && errResp.StatusMessage != "" {
+			messages = []string{errResp.StatusMessage}
+		}
+		return &Error{StatusCode: resp.StatusCode, Messages: messages}
+	}
+
+	if err := json.Unmarshal(respBody, result); err != nil {
+		return fmt.Errorf("midtrans: failed to decode response: %w", err)
+	}
+	return nil
+}
+
+// checkStatusCode surfaces Core API errors, which Midtrans reports in the
+// response body's status_code even when the HTTP status is 200.
+func checkStatusCode(resp *TransactionResponse) error {
+	var code int
+	if _, err := fmt.Sscanf(resp.StatusCode, "%d", &code); err != nil {
+		return nil
+	}
+	if code >= http.StatusBadRequest {
+		return &Error{StatusCode: code, Messages: []string{resp.StatusMessage}}
+	}
+	return nil
+}
+
+// Notification is the HTTP notification Midtrans posts when a transaction
+// changes status.
+type Notification struct {
+	TransactionTime   string `json:"transaction_time"`
+	TransactionStatus string `json:"transaction_status"`
+	TransactionID     string `json:"transaction_id"`
+	StatusMessage     string `json:"status_message"`
+	StatusCode        string `json:"status_code"`
+	SignatureKey      string `json:"signature_key"`
+	PaymentType       string `json:"payment_type"`
+	OrderID           string `json:"order_id"`
+	MerchantID        string `json:"merchant_id"`
+	GrossAmount       string `json:"gross_amount"`
+	FraudStatus       string `json:"fraud_status"`
+	Currency          string `json:"currency"`
+}
+
+// VerifySignature checks the notification's signature_key, which Midtrans
+// computes as SHA512(order_id + status_code + gross_amount + server_key).
+func (c *Client) VerifySignature(n *Notification) bool {
+	sum := sha512.Sum512([]byte(n.OrderID + n.StatusCode + n.GrossAmount + c.serverKey))
+	expected := hex.EncodeToString(sum[:])
+	return subtle.ConstantTimeCompare([]byte(expected), []byte(strings.ToLower(n.SignatureKey))) == 1
+}
@@ -0,0 +1,285 @@
+package midtrans
+
+import (
+	"crypto/sha512"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+const testServerKey = "SB-Mid-server-test"
+
+// fakeMidtrans serves handler as a Midtrans API and returns a client pointed
+// at it, for both the Core API and Snap.
+func fakeMidtrans(t *testing.T, handler http.HandlerFunc) *Client {
+	t.Helper()
+	server := httptest.NewServer(handler)
+	t.Cleanup(server.Close)
+
+	return NewClient(Config{
+		ServerKey: testServerKey,
+		APIURL:    server.URL,
+		SnapURL:   server.URL + "/snap/",
+	})
+}
+
+// checkRequest verifies the method, path and server key authentication of a
+// request and decodes its JSON body into body.
+func checkRequest(t *testing.T, r *http.Request, method, path string, body interface{}) {
+	t.Helper()
+	if r.Method != method || r.URL.Path != path {
+		t.Errorf("request = %s %s, want %s %s", r.Method, r.URL.Path, method, path)
+	}
+	if user, pass, ok := r.BasicAuth(); !ok || user != testServerKey || pass != "" {
+		t.Errorf("basic auth = %q, %q, %v, want the server key", user, pass, ok)
+	}
+	if body != nil {
+		if err := json.NewDecoder(r.Body).Decode(body); err != nil {
+			t.Errorf("failed to decode request body: %v", err)
+		}
+	}
+}
+
+func TestCreateSnapTransaction(t *testing.T) {
+	client := fakeMidtrans(t, func(w http.ResponseWriter, r *http.Request) {
+		var req SnapRequest
+		checkRequest(t, r, http.MethodPost, "/snap/v1/transactions", &req)
+		if req.TransactionDetails.OrderID != "ORD-1-abcd" || req.TransactionDetails.GrossAmount != 150000 {
+			t.Errorf("transaction details = %+v", req.TransactionDetails)
+		}
+		if len(req.EnabledPayments) != 1 || req.EnabledPayments[0] != "gopay" {
+			t.Errorf("enabled payments = %v, want [gopay]", req.EnabledPayments)
+		}
+		if req.Expiry == nil || req.Expiry.Unit != "minute" || req.Expiry.Duration != 60 {
+			t.Errorf("expiry = %+v", req.Expiry)
+		}
+
+		w.WriteHeader(http.StatusCreated)
+		w.Write([]byte(`{"token":"snap-token","redirect_url":"https://app.sandbox.midtrans.com/snap/v2/vtweb/snap-token"}`))
+	})
+
+	resp, err := client.CreateSnapTransaction(&SnapRequest{
+		TransactionDetails: TransactionDetails{OrderID: "ORD-1-abcd", GrossAmount: 150000},
+		EnabledPayments:    []string{"gopay"},
+		Expiry:             &SnapExpiry{Unit: "minute", Duration: 60},
+	})
+	if err != nil {
+		t.Fatalf("CreateSnapTransaction() error = %v", err)
+	}
+	if resp.Token != "snap-token" || !strings.HasSuffix(resp.RedirectURL, "/snap-token") {
+		t.Errorf("response = %+v", resp)
+	}
+}
+
+func TestCreateSnapTransactionRejected(t *testing.T) {
+	client := fakeMidtrans(t, func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte(`{"error_messages":["transaction_details.gross_amount is required"]}`))
+	})
+
+	_, err := client.CreateSnapTransaction(&SnapRequest{})
+	var midtransErr *Error
+	if !errors.As(err, &midtransErr) {
+		t.Fatalf("CreateSnapTransaction() error = %v, want *Error", err)
+	}
+	if midtransErr.StatusCode != http.StatusBadRequest || len(midtransErr.Messages) != 1 {
+		t.Errorf("error = %+v", midtransErr)
+	}
+}
+
+func TestChargeBankTransfer(t *testing.T) {
+	tests := []struct {
+		name     string
+		response string
+		wantVA   string
+	}{
+		{
+			name:     "va_numbers",
+			response: `{"status_code":"201","status_message":"Success, Bank Transfer transaction is created","transaction_id":"trx-1","order_id":"ORD-1-abcd","gross_amount":"150000.00","payment_type":"bank_transfer","transaction_status":"pending","va_numbers":[{"bank":"bca","va_number":"12345678901"}],"expiry_time":"2024-01-02 15:04:05"}`,
+			wantVA:   "12345678901",
+		},
+		{
+			name:     "permata",
+			response: `{"status_code":"201","status_message":"Success, PERMATA VA transaction is successful","transaction_id":"trx-1","order_id":"ORD-1-abcd","gross_amount":"150000.00","payment_type":"bank_transfer","transaction_status":"pending","permata_va_number":"8562000087926752","expiry_time":"2024-01-02 15:04:05"}`,
+			wantVA:   "8562000087926752",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client := fakeMidtrans(t, func(w http.ResponseWriter, r *http.Request) {
+				var req ChargeRequest
+				checkRequest(t, r, http.MethodPost, "/v2/charge", &req)
+				if req.PaymentType != "bank_transfer" || req.BankTransfer == nil || req.BankTransfer.Bank != "bca" {
+					t.Errorf("charge request = %+v", req)
+				}
+				if req.CustomExpiry == nil || req.CustomExpiry.ExpiryDuration != 1440 || req.CustomExpiry.Unit != "minute" {
+					t.Errorf("custom expiry = %+v", req.CustomExpiry)
+				}
+				w.Write([]byte(tt.response))
+			})
+
+			resp, err := client.Charge(&ChargeRequest{
+				PaymentType:        "bank_transfer",
+				TransactionDetails: TransactionDetails{OrderID: "ORD-1-abcd", GrossAmount: 150000},
+				BankTransfer:       &BankTransferDetails{Bank: "bca"},
+				CustomExpiry:       &CustomExpiry{ExpiryDuration: 1440, Unit: "minute"},
+			})
+			if err != nil {
+				t.Fatalf("Charge() error = %v", err)
+			}
+			if resp.TransactionID != "trx-1" {
+				t.Errorf("transaction ID = %q, want trx-1", resp.TransactionID)
+			}
+			if va := resp.VirtualAccount(); va != tt.wantVA {
+				t.Errorf("VirtualAccount() = %q, want %q", va, tt.wantVA)
+			}
+
+			expiresAt, ok := resp.ExpiresAt()
+			want := time.Date(2024, 1, 2, 8, 4, 5, 0, time.UTC)
+			if !ok || !expiresAt.Equal(want) {
+				t.Errorf("ExpiresAt() = %v, %v, want %v", expiresAt, ok, want)
+			}
+		})
+	}
+}
+
+func TestExpiresAt(t *testing.T) {
+	tests := []struct {
+		expiryTime string
+		wantOK     bool
+	}{
+		{"", false},
+		{"not a time", false},
+		{"2024-01-02T15:04:05Z", false},
+		{"2024-01-02 15:04:05", true},
+	}
+
+	for _, tt := range tests {
+		resp := &TransactionResponse{ExpiryTime: tt.expiryTime}
+		if _, ok := resp.ExpiresAt(); ok != tt.wantOK {
+			t.Errorf("ExpiresAt() for %q ok = %v, want %v", tt.expiryTime, ok, tt.wantOK)
+		}
+	}
+}
+
+func TestCheckStatusCode(t *testing.T) {
+	tests := []struct {
+		name       string
+		httpStatus int
+		body       string
+		wantStatus int // 0 for no error
+		wantMsg    string
+	}{
+		{
+			name:       "created",
+			httpStatus: http.StatusOK,
+			body:       `{"status_code":"201","status_message":"Success","transaction_id":"trx-1"}`,
+		},
+		{
+			name:       "status code in body",
+			httpStatus: http.StatusOK,
+			body:       `{"status_code":"406","status_message":"Duplicate order ID. Order ID has already been utilized previously"}`,
+			wantStatus: http.StatusNotAcceptable,
+			wantMsg:    "Duplicate order ID",
+		},
+		{
+			name:       "server error in body",
+			httpStatus: http.StatusOK,
+			body:       `{"status_code":"500","status_message":"Sorry, an unexpected error occurred"}`,
+			wantStatus: http.StatusInternalServerError,
+			wantMsg:    "unexpected error",
+		},
+		{
+			name:       "http error",
+			httpStatus: http.StatusUnauthorized,
+			body:       `{"status_code":"401","status_message":"Access denied due to unauthorized transaction"}`,
+			wantStatus: http.StatusUnauthorized,
+			wantMsg:    "Access denied",
+		},
+		{
+			name:       "missing status code",
+			httpStatus: http.StatusOK,
+			body:       `{"transaction_id":"trx-1"}`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client := fakeMidtrans(t, func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(tt.httpStatus)
+				w.Write([]byte(tt.body))
+			})
+
+			_, err := client.Charge(&ChargeRequest{PaymentType: "bank_transfer"})
+			if tt.wantStatus == 0 {
+				if err != nil {
+					t.Fatalf("Charge() error = %v, want nil", err)
+				}
+				return
+			}
+
+			var midtransErr *Error
+			if !errors.As(err, &midtransErr) {
+				t.Fatalf("Charge() error = %v, want *Error", err)
+			}
+			if midtransErr.StatusCode != tt.wantStatus || !strings.Contains(midtransErr.Error(), tt.wantMsg) {
+				t.Errorf("error = %v, want status %d with %q", midtransErr, tt.wantStatus, tt.wantMsg)
+			}
+		})
+	}
+}
+
+func TestCancelNotFound(t *testing.T) {
+	client := fakeMidtrans(t, func(w http.ResponseWriter, r *http.Request) {
+		checkRequest(t, r, http.MethodPost, "/v2/ORD-1-abcd/cancel", nil)
+		w.Write([]byte(`{"status_code":"404","status_message":"Transaction doesn't exist."}`))
+	})
+
+	_, err := client.Cancel("ORD-1-abcd")
+	var midtransErr *Error
+	if !errors.As(err, &midtransErr) || midtransErr.StatusCode != http.StatusNotFound {
+		t.Errorf("Cancel() error = %v, want status 404", err)
+	}
+}
+
+func TestVerifySignature(t *testing.T) {
+	client := NewClient(Config{ServerKey: testServerKey})
+	sign := func(orderID, statusCode, grossAmount, serverKey string) string {
+		sum := sha512.Sum512([]byte(orderID + statusCode + grossAmount + serverKey))
+		return hex.EncodeToString(sum[:])
+	}
+	notification := func(signature string) *Notification {
+		return &Notification{
+			OrderID:      "ORD-1-abcd",
+			StatusCode:   "200",
+			GrossAmount:  "150000.00",
+			SignatureKey: signature,
+		}
+	}
+
+	tests := []struct {
+		name      string
+		signature string
+		want      bool
+	}{
+		{"valid", sign("ORD-1-abcd", "200", "150000.00", testServerKey), true},
+		{"uppercase", strings.ToUpper(sign("ORD-1-abcd", "200", "150000.00", testServerKey)), true},
+		{"other server key", sign("ORD-1-abcd", "200", "150000.00", "SB-Mid-server-other"), false},
+		{"other amount", sign("ORD-1-abcd", "200", "1.00", testServerKey), false},
+		{"empty", "", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := client.VerifySignature(notification(tt.signature)); got != tt.want {
+				t.Errorf("VerifySignature() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,341 @@
+package rabbitmq
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/be-bcv/ecommerce-backend/pkg/messages"
+	"github.com/streadway/amqp"
+)
+
+// DeadLetterExchange receives messages that could not be processed. Each
+// consumer queue gets a "<queue>.dead" queue bound to it by queue name.
+const DeadLetterExchange = "dead_letters"
+
+// Headers added to retried and dead-lettered messages.
+const (
+	headerAttempts           = "x-attempts"
+	headerLastError          = "x-last-error"
+	headerOriginalExchange   = "x-original-exchange"
+	headerOriginalRoutingKey = "x-original-routing-key"
+)
+
+// EventHandler processes one event delivered to a queue.
+type EventHandler func(event *messages.RawEventMessage) error
+
+type ConsumerConfig struct {
+	// Queue is the durable queue to consume. It is bound to Exchange with
+	// the name of every event that has a handler.
+	Queue    string
+	Exchange string
+	// MaxAttempts is how many times a message is handled before it is
+	// dead-lettered. Defaults to 5.
+	MaxAttempts int
+	// RetryDelay is the delay before the first retry; it doubles on every
+	// further attempt up to MaxRetryDelay. Defaults to 1s and 5m.
+	RetryDelay    time.Duration
+	MaxRetryDelay time.Duration
+	// Prefetch is the number of unacknowledged messages the broker sends
+	// ahead, and Workers the number handled concurrently. Defaults to 10 and 1.
+	Prefetch int
+	Workers  int
+}
+
+// Consumer routes EventMessages from a queue to handlers by event name. A
+// message is acked once its handler succeeds. A failing message is acked and
+// republished to a delay queue, which hands it back to the queue after an
+// exponential backoff; after MaxAttempts it goes to DeadLetterExchange, as do
+// messages that cannot be decoded. Messages without a handler are acked. If
+// republishing fails, the message is requeued after a pause that grows while
+// publishing keeps failing.
+type Consumer struct {
+	r         *RabbitMQ
+	cfg       ConsumerConfig
+	handlers  map[string]EventHandler
+	publisher *Publisher
+	// publishFailures counts republishing failures in a row
+	publishFailures atomic.Int32
+}
+
+func NewConsumer(r *RabbitMQ, cfg ConsumerConfig, handlers map[string]EventHandler) *Consumer {
+	if cfg.MaxAttempts <= 0 {
+		cfg.MaxAttempts = 5
+	}
+	if cfg.RetryDelay <= 0 {
+		cfg.RetryDelay = time.Second
+	}
+	if cfg.MaxRetryDelay <= 0 {
+		cfg.MaxRetryDelay = 5 * time.Minute
+	}
+	if cfg.Prefetch <= 0 {
+		cfg.Prefetch = 10
+	}
+	if cfg.Workers <= 0 {
+		cfg.Workers = 1
+	}
+
+	return &Consumer{
+		r:        r,
+		cfg:      cfg,
+		handlers: handlers,
+	}
+}
+
+// retryQueue names the delay queue for the given attempt.
+func (c *Consumer) retryQueue(attempt int) string {
+	return fmt.Sprintf("%s.retry.%d", c.cfg.Queue, attempt)
+}
+
+// retryDelay is the backoff before the given retry attempt (1-based).
+func (c *Consumer) retryDelay(attempt int) time.Duration {
+	delay := c.cfg.RetryDelay
+	for i := 1; i < attempt && delay < c.cfg.MaxRetryDelay; i++ {
+		delay *= 2
+	}
+	if delay > c.cfg.MaxRetryDelay {
+		delay = c.cfg.MaxRetryDelay
+	}
+	return delay
+}
+
+// requeueDelay is the pause before requeueing a message after the given
+// number of republishing failures in a row, backing off like reconnection.
+func requeueDelay(failures int32) time.Duration {
+	delay := reconnectMinDelay
+	for i := int32(1); i < failures && delay < reconnectMaxDelay; i++ {
+		delay *= 2
+	}
+	if delay > reconnectMaxDelay {
+		delay = reconnectMaxDelay
+	}
+	return delay
+}
+
+// declareTopology declares the queue and its bindings, one delay queue per
+// retry attempt and the dead-letter exchange and queue.
+func (c *Consumer) declareTopology(ch *amqp.Channel) error {
+	if _, err := ch.QueueDeclare(c.cfg.Queue, true, false, false, false, nil); err != nil {
+		return fmt.Errorf("failed to declare queue %s: %w", c.cfg.Queue, err)
+	}
+	for eventName := range c.handlers {
+		if err := ch.QueueBind(c.cfg.Queue, eventName, c.cfg.Exchange, false, nil); err != nil {
+			return fmt.Errorf("failed to bind queue %s to %s: %w", c.cfg.Queue, eventName, err)
+		}
+	}
+
+	// Expired messages in a delay queue are dead-lettered through the
+	// default exchange straight back to the consumer queue.
+	for attempt := 1; attempt < c.cfg.MaxAttempts; attempt++ {
+		args := amqp.Table{
+			"x-message-ttl":             int64(c.retryDelay(attempt) / time.Millisecond),
+			"x-dead-letter-exchange":    "",
+			"x-dead-letter-routing-key": c.cfg.Queue,
+		}
+		if _, err := ch.QueueDeclare(c.retryQueue(attempt), true, false, false, false, args); err != nil {
+			return fmt.Errorf("failed to declare retry queue %s: %w", c.retryQueue(attempt), err)
+		}
+	}
+
+	if err := ch.ExchangeDeclare(DeadLetterExchange, "direct", true, false, false, false, nil); err != nil {
+		return fmt.Errorf("failed to declare exchange %s: %w", DeadLetterExchange, err)
+	}
+	deadQueue := c.cfg.Queue + ".dead"
+	if _, err := ch.QueueDeclare(deadQueue, true, false, false, false, nil); err != nil {
+		return fmt.Errorf("failed to declare queue %s: %w", deadQueue, err)
+	}
+	if err := ch.QueueBind(deadQueue, c.cfg.Queue, DeadLetterExchange, false, nil); err != nil {
+		return fmt.Errorf("failed to bind queue %s: %w", deadQueue, err)
+	}
+	return nil
+}
+
+// Run declares the consumer's topology and handles messages until ctx is
+// cancelled. If the channel or connection is lost, it waits for the broker
+// connection to recover and consumes again; only a failure to set up the
+// first session is returned. On shutdown it stops taking new deliveries and
+// returns once the messages already being handled are finished; prefetched
+// messages that were not started go back to the queue.
+func (c *Consumer) Run(ctx context.Context) error {
+	publisher, err := NewPublisher(c.r)
+	if err != nil {
+		return err
+	}
+	defer publisher.Close()
+	c.publisher = publisher
+
+	for first := true; ; first = false {
+		established, err := c.session(ctx)
+		if err == nil || ctx.Err() != nil {
+			return nil
+		}
+		if first && !established {
+			return err
+		}
+
+		log.Printf("Consumer on %s stopped, resuming once RabbitMQ is available: %v", c.cfg.Queue, err)
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-time.After(reconnectMinDelay):
+		}
+		if err := c.r.waitConnected(ctx); err != nil {
+			return nil
+		}
+	}
+}
+
+// session consumes on a new channel until ctx is cancelled, returning nil,
+// or until the channel closes. established reports whether consuming started.
+func (c *Consumer) session(ctx context.Context) (established bool, err error) {
+	ch, err := c.r.openChannel()
+	if err != nil {
+		return false, fmt.Errorf("failed to open consumer channel: %w", err)
+	}
+	defer ch.Close()
+	closed := ch.NotifyClose(make(chan *amqp.Error, 1))
+
+	if err := c.declareTopology(ch); err != nil {
+		return false, err
+	}
+	if err := ch.Qos(c.cfg.Prefetch, 0, false); err != nil {
+		return false, fmt.Errorf("failed to set prefetch: %w", err)
+	}
+
+	consumerTag := c.cfg.Queue + ".consumer"
+	deliveries, err := ch.Consume(c.cfg.Queue, consumerTag, false, false, false, false, nil)
+	if err != nil {
+		return false, fmt.Errorf("failed to consume queue %s: %w", c.cfg.Queue, err)
+	}
+
+	var wg sync.WaitGroup
+	for i := 0; i < c.cfg.Workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for d := range deliveries {
+				c.handle(ctx, d)
+			}
+		}()
+	}
+
+	select {
+	case <-ctx.Done():
+		// Stop new deliveries; the channel closes once the broker confirms,
+		// which lets the workers finish their current message and exit.
+		if err := ch.Cancel(consumerTag, false); err != nil {
+			log.Printf("Failed to cancel consumer on %s: %v", c.cfg.Queue, err)
+		}
+		wg.Wait()
+		return true, nil
+	case amqpErr := <-closed:
+		// Unacked messages are redelivered by the broker
+		wg.Wait()
+		return true, fmt.Errorf("consumer channel closed: %v", amqpErr)
+	}
+}
+
+func (c *Consumer) handle(ctx context.Context, d amqp.Delivery) {
+	var event messages.RawEventMessage
+	if err := json.Unmarshal(d.Body, &event); err != nil {
+		c.deadLetter(ctx, d, fmt.Errorf("malformed message: %w", err))
+		return
+	}
+
+	handler, ok := c.handlers[event.EventName]
+	if !ok {
+		d.Ack(false)
+		return
+	}
+
+	err := handler(&event)
+	if err == nil {
+		d.Ack(false)
+		return
+	}
+
+	attempt := attempts(d) + 1
+	if attempt >= c.cfg.MaxAttempts {
+		log.Printf("Dead-lettering %s event %s after %d attempts: %v", event.EventName, event.EventID, attempt, err)
+		c.deadLetter(ctx, d, err)
+		return
+	}
+
+	log.Printf("Failed to handle %s event %s (attempt %d), retrying in %s: %v", event.EventName, event.EventID, attempt, c.retryDelay(attempt), err)
+	msg := republishing(d, attempt, err)
+	if err := c.publisher.publish("", c.retryQueue(attempt), msg); err != nil {
+		c.requeue(ctx, d, fmt.Errorf("failed to schedule retry: %w", err))
+		return
+	}
+	c.publishFailures.Store(0)
+	d.Ack(false)
+}
+
+// deadLetter moves the delivery to the dead-letter exchange, or requeues it
+// if that fails so it is not lost.
+func (c *Consumer) deadLetter(ctx context.Context, d amqp.Delivery, reason error) {
+	msg := republishing(d, attempts(d)+1, reason)
+	if err := c.publisher.publish(DeadLetterExchange, c.cfg.Queue, msg); err != nil {
+		c.requeue(ctx, d, fmt.Errorf("failed to dead-letter message: %w", err))
+		return
+	}
+	c.publishFailures.Store(0)
+	d.Ack(false)
+}
+
+// requeue hands a message that could not be republished back to the queue.
+// It waits first, so that while publishing fails the worker does not take
+// the same message again straight away; on shutdown it requeues at once.
+func (c *Consumer) requeue(ctx context.Context, d amqp.Delivery, err error) {
+	delay := requeueDelay(c.publishFailures.Add(1))
+	log.Printf("Requeueing message on %s in %s: %v", c.cfg.Queue, delay, err)
+
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+	case <-timer.C:
+	}
+	d.Nack(false, true)
+}
+
+// attempts returns how many times the delivery has been handled before.
+func attempts(d amqp.Delivery) int {
+	switch n := d.Headers[headerAttempts].(type) {
+	case int32:
+		return int(n)
+	case int64:
+		return int(n)
+	}
+	return 0
+}
+
+// republishing copies the delivery for publishing again with its attempt
+// count, failure reason and original routing.
+func republishing(d amqp.Delivery, attempt int, reason error) amqp.Publishing {
+	headers := amqp.Table{}
+	for k, v := range d.Headers {
+		headers[k] = v
+	}
+	headers[headerAttempts] = int32(attempt)
+	headers[headerLastError] = reason.Error()
+	if _, ok := headers[headerOriginalExchange]; !ok {
+		headers[headerOriginalExchange] = d.Exchange
+		headers[headerOriginalRoutingKey] = d.RoutingKey
+	}
+
+	return amqp.Publishing{
+		Headers:      headers,
+		ContentType:  d.ContentType,
+		DeliveryMode: amqp.Persistent,
+		MessageId:    d.MessageId,
+		Timestamp:    d.Timestamp,
+		Type:         d.Type,
+		AppId:        d.AppId,
+		Body:         d.Body,
+	}
+}
@@ -0,0 +1,52 @@
+package rabbitmq
+
+import (
+	"testing"
+	"time"
+)
+
+func TestConsumerRetryDelay(t *testing.T) {
+	tests := []struct {
+		name    string
+		cfg     ConsumerConfig
+		attempt int
+		want    time.Duration
+	}{
+		{"defaults first retry", ConsumerConfig{}, 1, time.Second},
+		{"defaults doubles", ConsumerConfig{}, 2, 2 * time.Second},
+		{"defaults fourth retry", ConsumerConfig{}, 4, 8 * time.Second},
+		{"defaults capped", ConsumerConfig{}, 10, 5 * time.Minute},
+		{"defaults far past the cap", ConsumerConfig{}, 100, 5 * time.Minute},
+		{"custom delay", ConsumerConfig{RetryDelay: 3 * time.Second, MaxRetryDelay: time.Hour}, 3, 12 * time.Second},
+		{"custom cap between steps", ConsumerConfig{RetryDelay: 3 * time.Second, MaxRetryDelay: 10 * time.Second}, 3, 10 * time.Second},
+		{"delay above cap", ConsumerConfig{RetryDelay: time.Minute, MaxRetryDelay: 30 * time.Second}, 1, 30 * time.Second},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := NewConsumer(nil, tt.cfg, nil)
+			if got := c.retryDelay(tt.attempt); got != tt.want {
+				t.Errorf("retryDelay(%d) = %s, want %s", tt.attempt, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRequeueDelay(t *testing.T) {
+	tests := []struct {
+		failures int32
+		want     time.Duration
+	}{
+		{1, time.Second},
+		{2, 2 * time.Second},
+		{5, 16 * time.Second},
+		{6, 30 * time.Second},
+		{1000, 30 * time.Second},
+	}
+
+	for _, tt := range tests {
+		if got := requeueDelay(tt.failures); got != tt.want {
+			t.Errorf("requeueDelay(%d) = %s, want %s", tt.failures, got, tt.want)
+		}
+	}
+}
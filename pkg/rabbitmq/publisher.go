@@ -0,0 +1,176 @@
+package rabbitmq
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/be-bcv/ecommerce-backend/pkg/messages"
+	"github.com/streadway/amqp"
+)
+
+// confirmTimeout bounds how long Publish waits for the broker to confirm a message.
+const confirmTimeout = 5 * time.Second
+
+var ErrPublishNacked = errors.New("message was not confirmed by the broker")
+
+// Publisher publishes EventMessages as JSON on a dedicated channel in
+// confirm mode, so every Publish returns only once the broker has taken
+// responsibility for the message. While the broker connection is down,
+// publishes fail fast with ErrNotConnected; the channel is reopened on the
+// first publish after the connection is back.
+type Publisher struct {
+	r        *RabbitMQ
+	mu       sync.Mutex
+	channel  *amqp.Channel
+	confirms chan amqp.Confirmation
+	closed   chan *amqp.Error
+	// nextTag is the delivery tag the broker assigns to the next message
+	nextTag uint64
+}
+
+func NewPublisher(r *RabbitMQ) (*Publisher, error) {
+	p := &Publisher{r: r}
+	if err := p.open(); err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+// open puts a new channel in confirm mode. Callers other than NewPublisher
+// must hold p.mu.
+func (p *Publisher) open() error {
+	ch, err := p.r.openChannel()
+	if err != nil {
+		return fmt.Errorf("failed to open publisher channel: %w", err)
+	}
+
+	if err := ch.Confirm(false); err != nil {
+		ch.Close()
+		return fmt.Errorf("failed to enable publisher confirms: %w", err)
+	}
+
+	p.channel = ch
+	p.confirms = ch.NotifyPublish(make(chan amqp.Confirmation, 1))
+	p.closed = ch.NotifyClose(make(chan *amqp.Error, 1))
+	p.nextTag = 1
+	return nil
+}
+
+// ensureChannel reopens the channel if it was closed. Callers must hold p.mu.
+func (p *Publisher) ensureChannel() error {
+	if p.channel != nil {
+		select {
+		case <-p.closed:
+			p.channel = nil
+		default:
+			return nil
+		}
+	}
+	return p.open()
+}
+
+// reset drops the channel so the next publish opens a new one. Callers must
+// hold p.mu.
+func (p *Publisher) reset() {
+	if p.channel != nil {
+		p.channel.Close()
+		p.channel = nil
+	}
+}
+
+// Publish sends the event to exchange, routed by its event name, and waits
+// for the broker to confirm it.
+func (p *Publisher) Publish(exchange string, event messages.EventMessage) error {
+	body, err := json.Marshal(event)
+	if err != nil {
+		return fmt.Errorf("failed to encode %s event: %w", event.EventName, err)
+	}
+
+	return p.publish(exchange, event.EventName, eventPublishing(event.EventName, event.EventID, event.Timestamp, event.Service, body))
+}
+
+// PublishEncoded publishes an already JSON-encoded EventMessage, e.g. one
+// read back from an outbox table, without re-encoding it.
+func (p *Publisher) PublishEncoded(exchange string, body []byte) error {
+	var event messages.RawEventMessage
+	if err := json.Unmarshal(body, &event); err != nil {
+		return fmt.Errorf("failed to decode event: %w", err)
+	}
+
+	return p.publish(exchange, event.EventName, eventPublishing(event.EventName, event.EventID, event.Timestamp, event.Service, body))
+}
+
+func eventPublishing(eventName, eventID string, timestamp time.Time, service string, body []byte) amqp.Publishing {
+	return amqp.Publishing{
+		ContentType:  "application/json",
+		DeliveryMode: amqp.Persistent,
+		MessageId:    eventID,
+		Timestamp:    timestamp,
+		Type:         eventName,
+		AppId:        service,
+		Body:         body,
+	}
+}
+
+// publish sends msg and waits for the broker to confirm it.
+func (p *Publisher) publish(exchange, routingKey string, msg amqp.Publishing) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if err := p.ensureChannel(); err != nil {
+		return fmt.Errorf("failed to publish %s: %w", routingKey, err)
+	}
+
+	err := p.channel.Publish(
+		exchange,   // exchange
+		routingKey, // routing key
+		false,      // mandatory
+		false,      // immediate
+		msg,
+	)
+	if err != nil {
+		p.reset()
+		return fmt.Errorf("failed to publish %s: %w", routingKey, err)
+	}
+
+	tag := p.nextTag
+	p.nextTag++
+
+	timeout := time.NewTimer(confirmTimeout)
+	defer timeout.Stop()
+
+	for {
+		select {
+		case confirm, ok := <-p.confirms:
+			if !ok {
+				p.reset()
+				return fmt.Errorf("failed to publish %s: channel closed before confirm", routingKey)
+			}
+			// Skip late confirms for messages that already timed out
+			if confirm.DeliveryTag < tag {
+				continue
+			}
+			if !confirm.Ack {
+				return fmt.Errorf("failed to publish %s: %w", routingKey, ErrPublishNacked)
+			}
+			return nil
+		case <-timeout.C:
+			return fmt.Errorf("failed to publish %s: no confirm within %s", routingKey, confirmTimeout)
+		}
+	}
+}
+
+func (p *Publisher) Close() error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.channel == nil {
+		return nil
+	}
+	err := p.channel.Close()
+	p.channel = nil
+	return err
+}
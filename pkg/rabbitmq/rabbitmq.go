@@ -1,37 +1,257 @@
 package rabbitmq
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"log"
+	"sync"
+	"time"
 
 	"github.com/streadway/amqp"
 )
 
+// Reconnection backs off exponentially between these bounds.
+const (
+	reconnectMinDelay = time.Second
+	reconnectMaxDelay = 30 * time.Second
+)
+
+// ErrNotConnected is returned by operations attempted while the connection
+// to the broker is down and being re-established.
+var ErrNotConnected = errors.New("rabbitmq: not connected")
+
+// RabbitMQ is a connection to the broker that recovers from failures. When
+// the connection or its channel closes it reconnects with backoff and
+// re-declares every exchange, queue and binding declared through it.
+// Publishers and consumers open their own channels and recover them after
+// the connection is back.
 type RabbitMQ struct {
+	url string
+
+	mu      sync.RWMutex
 	conn    *amqp.Connection
 	channel *amqp.Channel
+	// ready is closed while connected and replaced when the connection drops
+	ready    chan struct{}
+	topology []func(ch *amqp.Channel) error
+	closing  bool
+	done     chan struct{}
 }
 
 func NewRabbitMQ(url string) (*RabbitMQ, error) {
+	conn, ch, err := dial(url)
+	if err != nil {
+		return nil, err
+	}
+
+	r := &RabbitMQ{
+		url:     url,
+		conn:    conn,
+		channel: ch,
+		ready:   make(chan struct{}),
+		done:    make(chan struct{}),
+	}
+	close(r.ready)
+	go r.watch(conn, ch)
+
+	log.Printf("RabbitMQ connected successfully")
+	return r, nil
+}
+
+func dial(url string) (*amqp.Connection, *amqp.Channel, error) {
 	conn, err := amqp.Dial(url)
 	if err != nil {
-		return nil, fmt.Errorf("failed to connect to RabbitMQ: %w", err)
+		return nil, nil, fmt.Errorf("failed to connect to RabbitMQ: %w", err)
 	}
 
+	ch, err := conn.Channel()
+	if err != nil {
+		conn.Close()
+		return nil, nil, fmt.Errorf("failed to open a channel: %w", err)
+	}
+	return conn, ch, nil
+}
+
+// watch waits for the connection or channel to close and recovers it,
+// until Close is called. The connection's close listener is registered once
+// per connection, as the library keeps every listener until it closes.
+func (r *RabbitMQ) watch(conn *amqp.Connection, ch *amqp.Channel) {
+	connClosed := conn.NotifyClose(make(chan *amqp.Error, 1))
+	for {
+		chClosed := ch.NotifyClose(make(chan *amqp.Error, 1))
+
+		select {
+		case err := <-connClosed:
+			if r.isClosing() {
+				return
+			}
+			log.Printf("RabbitMQ connection lost: %v", err)
+		case err := <-chClosed:
+			if r.isClosing() {
+				return
+			}
+			// A channel error leaves the connection usable, so try to
+			// replace just the channel first
+			log.Printf("RabbitMQ channel closed: %v", err)
+			if newCh, err := conn.Channel(); err == nil {
+				r.mu.Lock()
+				r.channel = newCh
+				r.mu.Unlock()
+				ch = newCh
+				continue
+			}
+			conn.Close()
+		}
+
+		r.mu.Lock()
+		r.ready = make(chan struct{})
+		r.mu.Unlock()
+
+		conn, ch = r.reconnect()
+		if conn == nil {
+			return
+		}
+		connClosed = conn.NotifyClose(make(chan *amqp.Error, 1))
+	}
+}
+
+// reconnect dials until it succeeds and the recorded topology is declared
+// again, or returns nil once Close is called.
+func (r *RabbitMQ) reconnect() (*amqp.Connection, *amqp.Channel) {
+	delay := reconnectMinDelay
+	for {
+		select {
+		case <-r.done:
+			return nil, nil
+		case <-time.After(delay):
+		}
+
+		conn, ch, err := dial(r.url)
+		if err == nil {
+			if err = r.redeclare(ch); err == nil {
+				r.mu.Lock()
+				if r.closing {
+					r.mu.Unlock()
+					conn.Close()
+					return nil, nil
+				}
+				r.conn = conn
+				r.channel = ch
+				close(r.ready)
+				r.mu.Unlock()
+
+				log.Printf("RabbitMQ reconnected")
+				return conn, ch
+			}
+			conn.Close()
+		}
+
+		log.Printf("Failed to reconnect to RabbitMQ, retrying in %s: %v", delay, err)
+		delay *= 2
+		if delay > reconnectMaxDelay {
+			delay = reconnectMaxDelay
+		}
+	}
+}
+
+func (r *RabbitMQ) redeclare(ch *amqp.Channel) error {
+	r.mu.RLock()
+	topology := append([]func(ch *amqp.Channel) error(nil), r.topology...)
+	r.mu.RUnlock()
+
+	for _, declare := range topology {
+		if err := declare(ch); err != nil {
+			return fmt.Errorf("failed to re-declare topology: %w", err)
+		}
+	}
+	return nil
+}
+
+func (r *RabbitMQ) isClosing() bool {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.closing
+}
+
+// IsConnected reports whether the connection to the broker is up.
+func (r *RabbitMQ) IsConnected() bool {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	select {
+	case <-r.ready:
+		return !r.closing
+	default:
+		return false
+	}
+}
+
+// waitConnected blocks until the connection is up or ctx is done.
+func (r *RabbitMQ) waitConnected(ctx context.Context) error {
+	r.mu.RLock()
+	ready := r.ready
+	r.mu.RUnlock()
+
+	select {
+	case <-ready:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// openChannel opens a new channel on the current connection.
+func (r *RabbitMQ) openChannel() (*amqp.Channel, error) {
+	if !r.IsConnected() {
+		return nil, ErrNotConnected
+	}
+
+	r.mu.RLock()
+	conn := r.conn
+	r.mu.RUnlock()
+
 	ch, err := conn.Channel()
 	if err != nil {
 		return nil, fmt.Errorf("failed to open a channel: %w", err)
 	}
+	return ch, nil
+}
 
-	log.Printf("RabbitMQ connected successfully")
-	return &RabbitMQ{
-		conn:    conn,
-		channel: ch,
-	}, nil
+// sharedChannel returns the connection's own channel, or ErrNotConnected.
+func (r *RabbitMQ) sharedChannel() (*amqp.Channel, error) {
+	if !r.IsConnected() {
+		return nil, ErrNotConnected
+	}
+
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.channel, nil
+}
+
+// declare runs a declaration now and records it to run again after a
+// reconnect.
+func (r *RabbitMQ) declare(declaration func(ch *amqp.Channel) error) error {
+	ch, err := r.sharedChannel()
+	if err != nil {
+		return err
+	}
+	if err := declaration(ch); err != nil {
+		return err
+	}
+
+	r.mu.Lock()
+	r.topology = append(r.topology, declaration)
+	r.mu.Unlock()
+	return nil
 }
 
 func (r *RabbitMQ) Publish(exchange, routingKey string, body []byte) error {
-	err := r.channel.Publish(
+	ch, err := r.sharedChannel()
+	if err != nil {
+		return err
+	}
+
+	err = ch.Publish(
 		exchange,  // exchange
 		routingKey, // routing key
 		false,     // mandatory
@@ -44,7 +264,12 @@ func (r *RabbitMQ) Publish(exchange, routingKey string, body []byte) error {
 }
 
 func (r *RabbitMQ) Consume(queue, consumer string, autoAck bool) (<-chan amqp.Delivery, error) {
-	return r.channel.Consume(
+	ch, err := r.sharedChannel()
+	if err != nil {
+		return nil, err
+	}
+
+	return ch.Consume(
 		queue,   // queue
 		consumer, // consumer
 		autoAck, // auto-ack
@@ -56,45 +281,71 @@ func (r *RabbitMQ) Consume(queue, consumer string, autoAck bool) (<-chan amqp.De
 }
 
 func (r *RabbitMQ) DeclareQueue(name string) error {
-	_, err := r.channel.QueueDeclare(
-		name,  // name
-		true,  // durable
-		false, // delete when unused
-		false, // exclusive
-		false, // no-wait
-		nil,   // arguments
-	)
-	return err
+	return r.declare(func(ch *amqp.Channel) error {
+		_, err := ch.QueueDeclare(
+			name,  // name
+			true,  // durable
+			false, // delete when unused
+			false, // exclusive
+			false, // no-wait
+			nil,   // arguments
+		)
+		return err
+	})
 }
 
 func (r *RabbitMQ) DeclareExchange(name, kind string) error {
-	return r.channel.ExchangeDeclare(
-		name,  // name
-		kind,  // type
-		true,  // durable
-		false, // auto-deleted
-		false, // internal
-		false, // no-wait
-		nil,   // arguments
-	)
+	return r.declare(func(ch *amqp.Channel) error {
+		return ch.ExchangeDeclare(
+			name,  // name
+			kind,  // type
+			true,  // durable
+			false, // auto-deleted
+			false, // internal
+			false, // no-wait
+			nil,   // arguments
+		)
+	})
+}
+
+// DeclareTopicExchanges declares a durable topic exchange for each name.
+func (r *RabbitMQ) DeclareTopicExchanges(names ...string) error {
+	for _, name := range names {
+		if err := r.DeclareExchange(name, "topic"); err != nil {
+			return fmt.Errorf("failed to declare exchange %s: %w", name, err)
+		}
+	}
+	return nil
 }
 
 func (r *RabbitMQ) BindQueue(queue, exchange, routingKey string) error {
-	return r.channel.QueueBind(
-		queue,      // queue name
-		routingKey, // routing key
-		exchange,   // exchange
-		false,      // no-wait
-		nil,        // arguments
-	)
+	return r.declare(func(ch *amqp.Channel) error {
+		return ch.QueueBind(
+			queue,      // queue name
+			routingKey, // routing key
+			exchange,   // exchange
+			false,      // no-wait
+			nil,        // arguments
+		)
+	})
 }
 
 func (r *RabbitMQ) Close() error {
-	if r.channel != nil {
-		r.channel.Close()
+	r.mu.Lock()
+	if r.closing {
+		r.mu.Unlock()
+		return nil
+	}
+	r.closing = true
+	close(r.done)
+	conn, ch := r.conn, r.channel
+	r.mu.Unlock()
+
+	if ch != nil {
+		ch.Close()
 	}
-	if r.conn != nil {
-		return r.conn.Close()
+	if conn != nil {
+		return conn.Close()
 	}
 	return nil
-}
\ No newline at end of file
+}
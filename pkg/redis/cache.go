@@ -0,0 +1,394 @@
+package redis
+
+import (
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"math/rand"
+	"sync/atomic"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/redis/go-redis/v9"
+)
+
+// ErrCacheMiss is returned by Cache.Get when nothing is cached for a key.
+var ErrCacheMiss = errors.New("cache miss")
+
+// notFoundMarker is stored for keys whose value is known not to exist.
+const notFoundMarker = "\x00not_found"
+
+// flushBatch is how many keys Flush scans and deletes at a time.
+const flushBatch = 500
+
+// lockPollInterval is how often a cache waiting on another instance's load
+// checks whether the value has been cached.
+const lockPollInterval = 50 * time.Millisecond
+
+// unlockScript releases a load lock only if it is still held by the caller.
+var unlockScript = redis.NewScript(`
+if redis.call("GET", KEYS[1]) == ARGV[1] then
+	return redis.call("DEL", KEYS[1])
+end
+return 0
+`)
+
+// Codec encodes the values a Cache stores in Redis.
+type Codec interface {
+	Marshal(v interface{}) ([]byte, error)
+	Unmarshal(data []byte, v interface{}) error
+}
+
+// JSONCodec encodes values as JSON.
+type JSONCodec struct{}
+
+func (JSONCodec) Marshal(v interface{}) ([]byte, error) {
+	return json.Marshal(v)
+}
+
+func (JSONCodec) Unmarshal(data []byte, v interface{}) error {
+	return json.Unmarshal(data, v)
+}
+
+type CacheConfig struct {
+	// Prefix namespaces the cache's keys as "<prefix>:<key>".
+	Prefix string
+	// TTL is how long values are fresh. Each expiry is moved by a random
+	// amount of up to Jitter times TTL either way, so that entries cached
+	// together do not all expire together. Jitter defaults to 0.1.
+	TTL    time.Duration
+	Jitter float64
+	// HardTTL is how long values are kept. Between TTL and HardTTL a value
+	// is stale: GetOrLoad still returns it and refreshes it in the
+	// background. Defaults to TTL, which never serves stale values.
+	HardTTL time.Duration
+	// NegativeTTL is how long a value that does not exist is remembered;
+	// zero disables negative caching.
+	NegativeTTL time.Duration
+	// LockTTL bounds how long one instance may hold the lock to load a key.
+	// Other instances wait up to LockWait for its result before loading the
+	// value themselves. They default to 10s and 2s.
+	LockTTL  time.Duration
+	LockWait time.Duration
+	// Codec defaults to JSONCodec.
+	Codec Codec
+}
+
+// CacheStats counts how a cache's GetOrLoad lookups were served.
+type CacheStats struct {
+	Hits       uint64 `json:"hits"`
+	Stale      uint64 `json:"stale"` // stale values served while refreshing
+	Misses     uint64 `json:"misses"`
+	Loads      uint64 `json:"loads"` // values loaded from the source, after coalescing
+	LoadErrors uint64 `json:"load_errors"`
+}
+
+// Cache stores values of type T in Redis. A nil value means the value does
+// not exist and is cached as such for NegativeTTL, so that lookups of
+// missing keys do not reach the database every time.
+//
+// GetOrLoad protects the source from stampedes when a popular key expires:
+// concurrent misses in the process share one load, a Redis lock lets one
+// instance load the key while the others wait for its result, and stale
+// values keep being served while one caller refreshes them.
+type Cache[T any] struct {
+	client  *RedisClient
+	cfg     CacheConfig
+	flights flightGroup[T]
+
+	hits       atomic.Uint64
+	stale      atomic.Uint64
+	misses     atomic.Uint64
+	loads      atomic.Uint64
+	loadErrors atomic.Uint64
+}
+
+// cacheEntry is a cached value and the time it stops being fresh.
+type cacheEntry[T any] struct {
+	value      *T
+	freshUntil time.Time
+}
+
+func NewCache[T any](client *RedisClient, cfg CacheConfig) *Cache[T] {
+	if cfg.Jitter <= 0 {
+		cfg.Jitter = 0.1
+	}
+	if cfg.HardTTL < cfg.TTL {
+		cfg.HardTTL = cfg.TTL
+	}
+	if cfg.LockTTL <= 0 {
+		cfg.LockTTL = 10 * time.Second
+	}
+	if cfg.LockWait <= 0 {
+		cfg.LockWait = 2 * time.Second
+	}
+	if cfg.Codec == nil {
+		cfg.Codec = JSONCodec{}
+	}
+
+	return &Cache[T]{
+		client: client,
+		cfg:    cfg,
+	}
+}
+
+func (c *Cache[T]) key(key string) string {
+	return fmt.Sprintf("%s:%s", c.cfg.Prefix, key)
+}
+
+// lockKey is kept outside the cache's prefix so that Flush leaves locks of
+// loads in progress alone.
+func (c *Cache[T]) lockKey(key string) string {
+	return fmt.Sprintf("lock:%s:%s", c.cfg.Prefix, key)
+}
+
+// jitter moves ttl by a random amount of up to Jitter times ttl.
+func (c *Cache[T]) jitter(ttl time.Duration) time.Duration {
+	spread := int64(float64(ttl) * c.cfg.Jitter)
+	if spread <= 0 {
+		return ttl
+	}
+	return ttl + time.Duration(rand.Int63n(2*spread+1)-spread)
+}
+
+// Stats returns the cache's counters since the process started.
+func (c *Cache[T]) Stats() CacheStats {
+	return CacheStats{
+		Hits:       c.hits.Load(),
+		Stale:      c.stale.Load(),
+		Misses:     c.misses.Load(),
+		Loads:      c.loads.Load(),
+		LoadErrors: c.loadErrors.Load(),
+	}
+}
+
+// get reads the entry for key. Entries are stored as the fresh-until time
+// in Unix milliseconds followed by the encoded value.
+func (c *Cache[T]) get(ctx context.Context, key string) (*cacheEntry[T], error) {
+	data, err := c.client.client.Get(ctx, c.key(key)).Bytes()
+	if err != nil {
+		if errors.Is(err, redis.Nil) {
+			return nil, ErrCacheMiss
+		}
+		return nil, err
+	}
+	if len(data) < 8 {
+		return nil, fmt.Errorf("invalid cached %s", c.key(key))
+	}
+
+	entry := &cacheEntry[T]{
+		freshUntil: time.UnixMilli(int64(binary.BigEndian.Uint64(data[:8]))),
+	}
+	data = data[8:]
+	if string(data) == notFoundMarker {
+		return entry, nil
+	}
+
+	var value T
+	if err := c.cfg.Codec.Unmarshal(data, &value); err != nil {
+		return nil, fmt.Errorf("failed to decode cached %s: %w", c.key(key), err)
+	}
+	entry.value = &value
+	return entry, nil
+}
+
+// Get returns the cached value for key, fresh or stale, nil if the value is
+// cached as not existing, or ErrCacheMiss if nothing is cached.
+func (c *Cache[T]) Get(ctx context.Context, key string) (*T, error) {
+	entry, err := c.get(ctx, key)
+	if err != nil {
+		return nil, err
+	}
+	return entry.value, nil
+}
+
+// Set caches value for key. A nil value is cached as not existing when
+// negative caching is enabled, and otherwise drops the key.
+func (c *Cache[T]) Set(ctx context.Context, key string, value *T) error {
+	var data []byte
+	var fresh, expiry time.Duration
+	if value == nil {
+		if c.cfg.NegativeTTL <= 0 {
+			return c.Delete(ctx, key)
+		}
+		data = []byte(notFoundMarker)
+		fresh = c.jitter(c.cfg.NegativeTTL)
+		expiry = fresh
+	} else {
+		encoded, err := c.cfg.Codec.Marshal(value)
+		if err != nil {
+			return fmt.Errorf("failed to encode %s for cache: %w", c.key(key), err)
+		}
+		data = encoded
+		fresh = c.jitter(c.cfg.TTL)
+		expiry = fresh + c.cfg.HardTTL - c.cfg.TTL
+	}
+
+	buf := make([]byte, 8, 8+len(data))
+	binary.BigEndian.PutUint64(buf, uint64(time.Now().Add(fresh).UnixMilli()))
+	return c.client.client.Set(ctx, c.key(key), append(buf, data...), expiry).Err()
+}
+
+// GetOrLoad returns the cached value for key, calling load and caching its
+// result on a miss. A stale value is returned as is while it is refreshed
+// in the background. Load errors are not cached. Redis failures are logged
+// and fall back to load, so that the cache never takes the caller down.
+func (c *Cache[T]) GetOrLoad(ctx context.Context, key string, load func() (*T, error)) (*T, error) {
+	entry, err := c.get(ctx, key)
+	if err == nil {
+		if time.Now().Before(entry.freshUntil) {
+			c.hits.Add(1)
+		} else {
+			c.stale.Add(1)
+			c.refresh(key, load)
+		}
+		return entry.value, nil
+	}
+	if !errors.Is(err, ErrCacheMiss) {
+		log.Printf("Failed to read %s from cache: %v", c.key(key), err)
+	}
+
+	c.misses.Add(1)
+	return c.flights.do(key, func() (*T, error) {
+		return c.load(ctx, key, load)
+	})
+}
+
+// load loads and caches a missing value. If another instance holds the
+// lock for key, its result is awaited for up to LockWait instead.
+func (c *Cache[T]) load(ctx context.Context, key string, load func() (*T, error)) (*T, error) {
+	token, locked, err := c.lock(ctx, key)
+	if err != nil {
+		log.Printf("Failed to lock %s: %v", c.key(key), err)
+	}
+	if locked {
+		defer c.unlock(ctx, key, token)
+
+		// The previous holder may have cached the value just now
+		if entry, err := c.get(ctx, key); err == nil && time.Now().Before(entry.freshUntil) {
+			return entry.value, nil
+		}
+	} else if err == nil {
+		if entry, ok := c.wait(ctx, key); ok {
+			return entry.value, nil
+		}
+	}
+
+	return c.loadAndSet(ctx, key, load)
+}
+
+// refresh reloads a stale value in the background, unless a refresh of key
+// is already running in this or another instance.
+func (c *Cache[T]) refresh(key string, load func() (*T, error)) {
+	c.flights.tryGo(key, func() (*T, error) {
+		ctx := context.Background()
+		token, locked, err := c.lock(ctx, key)
+		if err != nil {
+			log.Printf("Failed to lock %s: %v", c.key(key), err)
+			return nil, err
+		}
+		if !locked {
+			return nil, nil
+		}
+		defer c.unlock(ctx, key, token)
+
+		value, err := c.loadAndSet(ctx, key, load)
+		if err != nil {
+			log.Printf("Failed to refresh cached %s: %v", c.key(key), err)
+		}
+		return value, err
+	})
+}
+
+func (c *Cache[T]) loadAndSet(ctx context.Context, key string, load func() (*T, error)) (*T, error) {
+	c.loads.Add(1)
+	value, err := load()
+	if err != nil {
+		c.loadErrors.Add(1)
+		return nil, err
+	}
+	if err := c.Set(ctx, key, value); err != nil {
+		log.Printf("Failed to cache %s: %v", c.key(key), err)
+	}
+	return value, nil
+}
+
+// lock takes the lock to load key, returning the token to release it with.
+func (c *Cache[T]) lock(ctx context.Context, key string) (string, bool, error) {
+	token := uuid.NewString()
+	locked, err := c.client.client.SetNX(ctx, c.lockKey(key), token, c.cfg.LockTTL).Result()
+	if err != nil {
+		return "", false, err
+	}
+	return token, locked, nil
+}
+
+func (c *Cache[T]) unlock(ctx context.Context, key, token string) {
+	if err := unlockScript.Run(ctx, c.client.client, []string{c.lockKey(key)}, token).Err(); err != nil {
+		log.Printf("Failed to unlock %s: %v", c.key(key), err)
+	}
+}
+
+// wait polls for the value another instance is loading until it is cached
+// or LockWait has passed.
+func (c *Cache[T]) wait(ctx context.Context, key string) (*cacheEntry[T], bool) {
+	timer := time.NewTimer(c.cfg.LockWait)
+	defer timer.Stop()
+	ticker := time.NewTicker(lockPollInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil, false
+		case <-timer.C:
+			return nil, false
+		case <-ticker.C:
+		}
+
+		entry, err := c.get(ctx, key)
+		if err == nil {
+			return entry, true
+		}
+		if !errors.Is(err, ErrCacheMiss) {
+			return nil, false
+		}
+	}
+}
+
+func (c *Cache[T]) Delete(ctx context.Context, keys ...string) error {
+	if len(keys) == 0 {
+		return nil
+	}
+
+	prefixed := make([]string, len(keys))
+	for i, key := range keys {
+		prefixed[i] = c.key(key)
+	}
+	return c.client.client.Del(ctx, prefixed...).Err()
+}
+
+// Flush drops every key of the cache.
+func (c *Cache[T]) Flush(ctx context.Context) error {
+	iter := c.client.client.Scan(ctx, 0, c.key("*"), flushBatch).Iterator()
+	keys := make([]string, 0, flushBatch)
+	for iter.Next(ctx) {
+		keys = append(keys, iter.Val())
+		if len(keys) == flushBatch {
+			if err := c.client.client.Unlink(ctx, keys...).Err(); err != nil {
+				return err
+			}
+			keys = keys[:0]
+		}
+	}
+	if err := iter.Err(); err != nil {
+		return err
+	}
+	if len(keys) > 0 {
+		return c.client.client.Unlink(ctx, keys...).Err()
+	}
+	return nil
+}
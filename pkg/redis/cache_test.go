@@ -0,0 +1,44 @@
+package redis
+
+import (
+	"testing"
+	"time"
+)
+
+func TestCacheJitter(t *testing.T) {
+	tests := []struct {
+		name    string
+		jitter  float64
+		ttl     time.Duration
+		wantMin time.Duration
+		wantMax time.Duration
+	}{
+		{"default jitter", 0, 10 * time.Minute, 9 * time.Minute, 11 * time.Minute},
+		{"custom jitter", 0.5, 10 * time.Minute, 5 * time.Minute, 15 * time.Minute},
+		{"too short to jitter", 0.1, 5 * time.Nanosecond, 5 * time.Nanosecond, 5 * time.Nanosecond},
+		{"no ttl", 0.1, 0, 0, 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := NewCache[string](nil, CacheConfig{Prefix: "test", TTL: tt.ttl, Jitter: tt.jitter})
+			for i := 0; i < 1000; i++ {
+				if got := c.jitter(tt.ttl); got < tt.wantMin || got > tt.wantMax {
+					t.Fatalf("jitter(%s) = %s, want between %s and %s", tt.ttl, got, tt.wantMin, tt.wantMax)
+				}
+			}
+		})
+	}
+}
+
+func TestCacheKeys(t *testing.T) {
+	c := NewCache[string](nil, CacheConfig{Prefix: "product"})
+
+	if got, want := c.key("42"), "product:42"; got != want {
+		t.Errorf("key() = %q, want %q", got, want)
+	}
+	// Locks stay out of the prefix Flush scans
+	if got, want := c.lockKey("42"), "lock:product:42"; got != want {
+		t.Errorf("lockKey() = %q, want %q", got, want)
+	}
+}
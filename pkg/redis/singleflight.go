@@ -0,0 +1,74 @@
+package redis
+
+import "sync"
+
+// flightCall is a load in progress or completed.
+type flightCall[T any] struct {
+	wg  sync.WaitGroup
+	val *T
+	err error
+}
+
+// flightGroup coalesces concurrent loads of the same key within the process,
+// so that only one of them runs and the others share its result.
+type flightGroup[T any] struct {
+	mu    sync.Mutex
+	calls map[string]*flightCall[T]
+}
+
+// do runs fn for key unless a call for key is already running, in which case
+// it waits for that call and returns its result.
+func (g *flightGroup[T]) do(key string, fn func() (*T, error)) (*T, error) {
+	g.mu.Lock()
+	if g.calls == nil {
+		g.calls = make(map[string]*flightCall[T])
+	}
+	if call, ok := g.calls[key]; ok {
+		g.mu.Unlock()
+		call.wg.Wait()
+		return call.val, call.err
+	}
+
+	call := g.start(key)
+	g.mu.Unlock()
+
+	g.run(key, call, fn)
+	return call.val, call.err
+}
+
+// tryGo runs fn for key in the background unless a call for key is already
+// running.
+func (g *flightGroup[T]) tryGo(key string, fn func() (*T, error)) {
+	g.mu.Lock()
+	if g.calls == nil {
+		g.calls = make(map[string]*flightCall[T])
+	}
+	if _, ok := g.calls[key]; ok {
+		g.mu.Unlock()
+		return
+	}
+
+	call := g.start(key)
+	g.mu.Unlock()
+
+	go g.run(key, call, fn)
+}
+
+// start registers a new call for key; g.mu must be held.
+func (g *flightGroup[T]) start(key string) *flightCall[T] {
+	call := &flightCall[T]{}
+	call.wg.Add(1)
+	g.calls[key] = call
+	return call
+}
+
+func (g *flightGroup[T]) run(key string, call *flightCall[T], fn func() (*T, error)) {
+	defer func() {
+		g.mu.Lock()
+		delete(g.calls, key)
+		g.mu.Unlock()
+		call.wg.Done()
+	}()
+
+	call.val, call.err = fn()
+}
@@ -0,0 +1,115 @@
+package redis
+
+import (
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestFlightGroupDo(t *testing.T) {
+	tests := []struct {
+		name      string
+		keys      []string
+		err       error
+		wantLoads int32
+	}{
+		{"same key shares one load", []string{"a", "a", "a", "a"}, nil, 1},
+		{"different keys load separately", []string{"a", "b", "c"}, nil, 3},
+		{"errors are shared", []string{"a", "a"}, errors.New("database unavailable"), 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var g flightGroup[string]
+			var loads atomic.Int32
+			release := make(chan struct{})
+			started := make(chan struct{}, len(tt.keys))
+
+			var wg sync.WaitGroup
+			errs := make([]error, len(tt.keys))
+			vals := make([]*string, len(tt.keys))
+			for i, key := range tt.keys {
+				wg.Add(1)
+				go func(i int, key string) {
+					defer wg.Done()
+					vals[i], errs[i] = g.do(key, func() (*string, error) {
+						loads.Add(1)
+						started <- struct{}{}
+						<-release
+						value := "value of " + key
+						return &value, tt.err
+					})
+				}(i, key)
+			}
+
+			// Let every caller reach do before the loads finish
+			<-started
+			waitForCalls(t, &g, tt.keys)
+			time.Sleep(10 * time.Millisecond)
+			close(release)
+			wg.Wait()
+
+			if got := loads.Load(); got != tt.wantLoads {
+				t.Errorf("loads = %d, want %d", got, tt.wantLoads)
+			}
+			for i, key := range tt.keys {
+				if errs[i] != tt.err {
+					t.Errorf("do(%q) error = %v, want %v", key, errs[i], tt.err)
+				}
+				if vals[i] == nil || *vals[i] != "value of "+key {
+					t.Errorf("do(%q) = %v, want value of %s", key, vals[i], key)
+				}
+			}
+			if len(g.calls) != 0 {
+				t.Errorf("calls left after the loads finished = %d, want 0", len(g.calls))
+			}
+		})
+	}
+}
+
+func TestFlightGroupTryGo(t *testing.T) {
+	var g flightGroup[string]
+	var loads atomic.Int32
+	release := make(chan struct{})
+	done := make(chan struct{})
+
+	for i := 0; i < 3; i++ {
+		g.tryGo("a", func() (*string, error) {
+			loads.Add(1)
+			<-release
+			close(done)
+			return nil, nil
+		})
+	}
+	close(release)
+	<-done
+
+	if got := loads.Load(); got != 1 {
+		t.Errorf("background loads = %d, want 1", got)
+	}
+}
+
+// waitForCalls waits until a call is registered for every key.
+func waitForCalls(t *testing.T, g *flightGroup[string], keys []string) {
+	t.Helper()
+	deadline := time.Now().Add(time.Second)
+	for {
+		g.mu.Lock()
+		registered := true
+		for _, key := range keys {
+			if _, ok := g.calls[key]; !ok {
+				registered = false
+			}
+		}
+		g.mu.Unlock()
+		if registered {
+			return
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("loads did not start")
+		}
+		time.Sleep(time.Millisecond)
+	}
+}